@@ -0,0 +1,176 @@
+package archivefs
+
+import (
+	"io"
+	"os"
+	"path"
+	"syscall"
+)
+
+// file 压缩包中打开的文件，未压缩的条目直接随机读取，
+// 其余条目顺序解压，向后跳转时重新打开
+type file struct {
+	fs     *Fs
+	node   *node
+	path   string
+	offset int64
+	// 顺序读取的解压流及其当前位置
+	stream io.ReadCloser
+	pos    int64
+	// 目录读取位置
+	dirOffset int
+}
+
+func (f *file) pathErr(op string, err error) error {
+	return &os.PathError{Op: op, Path: f.path, Err: err}
+}
+
+func (f *file) Name() string {
+	return f.path
+}
+
+func (f *file) Stat() (os.FileInfo, error) {
+	return f.node, nil
+}
+
+func (f *file) Close() error {
+	if f.stream != nil {
+		err := f.stream.Close()
+		f.stream = nil
+		return err
+	}
+	return nil
+}
+
+// seekStream 将解压流定位到 offset
+func (f *file) seekStream(offset int64) error {
+	if f.stream != nil && f.pos > offset {
+		_ = f.stream.Close()
+		f.stream = nil
+	}
+	if f.stream == nil {
+		stream, err := f.node.open()
+		if err != nil {
+			return err
+		}
+		f.stream, f.pos = stream, 0
+	}
+	n, err := io.CopyN(io.Discard, f.stream, offset-f.pos)
+	f.pos += n
+	return err
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.node.dir {
+		return 0, f.pathErr("read", syscall.EISDIR)
+	}
+	if f.offset >= f.node.size {
+		return 0, io.EOF
+	}
+	if f.node.section != nil {
+		n, err := f.node.section.ReadAt(p, f.offset)
+		f.offset += int64(n)
+		if err == io.EOF && n > 0 {
+			err = nil
+		}
+		return n, err
+	}
+	if err := f.seekStream(f.offset); err != nil {
+		return 0, f.pathErr("read", err)
+	}
+	n, err := f.stream.Read(p)
+	f.pos += int64(n)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if f.node.dir {
+		return 0, f.pathErr("read", syscall.EISDIR)
+	}
+	if f.node.section != nil {
+		return f.node.section.ReadAt(p, off)
+	}
+	if off >= f.node.size {
+		return 0, io.EOF
+	}
+	if err := f.seekStream(off); err != nil {
+		return 0, f.pathErr("read", err)
+	}
+	n, err := io.ReadFull(f.stream, p)
+	f.pos += int64(n)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.node.dir {
+		if offset != 0 || whence != io.SeekStart {
+			return 0, f.pathErr("seek", os.ErrInvalid)
+		}
+		f.dirOffset = 0
+		return 0, nil
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.node.size
+	}
+	if offset < 0 {
+		return 0, f.pathErr("seek", os.ErrInvalid)
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *file) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.node.dir {
+		return nil, f.pathErr("readdir", syscall.ENOTDIR)
+	}
+	rest := f.node.children[f.dirOffset:]
+	if count > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > 0 {
+		rest = rest[:min(count, len(rest))]
+	}
+	f.dirOffset += len(rest)
+	dir := path.Clean("/" + f.path)
+	infos := make([]os.FileInfo, len(rest))
+	for i, name := range rest {
+		infos[i] = f.fs.nodes[path.Join(dir, name)]
+	}
+	return infos, nil
+}
+
+func (f *file) Readdirnames(count int) ([]string, error) {
+	infos, err := f.Readdir(count)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return names, err
+}
+
+func (f *file) Sync() error {
+	return nil
+}
+
+func (f *file) Write([]byte) (int, error) {
+	return 0, f.pathErr("write", syscall.EPERM)
+}
+
+func (f *file) WriteAt([]byte, int64) (int, error) {
+	return 0, f.pathErr("write", syscall.EPERM)
+}
+
+func (f *file) WriteString(string) (int, error) {
+	return 0, f.pathErr("write", syscall.EPERM)
+}
+
+func (f *file) Truncate(int64) error {
+	return f.pathErr("truncate", syscall.EPERM)
+}
@@ -0,0 +1,299 @@
+// Package archivefs 将 zip、tar、tar.gz 压缩包以只读文件系统的形式挂载，
+// 打开时只建立目录索引，文件内容在读取时才解压。
+package archivefs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// node 压缩包中的文件或目录
+type node struct {
+	name     string
+	dir      bool
+	size     int64
+	mode     os.FileMode
+	modTime  time.Time
+	children []string
+	// 可随机读取的内容（未压缩的条目），为 nil 时只能通过 open 顺序读取
+	section *io.SectionReader
+	open    func() (io.ReadCloser, error)
+}
+
+func (n *node) Name() string       { return n.name }
+func (n *node) Size() int64        { return n.size }
+func (n *node) ModTime() time.Time { return n.modTime }
+func (n *node) IsDir() bool        { return n.dir }
+func (n *node) Sys() any           { return nil }
+func (n *node) Mode() os.FileMode {
+	if n.dir {
+		return os.ModeDir | n.mode.Perm()
+	}
+	return n.mode.Perm()
+}
+
+// Fs 只读的压缩包文件系统
+type Fs struct {
+	file  *os.File
+	nodes map[string]*node
+}
+
+// Open 打开压缩包并建立索引，格式由扩展名决定：.zip、.tar、.tar.gz 或 .tgz
+func Open(name string) (*Fs, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	f := &Fs{file: file, nodes: make(map[string]*node)}
+	f.nodes["/"] = &node{name: "/", dir: true, mode: 0o555, modTime: stat.ModTime()}
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		err = f.indexZip(stat.Size())
+	case strings.HasSuffix(lower, ".tar"):
+		err = f.indexTar(stat.Size(), false)
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		err = f.indexTar(stat.Size(), true)
+	default:
+		err = fmt.Errorf("unsupported archive format: %s", path.Base(name))
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	for _, n := range f.nodes {
+		slices.Sort(n.children)
+	}
+	return f, nil
+}
+
+// Close 关闭压缩包文件
+func (f *Fs) Close() error {
+	return f.file.Close()
+}
+
+// entryPath 规范化条目路径，跳出压缩包根目录的条目返回空字符串
+func entryPath(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return ""
+		}
+	}
+	name = path.Clean("/" + name)
+	if name == "/" {
+		return ""
+	}
+	return name
+}
+
+// add 添加条目并补全缺失的上级目录，已存在的条目保持不变
+func (f *Fs) add(p string, n *node) {
+	if _, ok := f.nodes[p]; ok {
+		return
+	}
+	n.name = path.Base(p)
+	f.nodes[p] = n
+	parent := path.Dir(p)
+	if _, ok := f.nodes[parent]; !ok {
+		f.add(parent, &node{dir: true, mode: 0o555, modTime: n.modTime})
+	}
+	f.nodes[parent].children = append(f.nodes[parent].children, n.name)
+}
+
+func (f *Fs) indexZip(size int64) error {
+	reader, err := zip.NewReader(f.file, size)
+	if err != nil {
+		return err
+	}
+	for _, entry := range reader.File {
+		p := entryPath(entry.Name)
+		if p == "" {
+			continue
+		}
+		info := entry.FileInfo()
+		n := &node{dir: info.IsDir(), mode: info.Mode(), modTime: entry.Modified}
+		switch {
+		case info.IsDir():
+		case info.Mode().IsRegular():
+			n.size = int64(entry.UncompressedSize64)
+			n.open = entry.Open
+			if entry.Method == zip.Store {
+				if offset, err := entry.DataOffset(); err == nil {
+					n.section = io.NewSectionReader(f.file, offset, n.size)
+				}
+			}
+		default:
+			// 跳过符号链接等特殊文件
+			continue
+		}
+		f.add(p, n)
+	}
+	return nil
+}
+
+// countingReader 记录已读取的字节数，用于定位 tar 条目的数据偏移
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (f *Fs) indexTar(size int64, compressed bool) error {
+	var stream io.Reader = io.NewSectionReader(f.file, 0, size)
+	if compressed {
+		gz, err := gzip.NewReader(stream)
+		if err != nil {
+			return err
+		}
+		stream = gz
+	}
+	counter := &countingReader{r: stream}
+	reader := tar.NewReader(counter)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		p := entryPath(header.Name)
+		if p == "" {
+			continue
+		}
+		n := &node{mode: header.FileInfo().Mode(), modTime: header.ModTime}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			n.dir = true
+		case tar.TypeReg:
+			// Next 返回时读取位置恰好位于条目数据的开头
+			n.size = header.Size
+			n.section, n.open = f.tarSource(counter.n, header.Size, size, compressed)
+		case tar.TypeLink:
+			target, ok := f.nodes[entryPath(header.Linkname)]
+			if !ok || target.dir {
+				continue
+			}
+			link := *target
+			link.children = nil
+			n = &link
+		default:
+			continue
+		}
+		f.add(p, n)
+	}
+}
+
+// tarSource 未压缩的 tar 可以直接随机读取，tar.gz 需要从头解压到数据偏移处
+func (f *Fs) tarSource(offset, length, size int64, compressed bool) (*io.SectionReader, func() (io.ReadCloser, error)) {
+	if !compressed {
+		section := io.NewSectionReader(f.file, offset, length)
+		return section, func() (io.ReadCloser, error) {
+			return io.NopCloser(io.NewSectionReader(f.file, offset, length)), nil
+		}
+	}
+	return nil, func() (io.ReadCloser, error) {
+		gz, err := gzip.NewReader(io.NewSectionReader(f.file, 0, size))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.CopyN(io.Discard, gz, offset); err != nil {
+			_ = gz.Close()
+			return nil, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(gz, length), gz}, nil
+	}
+}
+
+func (f *Fs) lookup(op, name string) (*node, error) {
+	n, ok := f.nodes[path.Clean("/"+strings.ReplaceAll(name, "\\", "/"))]
+	if !ok {
+		return nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	return n, nil
+}
+
+func (f *Fs) Stat(name string) (os.FileInfo, error) {
+	return f.lookup("stat", name)
+}
+
+func (f *Fs) Open(name string) (afero.File, error) {
+	n, err := f.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return &file{fs: f, node: n, path: name}, nil
+}
+
+func (f *Fs) OpenFile(name string, flag int, _ os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EPERM}
+	}
+	return f.Open(name)
+}
+
+func (f *Fs) Name() string {
+	return "ArchiveFs"
+}
+
+func (f *Fs) Create(name string) (afero.File, error) {
+	return nil, &os.PathError{Op: "create", Path: name, Err: syscall.EPERM}
+}
+
+func (f *Fs) Mkdir(name string, _ os.FileMode) error {
+	return &os.PathError{Op: "mkdir", Path: name, Err: syscall.EPERM}
+}
+
+func (f *Fs) MkdirAll(name string, _ os.FileMode) error {
+	return &os.PathError{Op: "mkdir", Path: name, Err: syscall.EPERM}
+}
+
+func (f *Fs) Remove(name string) error {
+	return &os.PathError{Op: "remove", Path: name, Err: syscall.EPERM}
+}
+
+func (f *Fs) RemoveAll(name string) error {
+	return &os.PathError{Op: "remove", Path: name, Err: syscall.EPERM}
+}
+
+func (f *Fs) Rename(oldname, newname string) error {
+	return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.EPERM}
+}
+
+func (f *Fs) Chmod(name string, _ os.FileMode) error {
+	return &os.PathError{Op: "chmod", Path: name, Err: syscall.EPERM}
+}
+
+func (f *Fs) Chown(name string, _, _ int) error {
+	return &os.PathError{Op: "chown", Path: name, Err: syscall.EPERM}
+}
+
+func (f *Fs) Chtimes(name string, _, _ time.Time) error {
+	return &os.PathError{Op: "chtimes", Path: name, Err: syscall.EPERM}
+}
@@ -0,0 +1,112 @@
+package archivefs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+var testFiles = map[string]string{
+	"docs/readme.txt":   "hello archive",
+	"docs/sub/deep.txt": "0123456789",
+	"../escape.txt":     "ignored",
+	"top.txt":           "top",
+}
+
+func writeZip(t *testing.T, name string) {
+	out, err := os.Create(name)
+	assert.NoError(t, err)
+	w := zip.NewWriter(out)
+	for entry, content := range testFiles {
+		method := zip.Deflate
+		if entry == "top.txt" {
+			method = zip.Store
+		}
+		f, err := w.CreateHeader(&zip.FileHeader{Name: entry, Method: method, Modified: time.Now()})
+		assert.NoError(t, err)
+		_, _ = io.WriteString(f, content)
+	}
+	assert.NoError(t, w.Close())
+	assert.NoError(t, out.Close())
+}
+
+func writeTarGz(t *testing.T, name string) {
+	out, err := os.Create(name)
+	assert.NoError(t, err)
+	gz := gzip.NewWriter(out)
+	w := tar.NewWriter(gz)
+	for entry, content := range testFiles {
+		assert.NoError(t, w.WriteHeader(&tar.Header{Name: entry, Mode: 0o644, Size: int64(len(content)), ModTime: time.Now()}))
+		_, _ = io.WriteString(w, content)
+	}
+	assert.NoError(t, w.WriteHeader(&tar.Header{Name: "link.txt", Typeflag: tar.TypeLink, Linkname: "top.txt"}))
+	assert.NoError(t, w.Close())
+	assert.NoError(t, gz.Close())
+	assert.NoError(t, out.Close())
+}
+
+func checkArchive(t *testing.T, fs *Fs) {
+	names, err := afero.ReadDir(fs, "/")
+	assert.NoError(t, err)
+	var list []string
+	for _, info := range names {
+		list = append(list, info.Name())
+	}
+	assert.Contains(t, list, "docs")
+	assert.Contains(t, list, "top.txt")
+	assert.NotContains(t, list, "escape.txt", "跳出根目录的条目应被忽略")
+
+	stat, err := fs.Stat("/docs/sub")
+	assert.NoError(t, err)
+	assert.True(t, stat.IsDir(), "缺失的上级目录应自动补全")
+
+	data, err := afero.ReadFile(fs, "/docs/readme.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello archive", string(data))
+
+	f, err := fs.Open("/docs/sub/deep.txt")
+	assert.NoError(t, err)
+	buf := make([]byte, 3)
+	n, err := f.ReadAt(buf, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "789", string(buf[:n]))
+	_, _ = f.Seek(2, io.SeekStart)
+	rest, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "23456789", string(rest), "向后跳转后应能继续读取")
+	assert.NoError(t, f.Close())
+
+	_, err = fs.OpenFile("/top.txt", os.O_WRONLY, 0)
+	assert.ErrorIs(t, err, os.ErrPermission)
+	assert.ErrorIs(t, fs.Remove("/top.txt"), os.ErrPermission)
+}
+
+func TestFs_Zip(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "data.zip")
+	writeZip(t, name)
+	fs, err := Open(name)
+	assert.NoError(t, err)
+	defer fs.Close()
+	checkArchive(t, fs)
+	assert.NotNil(t, fs.nodes["/top.txt"].section, "未压缩的条目应支持随机读取")
+}
+
+func TestFs_TarGz(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "data.tar.gz")
+	writeTarGz(t, name)
+	fs, err := Open(name)
+	assert.NoError(t, err)
+	defer fs.Close()
+	checkArchive(t, fs)
+	data, err := afero.ReadFile(fs, "/link.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "top", string(data), "硬链接应指向原文件内容")
+}
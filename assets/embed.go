@@ -3,7 +3,9 @@ package assets
 import (
 	"embed"
 	_ "embed"
-	"text/template"
+	"fmt"
+	"os"
+	"path/filepath"
 
 	"github.com/Masterminds/sprig/v3"
 	"github.com/inhies/go-bytesize"
@@ -21,29 +23,108 @@ var zPreview string
 //go:embed z-login.tmpl.html
 var zLogin string
 
+//go:embed z-player.tmpl.html
+var zPlayer string
+
+//go:embed z-editor.tmpl.html
+var zEditor string
+
+//go:embed z-share.tmpl.html
+var zShare string
+
+//go:embed z-view.tmpl.html
+var zView string
+
 var (
-	ZIndex   *template.Template
-	ZPreview *template.Template
-	ZLogin   *template.Template
+	ZIndex   *Template
+	ZPreview *Template
+	ZLogin   *Template
+	ZPlayer  *Template
+	ZEditor  *Template
+	ZShare   *Template
+	ZView    *Template
 )
 
+// Branding 站点的标识，模板中以 {{ Brand }} 访问，字段为空时使用内置的样式
+type Branding struct {
+	// Title 站点标题
+	Title string
+	// Logo 标志图片的地址
+	Logo string
+	// Footer 页脚文字
+	Footer string
+	// CSS 额外样式文件的地址，在内置样式之后载入
+	CSS string
+}
+
+var branding Branding
+
+// SetBranding 设置站点的标识，应在开始处理请求前调用
+func SetBranding(b Branding) {
+	branding = b
+}
+
+// templates 模板的名称、文件名与内置内容
+var templates = []struct {
+	name   string
+	file   string
+	text   *string
+	target **Template
+}{
+	{"index", "z-index.tmpl.html", &zIndex, &ZIndex},
+	{"preview", "z-preview.tmpl.html", &zPreview, &ZPreview},
+	{"login", "z-login.tmpl.html", &zLogin, &ZLogin},
+	{"player", "z-player.tmpl.html", &zPlayer, &ZPlayer},
+	{"editor", "z-editor.tmpl.html", &zEditor, &ZEditor},
+	{"share", "z-share.tmpl.html", &zShare, &ZShare},
+	{"view", "z-view.tmpl.html", &zView, &ZView},
+}
+
 func init() {
-	var err error
+	if _, err := LoadTemplates(""); err != nil {
+		panic(err)
+	}
+}
+
+// LoadTemplates 解析模板，dir 中与内置模板同名的文件（如 z-login.tmpl.html）替换内置的版本，
+// 其余模板使用内置的版本；dir 为空时全部使用内置模板。返回被替换的文件，
+// 任一模板解析失败时返回错误，已载入的模板保持不变
+func LoadTemplates(dir string) ([]string, error) {
+	if dir != "" {
+		if info, err := os.Stat(dir); err != nil {
+			return nil, err
+		} else if !info.IsDir() {
+			return nil, fmt.Errorf("template dir is not a directory: %s", dir)
+		}
+	}
 	funcMap := sprig.FuncMap()
 	funcMap["Bytesize"] = func(size int64) string {
 		return bytesize.New(float64(size)).String()
 	}
-
-	ZIndex, err = template.New("index").Funcs(funcMap).Parse(zIndex)
-	if err != nil {
-		panic(err)
+	funcMap["Brand"] = func() Branding {
+		return branding
 	}
-	ZPreview, err = template.New("preview").Funcs(funcMap).Parse(zPreview)
-	if err != nil {
-		panic(err)
+	var replaced []string
+	parsed := make([]*Template, len(templates))
+	for i, tmpl := range templates {
+		text := *tmpl.text
+		if dir != "" {
+			data, err := os.ReadFile(filepath.Join(dir, tmpl.file))
+			if err == nil {
+				text = string(data)
+				replaced = append(replaced, tmpl.file)
+			} else if !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+		t, err := newTemplate(tmpl.name, text, funcMap)
+		if err != nil {
+			return nil, fmt.Errorf("parse template %s: %w", tmpl.file, err)
+		}
+		parsed[i] = t
 	}
-	ZLogin, err = template.New("login").Funcs(funcMap).Parse(zLogin)
-	if err != nil {
-		panic(err)
+	for i, tmpl := range templates {
+		*tmpl.target = parsed[i]
 	}
+	return replaced, nil
 }
@@ -0,0 +1,53 @@
+package assets
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadTemplates(t *testing.T) {
+	t.Cleanup(func() { _, _ = LoadTemplates("") })
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "z-login.tmpl.html"), []byte(`<p>custom {{ T "用户名" }}</p>`), 0o644))
+
+	replaced, err := LoadTemplates(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"z-login.tmpl.html"}, replaced)
+	var buf bytes.Buffer
+	assert.NoError(t, ZLogin.Execute(&buf, "en", nil))
+	assert.Equal(t, "<p>custom Username</p>", buf.String(), "替换的模板同样可以翻译")
+	buf.Reset()
+	assert.NoError(t, ZIndex.Execute(&buf, DefaultLanguage, map[string]any{}))
+	assert.Contains(t, buf.String(), "简易文件服务器", "缺少的模板使用内置的版本")
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "z-index.tmpl.html"), []byte(`{{ if }}`), 0o644))
+	_, err = LoadTemplates(dir)
+	assert.Error(t, err)
+	buf.Reset()
+	assert.NoError(t, ZLogin.Execute(&buf, "en", nil))
+	assert.Equal(t, "<p>custom Username</p>", buf.String(), "解析失败时保留已载入的模板")
+
+	_, err = LoadTemplates(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestBranding(t *testing.T) {
+	t.Cleanup(func() { SetBranding(Branding{}) })
+	var buf bytes.Buffer
+	assert.NoError(t, ZIndex.Execute(&buf, DefaultLanguage, map[string]any{}))
+	assert.Contains(t, buf.String(), "<title>WebDAV Server</title>", "未配置时使用内置的标题")
+	assert.NotContains(t, buf.String(), "brand-logo")
+
+	SetBranding(Branding{Title: "A&B Files", Logo: "/branding/logo", Footer: "© A&B", CSS: "/branding/style.css"})
+	buf.Reset()
+	assert.NoError(t, ZIndex.Execute(&buf, DefaultLanguage, map[string]any{}))
+	page := buf.String()
+	assert.Contains(t, page, "<title>A&amp;B Files</title>")
+	assert.Contains(t, page, `<img class="brand-logo" src="/branding/logo" alt="">`)
+	assert.Contains(t, page, "© A&amp;B")
+	assert.Contains(t, page, `<link rel="stylesheet" href="/branding/style.css">`)
+}
@@ -0,0 +1,126 @@
+package assets
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"text/template"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLanguage 模板的原文语言，没有匹配的语言时使用
+const DefaultLanguage = "zh-CN"
+
+// langCookie 保存 ?lang 选择的语言
+const langCookie = "webdav_lang"
+
+// locales 翻译目录，文件名为语言标签，内容为原文到译文的映射
+//
+//go:embed locales/*.json
+var locales embed.FS
+
+var (
+	// catalogs 各语言的翻译目录，原文语言为空目录
+	catalogs = map[string]map[string]string{DefaultLanguage: {}}
+	// Languages 支持的语言，第一个为原文语言
+	Languages = []string{DefaultLanguage}
+	// matcher 在变量初始化时载入翻译目录，早于解析模板的 init
+	matcher = loadCatalogs()
+)
+
+func loadCatalogs() language.Matcher {
+	entries, err := locales.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	tags := []language.Tag{language.MustParse(DefaultLanguage)}
+	for _, entry := range entries {
+		data, err := locales.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		catalog := make(map[string]string)
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			panic(fmt.Errorf("invalid catalog %s: %w", entry.Name(), err))
+		}
+		lang := strings.TrimSuffix(entry.Name(), ".json")
+		catalogs[lang] = catalog
+		Languages = append(Languages, lang)
+		tags = append(tags, language.MustParse(lang))
+	}
+	return language.NewMatcher(tags)
+}
+
+// translate 返回 msg 在语言 lang 中的译文，没有译文时返回原文。args 不为空时按 fmt 格式化
+func translate(lang, msg string, args ...any) string {
+	if text, ok := catalogs[lang][msg]; ok {
+		msg = text
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// Language 返回请求使用的语言：?lang 指定的语言（同时写入 Cookie，之后的页面沿用），
+// 其次为 Cookie 中保存的语言，最后按 Accept-Language 协商
+func Language(w http.ResponseWriter, r *http.Request) string {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		if _, ok := catalogs[lang]; ok {
+			http.SetCookie(w, &http.Cookie{
+				Name:     langCookie,
+				Value:    lang,
+				Path:     "/",
+				MaxAge:   86400 * 365,
+				SameSite: http.SameSiteLaxMode,
+			})
+			return lang
+		}
+	}
+	if cookie, err := r.Cookie(langCookie); err == nil {
+		if _, ok := catalogs[cookie.Value]; ok {
+			return cookie.Value
+		}
+	}
+	tags, _, _ := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLanguage
+	}
+	return Languages[index]
+}
+
+// Template 按语言解析的模板，模板中以 {{ T "原文" }} 输出译文，{{ Lang }} 为当前语言
+type Template struct {
+	langs map[string]*template.Template
+}
+
+func newTemplate(name, text string, funcMap template.FuncMap) (*Template, error) {
+	t := &Template{langs: make(map[string]*template.Template, len(catalogs))}
+	for lang := range catalogs {
+		funcs := template.FuncMap{
+			"T":    func(msg string, args ...any) string { return translate(lang, msg, args...) },
+			"Lang": func() string { return lang },
+		}
+		parsed, err := template.New(name).Funcs(funcMap).Funcs(funcs).Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		t.langs[lang] = parsed
+	}
+	return t, nil
+}
+
+// Execute 以语言 lang 渲染模板，不支持的语言使用原文
+func (t *Template) Execute(w io.Writer, lang string, data any) error {
+	parsed, ok := t.langs[lang]
+	if !ok {
+		parsed = t.langs[DefaultLanguage]
+	}
+	return parsed.Execute(w, data)
+}
@@ -0,0 +1,62 @@
+package assets
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalogs(t *testing.T) {
+	verbs := regexp.MustCompile(`%[sd]`)
+	keys := regexp.MustCompile(`\{\{ T "([^"]*)"`)
+	for _, text := range []string{zIndex, zPreview, zLogin, zPlayer, zEditor, zShare, zView} {
+		for _, match := range keys.FindAllStringSubmatch(text, -1) {
+			for lang, catalog := range catalogs {
+				if lang == DefaultLanguage {
+					continue
+				}
+				translated, ok := catalog[match[1]]
+				assert.True(t, ok, "%s 缺少译文: %s", lang, match[1])
+				assert.Equal(t, verbs.FindAllString(match[1], -1), verbs.FindAllString(translated, -1), "译文的参数与原文一致: %s", match[1])
+				// 译文也用于脚本中的单引号字符串
+				assert.False(t, strings.ContainsAny(translated, `'"\<>`), "译文不能包含引号与尖括号: %s", translated)
+			}
+		}
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Accept-Language", "en-US,en;q=0.9,zh-CN;q=0.8")
+	assert.Equal(t, "en", Language(httptest.NewRecorder(), request))
+	request.Header.Set("Accept-Language", "zh,en;q=0.5")
+	assert.Equal(t, DefaultLanguage, Language(httptest.NewRecorder(), request))
+	request.Header.Set("Accept-Language", "de")
+	assert.Equal(t, DefaultLanguage, Language(httptest.NewRecorder(), request), "没有匹配的语言时使用原文")
+
+	request = httptest.NewRequest(http.MethodGet, "/?lang=en", nil)
+	rec := httptest.NewRecorder()
+	assert.Equal(t, "en", Language(rec, request))
+	cookies := rec.Result().Cookies()
+	assert.Len(t, cookies, 1, "?lang 写入 Cookie")
+
+	request = httptest.NewRequest(http.MethodGet, "/?lang=xx", nil)
+	request.AddCookie(cookies[0])
+	assert.Equal(t, "en", Language(httptest.NewRecorder(), request), "忽略不支持的语言，沿用 Cookie")
+}
+
+func TestTemplateLanguage(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, ZLogin.Execute(&buf, "en", map[string]any{"Error": "用户名或密码错误"}))
+	assert.Contains(t, buf.String(), `<html lang="en">`)
+	assert.Contains(t, buf.String(), "Invalid username or password")
+
+	buf.Reset()
+	assert.NoError(t, ZLogin.Execute(&buf, "xx", map[string]any{}))
+	assert.Contains(t, buf.String(), "用户名", "不支持的语言使用原文")
+}
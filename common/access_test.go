@@ -0,0 +1,34 @@
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOnlyAccess(t *testing.T) {
+	cfg := &Config{
+		Bind: "127.0.0.1:0",
+		Users: map[string]ConfigUser{
+			"admin":   {Password: "admin"},
+			"auditor": {Password: "auditor", Access: "read-only"},
+		},
+		Pools: map[string]ConfigPool{
+			"data": {Path: t.TempDir(), DefaultPerm: "rw"},
+		},
+	}
+	assert.NoError(t, cfg.init())
+	ctx, err := NewContext(context.Background(), cfg)
+	assert.NoError(t, err)
+
+	assert.True(t, ctx.Writable("admin", "/data/a.txt"))
+	assert.False(t, ctx.Writable("auditor", "/data/a.txt"), "只读用户不可写入")
+	assert.False(t, ctx.Writable("guest", "/data@day1/a.txt"), "快照不可写入")
+	assert.Error(t, afero.WriteFile(ctx.LoadUserFS("auditor"), "/data/a.txt", []byte("v1"), 0o644), "文件系统同样只读")
+	assert.NoError(t, afero.WriteFile(ctx.LoadUserFS("admin"), "/data/a.txt", []byte("v1"), 0o644))
+
+	cfg.Users["auditor"] = ConfigUser{Password: "auditor", Access: "readonly"}
+	assert.Error(t, cfg.init(), "非法的 access")
+}
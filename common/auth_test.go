@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/crypto/argon2"
@@ -38,3 +39,17 @@ func TestVerifyPassword(t *testing.T) {
 	// Invalid Argon2id format
 	assert.False(t, verifyPassword("argon2id:invalid", "password"))
 }
+
+func TestAuthCache(t *testing.T) {
+	cache := newAuthCache(time.Minute, []byte("key"))
+	assert.True(t, cache.verify("admin", "password", "password"))
+	assert.Len(t, cache.entries, 1, "验证成功的凭据被缓存")
+	assert.False(t, cache.verify("admin", "password", "wrong"))
+	assert.Len(t, cache.entries, 1, "验证失败的凭据不缓存")
+	assert.False(t, cache.verify("admin", "changed", "password"), "修改密码后缓存失效")
+
+	for k := range cache.entries {
+		cache.entries[k] = time.Now().Add(-time.Second)
+	}
+	assert.True(t, cache.verify("admin", "password", "password"), "过期后重新验证")
+}
@@ -0,0 +1,69 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// authCacheSize 缓存的凭据数量上限，超过时清除过期的条目，仍超过时全部清除
+const authCacheSize = 4096
+
+// authCache 记住验证成功的用户名与密码，TTL 内重复的请求无需再次计算 argon2 等慢哈希。
+// 键为以进程内随机密钥计算的 HMAC，内存中不保存明文密码；验证失败的凭据不缓存
+type authCache struct {
+	ttl time.Duration
+	key []byte
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]time.Time
+}
+
+func newAuthCache(ttl time.Duration, key []byte) *authCache {
+	return &authCache{ttl: ttl, key: key, entries: make(map[[sha256.Size]byte]time.Time)}
+}
+
+// sum 计算凭据的键，包含配置中的密码哈希，修改密码后旧的缓存不再命中
+func (c *authCache) sum(username, hashed, password string) [sha256.Size]byte {
+	mac := hmac.New(sha256.New, c.key)
+	for _, s := range []string{username, hashed, password} {
+		mac.Write([]byte(s))
+		mac.Write([]byte{0})
+	}
+	var out [sha256.Size]byte
+	copy(out[:], mac.Sum(nil))
+	return out
+}
+
+// verify 验证密码，TTL 内验证成功过的凭据直接返回 true
+func (c *authCache) verify(username, hashed, password string) bool {
+	if c.ttl <= 0 {
+		return verifyPassword(hashed, password)
+	}
+	key := c.sum(username, hashed, password)
+	now := time.Now()
+	c.mu.Lock()
+	expires, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(expires) {
+		return true
+	}
+	if !verifyPassword(hashed, password) {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= authCacheSize {
+		for k, expires := range c.entries {
+			if !now.Before(expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= authCacheSize {
+			clear(c.entries)
+		}
+	}
+	c.entries[key] = now.Add(c.ttl)
+	return true
+}
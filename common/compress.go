@@ -0,0 +1,36 @@
+package common
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// compressTypes 压缩的响应类型：PROPFIND 的 multistatus、目录页面与 JSON 接口
+var compressTypes = []string{
+	"text/xml",
+	"application/xml",
+	"text/html",
+	"application/json",
+}
+
+// Compress 按 Accept-Encoding 以 gzip 或 deflate 压缩文本响应，未启用时不做处理。
+// 带 Range 的请求不压缩，使 Content-Range 与原始内容一致
+func Compress(cfg *Config) func(http.Handler) http.Handler {
+	if !cfg.Compression.Enabled {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+	compressor := middleware.NewCompressor(cfg.Compression.Level, compressTypes...)
+	return func(next http.Handler) http.Handler {
+		compressed := compressor.Handler(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			compressed.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("<D:response></D:response>", 100)
+	handler := Compress(&Config{Compression: ConfigCompression{Enabled: true, Level: 5}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		_, _ = w.Write([]byte(body))
+	}))
+	request := func(contentType string, rangeHeader string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/?type="+url.QueryEscape(contentType), nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request("text/xml; charset=utf-8", "")
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"), "压缩 multistatus")
+	assert.Less(t, rec.Body.Len(), len(body))
+	assert.Empty(t, request("application/octet-stream", "").Header().Get("Content-Encoding"), "不压缩文件内容")
+	assert.Empty(t, request("text/xml", "bytes=0-10").Header().Get("Content-Encoding"), "带 Range 的请求不压缩")
+}
@@ -0,0 +1,26 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalPoolPath(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	assert.NoError(t, os.WriteFile(file, nil, 0o644))
+	load := func(poolPath string) error {
+		config := filepath.Join(t.TempDir(), "config.yaml")
+		assert.NoError(t, os.WriteFile(config, []byte("bind: 127.0.0.1:0\nusers:\n  alice:\n    password: alice\npools:\n  data:\n    path: "+poolPath+"\n"), 0o644))
+		_, err := LoadConfig(config)
+		return err
+	}
+
+	assert.NoError(t, load(dir))
+	for _, p := range []string{filepath.Join(dir, "missing"), file} {
+		assert.ErrorContains(t, load(p), "not exists or not dir", "存储池的路径无效时拒绝启动")
+	}
+}
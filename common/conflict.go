@@ -0,0 +1,62 @@
+package common
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	// ConflictOverwrite 覆盖已存在的文件
+	ConflictOverwrite = "overwrite"
+	// ConflictRename 以 name (1).ext 的形式另存
+	ConflictRename = "rename"
+)
+
+// conflictHeader 客户端指定本次上传的冲突处理方式，值为 overwrite 或 rename
+const conflictHeader = "OC-Conflict"
+
+// maxConflictRenames 查找可用名称的最大次数
+const maxConflictRenames = 1000
+
+var errNoConflictName = errors.New("no free name for the conflicting upload")
+
+// ConflictPolicy 返回上传请求的冲突处理方式，请求头优先于 upload_conflict 配置
+func (c *Config) ConflictPolicy(request *http.Request) string {
+	switch value := strings.ToLower(strings.TrimSpace(request.Header.Get(conflictHeader))); value {
+	case ConflictOverwrite, ConflictRename:
+		return value
+	}
+	return c.UploadConflict
+}
+
+// ConflictName 返回上传到 name 时不与已有文件冲突的路径，name 不存在时原样返回，
+// 否则依次尝试 name (1).ext、name (2).ext 等
+func ConflictName(fs afero.Fs, name string) (string, error) {
+	if _, err := fs.Stat(name); os.IsNotExist(err) {
+		return name, nil
+	} else if err != nil {
+		return "", err
+	}
+	dir, base := path.Split(name)
+	ext := path.Ext(base)
+	if ext == base {
+		// .bashrc 等没有扩展名的隐藏文件
+		ext = ""
+	}
+	stem := strings.TrimSuffix(base, ext)
+	for i := 1; i <= maxConflictRenames; i++ {
+		candidate := dir + stem + " (" + strconv.Itoa(i) + ")" + ext
+		if _, err := fs.Stat(candidate); os.IsNotExist(err) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+	return "", errNoConflictName
+}
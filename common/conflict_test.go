@@ -0,0 +1,36 @@
+package common
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConflictName(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, name := range []string{"/dir/a.txt", "/dir/a (1).txt", "/dir/.bashrc", "/dir/README"} {
+		assert.NoError(t, afero.WriteFile(fs, name, []byte("x"), 0o644))
+	}
+	for name, expected := range map[string]string{
+		"/dir/b.txt":   "/dir/b.txt",
+		"/dir/a.txt":   "/dir/a (2).txt",
+		"/dir/.bashrc": "/dir/.bashrc (1)",
+		"/dir/README":  "/dir/README (1)",
+	} {
+		renamed, err := ConflictName(fs, name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, renamed, "上传到 %s", name)
+	}
+}
+
+func TestConflictPolicy(t *testing.T) {
+	config := &Config{UploadConflict: ConflictOverwrite}
+	request := httptest.NewRequest("PUT", "/dav/a.txt", nil)
+	assert.Equal(t, ConflictOverwrite, config.ConflictPolicy(request))
+	request.Header.Set("OC-Conflict", "Rename")
+	assert.Equal(t, ConflictRename, config.ConflictPolicy(request), "请求头优先于配置")
+	request.Header.Set("OC-Conflict", "unknown")
+	assert.Equal(t, ConflictOverwrite, config.ConflictPolicy(request), "无效的请求头使用配置")
+}
@@ -9,15 +9,19 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/ssh"
 
+	"code.d7z.net/packages/webdav-server/events"
 	"code.d7z.net/packages/webdav-server/mergefs"
+	"code.d7z.net/packages/webdav-server/metrics"
 	"github.com/spf13/afero"
 )
 
@@ -75,9 +79,25 @@ func verifyArgon2id(encodedHash, password string) bool {
 }
 
 type FsContext struct {
-	ctx       context.Context
-	Config    *Config
-	users     map[string]afero.Fs
+	ctx    context.Context
+	Config *Config
+	Events *events.Hub
+	// Journal 最近的变更，供 WebDAV sync-collection 增量同步
+	Journal *events.Journal
+	// Changes 映射到用户文件系统路径后的变更，Path 为用户文件系统中的路径，Pool 为空
+	Changes *events.Hub
+	// Metrics 导出的 Prometheus 指标
+	Metrics *metrics.Registry
+	// Shares 分享链接，未启用时为空
+	Shares *ShareStore
+	users  map[string]afero.Fs
+	// sites 启用了静态网站的存储池的只读文件系统
+	sites  map[string]afero.Fs
+	health *poolHealth
+	// scoped 按 Host 站点缓存的用户文件系统
+	scopedMu  sync.Mutex
+	scoped    map[string]afero.Fs
+	auth      *authCache
 	secretKey []byte
 }
 
@@ -90,40 +110,158 @@ func NewContext(ctx context.Context, cfg *Config) (*FsContext, error) {
 	if _, err := rand.Read(key); err != nil {
 		return nil, err
 	}
+	if err := registerMimeTypes(cfg.MimeTypes); err != nil {
+		return nil, err
+	}
 	f := &FsContext{
 		ctx:       ctx,
 		Config:    cfg,
+		Events:    events.NewHub(),
+		Journal:   events.NewJournal(cfg.Webdav.SyncHistory),
+		Changes:   events.NewHub(),
+		Metrics:   metrics.NewRegistry(),
 		users:     make(map[string]afero.Fs),
+		sites:     make(map[string]afero.Fs),
+		health:    newPoolHealth(),
+		auth:      newAuthCache(cfg.AuthCacheTTL, key),
 		secretKey: key,
 	}
+	if cfg.Preview.Shares.Enabled {
+		shares, err := NewShareStore(cfg.Preview.Shares.File)
+		if err != nil {
+			return nil, err
+		}
+		f.Shares = shares
+	}
 	pools := make(map[string]afero.Fs)
 	osFs := afero.NewOsFs()
 
 	for s, pool := range cfg.Pools {
-		pools[s] = afero.NewBasePathFs(osFs, pool.Path)
+		if pool.SourcePool != "" {
+			continue
+		}
+		poolFs, err := newPoolFs(osFs, s, pool)
+		if err != nil {
+			return nil, err
+		}
+		pools[s] = poolFs
+		if pool.Watch {
+			if err := events.WatchPool(ctx, f.Events, s, pool.Path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	go purgeLoop(ctx, cfg)
+	// 绑定的存储池共享源存储池的缓存与写入检查
+	for s, pool := range cfg.Pools {
+		if pool.SourcePool == "" {
+			continue
+		}
+		var bindFs afero.Fs = mergefs.NewSubFs(pools[pool.SourcePool], pool.Subpath)
+		if opts, ok := pool.modeOptions(); ok {
+			bindFs = mergefs.NewModeFs(bindFs, opts)
+		}
+		pools[s] = bindFs
+	}
+	for s, pool := range cfg.Pools {
+		if pool.Site.Enabled {
+			f.sites[s] = newSiteFs(pools[s], pool)
+		}
 	}
 	for userName := range cfg.Users {
 		baseFS := afero.NewMemMapFs()
 		rootFs := mergefs.NewMountFs(afero.NewReadOnlyFs(baseFS))
+		rootFs.SetNegativeCache(cfg.NegativeCacheTTL)
 		_ = afero.WriteFile(baseFS, "/README.txt", []byte(fmt.Sprintf("欢迎你,%s", userName)), os.ModePerm)
 		for poolName, poolFS := range pools {
-			perm, ok := cfg.Pools[poolName].Permissions[userName]
-			if !ok {
-				perm = cfg.Pools[poolName].DefaultPerm
-			}
+			pool := cfg.Pools[poolName]
+			perm := pool.permission(userName)
 			if !perm.IsRead() {
 				continue
 			}
 			distFS := poolFS
-			if !perm.IsWrite() {
-				distFS = afero.NewReadOnlyFs(distFS)
+			if pool.Type == "memory" && pool.Memory.PerUser {
+				distFS = newMemoryFs(pool)
+			}
+			if pool.Upper != "" && perm.IsWrite() {
+				upperFs, err := newUpperFs(osFs, pool, userName)
+				if err != nil {
+					return nil, err
+				}
+				distFS = mergefs.NewOverlayFs(afero.NewReadOnlyFs(poolFS), upperFs)
+			}
+			if pool.MaxFileSize > 0 && perm.IsWrite() {
+				distFS = mergefs.NewMaxSizeFs(distFS, int64(pool.MaxFileSize))
 			}
+			switch {
+			case !perm.IsWrite():
+				distFS = mergefs.NewReadOnlyFs(distFS)
+			case !pool.isObjectStore() || pool.Upper != "":
+				// 对象存储的上传在完成时才可见，无需经过临时文件
+				distFS = mergefs.NewAtomicFs(distFS)
+			}
+			distFS = newHiddenFs(distFS, pool)
 			if err := rootFs.Mount(fmt.Sprintf("/%s", poolName), distFS); err != nil {
 				return nil, err
 			}
+			rootFs.SetExdev("/"+poolName, pool.Exdev)
+		}
+		// guest 为匿名共享账户，没有私有目录
+		if cfg.HomePool != "" && userName != "guest" {
+			homeFs, err := newHomeFs(osFs, cfg, userName)
+			if err != nil {
+				return nil, err
+			}
+			if cfg.Users[userName].ReadOnly() {
+				homeFs = mergefs.NewReadOnlyFs(homeFs)
+			}
+			if err := rootFs.Mount("/"+homePool, homeFs); err != nil {
+				return nil, err
+			}
 		}
 		f.users[userName] = rootFs
 	}
+	f.refreshSnapshots()
+	go f.refreshSnapshotsLoop(ctx)
+	if cfg.Health.Interval > 0 {
+		go f.healthLoop(ctx)
+	}
+	f.Events.Subscribe(func(event events.Event) {
+		name := path.Join("/", event.Pool, event.Path)
+		if cacheFs, ok := pools[event.Pool].(*mergefs.CacheFs); ok {
+			if event.Op == events.OpRemove || event.Op == events.OpRename {
+				cacheFs.InvalidateTree(event.Path)
+			} else {
+				cacheFs.Invalidate(event.Path)
+			}
+		}
+		names := []string{name}
+		for poolName, pool := range cfg.Pools {
+			// 私有空间的变更不会出现在绑定的存储池中
+			if event.User != "" || pool.SourcePool != event.Pool {
+				continue
+			}
+			subpath := path.Clean("/" + pool.Subpath)
+			rel, ok := strings.CutPrefix(path.Join("/", event.Path), subpath)
+			if ok && (rel == "" || rel[0] == '/' || subpath == "/") {
+				names = append(names, path.Join("/", poolName, rel))
+			}
+		}
+		for _, name := range names {
+			f.Journal.Append(name, event.User)
+			f.Changes.Publish(events.Event{Path: name, Op: event.Op, Time: event.Time, User: event.User})
+		}
+		for userName, userFs := range f.users {
+			if event.User != "" && event.User != userName {
+				continue
+			}
+			if mountFs, ok := userFs.(*mergefs.MountFs); ok {
+				for _, name := range names {
+					mountFs.Invalidate(name)
+				}
+			}
+		}
+	})
 	return f, nil
 }
 
@@ -150,7 +288,7 @@ func (c *FsContext) LoadFS(username, password string, publicKey ssh.PublicKey, g
 		return nil, errors.Wrapf(NoAuthorizedError, "user %s not found", username)
 	}
 	if password != "" {
-		if !verifyPassword(user.Password, password) {
+		if !c.auth.verify(username, user.Password, password) {
 			return nil, errors.Wrapf(NoAuthorizedError, "user %s password not allowed", username)
 		}
 	}
@@ -247,3 +385,156 @@ func (c *FsContext) LoadWebFS(r *http.Request, guestAccept bool) (*AuthFS, error
 func (c *FsContext) LoadUserFS(username string) afero.Fs {
 	return c.users[username]
 }
+
+// MaxFileSize 返回路径所在存储池的单个文件大小上限，name 为用户文件系统中的路径，为 0 时不限制
+func (c *FsContext) MaxFileSize(name string) int64 {
+	poolName, _, _ := strings.Cut(strings.TrimPrefix(mergefs.NormalizePath(name), "/"), "/")
+	return int64(c.Config.Pools[poolName].MaxFileSize)
+}
+
+// Usage 返回用户文件系统中路径所在存储池的空间使用情况，已扣除 min_free 预留的空间。
+// 远程、压缩包与内存存储池无法按磁盘计算，返回 false
+func (c *FsContext) Usage(username, name string) (mergefs.DiskUsage, bool) {
+	poolName, _, _ := strings.Cut(strings.TrimPrefix(mergefs.NormalizePath(name), "/"), "/")
+	if poolName == homePool && c.Config.HomePool != "" && username != "guest" {
+		usage, err := mergefs.GetDiskUsage(c.Config.HomePath(username))
+		return usage, err == nil
+	}
+	pool, ok := c.Config.Pools[poolName]
+	if !ok {
+		return mergefs.DiskUsage{}, false
+	}
+	writable := pool.permission(username).IsWrite()
+	// 绑定的存储池与源存储池位于同一磁盘
+	if pool.SourcePool != "" {
+		pool = c.Config.Pools[pool.SourcePool]
+	}
+	dir := pool.Path
+	switch {
+	case pool.Upper != "" && writable:
+		dir = pool.UpperPath(username)
+	case !pool.hasPath() || pool.Type == "archive":
+		return mergefs.DiskUsage{}, false
+	}
+	usage, err := mergefs.GetDiskUsage(dir)
+	if err != nil {
+		return mergefs.DiskUsage{}, false
+	}
+	// 预留的空间不可写入，同时从总量中扣除以保持已使用的空间不变
+	reserved := uint64(pool.MinFree)
+	usage.Free -= min(usage.Free, reserved)
+	usage.Total -= min(usage.Total, reserved)
+	return usage, true
+}
+
+// Writable 判断用户能否写入路径所在的存储池，name 为用户文件系统中的路径。
+// 只读用户、没有写入权限的存储池与只读快照返回 false，不属于任何存储池的路径交由文件系统判断
+func (c *FsContext) Writable(username, name string) bool {
+	if c.Config.Users[username].ReadOnly() {
+		return false
+	}
+	poolName, _, _ := strings.Cut(strings.TrimPrefix(mergefs.NormalizePath(name), "/"), "/")
+	if pool, ok := c.Config.Pools[poolName]; ok {
+		return pool.permission(username).IsWrite()
+	}
+	if source, _, ok := strings.Cut(poolName, "@"); ok {
+		_, ok = c.Config.Pools[source]
+		return !ok
+	}
+	return true
+}
+
+// CacheKey 返回缓存文件的派生数据（ETag、校验和）时使用的路径，用户私有的空间带上用户名以区分
+func (c *FsContext) CacheKey(username, name string) string {
+	name = mergefs.NormalizePath(name)
+	if c.private(username, name) {
+		return username + ":" + name
+	}
+	return name
+}
+
+// private 判断路径是否位于用户私有的空间（家目录、独立的内存存储池与 upper 层）
+func (c *FsContext) private(username, name string) bool {
+	poolName, _, _ := strings.Cut(strings.TrimPrefix(mergefs.NormalizePath(name), "/"), "/")
+	if poolName == homePool && c.Config.HomePool != "" {
+		return true
+	}
+	pool, ok := c.Config.Pools[poolName]
+	return ok && ((pool.Type == "memory" && pool.Memory.PerUser) || (pool.Upper != "" && pool.permission(username).IsWrite()))
+}
+
+// PublishChange 发布用户通过本服务修改文件产生的变更，name 为用户文件系统中的路径，
+// 绑定的存储池映射到源存储池，私有空间的变更只对该用户可见
+func (c *FsContext) PublishChange(username, name string, op events.Op) {
+	name = mergefs.NormalizePath(name)
+	poolName, rel, _ := strings.Cut(strings.TrimPrefix(name, "/"), "/")
+	if poolName == "" {
+		return
+	}
+	event := events.Event{Pool: poolName, Path: "/" + rel, Op: op}
+	if c.private(username, name) {
+		event.User = username
+	} else if pool, ok := c.Config.Pools[poolName]; ok && pool.SourcePool != "" {
+		event.Pool, event.Path = pool.SourcePool, path.Join("/", pool.Subpath, rel)
+	}
+	c.Events.Publish(event)
+}
+
+// trashPool 返回用户可访问回收站的源存储池及用户看到的存储池根目录在其中的路径
+func (c *FsContext) trashPool(username, name string, write bool) (poolName, prefix string, err error) {
+	poolName, _, _ = strings.Cut(strings.TrimPrefix(mergefs.NormalizePath(name), "/"), "/")
+	pool, ok := c.Config.Pools[poolName]
+	if !ok || pool.Upper != "" {
+		return "", "", errors.Wrapf(os.ErrNotExist, "trash of pool %s not found", poolName)
+	}
+	perm := pool.permission(username)
+	if !perm.IsRead() || (write && !perm.IsWrite()) {
+		return "", "", errors.Wrapf(NoPermissionError, "user %s cannot access trash of %s", username, poolName)
+	}
+	prefix = "/"
+	if pool.SourcePool != "" {
+		poolName, prefix = pool.SourcePool, path.Join("/", pool.Subpath)
+	}
+	if !c.Config.Pools[poolName].Trash.Enabled {
+		return "", "", errors.Wrapf(os.ErrNotExist, "trash is not enabled: %s", poolName)
+	}
+	return poolName, prefix, nil
+}
+
+// TrashEnabled 判断用户能否查看路径所在存储池的回收站，只检查配置，不打开存储池
+func (c *FsContext) TrashEnabled(username, name string) bool {
+	_, _, err := c.trashPool(username, name, false)
+	return err == nil
+}
+
+// LoadTrash 返回用户可访问的存储池回收站，name 为用户文件系统中的路径，write 为 true 时要求写入权限。
+// 绑定的存储池映射到源存储池，prefix 为用户看到的存储池根目录在源存储池中的路径，回收站中只有其下的条目对用户可见。
+// 带有 upper 的存储池中用户的删除发生在各自的 upper 中，回收站中的条目不属于用户，因此不可访问
+func (c *FsContext) LoadTrash(username, name string, write bool) (trashFs *mergefs.TrashFs, poolName, prefix string, err error) {
+	poolName, prefix, err = c.trashPool(username, name, write)
+	if err != nil {
+		return nil, "", "", err
+	}
+	trashFs, err = OpenTrash(c.Config, poolName)
+	return trashFs, poolName, prefix, err
+}
+
+// LoadVersions 返回用户可访问的存储池历史版本，name 为用户文件系统中的路径，
+// write 为 true 时要求写入权限。绑定的存储池映射到源存储池，返回的 poolName 与 rel 为源存储池及其中的路径
+func (c *FsContext) LoadVersions(username, name string, write bool) (versionFs *mergefs.VersionFs, poolName, rel string, err error) {
+	poolName, rel, _ = strings.Cut(strings.TrimPrefix(mergefs.NormalizePath(name), "/"), "/")
+	pool, ok := c.Config.Pools[poolName]
+	if !ok {
+		return nil, "", "", errors.Wrapf(os.ErrNotExist, "pool %s not found", poolName)
+	}
+	perm := pool.permission(username)
+	if !perm.IsRead() || (write && !perm.IsWrite()) {
+		return nil, "", "", errors.Wrapf(NoPermissionError, "user %s cannot access versions of %s", username, poolName)
+	}
+	rel = "/" + rel
+	if pool.SourcePool != "" {
+		poolName, rel = pool.SourcePool, path.Join("/", pool.Subpath, rel)
+	}
+	versionFs, err = OpenVersions(c.Config, poolName)
+	return versionFs, poolName, rel, err
+}
@@ -0,0 +1,86 @@
+package common
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrInvalidName 新建文件的名称不符合 filenames 策略
+var ErrInvalidName = errors.New("file name is not allowed")
+
+// ConfigFilenames 新建文件与目录的名称策略，作用于 WebDAV、SFTP 与网页上传。
+// 已存在的条目保持原名，避免同一文件出现两个不同编码的名称
+type ConfigFilenames struct {
+	// 将名称转换为 Unicode NFC，macOS 上传的 NFD 名称在 Windows 与 Linux 客户端中才能正常显示
+	NFC bool `yaml:"nfc"`
+	// 去除名称中的控制字符
+	StripControl bool `yaml:"strip_control"`
+	// 拒绝 Windows 无法创建的名称：保留字符 <>:"\|?*、以点或空格结尾、CON、NUL、COM1 等设备名
+	RejectWindows bool `yaml:"reject_windows"`
+}
+
+// Enabled 判断是否需要处理名称
+func (c ConfigFilenames) Enabled() bool {
+	return c.NFC || c.StripControl || c.RejectWindows
+}
+
+// SanitizeName 按策略处理单个文件名，处理后为空或不符合 Windows 规则时返回 ErrInvalidName
+func (c ConfigFilenames) SanitizeName(name string) (string, error) {
+	if c.NFC {
+		name = norm.NFC.String(name)
+	}
+	if c.StripControl {
+		name = strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return -1
+			}
+			return r
+		}, name)
+	}
+	if name == "" || name == "." || name == ".." {
+		return "", ErrInvalidName
+	}
+	if c.RejectWindows && !windowsName(name) {
+		return "", ErrInvalidName
+	}
+	return name, nil
+}
+
+// SanitizePath 处理路径的最后一段，上层目录已存在，保持不变
+func (c ConfigFilenames) SanitizePath(name string) (string, error) {
+	if !c.Enabled() {
+		return name, nil
+	}
+	dir, base := path.Split(name)
+	base, err := c.SanitizeName(base)
+	if err != nil {
+		return "", err
+	}
+	return dir + base, nil
+}
+
+// windowsReserved Windows 的设备名，带扩展名时同样不可用
+var windowsReserved = map[string]struct{}{
+	"CON": {}, "PRN": {}, "AUX": {}, "NUL": {},
+	"COM1": {}, "COM2": {}, "COM3": {}, "COM4": {}, "COM5": {}, "COM6": {}, "COM7": {}, "COM8": {}, "COM9": {},
+	"LPT1": {}, "LPT2": {}, "LPT3": {}, "LPT4": {}, "LPT5": {}, "LPT6": {}, "LPT7": {}, "LPT8": {}, "LPT9": {},
+}
+
+// windowsName 判断名称能否在 Windows 上创建
+func windowsName(name string) bool {
+	if strings.ContainsAny(name, `<>:"\|?*`) || strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return false
+	}
+	for _, r := range name {
+		if r < 0x20 {
+			return false
+		}
+	}
+	stem, _, _ := strings.Cut(name, ".")
+	_, reserved := windowsReserved[strings.ToUpper(strings.TrimRight(stem, " "))]
+	return !reserved
+}
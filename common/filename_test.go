@@ -0,0 +1,38 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeName(t *testing.T) {
+	names := ConfigFilenames{NFC: true, StripControl: true}
+	name, err := names.SanitizeName("cafe\u0301.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "caf\u00e9.txt", name, "NFD 名称应转换为 NFC")
+	name, err = names.SanitizeName("a\tb\x7f.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "ab.txt", name, "应去除控制字符")
+	_, err = names.SanitizeName("\x01\x02")
+	assert.ErrorIs(t, err, ErrInvalidName, "去除控制字符后为空")
+
+	name, err = names.SanitizePath("/data/dir/cafe\u0301")
+	assert.NoError(t, err)
+	assert.Equal(t, "/data/dir/caf\u00e9", name)
+
+	names.RejectWindows = true
+	for _, invalid := range []string{"a:b.txt", "what?", "trailing.", "trailing ", "CON", "nul.txt", "Com1.log"} {
+		_, err := names.SanitizeName(invalid)
+		assert.ErrorIs(t, err, ErrInvalidName, "Windows 无法创建 %q", invalid)
+	}
+	for _, valid := range []string{"console.txt", "COM10", ".gitignore", "a b.txt"} {
+		_, err := names.SanitizeName(valid)
+		assert.NoError(t, err, "Windows 可以创建 %q", valid)
+	}
+
+	disabled := ConfigFilenames{}
+	name, err = disabled.SanitizePath("/data/a:b\x01")
+	assert.NoError(t, err)
+	assert.Equal(t, "/data/a:b\x01", name, "未开启时保持原名")
+}
@@ -0,0 +1,146 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/events"
+	"code.d7z.net/packages/webdav-server/mergefs"
+)
+
+// ErrPoolUnavailable 存储池未通过健康检查，暂时不可用
+var ErrPoolUnavailable = errors.New("pool unavailable")
+
+// defaultHealthTimeout 单次探测的默认超时
+const defaultHealthTimeout = 10 * time.Second
+
+// poolHealth 存储池的健康状态
+type poolHealth struct {
+	mu       sync.RWMutex
+	degraded map[string]error
+	// 仍在进行的探测，NFS 等无响应时探测可能长时间阻塞，此时不再发起新的探测
+	probing map[string]bool
+}
+
+func newPoolHealth() *poolHealth {
+	return &poolHealth{degraded: make(map[string]error), probing: make(map[string]bool)}
+}
+
+func (h *poolHealth) start(name string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.probing[name] {
+		return false
+	}
+	h.probing[name] = true
+	return true
+}
+
+func (h *poolHealth) finish(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.probing, name)
+}
+
+// set 更新探测结果，返回状态是否发生变化
+func (h *poolHealth) set(name string, err error) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, degraded := h.degraded[name]
+	if err != nil {
+		h.degraded[name] = err
+		return !degraded
+	}
+	delete(h.degraded, name)
+	return degraded
+}
+
+func (h *poolHealth) get(name string) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.degraded[name]
+}
+
+// PoolError 返回路径所在存储池不可用的原因，存储池正常或路径不属于存储池时返回 nil。
+// name 为用户文件系统中的路径，绑定的存储池随源存储池一起不可用
+func (c *FsContext) PoolError(name string) error {
+	poolName, _, _ := strings.Cut(strings.TrimPrefix(mergefs.NormalizePath(name), "/"), "/")
+	pool, ok := c.Config.Pools[poolName]
+	if !ok {
+		return nil
+	}
+	if pool.SourcePool != "" {
+		poolName = pool.SourcePool
+	}
+	if err := c.health.get(poolName); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrPoolUnavailable, poolName, err)
+	}
+	return nil
+}
+
+// RetryAfter 存储池不可用时建议客户端重试的间隔（秒）
+func (c *FsContext) RetryAfter() int {
+	return max(int(c.Config.Health.Interval.Seconds()), 1)
+}
+
+func (c *FsContext) healthLoop(ctx context.Context) {
+	ticker := time.NewTicker(c.Config.Health.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.checkHealth()
+		}
+	}
+}
+
+// checkHealth 并发探测所有存储池，等待本轮探测完成或超时
+func (c *FsContext) checkHealth() {
+	var wg sync.WaitGroup
+	for name, pool := range c.Config.Pools {
+		// 绑定的存储池随源存储池检查，内存存储池无需检查
+		if pool.SourcePool != "" || pool.Type == "memory" {
+			continue
+		}
+		wg.Go(func() {
+			c.probe(name, pool)
+		})
+	}
+	wg.Wait()
+}
+
+func (c *FsContext) probe(name string, pool ConfigPool) {
+	if !c.health.start(name) {
+		return
+	}
+	timeout := c.Config.Health.Timeout
+	writable := c.Config.Health.WriteTest && pool.hasWriter() && pool.Upper == ""
+	result := make(chan error, 1)
+	go func() {
+		defer c.health.finish(name)
+		result <- probePool(pool, writable)
+	}()
+	var err error
+	select {
+	case err = <-result:
+	case <-time.After(timeout):
+		err = fmt.Errorf("no response within %s", timeout)
+	}
+	if !c.health.set(name, err) {
+		return
+	}
+	if err != nil {
+		slog.Error("|health| Pool degraded.", "pool", name, "err", err)
+		return
+	}
+	slog.Info("|health| Pool recovered.", "pool", name)
+	// 不可用期间缓存的目录与不存在的路径可能已失效
+	c.Events.Publish(events.Event{Pool: name, Path: "/", Op: events.OpRename})
+}
@@ -0,0 +1,41 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolHealth(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	assert.NoError(t, os.Mkdir(dir, 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644))
+	cfg := &Config{
+		Bind: "127.0.0.1:0",
+		Pools: map[string]ConfigPool{
+			"data": {Path: dir, DefaultPerm: "rw"},
+			"sub":  {SourcePool: "data", Subpath: "/", DefaultPerm: "r"},
+		},
+		Health: ConfigHealth{WriteTest: true},
+	}
+	assert.NoError(t, cfg.init())
+	ctx, err := NewContext(context.Background(), cfg)
+	assert.NoError(t, err)
+
+	ctx.checkHealth()
+	assert.NoError(t, ctx.PoolError("/data/a.txt"))
+
+	assert.NoError(t, os.RemoveAll(dir))
+	ctx.checkHealth()
+	assert.True(t, errors.Is(ctx.PoolError("/data/a.txt"), ErrPoolUnavailable), "存储池不可用")
+	assert.True(t, errors.Is(ctx.PoolError("/sub"), ErrPoolUnavailable), "绑定的存储池随源存储池不可用")
+	assert.NoError(t, ctx.PoolError("/other"))
+
+	assert.NoError(t, os.Mkdir(dir, 0o755))
+	ctx.checkHealth()
+	assert.NoError(t, ctx.PoolError("/data/a.txt"), "存储池恢复后自动重新挂载")
+}
@@ -0,0 +1,47 @@
+package common
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHomePool(t *testing.T) {
+	homes := t.TempDir()
+	cfg := &Config{
+		Bind:     "127.0.0.1:0",
+		HomePool: filepath.Join(homes, "{user}"),
+		Pools: map[string]ConfigPool{
+			"data": {Path: t.TempDir(), DefaultPerm: "r"},
+		},
+		Users: map[string]ConfigUser{
+			"alice": {Password: "alice"},
+			"bob":   {Password: "bob"},
+		},
+	}
+	assert.NoError(t, cfg.init())
+
+	ctx, err := NewContext(context.Background(), cfg)
+	assert.NoError(t, err)
+	alice := ctx.LoadUserFS("alice")
+	assert.NoError(t, afero.WriteFile(alice, "/home/a.txt", []byte("alice"), 0o644))
+	data, err := os.ReadFile(filepath.Join(homes, "alice", "a.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", string(data))
+
+	_, err = ctx.LoadUserFS("bob").Stat("/home/a.txt")
+	assert.True(t, os.IsNotExist(err), "私有目录相互隔离")
+	_, err = ctx.LoadUserFS("guest").Stat("/home")
+	assert.True(t, os.IsNotExist(err), "guest 没有私有目录")
+
+	cfg = &Config{
+		Bind:     "127.0.0.1:0",
+		HomePool: filepath.Join(homes, "{user}"),
+		Pools:    map[string]ConfigPool{"home": {Path: t.TempDir()}},
+	}
+	assert.ErrorContains(t, cfg.init(), "reserved by home_pool")
+}
@@ -0,0 +1,36 @@
+package common
+
+import (
+	"net"
+	"time"
+
+	"github.com/pires/go-proxyproto"
+)
+
+// Listen 监听 TCP 地址，开启 proxyProtocol 时解析 HAProxy PROXY 协议头 (v1/v2)，
+// 使 RemoteAddr 返回真实的客户端地址
+func (c *Config) Listen(bind string, proxyProtocol bool) (net.Listener, error) {
+	listener, err := net.Listen("tcp", bind)
+	if err != nil {
+		return nil, err
+	}
+	if !proxyProtocol {
+		return listener, nil
+	}
+	return &proxyproto.Listener{
+		Listener:          listener,
+		ReadHeaderTimeout: 10 * time.Second,
+		ConnPolicy:        c.proxyHeaderPolicy,
+	}, nil
+}
+
+// proxyHeaderPolicy 配置可信代理后，忽略来自其他地址的 PROXY 协议头
+func (c *Config) proxyHeaderPolicy(opts proxyproto.ConnPolicyOptions) (proxyproto.Policy, error) {
+	if len(c.trustedProxies) == 0 {
+		return proxyproto.USE, nil
+	}
+	if addr, ok := opts.Upstream.(*net.TCPAddr); ok && c.IsTrustedProxy(addr.IP) {
+		return proxyproto.USE, nil
+	}
+	return proxyproto.IGNORE, nil
+}
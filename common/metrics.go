@@ -0,0 +1,46 @@
+package common
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+)
+
+// MetricsHandler 返回导出 Prometheus 指标的 handler，配置了令牌时要求 Authorization: Bearer <token>
+func (c *FsContext) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.Config.Metrics.Token != "" && !c.HasMetricsToken(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		c.Metrics.ServeHTTP(w, r)
+	})
+}
+
+// HasMetricsToken 请求是否携带了配置的指标令牌，未配置令牌时始终为 false
+func (c *FsContext) HasMetricsToken(r *http.Request) bool {
+	token := c.Config.Metrics.Token
+	if token == "" {
+		return false
+	}
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// PoolName 返回用户文件系统中路径所在的存储池，快照归入其存储池，不属于任何存储池时返回空
+func (c *FsContext) PoolName(name string) string {
+	poolName, _, _ := strings.Cut(strings.TrimPrefix(mergefs.NormalizePath(name), "/"), "/")
+	if poolName == homePool && c.Config.HomePool != "" {
+		return poolName
+	}
+	if source, _, ok := strings.Cut(poolName, "@"); ok {
+		poolName = source
+	}
+	if _, ok := c.Config.Pools[poolName]; ok {
+		return poolName
+	}
+	return ""
+}
@@ -0,0 +1,28 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasMetricsToken(t *testing.T) {
+	withAuth := func(auth string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/version", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		return r
+	}
+
+	ctx := &FsContext{Config: &Config{}}
+	assert.False(t, ctx.HasMetricsToken(withAuth("")), "未配置令牌时不视为已认证")
+	assert.False(t, ctx.HasMetricsToken(withAuth("Bearer ")), "未配置令牌时不视为已认证")
+
+	ctx.Config.Metrics.Token = "secret"
+	assert.True(t, ctx.HasMetricsToken(withAuth("Bearer secret")))
+	assert.False(t, ctx.HasMetricsToken(withAuth("Bearer wrong")))
+	assert.False(t, ctx.HasMetricsToken(withAuth("secret")))
+}
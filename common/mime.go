@@ -0,0 +1,70 @@
+package common
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// defaultMimeTypes 系统类型表（/etc/mime.types 或 Windows 注册表）缺失时使用的常见类型，
+// 使精简的容器镜像中下载的文件同样能以正确的应用打开
+var defaultMimeTypes = map[string]string{
+	".md":   "text/markdown; charset=utf-8",
+	".txt":  "text/plain; charset=utf-8",
+	".log":  "text/plain; charset=utf-8",
+	".csv":  "text/csv; charset=utf-8",
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".mkv":  "video/x-matroska",
+	".webm": "video/webm",
+	".mov":  "video/quicktime",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".flac": "audio/flac",
+	".ogg":  "audio/ogg",
+	".opus": "audio/ogg",
+	".wav":  "audio/wav",
+	".heic": "image/heic",
+	".zip":  "application/zip",
+	".gz":   "application/gzip",
+	".tar":  "application/x-tar",
+	".7z":   "application/x-7z-compressed",
+	".epub": "application/epub+zip",
+}
+
+// normalizeMimeTypes 校验配置的 mime_types，扩展名统一为小写并以 . 开头
+func normalizeMimeTypes(types map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(types))
+	for ext, ctype := range types {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" || ext == "." {
+			return nil, fmt.Errorf("invalid mime_types extension: %q", ext)
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if _, _, err := mime.ParseMediaType(ctype); err != nil {
+			return nil, fmt.Errorf("invalid mime type for %s: %w", ext, err)
+		}
+		result[ext] = ctype
+	}
+	return result, nil
+}
+
+// registerMimeTypes 注册 MIME 类型，WebDAV 与预览均通过 mime.TypeByExtension 按扩展名判断类型，
+// 无法判断（如没有扩展名）时读取文件开头的内容识别。配置的类型优先于系统类型表
+func registerMimeTypes(types map[string]string) error {
+	for ext, ctype := range defaultMimeTypes {
+		if mime.TypeByExtension(ext) == "" {
+			if err := mime.AddExtensionType(ext, ctype); err != nil {
+				return err
+			}
+		}
+	}
+	for ext, ctype := range types {
+		if err := mime.AddExtensionType(ext, ctype); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package common
+
+import (
+	"mime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMimeTypes(t *testing.T) {
+	types, err := normalizeMimeTypes(map[string]string{"NFO": "text/plain; charset=utf-8"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{".nfo": "text/plain; charset=utf-8"}, types, "扩展名应统一为小写并以 . 开头")
+	_, err = normalizeMimeTypes(map[string]string{".x": "not a type;;"})
+	assert.Error(t, err, "非法的 MIME 类型应被拒绝")
+
+	assert.NoError(t, registerMimeTypes(types))
+	assert.Equal(t, "text/plain; charset=utf-8", mime.TypeByExtension(".NFO"), "配置的类型应生效")
+	assert.NotEmpty(t, mime.TypeByExtension(".mkv"), "系统类型表缺失时使用内置类型")
+}
@@ -0,0 +1,306 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/archivefs"
+	"code.d7z.net/packages/webdav-server/dedup"
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"code.d7z.net/packages/webdav-server/objstore"
+	"code.d7z.net/packages/webdav-server/remotedav"
+	"github.com/spf13/afero"
+)
+
+// newPoolFs 根据存储池配置创建共享的存储池文件系统
+func newPoolFs(osFs afero.Fs, name string, pool ConfigPool) (afero.Fs, error) {
+	var poolFs afero.Fs
+	switch pool.Type {
+	case "s3", "azure", "gcs":
+		store, err := newObjectStore(pool)
+		if err != nil {
+			return nil, err
+		}
+		poolFs = objstore.NewFs(store, int64(pool.partSize()))
+	case "webdav":
+		remoteFs, err := newRemoteDavFs(pool)
+		if err != nil {
+			return nil, err
+		}
+		poolFs = remoteFs
+	case "memory":
+		poolFs = newMemoryFs(pool)
+	case "archive":
+		archiveFs, err := archivefs.Open(pool.Path)
+		if err != nil {
+			return nil, err
+		}
+		poolFs = archiveFs
+	case "dedup":
+		dedupFs, err := dedup.NewFs(afero.NewBasePathFs(osFs, pool.Path))
+		if err != nil {
+			return nil, err
+		}
+		poolFs = dedupFs
+	default:
+		poolFs = mergefs.NewOsPathFs(pool.Path, mergefs.SymlinkPolicy(pool.Symlinks))
+	}
+	if opts, ok := pool.modeOptions(); ok {
+		poolFs = mergefs.NewModeFs(poolFs, opts)
+	}
+	if uid, gid, ok := pool.owner(); ok {
+		poolFs = mergefs.NewOwnerFs(poolFs, uid, gid)
+	}
+	// 在权限与属主映射之上，覆盖文件时重新创建的文件同样适用映射
+	if pool.Versions.Enabled {
+		poolFs = mergefs.NewVersionFs(poolFs, pool.versionOptions())
+	}
+	if pool.Trash.Enabled {
+		poolFs = mergefs.NewTrashFs(poolFs)
+	}
+	if pool.MinFree > 0 {
+		poolFs = mergefs.NewMinFreeFs(poolFs, pool.Path, uint64(pool.MinFree))
+	}
+	if pool.Cache.TTL > 0 {
+		opts := mergefs.CacheOptions{
+			TTL:         pool.Cache.TTL,
+			MaxFileSize: int64(pool.Cache.MaxFileSize),
+		}
+		switch pool.Cache.Content {
+		case "":
+		case "memory":
+			opts.Content = afero.NewMemMapFs()
+		default:
+			dir := filepath.Join(pool.Cache.Content, name)
+			if err := osFs.MkdirAll(dir, 0o755); err != nil {
+				return nil, err
+			}
+			opts.Content = afero.NewBasePathFs(osFs, dir)
+		}
+		poolFs = mergefs.NewCacheFs(poolFs, opts)
+	}
+	return poolFs, nil
+}
+
+// newObjectStore 创建远程存储池的对象存储客户端
+func newObjectStore(pool ConfigPool) (objstore.Store, error) {
+	switch pool.Type {
+	case "s3":
+		return objstore.NewS3Store(objstore.S3Config{
+			Endpoint:     pool.S3.Endpoint,
+			Region:       pool.S3.Region,
+			Bucket:       pool.S3.Bucket,
+			Prefix:       pool.S3.Prefix,
+			AccessKey:    pool.S3.AccessKey,
+			SecretKey:    pool.S3.SecretKey,
+			SessionToken: pool.S3.SessionToken,
+			VirtualHost:  pool.S3.VirtualHost,
+		})
+	case "azure":
+		return objstore.NewAzureStore(objstore.AzureConfig{
+			Endpoint:  pool.Azure.Endpoint,
+			Account:   pool.Azure.Account,
+			Key:       pool.Azure.Key,
+			SASToken:  pool.Azure.SASToken,
+			Container: pool.Azure.Container,
+			Prefix:    pool.Azure.Prefix,
+		})
+	case "gcs":
+		return objstore.NewGCSStore(objstore.GCSConfig{
+			Endpoint:  pool.GCS.Endpoint,
+			Bucket:    pool.GCS.Bucket,
+			Prefix:    pool.GCS.Prefix,
+			AccessKey: pool.GCS.AccessKey,
+			SecretKey: pool.GCS.SecretKey,
+		})
+	}
+	return nil, fmt.Errorf("not a remote pool type: %s", pool.Type)
+}
+
+// newMemoryFs 创建内存存储池的文件系统
+func newMemoryFs(pool ConfigPool) *mergefs.MemoryFs {
+	return mergefs.NewMemoryFs(mergefs.MemoryOptions{
+		MaxSize: int64(pool.Memory.MaxSize),
+		TTL:     pool.Memory.TTL,
+	})
+}
+
+// newRemoteDavFs 创建远程 WebDAV 存储池的文件系统
+func newRemoteDavFs(pool ConfigPool) (*remotedav.Fs, error) {
+	return remotedav.NewFs(remotedav.Config{
+		URL:      pool.Webdav.URL,
+		Username: pool.Webdav.Username,
+		Password: pool.Webdav.Password,
+	})
+}
+
+// newHiddenFs 按存储池的隐藏与排除规则包装文件系统，未设置时原样返回
+func newHiddenFs(fs afero.Fs, pool ConfigPool) afero.Fs {
+	if !pool.Hide.Enabled() && len(pool.Exclude) == 0 {
+		return fs
+	}
+	return mergefs.NewHiddenFs(fs, mergefs.HiddenOptions{
+		Dotfiles:      pool.Hide.Dotfiles,
+		Names:         pool.Hide.Names,
+		Exclude:       pool.Exclude,
+		AllowExplicit: pool.Hide.AllowExplicit,
+	})
+}
+
+// newHomeFs 创建用户的私有目录，不存在时自动创建
+func newHomeFs(osFs afero.Fs, cfg *Config, userName string) (afero.Fs, error) {
+	dir := cfg.HomePath(userName)
+	if err := osFs.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return mergefs.NewAtomicFs(mergefs.NewOsPathFs(dir, mergefs.SymlinkWithin)), nil
+}
+
+// newUpperFs 创建用户独立的 overlay 上层目录
+func newUpperFs(osFs afero.Fs, pool ConfigPool, userName string) (afero.Fs, error) {
+	upperPath := pool.UpperPath(userName)
+	if err := osFs.MkdirAll(upperPath, 0o755); err != nil {
+		return nil, err
+	}
+	var upperFs afero.Fs = mergefs.NewOsPathFs(upperPath, mergefs.SymlinkPolicy(pool.Symlinks))
+	if opts, ok := pool.modeOptions(); ok {
+		upperFs = mergefs.NewModeFs(upperFs, opts)
+	}
+	if uid, gid, ok := pool.owner(); ok {
+		upperFs = mergefs.NewOwnerFs(upperFs, uid, gid)
+	}
+	if pool.MinFree > 0 {
+		upperFs = mergefs.NewMinFreeFs(upperFs, upperPath, uint64(pool.MinFree))
+	}
+	return upperFs, nil
+}
+
+// openPoolBase 打开本地或 dedup 存储池的底层文件系统，供命令行与后台任务直接访问
+func openPoolBase(cfg *Config, name string) (afero.Fs, error) {
+	pool, ok := cfg.Pools[name]
+	if !ok {
+		return nil, fmt.Errorf("pool does not exist: %s", name)
+	}
+	if pool.Type == "dedup" {
+		return dedup.NewFs(afero.NewBasePathFs(afero.NewOsFs(), pool.Path))
+	}
+	return mergefs.NewOsPathFs(pool.Path, mergefs.SymlinkPolicy(pool.Symlinks)), nil
+}
+
+// OpenTrash 打开存储池的回收站，存储池需启用 trash
+func OpenTrash(cfg *Config, name string) (*mergefs.TrashFs, error) {
+	if !cfg.Pools[name].Trash.Enabled {
+		return nil, fmt.Errorf("trash is not enabled: %s", name)
+	}
+	baseFs, err := openPoolBase(cfg, name)
+	if err != nil {
+		return nil, err
+	}
+	return mergefs.NewTrashFs(baseFs), nil
+}
+
+// OpenVersions 打开存储池的历史版本，存储池需启用 versions
+func OpenVersions(cfg *Config, name string) (*mergefs.VersionFs, error) {
+	if !cfg.Pools[name].Versions.Enabled {
+		return nil, fmt.Errorf("versions is not enabled: %s", name)
+	}
+	baseFs, err := openPoolBase(cfg, name)
+	if err != nil {
+		return nil, err
+	}
+	return mergefs.NewVersionFs(baseFs, cfg.Pools[name].versionOptions()), nil
+}
+
+// PruneVersions 按保留策略清理存储池的历史版本，names 为空时处理所有启用历史版本的存储池
+func PruneVersions(cfg *Config, names []string) error {
+	for name, pool := range cfg.Pools {
+		if !pool.Versions.Enabled || (len(names) > 0 && !slices.Contains(names, name)) {
+			continue
+		}
+		versionFs, err := OpenVersions(cfg, name)
+		if err != nil {
+			return fmt.Errorf("pool %s: %w", name, err)
+		}
+		count, err := versionFs.Prune()
+		if err != nil {
+			return fmt.Errorf("pool %s: %w", name, err)
+		}
+		if count > 0 {
+			slog.Info("|versions| Pool pruned.", "pool", name, "removed", count)
+		}
+	}
+	return nil
+}
+
+// PurgeTrash 清理存储池回收站中超过保留时间的条目，names 为空时处理所有启用回收站的存储池
+func PurgeTrash(cfg *Config, names []string, now time.Time) error {
+	for name, pool := range cfg.Pools {
+		if !pool.Trash.Enabled || pool.Trash.Retention <= 0 || (len(names) > 0 && !slices.Contains(names, name)) {
+			continue
+		}
+		trashFs, err := OpenTrash(cfg, name)
+		if err != nil {
+			return fmt.Errorf("pool %s: %w", name, err)
+		}
+		count, err := trashFs.Purge(now.Add(-pool.Trash.Retention))
+		if err != nil {
+			return fmt.Errorf("pool %s: %w", name, err)
+		}
+		if count > 0 {
+			slog.Info("|trash| Pool purged.", "pool", name, "removed", count)
+		}
+	}
+	return nil
+}
+
+// purgeInterval 定期清理回收站与历史版本的间隔
+const purgeInterval = time.Hour
+
+// purgeLoop 定期清理所有存储池回收站中过期的条目及超出保留策略的历史版本
+func purgeLoop(ctx context.Context, cfg *Config) {
+	if !slices.ContainsFunc(slices.Collect(maps.Values(cfg.Pools)), func(pool ConfigPool) bool {
+		return (pool.Trash.Enabled && pool.Trash.Retention > 0) || pool.Versions.Enabled
+	}) {
+		return
+	}
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		if err := PurgeTrash(cfg, nil, time.Now()); err != nil {
+			slog.Warn("|trash| Purge failed.", "err", err)
+		}
+		if err := PruneVersions(cfg, nil); err != nil {
+			slog.Warn("|versions| Prune failed.", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// GCPools 清理 dedup 存储池中未被引用的内容，names 为空时处理所有 dedup 存储池
+func GCPools(cfg *Config, names []string, grace time.Duration) error {
+	osFs := afero.NewOsFs()
+	for name, pool := range cfg.Pools {
+		if pool.Type != "dedup" || (len(names) > 0 && !slices.Contains(names, name)) {
+			continue
+		}
+		dedupFs, err := dedup.NewFs(afero.NewBasePathFs(osFs, pool.Path))
+		if err != nil {
+			return fmt.Errorf("pool %s: %w", name, err)
+		}
+		result, err := dedupFs.GC(grace)
+		if err != nil {
+			return fmt.Errorf("pool %s: %w", name, err)
+		}
+		slog.Info("|gc| Pool cleaned.", "pool", name, "removed", result.Removed, "freed", result.Freed)
+	}
+	return nil
+}
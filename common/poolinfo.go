@@ -0,0 +1,93 @@
+package common
+
+import (
+	"slices"
+	"strings"
+)
+
+// PoolInfo 首页与预览根目录中显示的存储池信息
+type PoolInfo struct {
+	// Name 存储池的挂载名
+	Name        string
+	Title       string
+	Description string
+	Icon        string
+	// Usage 存储池的空间使用情况，只为登录用户计算，无法计算时为 nil
+	Usage *PoolUsage
+}
+
+// PoolUsage 存储池所在磁盘的空间（字节），已扣除 min_free 预留的空间
+type PoolUsage struct {
+	Used  int64
+	Free  int64
+	Total int64
+}
+
+// Percent 已使用空间的百分比
+func (u PoolUsage) Percent() int {
+	if u.Total <= 0 {
+		return 0
+	}
+	return int(u.Used * 100 / u.Total)
+}
+
+// DisplayName 返回显示的名称，未设置 title 时为挂载名
+func (p PoolInfo) DisplayName() string {
+	if p.Title != "" {
+		return p.Title
+	}
+	return p.Name
+}
+
+// IconImage 图标为 http(s) 图片地址时返回 true，否则按文字（如 emoji）显示
+func (p PoolInfo) IconImage() bool {
+	return strings.HasPrefix(p.Icon, "http://") || strings.HasPrefix(p.Icon, "https://")
+}
+
+// PoolInfo 返回挂载名为 name 的存储池的显示信息，用户私有目录只有挂载名
+func (c *Config) PoolInfo(name string) (PoolInfo, bool) {
+	if pool, ok := c.Pools[name]; ok {
+		return PoolInfo{Name: name, Title: pool.Title, Description: pool.Description, Icon: pool.Icon}, true
+	}
+	if name == homePool && c.HomePool != "" {
+		return PoolInfo{Name: name}, true
+	}
+	return PoolInfo{}, false
+}
+
+// UserPools 返回用户可以读取的存储池，按挂载名排序。未定义的用户没有存储池
+func (c *Config) UserPools(user string) []PoolInfo {
+	if _, ok := c.Users[user]; !ok {
+		return nil
+	}
+	var result []PoolInfo
+	for name, pool := range c.Pools {
+		if pool.permission(user).IsRead() {
+			info, _ := c.PoolInfo(name)
+			result = append(result, info)
+		}
+	}
+	if c.HomePool != "" && user != "guest" {
+		info, _ := c.PoolInfo(homePool)
+		result = append(result, info)
+	}
+	slices.SortFunc(result, func(a, b PoolInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return result
+}
+
+// PoolUsage 返回用户可以读取的存储池及其空间使用情况。远程、压缩包与内存存储池无法按磁盘计算，
+// 未通过健康检查的存储池不再访问磁盘，以免阻塞页面
+func (c *FsContext) PoolUsage(user string) []PoolInfo {
+	pools := c.Config.UserPools(user)
+	for i := range pools {
+		if c.PoolError(pools[i].Name) != nil {
+			continue
+		}
+		if usage, ok := c.Usage(user, pools[i].Name); ok {
+			pools[i].Usage = &PoolUsage{Used: int64(usage.Used()), Free: int64(usage.Free), Total: int64(usage.Total)}
+		}
+	}
+	return pools
+}
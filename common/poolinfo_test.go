@@ -0,0 +1,55 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserPools(t *testing.T) {
+	cfg := &Config{
+		HomePool: "/srv/home/{user}",
+		Pools: map[string]ConfigPool{
+			"p1":     {Title: "项目资料", Description: "团队共享文档", Icon: "📁", DefaultPerm: "r"},
+			"p2":     {Icon: "https://example.com/p2.png", Permissions: map[string]FilePerm{"alice": "rw"}},
+			"secret": {Permissions: map[string]FilePerm{"bob": "r"}},
+		},
+		Users: map[string]ConfigUser{"guest": {}, "alice": {}},
+	}
+
+	pools := cfg.UserPools("alice")
+	assert.Equal(t, []PoolInfo{
+		{Name: "home"},
+		{Name: "p1", Title: "项目资料", Description: "团队共享文档", Icon: "📁"},
+		{Name: "p2", Icon: "https://example.com/p2.png"},
+	}, pools, "只包含有读取权限的存储池，按挂载名排序")
+	assert.Equal(t, "项目资料", pools[1].DisplayName())
+	assert.Equal(t, "p2", pools[2].DisplayName(), "未设置 title 时显示挂载名")
+	assert.False(t, pools[1].IconImage())
+	assert.True(t, pools[2].IconImage())
+
+	assert.Equal(t, []PoolInfo{{Name: "p1", Title: "项目资料", Description: "团队共享文档", Icon: "📁"}}, cfg.UserPools("guest"), "访客没有私有目录")
+	assert.Empty(t, cfg.UserPools("mallory"), "未定义的用户没有存储池")
+	_, ok := cfg.PoolInfo("missing")
+	assert.False(t, ok)
+}
+
+func TestPoolUsage(t *testing.T) {
+	cfg := &Config{
+		Pools: map[string]ConfigPool{
+			"data":    {Path: t.TempDir(), Permissions: map[string]FilePerm{"alice": "rw"}},
+			"scratch": {Type: "memory", Permissions: map[string]FilePerm{"alice": "rw"}},
+		},
+		Users: map[string]ConfigUser{"alice": {Password: "alice"}},
+	}
+	ctx, err := NewContext(t.Context(), cfg)
+	assert.NoError(t, err)
+
+	pools := ctx.PoolUsage("alice")
+	assert.Len(t, pools, 2)
+	assert.NotNil(t, pools[0].Usage, "本地存储池按磁盘计算空间")
+	assert.Positive(t, pools[0].Usage.Total)
+	assert.Equal(t, pools[0].Usage.Total, pools[0].Usage.Used+pools[0].Usage.Free)
+	assert.Nil(t, pools[1].Usage, "内存存储池无法按磁盘计算")
+	assert.Equal(t, 25, PoolUsage{Used: 1, Free: 3, Total: 4}.Percent())
+}
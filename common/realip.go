@@ -0,0 +1,86 @@
+package common
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseTrustedProxies 解析可信代理列表，支持 CIDR 与单个 IP
+func parseTrustedProxies(items []string) ([]*net.IPNet, error) {
+	result := make([]*net.IPNet, 0, len(items))
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "IP address", Text: item}
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			result = append(result, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ipNet)
+	}
+	return result, nil
+}
+
+// IsTrustedProxy 判断地址是否来自可信代理
+func (c *Config) IsTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range c.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP 解析请求的真实客户端地址，仅当连接来自可信代理时才使用转发头
+func (c *Config) ClientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if !c.IsTrustedProxy(net.ParseIP(remote)) {
+		return remote
+	}
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		// 从右向左跳过可信代理，第一个非可信地址即为客户端
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			ip := net.ParseIP(hop)
+			if ip == nil {
+				break
+			}
+			if !c.IsTrustedProxy(ip) || i == 0 {
+				return ip.String()
+			}
+		}
+	}
+	if xrip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); xrip != nil {
+		return xrip.String()
+	}
+	return remote
+}
+
+// RealIP 中间件，将 RemoteAddr 替换为可信的客户端地址
+func RealIP(cfg *Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.RemoteAddr = cfg.ClientIP(r)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package common
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := parseTrustedProxies([]string{"10.0.0.0/8", "127.0.0.1"})
+	assert.NoError(t, err)
+	cfg := &Config{trustedProxies: proxies}
+
+	// 非可信来源，忽略转发头
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "192.168.1.10:1234"
+	r.Header.Set("X-Forwarded-For", "1.2.3.4")
+	assert.Equal(t, "192.168.1.10", cfg.ClientIP(r))
+
+	// 可信来源，跳过链路中的可信代理
+	r.RemoteAddr = "127.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "6.6.6.6, 1.2.3.4, 10.0.0.2")
+	assert.Equal(t, "1.2.3.4", cfg.ClientIP(r))
+
+	// 可信来源，使用 X-Real-IP
+	r.Header.Del("X-Forwarded-For")
+	r.Header.Set("X-Real-IP", "5.6.7.8")
+	assert.Equal(t, "5.6.7.8", cfg.ClientIP(r))
+
+	// 无转发头
+	r.Header.Del("X-Real-IP")
+	assert.Equal(t, "127.0.0.1", cfg.ClientIP(r))
+
+	_, err = parseTrustedProxies([]string{"not-an-ip"})
+	assert.Error(t, err)
+}
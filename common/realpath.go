@@ -0,0 +1,53 @@
+package common
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+)
+
+// RealPath 返回用户文件系统中路径对应的磁盘文件，用于交由前端代理直接发送文件。
+// 仅本地存储池（含绑定的子目录与用户私有目录）可用，overlay、去重、远程与快照等返回 false。
+// 符号链接被解析，按存储池的策略指向存储池以外时返回 false；调用者需先通过用户文件系统检查路径可见
+func (c *FsContext) RealPath(username, name string) (string, bool) {
+	poolName, rel, _ := strings.Cut(strings.TrimPrefix(mergefs.NormalizePath(name), "/"), "/")
+	var root, policy string
+	if poolName == homePool && c.Config.HomePool != "" {
+		if username == "guest" {
+			return "", false
+		}
+		root = c.Config.HomePath(username)
+	} else {
+		pool, ok := c.Config.Pools[poolName]
+		if !ok {
+			return "", false
+		}
+		if pool.SourcePool != "" {
+			rel = path.Join(pool.Subpath, rel)
+			pool = c.Config.Pools[pool.SourcePool]
+		}
+		if pool.Type != "local" || pool.Upper != "" {
+			return "", false
+		}
+		root, policy = pool.Path, pool.Symlinks
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", false
+	}
+	real, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(path.Clean("/"+rel))))
+	if err != nil {
+		return "", false
+	}
+	if mergefs.SymlinkPolicy(policy) != mergefs.SymlinkAnywhere {
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			root = resolved
+		}
+		if within, err := filepath.Rel(root, real); err != nil || within == ".." || strings.HasPrefix(within, ".."+string(filepath.Separator)) {
+			return "", false
+		}
+	}
+	return real, true
+}
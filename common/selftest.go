@@ -0,0 +1,176 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/archivefs"
+	"github.com/spf13/afero"
+)
+
+// PoolCheckResult 存储池自检结果
+type PoolCheckResult struct {
+	Pool     string
+	Path     string
+	Writable bool
+	Err      error
+}
+
+// CheckPools 检查所有存储池：路径存在、为目录，且在有用户具备写权限时确实可写
+func CheckPools(cfg *Config) []PoolCheckResult {
+	names := make([]string, 0, len(cfg.Pools))
+	for name := range cfg.Pools {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	results := make([]PoolCheckResult, 0, len(names))
+	for _, name := range names {
+		pool := cfg.Pools[name]
+		// overlay 存储池的 path 为只读下层，无需检查写权限
+		result := PoolCheckResult{
+			Pool:     name,
+			Path:     pool.Path,
+			Writable: pool.hasWriter() && pool.Upper == "",
+		}
+		switch {
+		case pool.isRemote():
+			result.Path = pool.remoteLocation()
+			result.Err = checkRemotePool(pool, result.Writable)
+		case pool.Type == "bind":
+			result.Path = pool.SourcePool + ":" + path.Clean("/"+pool.Subpath)
+			if source := cfg.Pools[pool.SourcePool]; source.Type == "local" {
+				result.Err = checkPool(filepath.Join(source.Path, pool.Subpath), result.Writable)
+			}
+		case pool.Type == "memory":
+			result.Path = "memory"
+		case pool.Type == "archive":
+			result.Err = checkArchivePool(pool.Path)
+		default:
+			result.Err = checkPool(pool.Path, result.Writable)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// SelfTest 执行存储池自检并输出日志，存在失败项时返回错误
+func SelfTest(cfg *Config) error {
+	var errs []error
+	for _, result := range CheckPools(cfg) {
+		if result.Err != nil {
+			slog.Error("|selftest| Pool check failed.", "pool", result.Pool, "path", result.Path, "err", result.Err)
+			errs = append(errs, fmt.Errorf("pool %s: %w", result.Pool, result.Err))
+			continue
+		}
+		slog.Info("|selftest| Pool check passed.", "pool", result.Pool, "path", result.Path, "writable", result.Writable)
+	}
+	return errors.Join(errs...)
+}
+
+func checkPool(path string, writable bool) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !stat.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	if !writable {
+		return nil
+	}
+	file, err := os.CreateTemp(path, ".webdav-server-selftest-*")
+	if err != nil {
+		return fmt.Errorf("not writable: %w", err)
+	}
+	_ = file.Close()
+	return os.Remove(file.Name())
+}
+
+// probePool 定期健康检查时探测存储池，与自检相同但不重新索引压缩包
+func probePool(pool ConfigPool, writable bool) error {
+	switch {
+	case pool.isRemote():
+		return checkRemotePool(pool, writable)
+	case pool.Type == "archive":
+		_, err := os.Stat(pool.Path)
+		return err
+	}
+	return checkPool(pool.Path, writable)
+}
+
+// checkArchivePool 检查压缩包可以打开并建立索引
+func checkArchivePool(path string) error {
+	archiveFs, err := archivefs.Open(path)
+	if err != nil {
+		return err
+	}
+	return archiveFs.Close()
+}
+
+// checkRemotePool 检查远程存储池可以列出，且在需要时可以写入
+func checkRemotePool(pool ConfigPool, writable bool) error {
+	if pool.Type == "webdav" {
+		return checkRemoteDav(pool, writable)
+	}
+	store, err := newObjectStore(pool)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := store.List(ctx, "", "/", "", 1); err != nil {
+		return err
+	}
+	if !writable {
+		return nil
+	}
+	key := fmt.Sprintf(".webdav-server-selftest-%d", time.Now().UnixNano())
+	if err := store.Put(ctx, key, strings.NewReader(""), 0); err != nil {
+		return fmt.Errorf("not writable: %w", err)
+	}
+	return store.Delete(ctx, key)
+}
+
+// checkRemoteDav 检查远程 WebDAV 根目录可访问，且在需要时可以写入
+func checkRemoteDav(pool ConfigPool, writable bool) error {
+	remoteFs, err := newRemoteDavFs(pool)
+	if err != nil {
+		return err
+	}
+	stat, err := remoteFs.Stat("/")
+	if err != nil {
+		return err
+	}
+	if !stat.IsDir() {
+		return fmt.Errorf("%s is not a directory", pool.remoteLocation())
+	}
+	if !writable {
+		return nil
+	}
+	name := fmt.Sprintf("/.webdav-server-selftest-%d", time.Now().UnixNano())
+	if err := afero.WriteFile(remoteFs, name, nil, 0o644); err != nil {
+		return fmt.Errorf("not writable: %w", err)
+	}
+	return remoteFs.Remove(name)
+}
+
+// hasWriter 判断是否有用户拥有写权限
+func (p ConfigPool) hasWriter() bool {
+	if p.DefaultPerm.IsWrite() {
+		return true
+	}
+	for _, perm := range p.Permissions {
+		if perm.IsWrite() {
+			return true
+		}
+	}
+	return false
+}
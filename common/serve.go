@@ -0,0 +1,43 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// NewServeConfig 生成单目录模式的配置：目录作为唯一存储池，
+// users 为 name:password 列表，拥有读写权限，guest 使用 guestPerm
+func NewServeConfig(dir, bind string, users []string, guestPerm FilePerm) (*Config, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	poolName := filepath.Base(abs)
+	if !nameRegexp.MatchString(poolName) {
+		poolName = "data"
+	}
+	pool := ConfigPool{
+		Path:        abs,
+		Permissions: make(map[string]FilePerm),
+		DefaultPerm: guestPerm,
+	}
+	cfg := &Config{
+		Bind:   bind,
+		Pools:  map[string]ConfigPool{poolName: pool},
+		Users:  make(map[string]ConfigUser),
+		Webdav: ConfigWebdav{Enabled: true, Prefix: "/dav"},
+	}
+	for _, item := range users {
+		name, password, ok := strings.Cut(item, ":")
+		if !ok || name == "" || password == "" {
+			return nil, fmt.Errorf("invalid user %q, expected name:password", item)
+		}
+		cfg.Users[name] = ConfigUser{Password: password}
+		pool.Permissions[name] = "rw"
+	}
+	if err = cfg.init(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,237 @@
+package common
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+)
+
+// shareCookiePrefix 分享链接访问凭据的签名前缀，用户名不能包含 ":"，凭据不能用作登录会话
+const shareCookiePrefix = "share:"
+
+// Share 分享链接，Path 为创建者文件系统中的路径
+type Share struct {
+	Token   string    `json:"token"`
+	Owner   string    `json:"owner"`
+	Path    string    `json:"path"`
+	IsDir   bool      `json:"is_dir"`
+	Created time.Time `json:"created"`
+	// 过期时间，为零时永不过期
+	Expires time.Time `json:"expires,omitzero"`
+	// 访问密码的 argon2id 哈希，为空时无需密码
+	Password string `json:"password,omitempty"`
+	// 只允许下载，文件以附件发送，不在浏览器中打开
+	DownloadOnly bool `json:"download_only,omitempty"`
+}
+
+// Expired 判断分享链接是否已过期
+func (s Share) Expired(now time.Time) bool {
+	return !s.Expires.IsZero() && !now.Before(s.Expires)
+}
+
+// ShareStore 保存分享链接，配置 file 时写入 JSON 文件，重启后仍然有效
+type ShareStore struct {
+	file string
+
+	mu     sync.Mutex
+	shares map[string]Share
+}
+
+// NewShareStore 创建分享链接的存储，file 为空时只保存在内存中
+func NewShareStore(file string) (*ShareStore, error) {
+	s := &ShareStore{file: file, shares: make(map[string]Share)}
+	if file == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	var shares []Share
+	if err := json.Unmarshal(data, &shares); err != nil {
+		return nil, fmt.Errorf("invalid share file %s: %w", file, err)
+	}
+	for _, share := range shares {
+		s.shares[share.Token] = share
+	}
+	return s, nil
+}
+
+// hashSharePassword 以 argon2id 计算访问密码的哈希，格式与用户密码相同
+func hashSharePassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	const memory, iterations, parallelism = 19 * 1024, 2, 1
+	hash := argon2.IDKey([]byte(password), salt, iterations, memory, parallelism, 32)
+	return fmt.Sprintf("argon2id:$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, memory, iterations, parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(hash)), nil
+}
+
+// Create 创建分享链接，password 为空时无需密码，ttl 为 0 时永不过期
+func (s *ShareStore) Create(owner, name string, isDir bool, ttl time.Duration, password string, downloadOnly bool) (Share, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return Share{}, err
+	}
+	now := time.Now()
+	share := Share{
+		Token:        base64.RawURLEncoding.EncodeToString(token),
+		Owner:        owner,
+		Path:         mergefs.NormalizePath(name),
+		IsDir:        isDir,
+		Created:      now,
+		DownloadOnly: downloadOnly,
+	}
+	if ttl > 0 {
+		share.Expires = now.Add(ttl)
+	}
+	if password != "" {
+		hashed, err := hashSharePassword(password)
+		if err != nil {
+			return Share{}, err
+		}
+		share.Password = hashed
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shares[share.Token] = share
+	if err := s.save(); err != nil {
+		delete(s.shares, share.Token)
+		return Share{}, err
+	}
+	return share, nil
+}
+
+// Get 返回未过期的分享链接
+func (s *ShareStore) Get(token string) (Share, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	share, ok := s.shares[token]
+	if !ok || share.Expired(time.Now()) {
+		return Share{}, false
+	}
+	return share, true
+}
+
+// List 返回用户在目录 dir 中创建的未过期分享链接，最新的在前
+func (s *ShareStore) List(owner, dir string) []Share {
+	dir = mergefs.NormalizePath(dir)
+	now := time.Now()
+	s.mu.Lock()
+	result := make([]Share, 0)
+	for _, share := range s.shares {
+		if share.Owner == owner && path.Dir(share.Path) == dir && !share.Expired(now) {
+			result = append(result, share)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Created.After(result[j].Created)
+	})
+	return result
+}
+
+// Remove 删除用户创建的分享链接，链接不存在或不属于该用户时返回 os.ErrNotExist
+func (s *ShareStore) Remove(owner, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	share, ok := s.shares[token]
+	if !ok || share.Owner != owner {
+		return &os.PathError{Op: "unshare", Path: token, Err: os.ErrNotExist}
+	}
+	delete(s.shares, token)
+	if err := s.save(); err != nil {
+		s.shares[token] = share
+		return err
+	}
+	return nil
+}
+
+// save 写入分享链接，过期的链接被丢弃。先写入临时文件再重命名，中断时不会损坏已有的文件
+func (s *ShareStore) save() error {
+	now := time.Now()
+	shares := make([]Share, 0, len(s.shares))
+	for token, share := range s.shares {
+		if share.Expired(now) {
+			delete(s.shares, token)
+			continue
+		}
+		shares = append(shares, share)
+	}
+	if s.file == "" {
+		return nil
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		return shares[i].Created.Before(shares[j].Created)
+	})
+	data, err := json.MarshalIndent(shares, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(s.file), ".shares-*")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), s.file)
+	}
+	if err != nil {
+		_ = os.Remove(temp.Name())
+	}
+	return err
+}
+
+// CheckPassword 校验分享链接的访问密码，未设置密码时总是成功
+func (s Share) CheckPassword(password string) bool {
+	return s.Password == "" || verifyPassword(s.Password, password)
+}
+
+// SignShare 返回输入密码后访问分享链接的凭据，有效期与登录会话相同
+func (c *FsContext) SignShare(share Share) string {
+	return c.SignToken(shareCookiePrefix + share.Token)
+}
+
+// VerifyShare 校验访问分享链接的凭据
+func (c *FsContext) VerifyShare(share Share, value string) bool {
+	subject, err := c.VerifyToken(value)
+	return err == nil && subject == shareCookiePrefix+share.Token
+}
+
+// ShareFS 返回分享链接指向的只读文件系统，创建者已被删除时返回错误
+func (c *FsContext) ShareFS(share Share) (*AuthFS, error) {
+	userFs, ok := c.users[share.Owner]
+	if !ok || share.Owner == "guest" {
+		return nil, errors.Wrapf(NoAuthorizedError, "share owner %s not found", share.Owner)
+	}
+	return &AuthFS{User: share.Owner, Fs: mergefs.NewReadOnlyFs(userFs)}, nil
+}
+
+// Resolve 返回分享链接中的子路径在创建者文件系统中的路径，子路径不能越过分享的目录，分享文件时总是返回该文件
+func (s Share) Resolve(sub string) string {
+	sub = mergefs.NormalizePath("/" + sub)
+	if !s.IsDir || sub == "/" {
+		return s.Path
+	}
+	return path.Join(s.Path, sub)
+}
@@ -0,0 +1,47 @@
+package common
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShareStore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "shares.json")
+	store, err := NewShareStore(file)
+	assert.NoError(t, err)
+	share, err := store.Create("alice", "/data/docs", true, 0, "secret", false)
+	assert.NoError(t, err)
+	expired, err := store.Create("alice", "/data/old.txt", false, time.Nanosecond, "", false)
+	assert.NoError(t, err)
+	_, err = store.Create("bob", "/data/b.txt", false, time.Hour, "", true)
+	assert.NoError(t, err)
+
+	assert.True(t, share.CheckPassword("secret"))
+	assert.False(t, share.CheckPassword("wrong"))
+	_, ok := store.Get(expired.Token)
+	assert.False(t, ok, "过期的链接不可访问")
+	assert.Len(t, store.List("alice", "/data"), 1, "只列出用户在该目录中未过期的链接")
+
+	reloaded, err := NewShareStore(file)
+	assert.NoError(t, err)
+	loaded, ok := reloaded.Get(share.Token)
+	assert.True(t, ok, "重启后链接仍然有效")
+	assert.True(t, loaded.CheckPassword("secret"))
+
+	assert.Error(t, reloaded.Remove("bob", share.Token), "不能删除其他用户的链接")
+	assert.NoError(t, reloaded.Remove("alice", share.Token))
+	_, ok = reloaded.Get(share.Token)
+	assert.False(t, ok)
+}
+
+func TestShareResolve(t *testing.T) {
+	dir := Share{Path: "/data/docs", IsDir: true}
+	assert.Equal(t, "/data/docs/a/b.txt", dir.Resolve("/a/b.txt"))
+	assert.Equal(t, "/data/docs/x", dir.Resolve("../../x"), "子路径不能越过分享的目录")
+	assert.Equal(t, "/data/docs", dir.Resolve(""))
+	file := Share{Path: "/data/a.txt"}
+	assert.Equal(t, "/data/a.txt", file.Resolve("/other"))
+}
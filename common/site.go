@@ -0,0 +1,104 @@
+package common
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+)
+
+// reservedSitePaths 网站前缀不能占用的页面路径
+var reservedSitePaths = []string{"/preview", "/thumb", "/s", "/static", "/login", "/logout", "/version", "/branding"}
+
+// pathOverlaps 判断两个路径前缀是否相同或一个位于另一个之下
+func pathOverlaps(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
+
+// checkSites 检查存储池的静态网站配置，规范化前缀、域名与 404 页面
+func (c *Config) checkSites() error {
+	reserved := append([]string{}, reservedSitePaths...)
+	if c.Webdav.Enabled {
+		reserved = append(reserved, c.Webdav.Prefix)
+	}
+	if c.Metrics.Enabled {
+		reserved = append(reserved, c.Metrics.Path)
+	}
+	prefixes := make(map[string]string)
+	hosts := make(map[string]string)
+	for poolName, pool := range c.Pools {
+		site := &pool.Site
+		if !site.Enabled {
+			continue
+		}
+		if site.Prefix == "" && site.Host == "" {
+			return fmt.Errorf("site needs a prefix or a host: %s", poolName)
+		}
+		if site.Prefix != "" {
+			site.Prefix = "/" + strings.Trim(strings.TrimSpace(site.Prefix), "/")
+			if site.Prefix == "/" {
+				return fmt.Errorf("site prefix cannot be '/', use host instead: %s", poolName)
+			}
+			for _, other := range reserved {
+				if pathOverlaps(site.Prefix, other) {
+					return fmt.Errorf("site prefix %s conflicts with %s: %s", site.Prefix, other, poolName)
+				}
+			}
+			for other, otherPool := range prefixes {
+				if pathOverlaps(site.Prefix, other) {
+					return fmt.Errorf("site prefix %s conflicts with pool %s: %s", site.Prefix, otherPool, poolName)
+				}
+			}
+			prefixes[site.Prefix] = poolName
+		}
+		if site.Host != "" {
+			site.Host = normalizeHost(site.Host)
+			if _, ok := c.Webdav.Hosts[site.Host]; ok {
+				return fmt.Errorf("site host %s is also a webdav host: %s", site.Host, poolName)
+			}
+			if otherPool, ok := hosts[site.Host]; ok {
+				return fmt.Errorf("site host %s is used by pool %s: %s", site.Host, otherPool, poolName)
+			}
+			hosts[site.Host] = poolName
+		}
+		if site.NotFound != "" {
+			site.NotFound = path.Clean("/" + site.NotFound)
+		}
+		if site.MaxAge == 0 {
+			site.MaxAge = 5 * time.Minute
+		}
+		c.Pools[poolName] = pool
+	}
+	return nil
+}
+
+// SiteHost 返回以 Host 访问的网站所属的存储池，没有对应的网站时返回 false
+func (c *Config) SiteHost(host string) (string, bool) {
+	host = normalizeHost(host)
+	for poolName, pool := range c.Pools {
+		if pool.Site.Enabled && pool.Site.Host == host {
+			return poolName, true
+		}
+	}
+	return "", false
+}
+
+// newSiteFs 返回网站的只读文件系统，根目录为 site.root。点文件、hide.names 与 exclude 匹配的文件
+// 对访客始终不可见，即使存储池允许通过完整路径读取
+func newSiteFs(poolFs afero.Fs, pool ConfigPool) afero.Fs {
+	hidden := mergefs.NewHiddenFs(poolFs, mergefs.HiddenOptions{
+		Dotfiles: true,
+		Names:    pool.Hide.Names,
+		Exclude:  pool.Exclude,
+	})
+	return mergefs.NewReadOnlyFs(mergefs.NewSubFs(hidden, pool.Site.Root))
+}
+
+// SiteFS 返回存储池网站的文件系统，存储池未启用网站时返回 false
+func (c *FsContext) SiteFS(poolName string) (afero.Fs, bool) {
+	fs, ok := c.sites[poolName]
+	return fs, ok
+}
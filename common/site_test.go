@@ -0,0 +1,50 @@
+package common
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSiteConfig(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "public", ".git"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "public", "index.html"), []byte("home"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "public", ".git", "config"), []byte("secret"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "private.txt"), []byte("private"), 0o644))
+	newConfig := func(site ConfigPoolSite) *Config {
+		return &Config{
+			Bind:  "127.0.0.1:0",
+			Pools: map[string]ConfigPool{"docs": {Path: dir, Site: site}},
+			Users: map[string]ConfigUser{"alice": {Password: "alice"}},
+		}
+	}
+	cfg := newConfig(ConfigPoolSite{Enabled: true, Prefix: "docs/", Host: "Docs.Example.com.", Root: "public"})
+	assert.NoError(t, cfg.init())
+	site := cfg.Pools["docs"].Site
+	assert.Equal(t, "/docs", site.Prefix)
+	assert.Equal(t, 5*time.Minute, site.MaxAge)
+	poolName, ok := cfg.SiteHost("docs.example.com:8080")
+	assert.True(t, ok)
+	assert.Equal(t, "docs", poolName)
+
+	ctx, err := NewContext(context.Background(), cfg)
+	assert.NoError(t, err)
+	fs, ok := ctx.SiteFS("docs")
+	assert.True(t, ok)
+	_, err = fs.Stat("/index.html")
+	assert.NoError(t, err)
+	_, err = fs.Stat("/.git/config")
+	assert.Error(t, err, "点文件不公开")
+	_, err = fs.Stat("/../private.txt")
+	assert.Error(t, err, "不能访问网站根目录之外的文件")
+
+	for _, prefix := range []string{"/preview", "/static/docs", "/"} {
+		assert.ErrorContains(t, newConfig(ConfigPoolSite{Enabled: true, Prefix: prefix}).init(), "site prefix", "与本站页面冲突的前缀")
+	}
+	assert.ErrorContains(t, newConfig(ConfigPoolSite{Enabled: true}).init(), "prefix or a host")
+}
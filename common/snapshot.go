@@ -0,0 +1,175 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+)
+
+var snapshotNameRegexp = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._-]*$`)
+
+// snapshotRefreshInterval 重新扫描快照目录的间隔，命令行创建或删除的快照在此时间内生效
+const snapshotRefreshInterval = time.Minute
+
+// snapshotPool 返回启用快照的存储池配置
+func snapshotPool(cfg *Config, poolName string) (ConfigPool, error) {
+	pool, ok := cfg.Pools[poolName]
+	if !ok {
+		return pool, fmt.Errorf("pool does not exist: %s", poolName)
+	}
+	if pool.Snapshots.Dir == "" {
+		return pool, fmt.Errorf("snapshots is not enabled: %s", poolName)
+	}
+	return pool, nil
+}
+
+// ListSnapshots 列出存储池的快照名称
+func ListSnapshots(cfg *Config, poolName string) ([]string, error) {
+	pool, err := snapshotPool(cfg, poolName)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(pool.Snapshots.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() && snapshotNameRegexp.MatchString(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// CreateSnapshot 创建存储池的快照，先写入临时目录，完成后再重命名，避免挂载不完整的快照
+func CreateSnapshot(cfg *Config, poolName, name string) error {
+	pool, err := snapshotPool(cfg, poolName)
+	if err != nil {
+		return err
+	}
+	if !snapshotNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name: %s", name)
+	}
+	target := filepath.Join(pool.Snapshots.Dir, name)
+	if _, err := os.Stat(target); err == nil {
+		return fmt.Errorf("snapshot already exists: %s", name)
+	}
+	if err := os.MkdirAll(pool.Snapshots.Dir, 0o755); err != nil {
+		return err
+	}
+	staging := filepath.Join(pool.Snapshots.Dir, ".tmp-"+name)
+	if err := os.RemoveAll(staging); err != nil {
+		return err
+	}
+	result, err := mergefs.Snapshot(pool.Path, staging, mergefs.SnapshotOptions{
+		Link: pool.Snapshots.Link,
+		Skip: []string{path.Base(mergefs.TrashDir), path.Base(mergefs.VersionDir)},
+	})
+	if err == nil {
+		err = os.Rename(staging, target)
+	}
+	if err != nil {
+		_ = os.RemoveAll(staging)
+		return err
+	}
+	slog.Info("|snapshot| Created.", "pool", poolName, "name", name,
+		"reflinked", result.Reflinked, "linked", result.Linked, "copied", result.Copied)
+	return nil
+}
+
+// DeleteSnapshot 删除存储池的快照
+func DeleteSnapshot(cfg *Config, poolName, name string) error {
+	pool, err := snapshotPool(cfg, poolName)
+	if err != nil {
+		return err
+	}
+	if !snapshotNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name: %s", name)
+	}
+	target := filepath.Join(pool.Snapshots.Dir, name)
+	if _, err := os.Stat(target); err != nil {
+		return fmt.Errorf("snapshot does not exist: %s", name)
+	}
+	return os.RemoveAll(target)
+}
+
+// snapshotPrefix 快照在用户文件系统中的挂载点前缀，存储池名称中不允许出现 @
+func snapshotPrefix(poolName string) string {
+	return "/" + poolName + "@"
+}
+
+// refreshSnapshots 按快照目录的内容为有读取权限的用户挂载新快照并卸载已删除的快照
+func (c *FsContext) refreshSnapshots() {
+	for poolName, pool := range c.Config.Pools {
+		if pool.Snapshots.Dir == "" {
+			continue
+		}
+		names, err := ListSnapshots(c.Config, poolName)
+		if err != nil {
+			slog.Warn("|snapshot| List failed.", "pool", poolName, "err", err)
+			continue
+		}
+		for userName, userFs := range c.users {
+			mountFs, ok := userFs.(*mergefs.MountFs)
+			if !ok || !pool.permission(userName).IsRead() {
+				continue
+			}
+			mounted := make(map[string]bool)
+			for _, mount := range mountFs.ListMounts() {
+				name, ok := strings.CutPrefix(mount.Prefix, snapshotPrefix(poolName))
+				if !ok {
+					continue
+				}
+				if slices.Contains(names, name) {
+					mounted[name] = true
+				} else {
+					mountFs.Unmount(mount.Prefix)
+				}
+			}
+			for _, name := range names {
+				if mounted[name] {
+					continue
+				}
+				snapshotFs := mergefs.NewOsPathFs(filepath.Join(pool.Snapshots.Dir, name), mergefs.SymlinkPolicy(pool.Symlinks))
+				if err := mountFs.Mount(snapshotPrefix(poolName)+name, newHiddenFs(afero.NewReadOnlyFs(snapshotFs), pool)); err != nil {
+					slog.Warn("|snapshot| Mount failed.", "pool", poolName, "name", name, "err", err)
+				}
+			}
+		}
+	}
+	c.resetScoped()
+}
+
+// refreshSnapshotsLoop 定期刷新快照挂载
+func (c *FsContext) refreshSnapshotsLoop(ctx context.Context) {
+	if !slices.ContainsFunc(slices.Collect(maps.Values(c.Config.Pools)), func(pool ConfigPool) bool {
+		return pool.Snapshots.Dir != ""
+	}) {
+		return
+	}
+	ticker := time.NewTicker(snapshotRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.refreshSnapshots()
+		}
+	}
+}
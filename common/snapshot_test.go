@@ -0,0 +1,40 @@
+package common
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotMount(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("v1"), 0o644))
+	cfg := &Config{
+		Bind: "127.0.0.1:0",
+		Pools: map[string]ConfigPool{
+			"data": {Path: dir, DefaultPerm: "rw", Snapshots: ConfigPoolSnapshots{Dir: t.TempDir()}},
+		},
+	}
+	assert.NoError(t, cfg.init())
+	assert.NoError(t, CreateSnapshot(cfg, "data", "day1"))
+	assert.Error(t, CreateSnapshot(cfg, "data", "day1"), "快照已存在")
+	assert.Error(t, CreateSnapshot(cfg, "data", "../day2"), "快照名称非法")
+
+	ctx, err := NewContext(context.Background(), cfg)
+	assert.NoError(t, err)
+	userFs := ctx.LoadUserFS("guest")
+	assert.NoError(t, afero.WriteFile(userFs, "/data/a.txt", []byte("v2"), 0o644))
+	data, err := afero.ReadFile(userFs, "/data@day1/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", string(data))
+	assert.Error(t, afero.WriteFile(userFs, "/data@day1/a.txt", []byte("v3"), 0o644), "快照只读")
+
+	assert.NoError(t, DeleteSnapshot(cfg, "data", "day1"))
+	ctx.refreshSnapshots()
+	_, err = userFs.Stat("/data@day1")
+	assert.True(t, os.IsNotExist(err), "删除的快照被卸载")
+}
@@ -0,0 +1,52 @@
+package common
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// 构建信息，通过 -ldflags "-X code.d7z.net/packages/webdav-server/common.Version=..." 注入
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// GetBuildInfo 返回构建信息，未注入时尝试从 Go 模块的 VCS 信息中补全
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+	}
+	return info
+}
+
+// SSHServerVersion 返回 SSH 服务端版本标识，软件版本中不允许出现空格与 '-'
+func SSHServerVersion() string {
+	v := strings.NewReplacer(" ", "_", "-", "_").Replace(Version)
+	return "SSH-2.0-WebdavServer_" + v
+}
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+)
+
+// normalizeHost 去除 Host 中的端口与结尾的点，并转为小写
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(strings.Trim(host, "[]")), ".")
+}
+
+// WebdavHost 返回请求的 Host 对应的站点名称，未配置站点时返回 false
+func (c *Config) WebdavHost(host string) (string, bool) {
+	host = normalizeHost(host)
+	_, ok := c.Webdav.Hosts[host]
+	return host, ok
+}
+
+// ScopedFS 返回用户在 Host 站点中的文件系统，只包含站点配置的存储池及其快照。
+// 挂载的是用户文件系统中的同一实例，缓存与写入检查共享；结果被缓存，快照变化时重新构建
+func (c *FsContext) ScopedFS(username, host string) afero.Fs {
+	key := username + "\x00" + host
+	c.scopedMu.Lock()
+	defer c.scopedMu.Unlock()
+	if fs, ok := c.scoped[key]; ok {
+		return fs
+	}
+	site := c.Config.Webdav.Hosts[host]
+	baseFS := afero.NewMemMapFs()
+	_ = afero.WriteFile(baseFS, "/README.txt", []byte(fmt.Sprintf("欢迎你,%s", username)), 0o644)
+	scoped := mergefs.NewMountFs(afero.NewReadOnlyFs(baseFS))
+	if userFs, ok := c.users[username].(*mergefs.MountFs); ok {
+		for _, mount := range userFs.ListMounts() {
+			poolName, _, _ := strings.Cut(strings.TrimPrefix(mount.Prefix, "/"), "@")
+			if !slices.Contains(site.Pools, poolName) {
+				continue
+			}
+			if err := scoped.Mount(mount.Prefix, mount.Fs); err != nil {
+				continue
+			}
+			scoped.SetExdev(mount.Prefix, c.Config.Pools[poolName].Exdev)
+		}
+	}
+	if c.scoped == nil {
+		c.scoped = make(map[string]afero.Fs)
+	}
+	c.scoped[key] = scoped
+	return scoped
+}
+
+// resetScoped 清除缓存的站点文件系统，用户文件系统的挂载变化后调用
+func (c *FsContext) resetScoped() {
+	c.scopedMu.Lock()
+	defer c.scopedMu.Unlock()
+	c.scoped = nil
+}
@@ -0,0 +1,176 @@
+package dav
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"hash/adler32"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+)
+
+// errChecksumMismatch 上传的内容与客户端提供的校验和不一致
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// checksumsProp ownCloud / Nextcloud 客户端读取的校验和属性
+const checksumsProp = "checksums"
+
+const ownCloudNamespace = "http://owncloud.org/ns"
+
+// Checksums 文件的校验和，十六进制小写
+type Checksums struct {
+	SHA1    string
+	MD5     string
+	Adler32 string
+}
+
+// String 返回 OC-Checksum 的格式，多个值以空格分隔
+func (c Checksums) String() string {
+	return "SHA1:" + c.SHA1 + " MD5:" + c.MD5 + " ADLER32:" + c.Adler32
+}
+
+// get 返回指定算法的校验和，算法不支持时返回 false
+func (c Checksums) get(algorithm string) (string, bool) {
+	switch strings.ToUpper(algorithm) {
+	case "SHA1":
+		return c.SHA1, true
+	case "MD5":
+		return c.MD5, true
+	case "ADLER32":
+		return c.Adler32, true
+	}
+	return "", false
+}
+
+// checksumHasher 一次读取同时计算所有支持的校验和
+type checksumHasher struct {
+	sha1, md5 hash.Hash
+	adler32   hash.Hash32
+}
+
+func newChecksumHasher() *checksumHasher {
+	return &checksumHasher{sha1: sha1.New(), md5: md5.New(), adler32: adler32.New()}
+}
+
+func (h *checksumHasher) Write(p []byte) (int, error) {
+	h.sha1.Write(p)
+	h.md5.Write(p)
+	h.adler32.Write(p)
+	return len(p), nil
+}
+
+func (h *checksumHasher) Sum() Checksums {
+	return Checksums{
+		SHA1:    hex.EncodeToString(h.sha1.Sum(nil)),
+		MD5:     hex.EncodeToString(h.md5.Sum(nil)),
+		Adler32: hex.EncodeToString(h.adler32.Sum(nil)),
+	}
+}
+
+// expectedChecksums 返回上传请求中 OC-Checksum（如 SHA1:hex）与 Content-MD5（base64）声明的校验和，
+// 键为大写的算法名，不支持的算法被忽略
+func expectedChecksums(request *http.Request) (map[string]string, error) {
+	expected := make(map[string]string)
+	if value := strings.TrimSpace(request.Header.Get("OC-Checksum")); value != "" {
+		algorithm, sum, ok := strings.Cut(value, ":")
+		if !ok {
+			return nil, errChecksumMismatch
+		}
+		if _, supported := (Checksums{}).get(algorithm); supported {
+			expected[strings.ToUpper(algorithm)] = strings.ToLower(strings.TrimSpace(sum))
+		}
+	}
+	if value := strings.TrimSpace(request.Header.Get("Content-MD5")); value != "" {
+		sum, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(sum) != md5.Size {
+			return nil, errChecksumMismatch
+		}
+		expected["MD5"] = hex.EncodeToString(sum)
+	}
+	return expected, nil
+}
+
+// verify 比较计算的校验和与上传时声明的校验和
+func (c Checksums) verify(expected map[string]string) bool {
+	for algorithm, sum := range expected {
+		if actual, _ := c.get(algorithm); actual != sum {
+			return false
+		}
+	}
+	return true
+}
+
+// ChecksumStore 缓存文件的校验和，大小或修改时间变化后失效
+type ChecksumStore struct {
+	// maxSize 按需计算校验和的最大文件大小，更大的文件只返回上传时计算的值
+	maxSize int64
+	cache   *mergefs.HashCache[Checksums]
+}
+
+func NewChecksumStore(maxSize int64, maxEntries int) *ChecksumStore {
+	return &ChecksumStore{maxSize: maxSize, cache: mergefs.NewHashCache[Checksums](max(maxEntries, 1), nil)}
+}
+
+// Put 保存已计算的校验和，如上传时边接收边计算的结果
+func (s *ChecksumStore) Put(key string, info os.FileInfo, sums Checksums) {
+	s.cache.Put(key, info.Size(), info.ModTime().UnixNano(), sums)
+}
+
+// Get 返回文件的校验和，未缓存时 compute 为 true 且文件不超过大小上限则读取文件计算
+func (s *ChecksumStore) Get(ctx context.Context, fs afero.Fs, key, name string, info os.FileInfo, compute bool) (Checksums, bool) {
+	if info.IsDir() {
+		return Checksums{}, false
+	}
+	if sums, ok := s.cache.Get(key, info.Size(), info.ModTime().UnixNano()); ok {
+		return sums, true
+	}
+	if !compute || info.Size() > s.maxSize {
+		return Checksums{}, false
+	}
+	file, err := fs.Open(name)
+	if err != nil {
+		return Checksums{}, false
+	}
+	defer file.Close()
+	hasher := newChecksumHasher()
+	if _, err := io.Copy(hasher, mergefs.NewContextReader(ctx, file)); err != nil {
+		return Checksums{}, false
+	}
+	sums := hasher.Sum()
+	s.Put(key, info, sums)
+	return sums, true
+}
+
+// checksumRequestKey PROPFIND 请求了 oc:checksums 属性，此时才按需计算校验和
+type checksumRequestKey struct{}
+
+// withChecksumRequest 读取 PROPFIND 请求体判断是否请求了校验和，请求体随后原样交给 webdav.Handler
+func withChecksumRequest(request *http.Request) *http.Request {
+	if request.Method != "PROPFIND" || request.Body == nil {
+		return request
+	}
+	body, err := io.ReadAll(io.LimitReader(request.Body, maxReportBody))
+	if err != nil {
+		return request
+	}
+	request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), request.Body))
+	if !bytes.Contains(body, []byte(ownCloudNamespace)) || !bytes.Contains(body, []byte(checksumsProp)) {
+		return request
+	}
+	return request.WithContext(context.WithValue(request.Context(), checksumRequestKey{}, true))
+}
+
+func isChecksumRequest(ctx context.Context) bool {
+	requested, _ := ctx.Value(checksumRequestKey{}).(bool)
+	return requested
+}
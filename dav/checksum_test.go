@@ -0,0 +1,65 @@
+package dav
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+func TestChecksums(t *testing.T) {
+	const (
+		sha1Hello = "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"
+		md5Hello  = "5d41402abc4b2a76b9719d911017c592"
+	)
+	upload := func(headers map[string]string) error {
+		req := httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("hello"))
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		expected, err := expectedChecksums(req)
+		if err != nil {
+			return err
+		}
+		var cause error
+		body := &uploadBody{ReadCloser: req.Body, cancel: func(err error) { cause = err }, expected: expected, hasher: newChecksumHasher()}
+		_, err = io.ReadAll(body)
+		assert.Equal(t, err, cause, "校验失败时取消上传")
+		return err
+	}
+	assert.NoError(t, upload(map[string]string{"OC-Checksum": "SHA1:" + strings.ToUpper(sha1Hello)}))
+	assert.NoError(t, upload(map[string]string{"Content-MD5": "XUFAKrxLKna5cZ2REBfFkg=="}))
+	assert.ErrorIs(t, upload(map[string]string{"OC-Checksum": "MD5:" + sha1Hello}), errChecksumMismatch)
+	assert.NoError(t, upload(map[string]string{"OC-Checksum": "SHA512:unknown"}), "忽略不支持的算法")
+
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("hello"), 0o644))
+	store := NewChecksumStore(1024, 16)
+	davFs := NewWebdavFS(mergefs.NewMountFs(fs))
+	davFs.Checksums = func(ctx context.Context, name string, info os.FileInfo) (Checksums, bool) {
+		return store.Get(ctx, fs, name, name, info, isChecksumRequest(ctx))
+	}
+	handler := &webdav.Handler{FileSystem: davFs, LockSystem: webdav.NewMemLS()}
+	propfind := func(prop string) string {
+		req := httptest.NewRequest("PROPFIND", "/a.txt", strings.NewReader(`<?xml version="1.0"?>
+<d:propfind xmlns:d="DAV:" xmlns:oc="http://owncloud.org/ns"><d:prop>`+prop+`</d:prop></d:propfind>`))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, withChecksumRequest(req))
+		return rec.Body.String()
+	}
+	assert.Contains(t, propfind("<oc:checksums/>"), "SHA1:"+sha1Hello+" MD5:"+md5Hello+" ADLER32:062c0215")
+	info, err := fs.Stat("/a.txt")
+	assert.NoError(t, err)
+	_, ok := store.Get(context.Background(), fs, "/a.txt", "/a.txt", info, false)
+	assert.True(t, ok, "计算后缓存")
+	_, ok = NewChecksumStore(1024, 16).Get(context.Background(), fs, "/a.txt", "/a.txt", info, false)
+	assert.False(t, ok, "未请求时不读取文件计算")
+}
@@ -0,0 +1,72 @@
+package dav
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// isConditionalWrite 判断请求是否为需要检查 RFC 9110 前置条件的写入，
+// webdav.Handler 只处理 WebDAV 的 If 头，不检查 If-Match 等条件
+func isConditionalWrite(request *http.Request) bool {
+	switch request.Method {
+	case http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return false
+	}
+	header := request.Header
+	return header.Get("If-Match") != "" || header.Get("If-None-Match") != "" || header.Get("If-Unmodified-Since") != ""
+}
+
+// checkPreconditions 按 RFC 9110 13.2.2 的顺序检查 If-Match、If-Unmodified-Since 与 If-None-Match，
+// 条件不满足时返回 412，避免两个客户端同时编辑时覆盖对方的修改。在读取请求体前调用，客户端无需上传完整内容
+func checkPreconditions(ctx context.Context, request *http.Request, fs webdav.FileSystem, name string) int {
+	info, err := fs.Stat(ctx, name)
+	if err != nil && !os.IsNotExist(err) {
+		return http.StatusInternalServerError
+	}
+	var etag string
+	if info != nil {
+		if etag, err = fileETag(ctx, info); err != nil {
+			return http.StatusInternalServerError
+		}
+	}
+	if value := request.Header.Get("If-Match"); value != "" {
+		if info == nil || !matchETag(value, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if value := request.Header.Get("If-Unmodified-Since"); value != "" && info != nil {
+		if since, err := http.ParseTime(value); err == nil && info.ModTime().Truncate(time.Second).After(since) {
+			return http.StatusPreconditionFailed
+		}
+	}
+	if value := request.Header.Get("If-None-Match"); value != "" && info != nil && matchETag(value, etag, true) {
+		return http.StatusPreconditionFailed
+	}
+	return 0
+}
+
+// matchETag 判断条件头中的 ETag 列表是否包含 etag，* 匹配任意存在的资源。
+// weak 为 false 时使用强比较，弱 ETag 不匹配
+func matchETag(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if after, ok := strings.CutPrefix(tag, "W/"); ok {
+			if !weak {
+				continue
+			}
+			tag = after
+		}
+		if tag == strings.TrimPrefix(etag, "W/") && (weak || !strings.HasPrefix(etag, "W/")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package dav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+func TestCheckPreconditions(t *testing.T) {
+	ctx := context.Background()
+	fs := webdav.NewMemFS()
+	file, err := fs.OpenFile(ctx, "/a.txt", os.O_CREATE|os.O_WRONLY, 0o644)
+	assert.NoError(t, err)
+	_, _ = file.Write([]byte("hello"))
+	assert.NoError(t, file.Close())
+	info, err := fs.Stat(ctx, "/a.txt")
+	assert.NoError(t, err)
+	etag, err := fileETag(ctx, info)
+	assert.NoError(t, err)
+
+	check := func(method, name string, header map[string]string) int {
+		request := httptest.NewRequest(method, name, nil)
+		for key, value := range header {
+			request.Header.Set(key, value)
+		}
+		assert.True(t, isConditionalWrite(request))
+		return checkPreconditions(ctx, request, fs, name)
+	}
+	assert.Equal(t, 0, check(http.MethodPut, "/a.txt", map[string]string{"If-Match": etag}))
+	assert.Equal(t, 0, check(http.MethodDelete, "/a.txt", map[string]string{"If-Match": `"other", ` + etag}))
+	assert.Equal(t, http.StatusPreconditionFailed, check(http.MethodPut, "/a.txt", map[string]string{"If-Match": `"other"`}), "ETag 不一致时拒绝写入")
+	assert.Equal(t, http.StatusPreconditionFailed, check(http.MethodPut, "/a.txt", map[string]string{"If-Match": "W/" + etag}), "If-Match 使用强比较")
+	assert.Equal(t, http.StatusPreconditionFailed, check(http.MethodPut, "/b.txt", map[string]string{"If-Match": "*"}), "文件不存在时 If-Match 不满足")
+
+	assert.Equal(t, http.StatusPreconditionFailed, check(http.MethodPut, "/a.txt", map[string]string{"If-None-Match": "*"}), "文件已存在时不覆盖")
+	assert.Equal(t, 0, check(http.MethodPut, "/b.txt", map[string]string{"If-None-Match": "*"}))
+	assert.Equal(t, http.StatusPreconditionFailed, check(http.MethodPut, "/a.txt", map[string]string{"If-None-Match": "W/" + etag}))
+
+	past := info.ModTime().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	future := info.ModTime().Add(time.Hour).UTC().Format(http.TimeFormat)
+	assert.Equal(t, http.StatusPreconditionFailed, check(http.MethodPut, "/a.txt", map[string]string{"If-Unmodified-Since": past}), "文件在此后修改过")
+	assert.Equal(t, 0, check(http.MethodPut, "/a.txt", map[string]string{"If-Unmodified-Since": future}))
+	assert.Equal(t, 0, check(http.MethodPut, "/a.txt", map[string]string{"If-Match": etag, "If-Unmodified-Since": past}), "存在 If-Match 时忽略 If-Unmodified-Since")
+}
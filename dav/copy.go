@@ -0,0 +1,96 @@
+package dav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"golang.org/x/net/webdav"
+)
+
+// copyRequestKey 标记 COPY 请求，此时 WebdavFS 打开的文件支持服务端复制
+type copyRequestKey struct{}
+
+func withCopyRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, copyRequestKey{}, true)
+}
+
+func isCopyRequest(ctx context.Context) bool {
+	copying, _ := ctx.Value(copyRequestKey{}).(bool)
+	return copying
+}
+
+// wrapCopyFile 在 COPY 请求中标记打开的源文件与目标文件，
+// webdav 逐个文件复制时通过 io.Copy 调用源文件的 WriteTo，从而改为服务端复制
+func wrapCopyFile(ctx context.Context, copier mergefs.Copier, file webdav.File, name string, flag int) webdav.File {
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return &copySourceFile{File: file, ctx: ctx, copier: copier, name: name}
+	}
+	if flag&os.O_TRUNC != 0 {
+		return &copyTargetFile{File: file, name: name}
+	}
+	return file
+}
+
+// copySourceFile COPY 请求中读取的源文件
+type copySourceFile struct {
+	webdav.File
+	ctx    context.Context
+	copier mergefs.Copier
+	name   string
+}
+
+// copyTargetFile COPY 请求中截断写入的目标文件
+type copyTargetFile struct {
+	webdav.File
+	name string
+}
+
+func (f *copySourceFile) DeadProps() (map[xml.Name]webdav.Property, error) {
+	return deadProps(f.File)
+}
+
+func (f *copySourceFile) Patch(patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	return patchProps(f.File, patches)
+}
+
+func (f *copyTargetFile) DeadProps() (map[xml.Name]webdav.Property, error) {
+	return deadProps(f.File)
+}
+
+func (f *copyTargetFile) Patch(patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	return patchProps(f.File, patches)
+}
+
+// WriteTo 写入目标为 COPY 打开的文件且可放弃写入时，优先在服务端复制并放弃该文件，
+// 不支持服务端复制时回退为读取后写入
+func (f *copySourceFile) WriteTo(w io.Writer) (int64, error) {
+	if target, ok := w.(*copyTargetFile); ok {
+		if aborter, ok := aborterOf(target.File); ok {
+			err := f.copier.CopyFile(f.ctx, f.name, target.name)
+			if err == nil {
+				var size int64
+				if info, err := f.File.Stat(); err == nil {
+					size = info.Size()
+				}
+				return size, aborter.Abort()
+			}
+			if !errors.Is(err, mergefs.ErrNoCopy) {
+				return 0, err
+			}
+		}
+	}
+	return io.Copy(w, struct{ io.Reader }{f.File})
+}
+
+// aborterOf 返回可放弃写入的底层文件，跳过保存属性的包装
+func aborterOf(file webdav.File) (mergefs.Aborter, bool) {
+	if f, ok := file.(*propsFile); ok {
+		file = f.File
+	}
+	aborter, ok := file.(mergefs.Aborter)
+	return aborter, ok
+}
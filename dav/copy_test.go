@@ -0,0 +1,70 @@
+package dav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+// copierFs 记录服务端复制次数的内存文件系统
+type copierFs struct {
+	afero.Fs
+	copies int
+}
+
+func (c *copierFs) CopyFile(_ context.Context, src, dst string) error {
+	data, err := afero.ReadFile(c.Fs, src)
+	if err != nil {
+		return err
+	}
+	c.copies++
+	return afero.WriteFile(c.Fs, dst, data, 0o644)
+}
+
+func TestCopyServerSide(t *testing.T) {
+	pool := &copierFs{Fs: afero.NewMemMapFs()}
+	other := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(pool, "/dir/a.txt", []byte("hello"), 0o644))
+	assert.NoError(t, afero.WriteFile(pool, "/dir/b.txt", []byte("world"), 0o644))
+	mountFs := mergefs.NewMountFs(afero.NewMemMapFs())
+	assert.NoError(t, mountFs.Mount("/pool", mergefs.NewAtomicFs(pool)))
+	assert.NoError(t, mountFs.Mount("/other", mergefs.NewAtomicFs(other)))
+	handler := &webdav.Handler{
+		FileSystem: NewWebdavFS(mountFs),
+		LockSystem: webdav.NewMemLS(),
+	}
+	copyTo := func(src, dst string) int {
+		req := httptest.NewRequest("COPY", src, nil)
+		req = req.WithContext(withCopyRequest(req.Context()))
+		req.Header.Set("Destination", dst)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusCreated, copyTo("/pool/dir", "/pool/copy"))
+	assert.Equal(t, 2, pool.copies, "目录中的文件逐个在服务端复制")
+	data, err := afero.ReadFile(pool, "/copy/b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "world", string(data))
+
+	assert.Equal(t, http.StatusNoContent, copyTo("/pool/dir/a.txt", "/pool/copy/b.txt"))
+	data, err = afero.ReadFile(pool, "/copy/b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+	names, err := afero.ReadDir(pool, "/copy")
+	assert.NoError(t, err)
+	assert.Len(t, names, 2, "不应残留上传临时文件")
+
+	assert.Equal(t, http.StatusCreated, copyTo("/pool/dir/a.txt", "/other/a.txt"))
+	assert.Equal(t, 3, pool.copies, "跨存储池时回退为读取后写入")
+	data, err = afero.ReadFile(other, "/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+}
@@ -0,0 +1,57 @@
+package dav
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"golang.org/x/net/webdav"
+)
+
+// isInfinitePropfind 判断是否为 Depth: infinity 的 PROPFIND，未指定 Depth 时等同于 infinity
+func isInfinitePropfind(request *http.Request) bool {
+	if request.Method != "PROPFIND" {
+		return false
+	}
+	depth := strings.TrimSpace(request.Header.Get("Depth"))
+	return depth == "" || strings.EqualFold(depth, "infinity")
+}
+
+// depthLimitKey 限制 Depth: infinity PROPFIND 遍历的层数
+type depthLimitKey struct{}
+
+type depthLimit struct {
+	root     string
+	maxDepth int
+}
+
+func withDepthLimit(ctx context.Context, root string, maxDepth int) context.Context {
+	return context.WithValue(ctx, depthLimitKey{}, depthLimit{root: mergefs.NormalizePath(root), maxDepth: maxDepth})
+}
+
+// limitDepth 超过层数限制的目录不再列出子项，webdav 遍历时将其视为没有内容的目录
+func limitDepth(ctx context.Context, file webdav.File, name string) webdav.File {
+	limit, ok := ctx.Value(depthLimitKey{}).(depthLimit)
+	if !ok {
+		return file
+	}
+	rel := strings.Trim(strings.TrimPrefix(mergefs.NormalizePath(name), limit.root), "/")
+	depth := 0
+	if rel != "" {
+		depth = strings.Count(rel, "/") + 1
+	}
+	if depth < limit.maxDepth {
+		return file
+	}
+	return &depthLimitedFile{File: file}
+}
+
+type depthLimitedFile struct {
+	webdav.File
+}
+
+func (f *depthLimitedFile) Readdir(int) ([]os.FileInfo, error) {
+	return nil, nil
+}
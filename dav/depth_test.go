@@ -0,0 +1,37 @@
+package dav
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+func TestDepthLimit(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/a/b/c/d.txt", []byte("d"), 0o644))
+	handler := &webdav.Handler{
+		FileSystem: NewWebdavFS(mergefs.NewMountFs(fs)),
+		LockSystem: webdav.NewMemLS(),
+	}
+	propfind := func(maxDepth int) string {
+		req := httptest.NewRequest("PROPFIND", "/a", nil)
+		assert.True(t, isInfinitePropfind(req), "未指定 Depth 时等同于 infinity")
+		if maxDepth > 0 {
+			req = req.WithContext(withDepthLimit(req.Context(), "/a", maxDepth))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		return rec.Body.String()
+	}
+
+	assert.Contains(t, propfind(0), "/a/b/c/d.txt", "不限制时遍历全部层级")
+	body := propfind(1)
+	assert.Contains(t, body, "/a/b/")
+	assert.NotContains(t, body, "/a/b/c/", "超过层数的目录不列出子项")
+}
@@ -0,0 +1,185 @@
+package dav
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+	"golang.org/x/net/webdav"
+)
+
+// ETag 的计算方式
+const (
+	// ETagMtime 修改时间与大小，与 webdav 默认相同
+	ETagMtime = "mtime"
+	// ETagHash 修改时间与大小的哈希
+	ETagHash = "hash"
+	// ETagContent 文件内容的 SHA-256，修改时间不可靠（如网络文件系统）时也能准确反映内容变化
+	ETagContent = "content"
+)
+
+// ETagOptions ETag 的计算选项
+type ETagOptions struct {
+	Mode string
+	// Cache content 模式下保存已计算哈希的文件，为空时仅缓存在内存中
+	Cache string
+	// MaxSize content 模式下计算内容哈希的最大文件大小，更大的文件使用 hash 模式
+	MaxSize int64
+}
+
+// ETagger 计算并缓存文件的 ETag
+type ETagger struct {
+	opts ETagOptions
+
+	cache *mergefs.HashCache[string]
+
+	mu      sync.Mutex
+	journal *os.File
+}
+
+// etagEntry 缓存的内容哈希，大小或修改时间变化后失效
+type etagEntry struct {
+	Key     string `json:"k"`
+	Size    int64  `json:"s"`
+	ModTime int64  `json:"m"`
+	ETag    string `json:"e"`
+}
+
+// NewETagger 创建 ETagger，content 模式下从 Cache 加载已计算的哈希，并整理掉其中重复的记录。
+// 缓存文件无法读写时返回的 ETagger 仍然可用，计算的哈希只保存在内存中
+func NewETagger(opts ETagOptions) (*ETagger, error) {
+	e := &ETagger{opts: opts, cache: mergefs.NewHashCache[string](0, nil)}
+	if opts.Mode != ETagContent || opts.Cache == "" {
+		return e, nil
+	}
+	if err := e.load(); err != nil {
+		return e, err
+	}
+	return e, e.compact()
+}
+
+func (e *ETagger) load() error {
+	file, err := os.Open(e.opts.Cache)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry etagEntry
+		// 写入中断时最后一行可能不完整
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			e.cache.Put(entry.Key, entry.Size, entry.ModTime, entry.ETag)
+		}
+	}
+	return scanner.Err()
+}
+
+// compact 重写缓存文件，只保留每个路径最新的记录，之后新计算的哈希追加到文件末尾
+func (e *ETagger) compact() error {
+	if err := os.MkdirAll(filepath.Dir(e.opts.Cache), 0o755); err != nil {
+		return err
+	}
+	tmp := e.opts.Cache + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	e.cache.Range(func(key string, size, modTime int64, etag string) {
+		if err == nil {
+			err = encoder.Encode(etagEntry{Key: key, Size: size, ModTime: modTime, ETag: etag})
+		}
+	})
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, e.opts.Cache)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	e.journal, err = os.OpenFile(e.opts.Cache, os.O_WRONLY|os.O_APPEND, 0o644)
+	return err
+}
+
+// ETag 返回文件的 ETag，key 用于区分缓存中不同的文件，mtime 模式返回 webdav.ErrNotImplemented 以使用默认值
+func (e *ETagger) ETag(ctx context.Context, fs afero.Fs, key, name string, info os.FileInfo) (string, error) {
+	switch {
+	case e.opts.Mode == ETagContent && !info.IsDir() && info.Size() <= e.opts.MaxSize:
+		return e.contentETag(ctx, fs, key, name, info)
+	case e.opts.Mode == ETagHash || e.opts.Mode == ETagContent:
+		sum := sha256.Sum256([]byte(strconv.FormatInt(info.Size(), 10) + "-" + strconv.FormatInt(info.ModTime().UnixNano(), 10)))
+		return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+	}
+	return "", webdav.ErrNotImplemented
+}
+
+func (e *ETagger) contentETag(ctx context.Context, fs afero.Fs, key, name string, info os.FileInfo) (string, error) {
+	size, mtime := info.Size(), info.ModTime().UnixNano()
+	if etag, ok := e.cache.Get(key, size, mtime); ok {
+		return etag, nil
+	}
+	file, err := fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, mergefs.NewContextReader(ctx, file)); err != nil {
+		return "", err
+	}
+	entry := etagEntry{Key: key, Size: size, ModTime: mtime, ETag: fmt.Sprintf(`"%x"`, hash.Sum(nil))}
+	e.cache.Put(key, size, mtime, entry.ETag)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.journal != nil {
+		if data, err := json.Marshal(entry); err == nil {
+			_, _ = e.journal.Write(append(data, '\n'))
+		}
+	}
+	return entry.ETag, nil
+}
+
+// etagFileInfo 由 webdav 通过 webdav.ETager 获取 ETag
+type etagFileInfo struct {
+	os.FileInfo
+	etag func(ctx context.Context, info os.FileInfo) (string, error)
+}
+
+func (i *etagFileInfo) ETag(ctx context.Context) (string, error) {
+	return i.etag(ctx, i.FileInfo)
+}
+
+// etagFile 为 Stat 返回的 os.FileInfo 附加 ETag
+type etagFile struct {
+	webdav.File
+	etag func(ctx context.Context, info os.FileInfo) (string, error)
+}
+
+func (f *etagFile) Stat() (os.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &etagFileInfo{FileInfo: info, etag: f.etag}, nil
+}
@@ -0,0 +1,60 @@
+package dav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+func TestContentETag(t *testing.T) {
+	cache := filepath.Join(t.TempDir(), "etag.jsonl")
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("hello"), 0o644))
+	mtime := time.Unix(1700000000, 0)
+	assert.NoError(t, fs.Chtimes("/a.txt", mtime, mtime))
+
+	etagger, err := NewETagger(ETagOptions{Mode: ETagContent, Cache: cache, MaxSize: 1024})
+	assert.NoError(t, err)
+	davFs := NewWebdavFS(mergefs.NewMountFs(fs))
+	davFs.ETag = func(ctx context.Context, name string, info os.FileInfo) (string, error) {
+		return etagger.ETag(ctx, fs, name, name, info)
+	}
+	handler := &webdav.Handler{FileSystem: davFs, LockSystem: webdav.NewMemLS()}
+	get := func() string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a.txt", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		return rec.Header().Get("ETag")
+	}
+
+	// sha256("hello")
+	expected := `"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"`
+	assert.Equal(t, expected, get(), "使用文件内容的哈希")
+
+	// 修改时间变化但内容不变时 ETag 不变
+	other := time.Unix(1800000000, 0)
+	assert.NoError(t, fs.Chtimes("/a.txt", other, other))
+	assert.Equal(t, expected, get())
+
+	assert.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("world"), 0o644))
+	assert.NotEqual(t, expected, get(), "内容变化后重新计算")
+
+	// 重新加载时使用缓存文件中的哈希
+	reloaded, err := NewETagger(ETagOptions{Mode: ETagContent, Cache: cache, MaxSize: 1024})
+	assert.NoError(t, err)
+	info, err := fs.Stat("/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reloaded.cache.Len(), "整理后每个路径只保留一条记录")
+	etag, err := reloaded.ETag(context.Background(), afero.NewMemMapFs(), "/a.txt", "/a.txt", info)
+	assert.NoError(t, err, "命中缓存时不读取文件")
+	assert.Equal(t, get(), etag)
+}
@@ -4,34 +4,85 @@ import (
 	"context"
 	"os"
 
+	"code.d7z.net/packages/webdav-server/mergefs"
 	"github.com/spf13/afero"
 	"golang.org/x/net/webdav"
 )
 
+// contextRenamer 支持在请求取消时中止重命名的文件系统
+type contextRenamer interface {
+	RenameContext(ctx context.Context, oldName, newName string) error
+}
+
 type WebdavFS struct {
 	afero.Fs
+	// Usage 返回路径所在存储池的空间使用情况，用于目录的 quota 属性，为 nil 时不返回
+	Usage func(name string) (mergefs.DiskUsage, bool)
+	// ETag 计算文件的 ETag，返回 webdav.ErrNotImplemented 或为 nil 时使用 webdav 默认的修改时间与大小
+	ETag func(ctx context.Context, name string, info os.FileInfo) (string, error)
+	// SyncToken 返回当前的同步令牌，用于目录的 sync-token 属性，为 nil 时不返回
+	SyncToken func() string
+	// Checksums 返回文件的校验和，用于 ownCloud 的 checksums 属性，为 nil 时不返回
+	Checksums func(ctx context.Context, name string, info os.FileInfo) (Checksums, bool)
 }
 
 func NewWebdavFS(fs afero.Fs) *WebdavFS {
-	return &WebdavFS{fs}
+	return &WebdavFS{Fs: fs}
 }
 
 func (w *WebdavFS) Mkdir(_ context.Context, name string, perm os.FileMode) error {
 	return w.Fs.Mkdir(name, perm)
 }
 
-func (w *WebdavFS) OpenFile(_ context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
-	return w.Fs.OpenFile(name, flag, perm)
+func (w *WebdavFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	var (
+		file webdav.File
+		err  error
+	)
+	if fs, ok := w.Fs.(mergefs.ContextOpener); ok {
+		file, err = fs.OpenFileContext(ctx, name, flag, perm)
+	} else {
+		file, err = w.Fs.OpenFile(name, flag, perm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 写入中的文件内容尚未提交，不计算 ETag
+	if w.ETag != nil && flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		file = &etagFile{File: file, etag: w.etagOf(name)}
+	}
+	file = limitDepth(ctx, file, name)
+	file = wrapProgressFile(ctx, w.Fs, file, name, flag)
+	if store, ok := w.Fs.(mergefs.PropStore); ok {
+		file = wrapPropsFile(ctx, w, store, file, name, flag)
+	}
+	if copier, ok := w.Fs.(mergefs.Copier); ok && isCopyRequest(ctx) {
+		return wrapCopyFile(ctx, copier, file, name, flag), nil
+	}
+	return file, nil
 }
 
 func (w *WebdavFS) RemoveAll(_ context.Context, name string) error {
 	return w.Fs.RemoveAll(name)
 }
 
-func (w *WebdavFS) Rename(_ context.Context, oldName, newName string) error {
+func (w *WebdavFS) Rename(ctx context.Context, oldName, newName string) error {
+	if fs, ok := w.Fs.(contextRenamer); ok {
+		return fs.RenameContext(ctx, oldName, newName)
+	}
 	return w.Fs.Rename(oldName, newName)
 }
 
 func (w *WebdavFS) Stat(_ context.Context, name string) (os.FileInfo, error) {
-	return w.Fs.Stat(name)
+	info, err := w.Fs.Stat(name)
+	if err != nil || w.ETag == nil {
+		return info, err
+	}
+	return &etagFileInfo{FileInfo: info, etag: w.etagOf(name)}, nil
+}
+
+func (w *WebdavFS) etagOf(name string) func(ctx context.Context, info os.FileInfo) (string, error) {
+	return func(ctx context.Context, info os.FileInfo) (string, error) {
+		return w.ETag(ctx, name, info)
+	}
 }
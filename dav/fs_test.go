@@ -0,0 +1,40 @@
+package dav
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+	"golang.org/x/net/webdav"
+)
+
+// BenchmarkPropfind 在多存储池、深层目录下执行 Depth: infinity 的 PROPFIND
+func BenchmarkPropfind(b *testing.B) {
+	mountFs := mergefs.NewMountFs(afero.NewMemMapFs())
+	for i := range 20 {
+		pool := afero.NewMemMapFs()
+		dir := "/"
+		for depth := range 8 {
+			dir = fmt.Sprintf("%sd%d/", dir, depth)
+			_ = afero.WriteFile(pool, dir+"file.txt", []byte("data"), 0o644)
+		}
+		_ = mountFs.Mount(fmt.Sprintf("/pool%02d", i), pool)
+	}
+	handler := &webdav.Handler{
+		FileSystem: NewWebdavFS(mountFs),
+		LockSystem: webdav.NewMemLS(),
+	}
+	b.ResetTimer()
+	for b.Loop() {
+		req := httptest.NewRequest("PROPFIND", "/", nil)
+		req.Header.Set("Depth", "infinity")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusMultiStatus {
+			b.Fatalf("unexpected status %d", rec.Code)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package dav
+
+import (
+	"sync"
+
+	"github.com/spf13/afero"
+	"golang.org/x/net/webdav"
+)
+
+// handlerCache 按用户与站点缓存 webdav.Handler，用户的文件系统在启动时创建且不再变化，无需每个请求重新构建
+type handlerCache struct {
+	create func(user, prefix string, fs afero.Fs) *webdav.Handler
+
+	mu       sync.Mutex
+	handlers map[string]*webdav.Handler
+}
+
+func newHandlerCache(create func(user, prefix string, fs afero.Fs) *webdav.Handler) *handlerCache {
+	return &handlerCache{create: create, handlers: make(map[string]*webdav.Handler)}
+}
+
+// get 返回用户在站点 space 中的 webdav.Handler，首次请求时创建。
+// 同一用户与站点的 prefix 与 fs 总是相同，站点的文件系统在快照变化时重建，此时一并替换
+func (c *handlerCache) get(user, prefix, space string, fs afero.Fs) *webdav.Handler {
+	key := user + "\x00" + space
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	handler, ok := c.handlers[key]
+	if !ok || handler.FileSystem.(*WebdavFS).Fs != fs {
+		handler = c.create(user, prefix, fs)
+		c.handlers[key] = handler
+	}
+	return handler
+}
@@ -0,0 +1,264 @@
+package dav
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/spf13/afero"
+	"golang.org/x/net/webdav"
+)
+
+// jobsPath 后台任务的状态路径，位于 WebDAV 前缀下。存储池名称不能以 . 开头，不会与文件冲突
+const jobsPath = "/.jobs/"
+
+const (
+	jobRunning  = "running"
+	jobDone     = "done"
+	jobFailed   = "failed"
+	jobCanceled = "canceled"
+)
+
+// jobStatus 后台任务的状态，以 JSON 返回给客户端
+type jobStatus struct {
+	ID          string `json:"id"`
+	Method      string `json:"method"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	State       string `json:"state"`
+	// Status 完成后 COPY/MOVE 本应返回的状态码
+	Status     int        `json:"status,omitempty"`
+	Files      int64      `json:"files"`
+	Bytes      int64      `json:"bytes"`
+	TotalFiles int64      `json:"total_files"`
+	TotalBytes int64      `json:"total_bytes"`
+	Started    time.Time  `json:"started"`
+	Finished   *time.Time `json:"finished,omitempty"`
+}
+
+// job 在后台执行的 COPY/MOVE
+type job struct {
+	user   string
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	status jobStatus
+}
+
+func (j *job) snapshot() jobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+func (j *job) update(fn func(status *jobStatus)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn(&j.status)
+}
+
+// jobStore 保存后台任务，完成的任务保留 retention 后删除
+type jobStore struct {
+	retention time.Duration
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+func newJobStore(retention time.Duration) *jobStore {
+	return &jobStore{retention: retention, jobs: make(map[string]*job)}
+}
+
+func (s *jobStore) add(j *job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, old := range s.jobs {
+		if finished := old.snapshot().Finished; finished != nil && time.Since(*finished) > s.retention {
+			delete(s.jobs, id)
+		}
+	}
+	s.jobs[j.snapshot().ID] = j
+}
+
+// get 返回用户的任务，其他用户的任务视为不存在
+func (s *jobStore) get(user, id string) (*job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok || j.user != user {
+		return nil, false
+	}
+	return j, true
+}
+
+// preferAsync 判断客户端是否以 RFC 7240 的 Prefer: respond-async 接受异步处理
+func preferAsync(request *http.Request) bool {
+	for _, header := range request.Header.Values("Prefer") {
+		for _, token := range strings.Split(header, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isAsyncCopy 判断请求是否为客户端接受异步处理的跨存储池 COPY/MOVE，
+// 此类请求需要复制全部数据，同步处理时可能长时间阻塞直至超时
+func isAsyncCopy(ctx *common.FsContext, prefix string, request *http.Request) bool {
+	if (request.Method != "COPY" && request.Method != "MOVE") || !preferAsync(request) {
+		return false
+	}
+	dst, ok := destination(prefix, request)
+	return ok && ctx.PoolName(strings.TrimPrefix(request.URL.Path, prefix)) != ctx.PoolName(dst)
+}
+
+// startJob 在后台执行 COPY/MOVE，立即返回 202 与任务状态的地址。
+// 请求由 webdav.Handler 按原有的语义处理，完成后以 done 报告其状态码
+func startJob(ctx *common.FsContext, w http.ResponseWriter, request *http.Request, handler *webdav.Handler, fs afero.Fs,
+	jobs *jobStore, user, prefix string, done func(code int),
+) {
+	random := make([]byte, 16)
+	_, _ = rand.Read(random)
+	id := hex.EncodeToString(random)
+	name := strings.TrimPrefix(request.URL.Path, prefix)
+	dst, _ := destination(prefix, request)
+	// 保留请求中的标记（如 COPY 的服务端复制），但不随客户端断开而取消
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(request.Context()))
+	stop := context.AfterFunc(ctx.Context(), cancel)
+	j := &job{user: user, cancel: cancel, status: jobStatus{
+		ID:          id,
+		Method:      request.Method,
+		Source:      name,
+		Destination: dst,
+		State:       jobRunning,
+		Started:     time.Now(),
+	}}
+	jobs.add(j)
+
+	req := request.Clone(withJobProgress(jobCtx, j))
+	req.Header.Del("Prefer")
+	req.Body = http.NoBody
+	req.ContentLength = 0
+	go func() {
+		defer stop()
+		defer cancel()
+		var files, size int64
+		_ = afero.Walk(fs, name, func(_ string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if !info.IsDir() {
+				files++
+				size += info.Size()
+			}
+			return jobCtx.Err()
+		})
+		j.update(func(status *jobStatus) {
+			status.TotalFiles, status.TotalBytes = files, size
+		})
+		rec := &bufferedResponse{header: make(http.Header)}
+		handler.ServeHTTP(rec, req)
+		code := rec.code
+		if code == 0 {
+			code = http.StatusOK
+		}
+		done(code)
+		now := time.Now()
+		j.update(func(status *jobStatus) {
+			status.Status = code
+			status.Finished = &now
+			switch {
+			case jobCtx.Err() != nil:
+				status.State = jobCanceled
+			case code >= http.StatusOK && code < http.StatusMultipleChoices && code != http.StatusMultiStatus:
+				status.State = jobDone
+				// 跨存储池的 MOVE 在文件系统内部复制，不经过 WebdavFS，完成后以总量作为进度
+				status.Files, status.Bytes = max(status.Files, status.TotalFiles), max(status.Bytes, status.TotalBytes)
+			default:
+				status.State = jobFailed
+			}
+		})
+	}()
+
+	w.Header().Set("Location", ctx.Config.Webdav.Prefix+jobsPath+id)
+	w.Header().Set("Preference-Applied", "respond-async")
+	writeJob(w, http.StatusAccepted, j.snapshot())
+}
+
+// handleJob 处理任务状态的请求：GET 查询进度，DELETE 取消任务
+func handleJob(w http.ResponseWriter, request *http.Request, jobs *jobStore, user, id string) {
+	j, ok := jobs.get(user, id)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	switch request.Method {
+	case http.MethodGet, http.MethodHead:
+		writeJob(w, http.StatusOK, j.snapshot())
+	case http.MethodDelete:
+		j.cancel()
+		writeJob(w, http.StatusAccepted, j.snapshot())
+	default:
+		w.Header().Set("Allow", "GET, HEAD, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJob(w http.ResponseWriter, code int, status jobStatus) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// jobProgressKey 后台任务的请求，WebdavFS 写入的文件计入任务进度
+type jobProgressKey struct{}
+
+func withJobProgress(ctx context.Context, j *job) context.Context {
+	return context.WithValue(ctx, jobProgressKey{}, j)
+}
+
+// wrapProgressFile 在后台任务中统计写入的文件，任务取消后中止写入
+func wrapProgressFile(ctx context.Context, fs afero.Fs, file webdav.File, name string, flag int) webdav.File {
+	j, ok := ctx.Value(jobProgressKey{}).(*job)
+	if !ok || flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return file
+	}
+	return &progressFile{File: file, ctx: ctx, fs: fs, job: j, name: name}
+}
+
+type progressFile struct {
+	webdav.File
+	ctx  context.Context
+	fs   afero.Fs
+	job  *job
+	name string
+}
+
+func (f *progressFile) Write(p []byte) (int, error) {
+	if err := f.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return f.File.Write(p)
+}
+
+// Close 提交文件后按其大小计入进度，服务端复制不经过 Write，因此不在写入时累计
+func (f *progressFile) Close() error {
+	err := f.File.Close()
+	if err == nil {
+		if info, statErr := f.fs.Stat(f.name); statErr == nil {
+			f.job.update(func(status *jobStatus) {
+				status.Files++
+				status.Bytes += info.Size()
+			})
+		}
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package dav
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsyncMove(t *testing.T) {
+	dir := t.TempDir()
+	for _, pool := range []string{"a", "b"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, pool), 0o755))
+	}
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "dir"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a", "dir", "1.txt"), []byte("hello"), 0o644))
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(config, []byte(`
+bind: 127.0.0.1:0
+users:
+  alice:
+    password: alice
+pools:
+  a:
+    path: `+filepath.Join(dir, "a")+`
+    permission: rw
+  b:
+    path: `+filepath.Join(dir, "b")+`
+    permission: rw
+webdav:
+  enabled: true
+`), 0o644))
+	cfg, err := common.LoadConfig(config)
+	assert.NoError(t, err)
+	ctx, err := common.NewContext(context.Background(), cfg)
+	assert.NoError(t, err)
+	router := chi.NewRouter()
+	router.Route(cfg.Webdav.Prefix, WithWebdav(ctx))
+	request := func(method, target string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.SetBasicAuth("alice", "alice")
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request("MOVE", "/dav/a/dir", map[string]string{"Destination": "/dav/b/dir", "Prefer": "respond-async"})
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "respond-async", rec.Header().Get("Preference-Applied"))
+	location := rec.Header().Get("Location")
+	var status jobStatus
+	assert.Eventually(t, func() bool {
+		rec := request(http.MethodGet, location, nil)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+		return status.State != jobRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, jobDone, status.State)
+	assert.Equal(t, http.StatusCreated, status.Status)
+	assert.Equal(t, int64(1), status.TotalFiles)
+	assert.Equal(t, int64(5), status.Bytes)
+	assert.FileExists(t, filepath.Join(dir, "b", "dir", "1.txt"))
+	assert.NoDirExists(t, filepath.Join(dir, "a", "dir"))
+
+	rec = request("MOVE", "/dav/b/dir", map[string]string{"Destination": "/dav/b/moved", "Prefer": "respond-async"})
+	assert.Equal(t, http.StatusCreated, rec.Code, "同一存储池内的移动同步完成")
+	assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "/dav/.jobs/unknown", nil).Code)
+}
@@ -0,0 +1,32 @@
+package dav
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockTimeouts LOCK 请求可申请的锁超时范围
+type lockTimeouts struct {
+	def, min, max time.Duration
+}
+
+// clamp 将 LOCK 请求的 Timeout 头改为范围内的秒数，未指定或无法解析时使用默认值，
+// 申请无限期的锁时使用上限。webdav.Handler 按改写后的值创建锁并在响应中返回，客户端据此按时刷新
+func (l lockTimeouts) clamp(header string) string {
+	timeout := l.def
+	value, _, _ := strings.Cut(header, ",")
+	switch value = strings.TrimSpace(value); {
+	case value == "Infinite":
+		timeout = l.max
+	case strings.HasPrefix(value, "Second-"):
+		if seconds, err := strconv.ParseUint(strings.TrimPrefix(value, "Second-"), 10, 32); err == nil {
+			timeout = time.Duration(seconds) * time.Second
+		}
+	}
+	timeout = min(max(timeout, l.min), l.max)
+	seconds := int64(math.Ceil(timeout.Seconds()))
+	// webdav 只接受 32 位的秒数
+	return "Second-" + strconv.FormatInt(min(max(seconds, 1), math.MaxUint32), 10)
+}
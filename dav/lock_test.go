@@ -0,0 +1,23 @@
+package dav
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockTimeouts(t *testing.T) {
+	timeouts := lockTimeouts{def: time.Hour, min: time.Minute, max: 24 * time.Hour}
+	for header, expected := range map[string]string{
+		"":                            "Second-3600",
+		"Infinite":                    "Second-86400",
+		"Infinite, Second-4100000000": "Second-86400",
+		"Second-600":                  "Second-600",
+		"Second-1":                    "Second-60",
+		"Second-999999":               "Second-86400",
+		"invalid":                     "Second-3600",
+	} {
+		assert.Equal(t, expected, timeouts.clamp(header), header)
+	}
+}
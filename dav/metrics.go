@@ -0,0 +1,59 @@
+package dav
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"code.d7z.net/packages/webdav-server/metrics"
+)
+
+var (
+	// durationBuckets 请求耗时的直方图上界（秒）
+	durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+	// sizeBuckets 上传大小的直方图上界（字节）
+	sizeBuckets = []float64{1 << 10, 1 << 14, 1 << 17, 1 << 20, 1 << 23, 1 << 26, 1 << 30, 1 << 33}
+)
+
+// davMetrics WebDAV 请求的指标，按方法、用户与存储池区分
+type davMetrics struct {
+	requests *metrics.CounterVec
+	duration *metrics.HistogramVec
+	propfind *metrics.CounterVec
+	upload   *metrics.HistogramVec
+}
+
+func newDavMetrics(registry *metrics.Registry) *davMetrics {
+	return &davMetrics{
+		requests: registry.NewCounterVec("webdav_requests_total",
+			"WebDAV requests by method, user, pool and status.", "method", "user", "pool", "status"),
+		duration: registry.NewHistogramVec("webdav_request_duration_seconds",
+			"WebDAV request latency in seconds.", durationBuckets, "method", "user", "pool"),
+		propfind: registry.NewCounterVec("webdav_propfind_total",
+			"WebDAV PROPFIND requests by Depth header.", "depth", "user", "pool"),
+		upload: registry.NewHistogramVec("webdav_put_bytes",
+			"Bytes received by WebDAV PUT requests.", sizeBuckets, "user", "pool"),
+	}
+}
+
+// observe 记录一个已完成的请求，uploaded 为 PUT 实际读取的字节数，其他请求为 -1
+func (m *davMetrics) observe(ctx *common.FsContext, prefix string, request *http.Request, user string, code int, start time.Time, uploaded int64) {
+	pool := ctx.PoolName(strings.TrimPrefix(request.URL.Path, prefix))
+	if code == 0 {
+		code = http.StatusOK
+	}
+	m.requests.Inc(request.Method, user, pool, strconv.Itoa(code))
+	m.duration.Observe(time.Since(start).Seconds(), request.Method, user, pool)
+	if request.Method == "PROPFIND" {
+		depth := strings.ToLower(strings.TrimSpace(request.Header.Get("Depth")))
+		if depth != "0" && depth != "1" {
+			depth = "infinity"
+		}
+		m.propfind.Inc(depth, user, pool)
+	}
+	if uploaded >= 0 {
+		m.upload.Observe(float64(uploaded), user, pool)
+	}
+}
@@ -0,0 +1,39 @@
+package dav
+
+import (
+	"net/http"
+	"strings"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"golang.org/x/net/webdav"
+)
+
+const (
+	// allowCollection 目录（包括前缀根目录与挂载点等虚拟目录）支持的方法
+	allowCollection = "OPTIONS, GET, HEAD, POST, DELETE, PROPFIND, PROPPATCH, COPY, MOVE, LOCK, UNLOCK, SEARCH, REPORT"
+	// allowFile 文件支持的方法
+	allowFile = "OPTIONS, GET, HEAD, POST, PUT, PATCH, DELETE, PROPFIND, PROPPATCH, COPY, MOVE, LOCK, UNLOCK"
+	// allowMissing 不存在的路径可创建
+	allowMissing = "OPTIONS, PUT, MKCOL, LOCK"
+)
+
+// handleOptions 处理 OPTIONS。webdav.Handler 在前缀根目录（不带结尾的 /）上无法去除前缀，
+// 会把根目录当作不存在的路径，且 Allow 不包含 GET、SEARCH 等方法。Finder 与 Windows 挂载前会检查这些响应头
+func handleOptions(w http.ResponseWriter, r *http.Request, handler *webdav.Handler) {
+	allow := allowMissing
+	name := mergefs.NormalizePath(strings.TrimPrefix(r.URL.Path, handler.Prefix))
+	if info, err := handler.FileSystem.Stat(r.Context(), name); err == nil {
+		if info.IsDir() {
+			allow = allowCollection
+		} else {
+			allow = allowFile
+		}
+	}
+	header := w.Header()
+	header.Set("Allow", allow)
+	header.Set("DAV", "1, 2")
+	header.Set("MS-Author-Via", "DAV")
+	header.Set("DASL", daslHeader)
+	header.Set("Content-Length", "0")
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,35 @@
+package dav
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+func TestHandleOptions(t *testing.T) {
+	pool := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(pool, "/a.txt", []byte("a"), 0o644))
+	fs := mergefs.NewMountFs(afero.NewMemMapFs())
+	assert.NoError(t, fs.Mount("/data", pool))
+	handler := &webdav.Handler{Prefix: "/dav", FileSystem: NewWebdavFS(fs), LockSystem: webdav.NewMemLS()}
+	for target, allow := range map[string]string{
+		"/dav":             allowCollection,
+		"/dav/":            allowCollection,
+		"/dav/data":        allowCollection,
+		"/dav/data/a.txt":  allowFile,
+		"/dav/data/b.txt":  allowMissing,
+		"/dav/missing/dir": allowMissing,
+	} {
+		rec := httptest.NewRecorder()
+		handleOptions(rec, httptest.NewRequest(http.MethodOptions, target, nil), handler)
+		assert.Equal(t, http.StatusOK, rec.Code, target)
+		assert.Equal(t, allow, rec.Header().Get("Allow"), target)
+		assert.Equal(t, "1, 2", rec.Header().Get("DAV"), target)
+		assert.Equal(t, "DAV", rec.Header().Get("MS-Author-Via"), target)
+	}
+}
@@ -0,0 +1,216 @@
+package dav
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// partialUpdateType SabreDAV 部分更新使用的 Content-Type
+const partialUpdateType = "application/x-sabredav-partialupdate"
+
+var (
+	contentRangePattern = regexp.MustCompile(`^bytes (\d+)-(\d+)/(\d+|\*)$`)
+	updateRangePattern  = regexp.MustCompile(`^bytes=(\d*)-(\d*)$`)
+	lockTokenPattern    = regexp.MustCompile(`\(\s*(?:Not\s+)?<([^>]+)>`)
+)
+
+// isPartialUpload 判断是否为断点续传的上传：带 Content-Range 的 PUT 或 SabreDAV 风格的 PATCH
+func isPartialUpload(request *http.Request) bool {
+	return (request.Method == http.MethodPut && request.Header.Get("Content-Range") != "") ||
+		request.Method == http.MethodPatch
+}
+
+// partialRange 部分写入的位置，length 为 -1 时写入整个请求体，total 为 -1 时不截断
+type partialRange struct {
+	offset, length, total int64
+	// fromEnd 以文件末尾为基准，offset 为距末尾的字节数
+	fromEnd bool
+}
+
+// parsePartialRange 解析 PUT 的 Content-Range 或 PATCH 的 X-Update-Range
+func parsePartialRange(request *http.Request) (partialRange, int) {
+	if request.Method == http.MethodPut {
+		match := contentRangePattern.FindStringSubmatch(strings.TrimSpace(request.Header.Get("Content-Range")))
+		if match == nil {
+			return partialRange{}, http.StatusBadRequest
+		}
+		start, _ := strconv.ParseInt(match[1], 10, 64)
+		end, _ := strconv.ParseInt(match[2], 10, 64)
+		total := int64(-1)
+		if match[3] != "*" {
+			total, _ = strconv.ParseInt(match[3], 10, 64)
+		}
+		if end < start || (total >= 0 && end >= total) {
+			return partialRange{}, http.StatusRequestedRangeNotSatisfiable
+		}
+		return partialRange{offset: start, length: end - start + 1, total: total}, 0
+	}
+	if mediaType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type")); mediaType != partialUpdateType {
+		return partialRange{}, http.StatusUnsupportedMediaType
+	}
+	value := strings.TrimSpace(request.Header.Get("X-Update-Range"))
+	if strings.EqualFold(value, "append") {
+		return partialRange{offset: 0, length: -1, total: -1, fromEnd: true}, 0
+	}
+	match := updateRangePattern.FindStringSubmatch(value)
+	if match == nil || match[1] == "" && match[2] == "" {
+		return partialRange{}, http.StatusBadRequest
+	}
+	if match[1] == "" {
+		// bytes=-N 覆盖末尾的 N 个字节
+		n, _ := strconv.ParseInt(match[2], 10, 64)
+		return partialRange{offset: n, length: -1, total: -1, fromEnd: true}, 0
+	}
+	start, _ := strconv.ParseInt(match[1], 10, 64)
+	r := partialRange{offset: start, length: -1, total: -1}
+	if match[2] != "" {
+		end, _ := strconv.ParseInt(match[2], 10, 64)
+		if end < start {
+			return partialRange{}, http.StatusRequestedRangeNotSatisfiable
+		}
+		r.length = end - start + 1
+	}
+	return r, 0
+}
+
+// handlePartialUpload 在文件的指定位置写入请求体，用于续传中断的上传。
+// 写入位置不能超过当前文件大小，避免在文件中留下空洞；写入不经过临时文件，中断时已写入的部分保留，客户端可从文件大小处继续
+func handlePartialUpload(w http.ResponseWriter, r *http.Request, handler *webdav.Handler, name string) {
+	rng, status := parsePartialRange(r)
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	if rng.length >= 0 && r.ContentLength >= 0 && r.ContentLength != rng.length {
+		http.Error(w, "request body does not match the range", http.StatusBadRequest)
+		return
+	}
+	release, status := confirmLock(r, handler.LockSystem, name)
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	defer release()
+
+	info, err := handler.FileSystem.Stat(r.Context(), name)
+	switch {
+	case err == nil && info.IsDir():
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	case err != nil && !os.IsNotExist(err):
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	case err != nil && r.Method == http.MethodPatch:
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	var size int64
+	if info != nil {
+		size = info.Size()
+	}
+	offset := rng.offset
+	if rng.fromEnd {
+		offset = size - rng.offset
+	}
+	if offset < 0 || offset > size {
+		w.Header().Set("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+		http.Error(w, http.StatusText(http.StatusRequestedRangeNotSatisfiable), http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	file, err := handler.FileSystem.OpenFile(r.Context(), name, os.O_WRONLY|os.O_CREATE, 0o666)
+	if err != nil {
+		writePartialError(w, err)
+		return
+	}
+	var body io.Reader = r.Body
+	if rng.length >= 0 {
+		body = io.LimitReader(r.Body, rng.length)
+	}
+	var written int64
+	if _, err = file.Seek(offset, io.SeekStart); err == nil {
+		written, err = io.Copy(file, body)
+	}
+	if err == nil && rng.total >= 0 && offset+written == rng.total && size > rng.total {
+		// 最后一段写入后去除原有文件多出的内容
+		err = truncateFile(file, rng.total)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		writePartialError(w, err)
+		return
+	}
+	if rng.length >= 0 && written != rng.length {
+		http.Error(w, "request body is shorter than the range", http.StatusBadRequest)
+		return
+	}
+	if info == nil {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// truncateFile 截断写入的文件，webdav.File 未声明 Truncate，需跳过保存属性的包装
+func truncateFile(file webdav.File, size int64) error {
+	if f, ok := file.(*propsFile); ok {
+		file = f.File
+	}
+	if truncater, ok := file.(interface{ Truncate(int64) error }); ok {
+		return truncater.Truncate(size)
+	}
+	return webdav.ErrNotImplemented
+}
+
+func writePartialError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, syscall.EFBIG):
+		http.Error(w, errFileTooLarge.Error(), http.StatusRequestEntityTooLarge)
+	case os.IsPermission(err):
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	case os.IsNotExist(err):
+		http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+	case errors.Is(err, webdav.ErrNotImplemented) || errors.Is(err, errors.ErrUnsupported):
+		http.Error(w, "partial upload is not supported by this pool", http.StatusNotImplemented)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// confirmLock 确认写入的路径未被其他客户端锁定，与 webdav.Handler 的处理相同：
+// 没有 If 头时创建临时锁检查冲突，否则要求 If 头中的锁令牌有效
+func confirmLock(r *http.Request, ls webdav.LockSystem, name string) (func(), int) {
+	now := time.Now()
+	header := r.Header.Get("If")
+	if header == "" {
+		token, err := ls.Create(now, webdav.LockDetails{Root: name, Duration: -1, ZeroDepth: true})
+		if errors.Is(err, webdav.ErrLocked) {
+			return nil, webdav.StatusLocked
+		}
+		if err != nil {
+			return nil, http.StatusInternalServerError
+		}
+		return func() { _ = ls.Unlock(now, token) }, 0
+	}
+	var conditions []webdav.Condition
+	for _, match := range lockTokenPattern.FindAllStringSubmatch(header, -1) {
+		conditions = append(conditions, webdav.Condition{Token: match[1]})
+	}
+	release, err := ls.Confirm(now, name, "", conditions...)
+	if err != nil {
+		return nil, http.StatusPreconditionFailed
+	}
+	return release, 0
+}
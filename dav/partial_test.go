@@ -0,0 +1,51 @@
+package dav
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+func TestPartialUpload(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	handler := &webdav.Handler{FileSystem: NewWebdavFS(mergefs.NewMountFs(fs)), LockSystem: webdav.NewMemLS()}
+	upload := func(method, body string, headers map[string]string) int {
+		req := httptest.NewRequest(method, "/a.txt", strings.NewReader(body))
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		handlePartialUpload(rec, req, handler, "/a.txt")
+		return rec.Code
+	}
+	content := func() string {
+		data, err := afero.ReadFile(fs, "/a.txt")
+		assert.NoError(t, err)
+		return string(data)
+	}
+
+	assert.Equal(t, http.StatusCreated, upload(http.MethodPut, "hello", map[string]string{"Content-Range": "bytes 0-4/11"}))
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, upload(http.MethodPut, "d", map[string]string{"Content-Range": "bytes 10-10/11"}), "不能在文件中留下空洞")
+	assert.Equal(t, http.StatusNoContent, upload(http.MethodPut, " world", map[string]string{"Content-Range": "bytes 5-10/11"}))
+	assert.Equal(t, "hello world", content(), "从中断处继续上传")
+
+	partial := map[string]string{"Content-Type": partialUpdateType, "X-Update-Range": "append"}
+	assert.Equal(t, http.StatusNoContent, upload(http.MethodPatch, "!", partial))
+	assert.Equal(t, "hello world!", content())
+	partial["X-Update-Range"] = "bytes=0-4"
+	assert.Equal(t, http.StatusNoContent, upload(http.MethodPatch, "HELLO", partial))
+	assert.Equal(t, "HELLO world!", content())
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, upload(http.MethodPatch, "x", map[string]string{"X-Update-Range": "append"}))
+	assert.Equal(t, http.StatusBadRequest, upload(http.MethodPut, "abc", map[string]string{"Content-Range": "bytes 0-4/5"}), "请求体与范围不符")
+
+	// 覆盖更长的文件时，最后一段写入后截断
+	assert.Equal(t, http.StatusNoContent, upload(http.MethodPut, "bye", map[string]string{"Content-Range": "bytes 0-2/3"}))
+	assert.Equal(t, "bye", content())
+}
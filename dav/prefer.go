@@ -0,0 +1,106 @@
+package dav
+
+import (
+	"bytes"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// preferMinimal 判断客户端是否要求精简的响应：RFC 7240 的 Prefer: return=minimal 或早期客户端使用的 Brief: t
+func preferMinimal(request *http.Request) (prefer, brief bool) {
+	for _, header := range request.Header.Values("Prefer") {
+		for _, token := range strings.Split(header, ",") {
+			if strings.EqualFold(strings.ReplaceAll(strings.TrimSpace(token), " ", ""), "return=minimal") {
+				prefer = true
+			}
+		}
+	}
+	brief = strings.EqualFold(strings.TrimSpace(request.Header.Get("Brief")), "t")
+	return prefer, brief
+}
+
+var propstatPattern = regexp.MustCompile(`(?s)<D:propstat>.*?</D:propstat>`)
+
+// responseEnd webdav.Handler 逐个写出 D:response，按此分段过滤，无需缓存整个 multistatus
+var responseEnd = []byte("</D:response>")
+
+// minimalResponseWriter 精简 PROPFIND 与 PROPPATCH 的 multistatus：PROPFIND 去除不存在属性的 404 propstat，
+// PROPPATCH 去除回显请求属性的 200 propstat，全部成功时只返回状态
+type minimalResponseWriter struct {
+	http.ResponseWriter
+	drop   string
+	status bool
+	buf    []byte
+}
+
+// newMinimalResponseWriter 客户端要求精简响应时包装 w，其他请求返回 nil
+func newMinimalResponseWriter(w http.ResponseWriter, request *http.Request) *minimalResponseWriter {
+	prefer, brief := preferMinimal(request)
+	if !prefer && !brief {
+		return nil
+	}
+	writer := &minimalResponseWriter{ResponseWriter: w}
+	switch request.Method {
+	case "PROPFIND":
+		writer.drop = "<D:status>HTTP/1.1 404 Not Found</D:status>"
+	case "PROPPATCH":
+		writer.drop = "<D:status>HTTP/1.1 200 OK</D:status>"
+		writer.status = true
+	default:
+		return nil
+	}
+	w.Header().Add("Vary", "Brief, Prefer")
+	if prefer {
+		w.Header().Set("Preference-Applied", "return=minimal")
+	}
+	return writer
+}
+
+func (w *minimalResponseWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		end := bytes.Index(w.buf, responseEnd)
+		if end < 0 {
+			return len(p), nil
+		}
+		end += len(responseEnd)
+		if _, err := w.ResponseWriter.Write(w.filter(w.buf[:end])); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[end:]
+	}
+}
+
+// filter 去除一个 D:response 中需要精简的 propstat，全部被去除时 PROPFIND 保留原样，PROPPATCH 改为只返回状态
+func (w *minimalResponseWriter) filter(response []byte) []byte {
+	kept := 0
+	result := propstatPattern.ReplaceAllFunc(response, func(propstat []byte) []byte {
+		if bytes.Contains(propstat, []byte(w.drop)) {
+			return nil
+		}
+		kept++
+		return propstat
+	})
+	switch {
+	case kept > 0:
+		return result
+	case w.status:
+		return bytes.Replace(result, responseEnd, []byte(w.drop+string(responseEnd)), 1)
+	}
+	return response
+}
+
+func (w *minimalResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish 写出剩余的内容，在 webdav.Handler 返回后调用
+func (w *minimalResponseWriter) finish() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.buf)
+	w.buf = nil
+	return err
+}
@@ -0,0 +1,51 @@
+package dav
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+func TestPreferMinimal(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/dir/a.txt", []byte("a"), 0o644))
+	handler := &webdav.Handler{FileSystem: NewWebdavFS(mergefs.NewMountFs(fs)), LockSystem: webdav.NewMemLS()}
+	propfind := func(header, value string) (*httptest.ResponseRecorder, string) {
+		req := httptest.NewRequest("PROPFIND", "/dir", strings.NewReader(`<?xml version="1.0"?>
+<d:propfind xmlns:d="DAV:" xmlns:x="urn:x"><d:prop><d:getcontentlength/><x:missing/></d:prop></d:propfind>`))
+		req.Header.Set("Depth", "1")
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		if minimal := newMinimalResponseWriter(rec, req); minimal != nil {
+			handler.ServeHTTP(minimal, req)
+			assert.NoError(t, minimal.finish())
+		} else {
+			handler.ServeHTTP(rec, req)
+		}
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		return rec, rec.Body.String()
+	}
+
+	_, full := propfind("", "")
+	assert.Contains(t, full, "404 Not Found")
+
+	rec, body := propfind("Prefer", "return=minimal")
+	assert.Equal(t, "return=minimal", rec.Header().Get("Preference-Applied"))
+	assert.Equal(t, 2, strings.Count(full, "404 Not Found"))
+	assert.Equal(t, 1, strings.Count(body, "404 Not Found"), "去除文件中不存在属性的 404 propstat")
+	assert.Contains(t, body, "<D:getcontentlength>1</D:getcontentlength>")
+	assert.Contains(t, body, "<D:href>/dir/</D:href>", "只有 404 propstat 的目录保留原样")
+	assert.True(t, strings.HasSuffix(body, "</D:multistatus>"))
+
+	rec, body = propfind("Brief", "t")
+	assert.Empty(t, rec.Header().Get("Preference-Applied"))
+	assert.Less(t, len(body), len(full))
+}
@@ -0,0 +1,198 @@
+package dav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"html"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"golang.org/x/net/webdav"
+)
+
+// propsFile 将 PROPPATCH 设置的 dead property 保存到文件系统，PROPFIND 时返回
+type propsFile struct {
+	webdav.File
+	ctx   context.Context
+	store mergefs.PropStore
+	name  string
+	fs    *WebdavFS
+	// 截断写入的文件在关闭前尚未出现在目标位置（如 COPY 写入的临时文件），属性在关闭后再保存
+	deferred bool
+	pending  []webdav.Proppatch
+}
+
+func wrapPropsFile(ctx context.Context, fs *WebdavFS, store mergefs.PropStore, file webdav.File, name string, flag int) webdav.File {
+	return &propsFile{
+		File:     file,
+		ctx:      ctx,
+		store:    store,
+		name:     name,
+		fs:       fs,
+		deferred: flag&(os.O_WRONLY|os.O_RDWR) != 0 && flag&os.O_TRUNC != 0,
+	}
+}
+
+// 由服务端计算的属性，客户端不可修改：目录的 RFC 4331 配额与 RFC 6578 同步令牌，文件的 ownCloud 校验和
+var (
+	quotaAvailableBytes = xml.Name{Space: "DAV:", Local: "quota-available-bytes"}
+	quotaUsedBytes      = xml.Name{Space: "DAV:", Local: "quota-used-bytes"}
+	syncTokenProp       = xml.Name{Space: "DAV:", Local: "sync-token"}
+	checksumsName       = xml.Name{Space: ownCloudNamespace, Local: checksumsProp}
+)
+
+func isLiveProp(name xml.Name) bool {
+	return name == quotaAvailableBytes || name == quotaUsedBytes || name == syncTokenProp || name == checksumsName
+}
+
+// liveProps 返回目录所在存储池的配额与当前的同步令牌，或文件的校验和
+func (f *propsFile) liveProps() map[xml.Name]webdav.Property {
+	if f.fs.Usage == nil && f.fs.SyncToken == nil && f.fs.Checksums == nil {
+		return nil
+	}
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil
+	}
+	result := make(map[xml.Name]webdav.Property)
+	switch {
+	case !info.IsDir():
+		if f.fs.Checksums == nil {
+			break
+		}
+		if sums, ok := f.fs.Checksums(f.ctx, f.name, info); ok {
+			result[checksumsName] = webdav.Property{
+				XMLName:  checksumsName,
+				InnerXML: []byte(`<checksum xmlns="` + ownCloudNamespace + `">` + sums.String() + `</checksum>`),
+			}
+		}
+	default:
+		if f.fs.Usage != nil {
+			if usage, ok := f.fs.Usage(f.name); ok {
+				result[quotaAvailableBytes] = webdav.Property{XMLName: quotaAvailableBytes, InnerXML: strconv.AppendUint(nil, usage.Free, 10)}
+				result[quotaUsedBytes] = webdav.Property{XMLName: quotaUsedBytes, InnerXML: strconv.AppendUint(nil, usage.Used(), 10)}
+			}
+		}
+		if f.fs.SyncToken != nil {
+			result[syncTokenProp] = webdav.Property{XMLName: syncTokenProp, InnerXML: []byte(html.EscapeString(f.fs.SyncToken()))}
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// propKey 与 parsePropKey 在 xml.Name 与保存的属性名 "{命名空间}名称" 之间转换
+func propKey(name xml.Name) string {
+	return "{" + name.Space + "}" + name.Local
+}
+
+func parsePropKey(key string) (xml.Name, bool) {
+	space, local, ok := strings.Cut(strings.TrimPrefix(key, "{"), "}")
+	if !ok || !strings.HasPrefix(key, "{") || local == "" {
+		return xml.Name{}, false
+	}
+	return xml.Name{Space: space, Local: local}, true
+}
+
+// DeadProps 返回保存的属性，webdav 仅支持 dead property 与固定的 live property，配额与同步令牌也由此返回
+func (f *propsFile) DeadProps() (map[xml.Name]webdav.Property, error) {
+	props, err := f.store.Props(f.name)
+	if err != nil && !errors.Is(err, mergefs.ErrNoProps) && !os.IsNotExist(err) {
+		return nil, err
+	}
+	result := f.liveProps()
+	if result == nil && len(props) == 0 {
+		return nil, nil
+	}
+	if result == nil {
+		result = make(map[xml.Name]webdav.Property, len(props))
+	}
+	for key, value := range props {
+		if name, ok := parsePropKey(key); ok && !isLiveProp(name) {
+			result[name] = webdav.Property{XMLName: name, InnerXML: value}
+		}
+	}
+	return result, nil
+}
+
+func (f *propsFile) Patch(patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	for _, patch := range patches {
+		for _, prop := range patch.Props {
+			if isLiveProp(prop.XMLName) {
+				return patchResult(patches, http.StatusForbidden), nil
+			}
+		}
+	}
+	if f.deferred {
+		f.pending = append(f.pending, patches...)
+		return patchResult(patches, http.StatusOK), nil
+	}
+	err := f.patch(patches)
+	switch {
+	case errors.Is(err, mergefs.ErrNoProps) || os.IsPermission(err):
+		return patchResult(patches, http.StatusForbidden), nil
+	case err != nil:
+		return nil, err
+	}
+	return patchResult(patches, http.StatusOK), nil
+}
+
+func (f *propsFile) patch(patches []webdav.Proppatch) error {
+	set := make(map[string][]byte)
+	var remove []string
+	for _, patch := range patches {
+		for _, prop := range patch.Props {
+			key := propKey(prop.XMLName)
+			if patch.Remove {
+				delete(set, key)
+				remove = append(remove, key)
+			} else {
+				set[key] = prop.InnerXML
+			}
+		}
+	}
+	return f.store.PatchProps(f.name, set, remove)
+}
+
+func (f *propsFile) Close() error {
+	err := f.File.Close()
+	if len(f.pending) > 0 {
+		// 写入失败或被放弃时目标不存在，属性随之丢弃
+		_ = f.patch(f.pending)
+		f.pending = nil
+	}
+	return err
+}
+
+// patchResult 返回所有属性均为 status 的结果
+func patchResult(patches []webdav.Proppatch, status int) []webdav.Propstat {
+	stat := webdav.Propstat{Status: status}
+	for _, patch := range patches {
+		for _, prop := range patch.Props {
+			stat.Props = append(stat.Props, webdav.Property{XMLName: prop.XMLName})
+		}
+	}
+	return []webdav.Propstat{stat}
+}
+
+// deadProps 与 patchProps 转发到实现了 DeadPropsHolder 的底层文件，
+// 否则与 webdav 对未实现的文件的处理相同：没有 dead property，且拒绝修改
+func deadProps(file webdav.File) (map[xml.Name]webdav.Property, error) {
+	if holder, ok := file.(webdav.DeadPropsHolder); ok {
+		return holder.DeadProps()
+	}
+	return nil, nil
+}
+
+func patchProps(file webdav.File, patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	if holder, ok := file.(webdav.DeadPropsHolder); ok {
+		return holder.Patch(patches)
+	}
+	return patchResult(patches, http.StatusForbidden), nil
+}
@@ -0,0 +1,89 @@
+package dav
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+func TestDeadProps(t *testing.T) {
+	pool := mergefs.NewOsPathFs(t.TempDir(), mergefs.SymlinkWithin)
+	assert.NoError(t, afero.WriteFile(pool, "/a.txt", []byte("hello"), 0o644))
+	if err := pool.PatchProps("/a.txt", nil, []string{"{test:}probe"}); errors.Is(err, mergefs.ErrNoProps) {
+		t.Skip("文件系统不支持扩展属性")
+	}
+	mountFs := mergefs.NewMountFs(afero.NewMemMapFs())
+	assert.NoError(t, mountFs.Mount("/pool", mergefs.NewAtomicFs(pool)))
+	handler := &webdav.Handler{
+		FileSystem: NewWebdavFS(mountFs),
+		LockSystem: webdav.NewMemLS(),
+	}
+	serve := func(method, target, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	hasLabel := func(target string) bool {
+		rec := serve("PROPFIND", target, `<?xml version="1.0"?><D:propfind xmlns:D="DAV:"><D:allprop/></D:propfind>`, "Depth", "0")
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		return strings.Contains(rec.Body.String(), "red")
+	}
+
+	rec := serve("PROPPATCH", "/pool/a.txt", `<?xml version="1.0"?>
+<D:propertyupdate xmlns:D="DAV:" xmlns:F="urn:finder"><D:set><D:prop><F:label>red</F:label></D:prop></D:set></D:propertyupdate>`)
+	assert.Equal(t, http.StatusMultiStatus, rec.Code)
+	assert.Contains(t, rec.Body.String(), "200 OK")
+	assert.True(t, hasLabel("/pool/a.txt"), "PROPFIND 应返回设置的属性")
+
+	assert.Equal(t, http.StatusCreated, serve("PUT", "/pool/a.txt", "world").Code)
+	assert.True(t, hasLabel("/pool/a.txt"), "覆盖上传应保留属性")
+
+	assert.Equal(t, http.StatusCreated, serve("COPY", "/pool/a.txt", "", "Destination", "/pool/b.txt").Code)
+	assert.True(t, hasLabel("/pool/b.txt"), "COPY 应复制属性")
+
+	assert.Equal(t, http.StatusCreated, serve("MOVE", "/pool/b.txt", "", "Destination", "/pool/c.txt").Code)
+	assert.True(t, hasLabel("/pool/c.txt"), "MOVE 应保留属性")
+
+	rec = serve("PROPPATCH", "/pool/a.txt", `<?xml version="1.0"?>
+<D:propertyupdate xmlns:D="DAV:" xmlns:F="urn:finder"><D:remove><D:prop><F:label/></D:prop></D:remove></D:propertyupdate>`)
+	assert.Equal(t, http.StatusMultiStatus, rec.Code)
+	assert.False(t, hasLabel("/pool/a.txt"), "属性应被删除")
+}
+
+func TestQuotaProps(t *testing.T) {
+	mountFs := mergefs.NewMountFs(afero.NewMemMapFs())
+	pool := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(pool, "/a.txt", []byte("hello"), 0o644))
+	assert.NoError(t, mountFs.Mount("/pool", pool))
+	fs := NewWebdavFS(mountFs)
+	fs.Usage = func(name string) (mergefs.DiskUsage, bool) {
+		return mergefs.DiskUsage{Total: 1000, Free: 400}, strings.HasPrefix(name, "/pool")
+	}
+	handler := &webdav.Handler{FileSystem: fs, LockSystem: webdav.NewMemLS()}
+	propfind := func(target string) string {
+		req := httptest.NewRequest("PROPFIND", target, strings.NewReader(`<?xml version="1.0"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:quota-available-bytes/><D:quota-used-bytes/></D:prop></D:propfind>`))
+		req.Header.Set("Depth", "0")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		return rec.Body.String()
+	}
+
+	body := propfind("/pool")
+	assert.Contains(t, body, ">400</", "目录应返回可用空间")
+	assert.Contains(t, body, ">600</", "目录应返回已使用空间")
+	assert.NotContains(t, propfind("/pool/a.txt"), ">400</", "文件不返回配额")
+	assert.NotContains(t, propfind("/"), ">400</", "无法获取空间信息时不返回配额")
+}
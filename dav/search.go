@@ -0,0 +1,284 @@
+package dav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+	"golang.org/x/net/webdav"
+)
+
+// daslHeader OPTIONS 响应中声明支持的查询语法
+const daslHeader = "<DAV:basicsearch>"
+
+// searchRequest RFC 5323 SEARCH 的请求体，仅支持 basicsearch
+type searchRequest struct {
+	XMLName xml.Name
+	Basic   *struct {
+		Select struct {
+			Prop *propList `xml:"DAV: prop"`
+		} `xml:"DAV: select"`
+		Scopes []struct {
+			Href  string `xml:"DAV: href"`
+			Depth string `xml:"DAV: depth"`
+		} `xml:"DAV: from>scope"`
+		Where    *searchNode `xml:"DAV: where"`
+		NResults int         `xml:"DAV: limit>nresults"`
+	} `xml:"DAV: basicsearch"`
+}
+
+// searchNode where 中的条件，按元素名解析
+type searchNode struct {
+	XMLName  xml.Name
+	Children []searchNode `xml:",any"`
+	Text     string       `xml:",chardata"`
+}
+
+func (n *searchNode) child(local string) *searchNode {
+	for i := range n.Children {
+		if n.Children[i].XMLName.Space == "DAV:" && n.Children[i].XMLName.Local == local {
+			return &n.Children[i]
+		}
+	}
+	return nil
+}
+
+// searchMatcher 判断文件是否满足条件
+type searchMatcher func(info os.FileInfo) bool
+
+var errSearchGrammar = errors.New("unsupported search condition")
+
+// compileSearch 将 where 条件转换为 searchMatcher，支持 and、or、not、is-collection，
+// displayname 的 like 与 eq，getlastmodified 与 getcontentlength 的比较
+func compileSearch(node *searchNode) (searchMatcher, error) {
+	if node.XMLName.Space != "DAV:" {
+		return nil, errSearchGrammar
+	}
+	switch node.XMLName.Local {
+	case "and", "or":
+		var matchers []searchMatcher
+		for i := range node.Children {
+			matcher, err := compileSearch(&node.Children[i])
+			if err != nil {
+				return nil, err
+			}
+			matchers = append(matchers, matcher)
+		}
+		all := node.XMLName.Local == "and"
+		return func(info os.FileInfo) bool {
+			for _, matcher := range matchers {
+				if matcher(info) != all {
+					return !all
+				}
+			}
+			return all
+		}, nil
+	case "not":
+		if len(node.Children) != 1 {
+			return nil, errSearchGrammar
+		}
+		matcher, err := compileSearch(&node.Children[0])
+		if err != nil {
+			return nil, err
+		}
+		return func(info os.FileInfo) bool { return !matcher(info) }, nil
+	case "is-collection":
+		return func(info os.FileInfo) bool { return info.IsDir() }, nil
+	}
+	prop, literal := node.child("prop"), node.child("literal")
+	if prop == nil || literal == nil || len(prop.Children) != 1 || prop.Children[0].XMLName.Space != "DAV:" {
+		return nil, errSearchGrammar
+	}
+	op := node.XMLName.Local
+	switch prop.Children[0].XMLName.Local {
+	case "displayname":
+		switch op {
+		case "like":
+			pattern, err := likePattern(literal.Text)
+			if err != nil {
+				return nil, err
+			}
+			return func(info os.FileInfo) bool { return pattern.MatchString(info.Name()) }, nil
+		case "eq":
+			return func(info os.FileInfo) bool { return strings.EqualFold(info.Name(), literal.Text) }, nil
+		}
+	case "getlastmodified":
+		value, err := parseSearchTime(strings.TrimSpace(literal.Text))
+		if err != nil {
+			return nil, errSearchGrammar
+		}
+		if compare, ok := searchComparator(op); ok {
+			return func(info os.FileInfo) bool { return compare(info.ModTime().Unix(), value.Unix()) }, nil
+		}
+	case "getcontentlength":
+		value, err := strconv.ParseInt(strings.TrimSpace(literal.Text), 10, 64)
+		if err != nil {
+			return nil, errSearchGrammar
+		}
+		if compare, ok := searchComparator(op); ok {
+			return func(info os.FileInfo) bool { return !info.IsDir() && compare(info.Size(), value) }, nil
+		}
+	}
+	return nil, errSearchGrammar
+}
+
+func searchComparator(op string) (func(a, b int64) bool, bool) {
+	switch op {
+	case "eq":
+		return func(a, b int64) bool { return a == b }, true
+	case "lt":
+		return func(a, b int64) bool { return a < b }, true
+	case "lte":
+		return func(a, b int64) bool { return a <= b }, true
+	case "gt":
+		return func(a, b int64) bool { return a > b }, true
+	case "gte":
+		return func(a, b int64) bool { return a >= b }, true
+	}
+	return nil, false
+}
+
+// likePattern 将 like 的模式转换为忽略大小写的正则，% 匹配任意个字符，_ 匹配单个字符
+func likePattern(pattern string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			expr.WriteString(".*")
+		case '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+// parseSearchTime 解析 getlastmodified 的 HTTP 日期，也接受 RFC 3339 格式
+func parseSearchTime(value string) (time.Time, error) {
+	if t, err := http.ParseTime(value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// handleSearch 处理 SEARCH，遍历合并后的文件系统查找满足条件的文件，
+// 结果超过 maxResults 时截断，并以 507 标记查询范围
+func handleSearch(w http.ResponseWriter, r *http.Request, handler *webdav.Handler, fs afero.Fs, maxResults int) {
+	var request searchRequest
+	if err := xml.NewDecoder(io.LimitReader(r.Body, maxReportBody)).Decode(&request); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if request.XMLName != (xml.Name{Space: "DAV:", Local: "searchrequest"}) || request.Basic == nil {
+		http.Error(w, "only DAV:basicsearch is supported", http.StatusUnprocessableEntity)
+		return
+	}
+	basic := request.Basic
+	matcher := func(os.FileInfo) bool { return true }
+	if basic.Where != nil {
+		if len(basic.Where.Children) != 1 {
+			http.Error(w, errSearchGrammar.Error(), http.StatusBadRequest)
+			return
+		}
+		var err error
+		if matcher, err = compileSearch(&basic.Where.Children[0]); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	limit := maxResults
+	if basic.NResults > 0 && (limit <= 0 || basic.NResults < limit) {
+		limit = basic.NResults
+	}
+	if len(basic.Scopes) == 0 {
+		http.Error(w, "search scope is required", http.StatusBadRequest)
+		return
+	}
+
+	var matches []string
+	var truncated []string
+	for _, scope := range basic.Scopes {
+		u, err := url.Parse(scope.Href)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		root := mergefs.NormalizePath(strings.TrimPrefix(u.Path, handler.Prefix))
+		depth := -1
+		switch strings.TrimSpace(scope.Depth) {
+		case "0":
+			depth = 0
+		case "1":
+			depth = 1
+		}
+		var stop bool
+		matches, stop = searchWalk(r.Context(), fs, root, depth, matcher, matches, limit)
+		if err := r.Context().Err(); err != nil {
+			return
+		}
+		if stop {
+			truncated = append(truncated, root)
+			break
+		}
+	}
+
+	body := basic.Select.Prop.propfindBody()
+	var responses [][]byte
+	for _, name := range matches {
+		for _, response := range propfind(r, handler, name, "0", body) {
+			responses = append(responses, response.InnerXML)
+		}
+	}
+	for _, root := range truncated {
+		responses = append(responses, statusResponse(handler.Prefix, root, http.StatusInsufficientStorage, "result limit reached"))
+	}
+	writeMultistatus(w, responses, "")
+}
+
+// searchWalk 按名称顺序遍历 root，depth 为 -1 时不限层数，结果达到 limit 时返回 true
+func searchWalk(ctx context.Context, fs afero.Fs, root string, depth int, matcher searchMatcher, matches []string, limit int) ([]string, bool) {
+	info, err := fs.Stat(root)
+	if err != nil {
+		return matches, false
+	}
+	var walk func(name string, info os.FileInfo, level int) bool
+	walk = func(name string, info os.FileInfo, level int) bool {
+		if ctx.Err() != nil {
+			return true
+		}
+		if matcher(info) {
+			if limit > 0 && len(matches) >= limit {
+				return true
+			}
+			matches = append(matches, name)
+		}
+		if !info.IsDir() || (depth >= 0 && level >= depth) {
+			return false
+		}
+		children, err := afero.ReadDir(fs, name)
+		if err != nil {
+			return false
+		}
+		for _, child := range children {
+			if walk(path.Join(name, child.Name()), child, level+1) {
+				return true
+			}
+		}
+		return false
+	}
+	return matches, walk(root, info, 0)
+}
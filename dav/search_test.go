@@ -0,0 +1,59 @@
+package dav
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+func TestSearch(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	old, recent := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for name, mtime := range map[string]time.Time{
+		"/docs/Report-2020.txt": old,
+		"/docs/report-2024.txt": recent,
+		"/docs/sub/report.md":   recent,
+		"/docs/notes.txt":       recent,
+	} {
+		assert.NoError(t, afero.WriteFile(fs, name, []byte("x"), 0o644))
+		assert.NoError(t, fs.Chtimes(name, mtime, mtime))
+	}
+	mountFs := mergefs.NewMountFs(fs)
+	handler := &webdav.Handler{Prefix: "/dav", FileSystem: NewWebdavFS(mountFs), LockSystem: webdav.NewMemLS()}
+	search := func(where string, depth string, limit int) (int, string) {
+		body := `<?xml version="1.0"?><d:searchrequest xmlns:d="DAV:"><d:basicsearch>
+<d:select><d:prop><d:displayname/></d:prop></d:select>
+<d:from><d:scope><d:href>/dav/docs</d:href><d:depth>` + depth + `</d:depth></d:scope></d:from>
+<d:where>` + where + `</d:where></d:basicsearch></d:searchrequest>`
+		rec := httptest.NewRecorder()
+		handleSearch(rec, httptest.NewRequest("SEARCH", "/dav/", strings.NewReader(body)), handler, mountFs, limit)
+		return rec.Code, rec.Body.String()
+	}
+	like := `<d:like><d:prop><d:displayname/></d:prop><d:literal>report%</d:literal></d:like>`
+
+	code, body := search(like, "infinity", 0)
+	assert.Equal(t, http.StatusMultiStatus, code)
+	assert.Contains(t, body, "/dav/docs/Report-2020.txt", "like 忽略大小写")
+	assert.Contains(t, body, "/dav/docs/sub/report.md")
+	assert.NotContains(t, body, "notes.txt")
+
+	_, body = search(like, "1", 0)
+	assert.NotContains(t, body, "report.md", "depth 为 1 时不查找子目录")
+
+	_, body = search(`<d:and>`+like+`<d:gt><d:prop><d:getlastmodified/></d:prop><d:literal>Sat, 01 Jan 2022 00:00:00 GMT</d:literal></d:gt></d:and>`, "infinity", 0)
+	assert.NotContains(t, body, "Report-2020.txt", "按修改时间过滤")
+	assert.Contains(t, body, "report-2024.txt")
+
+	_, body = search(like, "infinity", 1)
+	assert.Contains(t, body, "HTTP/1.1 507 Insufficient Storage", "超过结果上限时截断")
+
+	code, _ = search(`<d:contains>x</d:contains>`, "infinity", 0)
+	assert.Equal(t, http.StatusBadRequest, code, "不支持的条件")
+}
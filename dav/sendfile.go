@@ -0,0 +1,57 @@
+package dav
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"golang.org/x/net/webdav"
+)
+
+// handleSendfile 以 X-Accel-Redirect 或 X-Sendfile 响应本地存储池中文件的 GET，由前端代理读取并发送文件，
+// Range 与条件请求同样由代理处理。文件不在本地存储池或小于 min_size 时返回 false，仍由 webdav.Handler 发送
+func handleSendfile(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, handler *webdav.Handler, user string) bool {
+	cfg := ctx.Config.Webdav.Sendfile
+	name := strings.TrimPrefix(r.URL.Path, handler.Prefix)
+	// 经由用户的文件系统检查，隐藏、排除与没有读取权限的文件不会被转交
+	info, err := handler.FileSystem.Stat(r.Context(), name)
+	if err != nil || !info.Mode().IsRegular() || info.Size() < int64(cfg.MinSize) {
+		return false
+	}
+	real, ok := ctx.RealPath(user, name)
+	if !ok {
+		return false
+	}
+	header := w.Header()
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	header.Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	if etag, err := fileETag(r.Context(), info); err == nil {
+		header.Set("ETag", etag)
+	}
+	switch cfg.Mode {
+	case "x-accel-redirect":
+		target := (&url.URL{Path: strings.TrimSuffix(cfg.Prefix, "/") + "/" + strings.TrimPrefix(filepath.ToSlash(real), "/")}).EscapedPath()
+		header.Set("X-Accel-Redirect", target)
+	case "x-sendfile":
+		header.Set("X-Sendfile", real)
+	}
+	w.WriteHeader(http.StatusOK)
+	return true
+}
+
+// fileETag 与 webdav.Handler 返回相同的 ETag
+func fileETag(ctx context.Context, info os.FileInfo) (string, error) {
+	if etager, ok := info.(webdav.ETager); ok {
+		return etager.ETag(ctx)
+	}
+	return fmt.Sprintf(`"%x%x"`, info.ModTime().UnixNano(), info.Size()), nil
+}
@@ -0,0 +1,48 @@
+package dav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+func TestSendfile(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0o644))
+	assert.NoError(t, os.Symlink(os.TempDir(), filepath.Join(dir, "escape")))
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(config, []byte(`
+bind: 127.0.0.1:0
+pools:
+  data:
+    path: `+dir+`
+    permission: r
+webdav:
+  enabled: true
+  sendfile:
+    mode: x-accel-redirect
+`), 0o644))
+	cfg, err := common.LoadConfig(config)
+	assert.NoError(t, err)
+	ctx, err := common.NewContext(context.Background(), cfg)
+	assert.NoError(t, err)
+	handler := &webdav.Handler{FileSystem: NewWebdavFS(ctx.LoadUserFS("guest")), LockSystem: webdav.NewMemLS()}
+
+	rec := httptest.NewRecorder()
+	assert.True(t, handleSendfile(rec, httptest.NewRequest(http.MethodGet, "/data/a.txt", nil), ctx, handler, "guest"))
+	real, _ := filepath.EvalSymlinks(filepath.Join(dir, "a.txt"))
+	assert.Equal(t, "/_sendfile"+filepath.ToSlash(real), rec.Header().Get("X-Accel-Redirect"))
+	assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Empty(t, rec.Body.String(), "文件内容由代理发送")
+
+	assert.False(t, handleSendfile(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/data", nil), ctx, handler, "guest"), "目录不转交")
+	_, ok := ctx.RealPath("guest", "/data/escape")
+	assert.False(t, ok, "指向存储池以外的符号链接不转交")
+}
@@ -0,0 +1,51 @@
+package dav
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// spoolPattern 暂存文件的名称，启动时清理上次退出时残留的文件
+const spoolPattern = "webdav-spool-*"
+
+// spoolFile 完整接收后的上传内容，关闭时删除
+type spoolFile struct {
+	*os.File
+}
+
+func (f *spoolFile) Close() error {
+	err := f.File.Close()
+	_ = os.Remove(f.Name())
+	return err
+}
+
+// spoolUpload 将请求体完整写入 dir 中的暂存文件，返回从头读取该文件的请求体与大小。
+// 上传中断时目标路径不会被写入，接收完成后再经由存储池正常的写入流程放入目标位置
+func spoolUpload(dir string, body io.Reader) (*spoolFile, int64, error) {
+	file, err := os.CreateTemp(dir, spoolPattern)
+	if err != nil {
+		return nil, 0, err
+	}
+	spool := &spoolFile{File: file}
+	size, err := io.Copy(file, body)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = spool.Close()
+		return nil, 0, err
+	}
+	return spool, size, nil
+}
+
+// cleanSpool 删除暂存目录中残留的文件
+func cleanSpool(dir string) {
+	matches, _ := filepath.Glob(filepath.Join(dir, spoolPattern))
+	for _, name := range matches {
+		if err := os.Remove(name); err != nil {
+			slog.Warn("|webdav| Failed to remove stale spool file.", "path", name, "err", err)
+		}
+	}
+}
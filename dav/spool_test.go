@@ -0,0 +1,42 @@
+package dav
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpoolUpload(t *testing.T) {
+	dir := t.TempDir()
+	file, size, err := spoolUpload(dir, strings.NewReader("hello"))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, size)
+	data, err := io.ReadAll(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data), "从头读取暂存的内容")
+	assert.NoError(t, file.Close())
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries, "关闭后删除暂存文件")
+
+	broken := io.MultiReader(strings.NewReader("hel"), &failReader{})
+	_, _, err = spoolUpload(dir, broken)
+	assert.Error(t, err)
+	entries, _ = os.ReadDir(dir)
+	assert.Empty(t, entries, "上传中断时删除暂存文件")
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "webdav-spool-123"), nil, 0o600))
+	cleanSpool(dir)
+	entries, _ = os.ReadDir(dir)
+	assert.Empty(t, entries, "启动时清理残留的暂存文件")
+}
+
+type failReader struct{}
+
+func (*failReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
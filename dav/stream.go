@@ -0,0 +1,95 @@
+package dav
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"golang.org/x/net/webdav"
+)
+
+// multistatusStart 流式输出的 multistatus 开头，与 webdav.Handler 输出的一致
+const multistatusStart = `<?xml version="1.0" encoding="UTF-8"?><D:multistatus xmlns:D="DAV:">`
+
+// streamPropfind 处理目录的 Depth: 1 PROPFIND。webdav.Handler 会一次读取目录的全部条目，
+// 此处按 batch 分批读取，逐个条目以 Depth: 0 查询属性并输出，每批之后刷新，内存占用与目录大小无关。
+// 请求不适用（不是 Depth: 1 或不是目录）时返回 false，交由 webdav.Handler 处理
+func streamPropfind(w http.ResponseWriter, r *http.Request, handler *webdav.Handler, batch int) bool {
+	if r.Method != "PROPFIND" || strings.TrimSpace(r.Header.Get("Depth")) != "1" {
+		return false
+	}
+	name := mergefs.NormalizePath(strings.TrimPrefix(r.URL.Path, handler.Prefix))
+	info, err := handler.FileSystem.Stat(r.Context(), name)
+	if err != nil || !info.IsDir() {
+		return false
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxReportBody))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return true
+	}
+	self := propfindEntry(r, handler, name, body)
+	if self.code != http.StatusMultiStatus {
+		// 请求体错误等，原样返回 webdav.Handler 的响应
+		for key, values := range self.header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(self.code)
+		_, _ = w.Write(self.body.Bytes())
+		return true
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_, _ = io.WriteString(w, multistatusStart)
+	_, _ = w.Write(multistatusInner(self.body.Bytes()))
+
+	dir, err := handler.FileSystem.OpenFile(r.Context(), name, os.O_RDONLY, 0)
+	if err == nil {
+		defer dir.Close()
+		flusher := http.NewResponseController(w)
+		for r.Context().Err() == nil {
+			infos, err := dir.Readdir(batch)
+			for _, child := range infos {
+				entry := propfindEntry(r, handler, path.Join(name, child.Name()), body)
+				if entry.code == http.StatusMultiStatus {
+					_, _ = w.Write(multistatusInner(entry.body.Bytes()))
+				}
+			}
+			_ = flusher.Flush()
+			if err != nil || len(infos) == 0 {
+				break
+			}
+		}
+	}
+	_, _ = io.WriteString(w, "</D:multistatus>")
+	return true
+}
+
+// propfindEntry 通过 webdav.Handler 以 Depth: 0 查询单个路径的属性
+func propfindEntry(r *http.Request, handler *webdav.Handler, name string, body []byte) *bufferedResponse {
+	rec := &bufferedResponse{header: make(http.Header)}
+	target := (&url.URL{Path: path.Join(handler.Prefix, name)}).EscapedPath()
+	req, err := http.NewRequestWithContext(r.Context(), "PROPFIND", target, bytes.NewReader(body))
+	if err != nil {
+		rec.code = http.StatusBadRequest
+		return rec
+	}
+	req.Header.Set("Depth", "0")
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// multistatusInner 返回 multistatus 中的 D:response 元素
+func multistatusInner(body []byte) []byte {
+	start := bytes.Index(body, []byte("<D:response"))
+	end := bytes.LastIndex(body, []byte("</D:multistatus>"))
+	if start < 0 || end < start {
+		return nil
+	}
+	return body[start:end]
+}
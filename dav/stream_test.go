@@ -0,0 +1,49 @@
+package dav
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+func TestStreamPropfind(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for i := range 25 {
+		assert.NoError(t, afero.WriteFile(fs, fmt.Sprintf("/dir/%02d.txt", i), []byte("a"), 0o644))
+	}
+	assert.NoError(t, fs.MkdirAll("/dir/sub", 0o755))
+	handler := &webdav.Handler{FileSystem: NewWebdavFS(mergefs.NewMountFs(fs)), LockSystem: webdav.NewMemLS()}
+	propfind := func(stream bool, target, depth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PROPFIND", target, strings.NewReader(`<?xml version="1.0"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:getcontentlength/><d:resourcetype/></d:prop></d:propfind>`))
+		req.Header.Set("Depth", depth)
+		rec := httptest.NewRecorder()
+		if !stream || !streamPropfind(rec, req, handler, 10) {
+			handler.ServeHTTP(rec, req)
+		}
+		return rec
+	}
+	responses := func(body string) []string {
+		parts := strings.Split(body, "<D:response>")[1:]
+		slices.Sort(parts)
+		return parts
+	}
+
+	expected, streamed := propfind(false, "/dir", "1"), propfind(true, "/dir", "1")
+	assert.Equal(t, http.StatusMultiStatus, streamed.Code)
+	assert.Len(t, responses(streamed.Body.String()), 27, "目录自身与全部子项")
+	assert.Equal(t, responses(expected.Body.String()), responses(streamed.Body.String()), "与 webdav.Handler 的结果一致")
+	assert.True(t, strings.HasSuffix(streamed.Body.String(), "</D:multistatus>"))
+
+	req := httptest.NewRequest("PROPFIND", "/dir/00.txt", nil)
+	req.Header.Set("Depth", "1")
+	assert.False(t, streamPropfind(httptest.NewRecorder(), req, handler, 10), "文件交由 webdav.Handler 处理")
+}
@@ -0,0 +1,244 @@
+package dav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"code.d7z.net/packages/webdav-server/events"
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"golang.org/x/net/webdav"
+)
+
+// syncTokenPrefix 同步令牌需为 URI，以此前缀包装变更日志的令牌
+const syncTokenPrefix = "urn:x-webdav-server:sync:"
+
+// maxReportBody REPORT 请求体的大小上限
+const maxReportBody = 1 << 20
+
+// syncCollection RFC 6578 sync-collection REPORT 的请求体
+type syncCollection struct {
+	XMLName   xml.Name
+	SyncToken string    `xml:"DAV: sync-token"`
+	SyncLevel string    `xml:"DAV: sync-level"`
+	Prop      *propList `xml:"DAV: prop"`
+}
+
+// propList 请求的属性名，命名空间可能声明在上层元素中，需解析后再转发
+type propList []xml.Name
+
+func (p *propList) UnmarshalXML(d *xml.Decoder, _ xml.StartElement) error {
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			*p = append(*p, t.Name)
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// propfindBody 生成查询 props 的 PROPFIND 请求体，未指定属性时查询全部
+func (p *propList) propfindBody() string {
+	if p == nil || len(*p) == 0 {
+		return `<?xml version="1.0" encoding="utf-8"?><D:propfind xmlns:D="DAV:"><D:allprop/></D:propfind>`
+	}
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0" encoding="utf-8"?><D:propfind xmlns:D="DAV:"><D:prop>`)
+	for _, name := range *p {
+		body.WriteString("<" + name.Local + ` xmlns="` + html.EscapeString(name.Space) + `"/>`)
+	}
+	body.WriteString(`</D:prop></D:propfind>`)
+	return body.String()
+}
+
+// handleSyncCollection 处理 sync-collection REPORT，返回令牌之后有变更的成员，
+// 未提供令牌时返回全部成员。成员的属性由 webdav.Handler 以 PROPFIND 查询
+func handleSyncCollection(w http.ResponseWriter, r *http.Request, handler *webdav.Handler, journal *events.Journal, user string) {
+	var report syncCollection
+	if err := xml.NewDecoder(io.LimitReader(r.Body, maxReportBody)).Decode(&report); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if report.XMLName != (xml.Name{Space: "DAV:", Local: "sync-collection"}) {
+		writePreconditionError(w, "supported-report")
+		return
+	}
+	name := mergefs.NormalizePath(strings.TrimPrefix(r.URL.Path, handler.Prefix))
+	info, err := handler.FileSystem.Stat(r.Context(), name)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if !info.IsDir() {
+		writePreconditionError(w, "supported-report")
+		return
+	}
+	infinite := strings.TrimSpace(report.SyncLevel) == "infinite"
+	body := report.Prop.propfindBody()
+
+	var responses [][]byte
+	var token string
+	if report.SyncToken == "" {
+		// 先取得令牌，列出成员期间的变更在下次同步时再次返回
+		token = journal.Token()
+		depth := "1"
+		if infinite {
+			depth = "infinity"
+		}
+		self := path.Join(handler.Prefix, name)
+		for _, response := range propfind(r, handler, name, depth, body) {
+			if href, err := url.PathUnescape(response.Href); err != nil || path.Clean(href) != self {
+				responses = append(responses, response.InnerXML)
+			}
+		}
+	} else {
+		entries, current, ok := journal.Since(strings.TrimPrefix(report.SyncToken, syncTokenPrefix))
+		if !ok || !strings.HasPrefix(report.SyncToken, syncTokenPrefix) {
+			writePreconditionError(w, "valid-sync-token")
+			return
+		}
+		token = current
+		for _, member := range syncMembers(entries, name, infinite, user) {
+			found := propfind(r, handler, member, "0", body)
+			if len(found) == 0 {
+				// 已删除的成员
+				responses = append(responses, statusResponse(handler.Prefix, member, http.StatusNotFound, ""))
+			}
+			for _, response := range found {
+				responses = append(responses, response.InnerXML)
+			}
+		}
+	}
+
+	writeMultistatus(w, responses, "<D:sync-token>"+html.EscapeString(syncTokenPrefix+token)+"</D:sync-token>")
+}
+
+// writeMultistatus 输出 multistatus，responses 为各 D:response 元素的内容，extra 附加在其后
+func writeMultistatus(w http.ResponseWriter, responses [][]byte, extra string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	var out bytes.Buffer
+	out.WriteString(`<?xml version="1.0" encoding="UTF-8"?><D:multistatus xmlns:D="DAV:">`)
+	for _, response := range responses {
+		out.WriteString("<D:response>")
+		out.Write(response)
+		out.WriteString("</D:response>")
+	}
+	out.WriteString(extra + "</D:multistatus>")
+	_, _ = w.Write(out.Bytes())
+}
+
+// syncMembers 返回变更涉及的成员路径，sync-level 为 1 时深层的变更归入其所在的直接子项，
+// 其他用户私有空间中的变更被忽略
+func syncMembers(entries []events.JournalEntry, root string, infinite bool, user string) []string {
+	var members []string
+	for _, entry := range entries {
+		if entry.User != "" && entry.User != user {
+			continue
+		}
+		rel, ok := strings.CutPrefix(mergefs.NormalizePath(entry.Name), root)
+		if !ok || (root != "/" && rel != "" && rel[0] != '/') {
+			continue
+		}
+		rel = strings.TrimPrefix(rel, "/")
+		if rel == "" {
+			continue
+		}
+		if !infinite {
+			rel, _, _ = strings.Cut(rel, "/")
+		}
+		member := path.Join(root, rel)
+		if !slices.Contains(members, member) {
+			members = append(members, member)
+		}
+	}
+	slices.Sort(members)
+	return members
+}
+
+// statusResponse 生成只有状态的 D:response 内容
+func statusResponse(prefix, name string, code int, description string) []byte {
+	href := (&url.URL{Path: path.Join(prefix, name)}).EscapedPath()
+	response := "<D:href>" + html.EscapeString(href) + "</D:href><D:status>HTTP/1.1 " + strconv.Itoa(code) + " " + http.StatusText(code) + "</D:status>"
+	if description != "" {
+		response += "<D:responsedescription>" + html.EscapeString(description) + "</D:responsedescription>"
+	}
+	return []byte(response)
+}
+
+// davResponse multistatus 中的一项，InnerXML 沿用 webdav.Handler 输出的 D: 前缀
+type davResponse struct {
+	Href     string `xml:"DAV: href"`
+	InnerXML []byte `xml:",innerxml"`
+}
+
+// propfind 通过 webdav.Handler 查询路径的属性，路径不存在时返回空
+func propfind(r *http.Request, handler *webdav.Handler, name, depth, body string) []davResponse {
+	target := (&url.URL{Path: path.Join(handler.Prefix, name)}).EscapedPath()
+	req, err := http.NewRequestWithContext(r.Context(), "PROPFIND", target, strings.NewReader(body))
+	if err != nil {
+		return nil
+	}
+	req.Header.Set("Depth", depth)
+	rec := &bufferedResponse{header: make(http.Header)}
+	handler.ServeHTTP(rec, req)
+	if rec.code != http.StatusMultiStatus {
+		return nil
+	}
+	var responses []davResponse
+	decoder := xml.NewDecoder(&rec.body)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return responses
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name != (xml.Name{Space: "DAV:", Local: "response"}) {
+			continue
+		}
+		var response davResponse
+		if err := decoder.DecodeElement(&response, &start); err != nil {
+			return responses
+		}
+		responses = append(responses, response)
+	}
+}
+
+// bufferedResponse 在内存中接收 webdav.Handler 的响应
+type bufferedResponse struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(code int) {
+	if b.code == 0 {
+		b.code = code
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.code == 0 {
+		b.code = http.StatusOK
+	}
+	return b.body.Write(p)
+}
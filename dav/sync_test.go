@@ -0,0 +1,66 @@
+package dav
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/events"
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+func TestSyncCollection(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/dir/a.txt", []byte("a"), 0o644))
+	assert.NoError(t, afero.WriteFile(fs, "/dir/sub/b.txt", []byte("b"), 0o644))
+	journal := events.NewJournal(16)
+	handler := &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: NewWebdavFS(mergefs.NewMountFs(fs)),
+		LockSystem: webdav.NewMemLS(),
+	}
+	tokenPattern := regexp.MustCompile(`<D:sync-token>([^<]+)</D:sync-token>`)
+	report := func(token string) (int, string, string) {
+		body := `<?xml version="1.0"?><d:sync-collection xmlns:d="DAV:"><d:sync-token>` + token +
+			`</d:sync-token><d:sync-level>1</d:sync-level><d:prop><d:getetag/></d:prop></d:sync-collection>`
+		rec := httptest.NewRecorder()
+		handleSyncCollection(rec, httptest.NewRequest("REPORT", "/dav/dir", strings.NewReader(body)), handler, journal, "alice")
+		match := tokenPattern.FindStringSubmatch(rec.Body.String())
+		if match == nil {
+			return rec.Code, rec.Body.String(), ""
+		}
+		return rec.Code, rec.Body.String(), match[1]
+	}
+
+	code, body, token := report("")
+	assert.Equal(t, http.StatusMultiStatus, code)
+	assert.Contains(t, body, "/dav/dir/a.txt", "首次同步返回全部成员")
+	assert.Contains(t, body, "/dav/dir/sub/")
+	assert.NotContains(t, body, "<D:href>/dav/dir/</D:href>", "不返回目录本身")
+	assert.NotContains(t, body, "b.txt", "sync-level 为 1 时不返回深层的成员")
+
+	assert.NoError(t, fs.Remove("/dir/a.txt"))
+	journal.Append("/dir/a.txt", "")
+	journal.Append("/dir/sub/b.txt", "")
+	journal.Append("/dir/private.txt", "bob")
+	journal.Append("/other/c.txt", "")
+	code, body, next := report(token)
+	assert.Equal(t, http.StatusMultiStatus, code)
+	assert.Contains(t, body, "<D:href>/dav/dir/a.txt</D:href><D:status>HTTP/1.1 404 Not Found</D:status>", "已删除的成员返回 404")
+	assert.Contains(t, body, "/dav/dir/sub/", "深层的变更归入直接子项")
+	assert.NotContains(t, body, "private.txt", "忽略其他用户私有空间的变更")
+	assert.NotContains(t, body, "c.txt")
+	assert.NotEqual(t, token, next)
+
+	_, body, _ = report(next)
+	assert.NotContains(t, body, "<D:response>", "没有新的变更")
+
+	code, body, _ = report(syncTokenPrefix + "invalid-1")
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Contains(t, body, "valid-sync-token")
+}
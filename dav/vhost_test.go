@@ -0,0 +1,75 @@
+package dav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserPrefixAndHosts(t *testing.T) {
+	dir := t.TempDir()
+	for _, pool := range []string{"data", "other"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, pool), 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, pool, "a.txt"), []byte(pool), 0o644))
+	}
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(config, []byte(`
+bind: 127.0.0.1:0
+users:
+  alice:
+    password: alice
+  bob:
+    password: bob
+pools:
+  data:
+    path: `+filepath.Join(dir, "data")+`
+    permission: rw
+  other:
+    path: `+filepath.Join(dir, "other")+`
+    permission: rw
+webdav:
+  enabled: true
+  user_prefix: true
+  hosts:
+    Team.Example.com:
+      pools: [data]
+`), 0o644))
+	cfg, err := common.LoadConfig(config)
+	assert.NoError(t, err)
+	ctx, err := common.NewContext(context.Background(), cfg)
+	assert.NoError(t, err)
+	router := chi.NewRouter()
+	router.Route(cfg.Webdav.Prefix, WithWebdav(ctx))
+	request := func(method, host, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Host = host
+		req.SetBasicAuth("alice", "alice")
+		req.Header.Set("Depth", "1")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request(http.MethodGet, "localhost", "/dav/alice/other/a.txt")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "other", rec.Body.String())
+	assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "localhost", "/dav/bob/data/a.txt").Code, "其他用户的路径")
+	rec = request(http.MethodGet, "localhost", "/dav")
+	assert.Equal(t, http.StatusTemporaryRedirect, rec.Code)
+	assert.Equal(t, "/dav/alice/", rec.Header().Get("Location"))
+
+	rec = request("PROPFIND", "team.example.com:8080", "/dav/alice/")
+	assert.Equal(t, http.StatusMultiStatus, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<D:href>/dav/alice/data/</D:href>")
+	assert.False(t, strings.Contains(rec.Body.String(), "/dav/alice/other/"), "站点只包含配置的存储池")
+	assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "team.example.com", "/dav/alice/other/a.txt").Code)
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "team.example.com", "/dav/alice/data/a.txt").Code)
+}
@@ -1,12 +1,23 @@
 package dav
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"code.d7z.net/packages/webdav-server/common"
+	"code.d7z.net/packages/webdav-server/events"
+	"code.d7z.net/packages/webdav-server/mergefs"
 	"github.com/go-chi/chi/v5"
+	"github.com/spf13/afero"
 	"golang.org/x/net/webdav"
 )
 
@@ -25,8 +36,56 @@ func init() {
 
 func WithWebdav(ctx *common.FsContext) func(r chi.Router) {
 	locker := webdav.NewMemLS()
+	etagConfig := ctx.Config.Webdav.ETag
+	etagger, err := NewETagger(ETagOptions{
+		Mode:    etagConfig.Mode,
+		Cache:   etagConfig.Cache,
+		MaxSize: int64(etagConfig.MaxSize),
+	})
+	if err != nil {
+		slog.Warn("|webdav| Failed to load etag cache, hashes are kept in memory only.", "cache", etagConfig.Cache, "err", err)
+	}
+	timeouts := lockTimeouts{
+		def: ctx.Config.Webdav.Lock.DefaultTimeout,
+		min: ctx.Config.Webdav.Lock.MinTimeout,
+		max: ctx.Config.Webdav.Lock.MaxTimeout,
+	}
+	checksums := NewChecksumStore(int64(ctx.Config.Webdav.Checksum.MaxSize), checksumCacheSize)
+	if dir := ctx.Config.Webdav.Spool.Dir; dir != "" {
+		cleanSpool(dir)
+	}
+	var stats *davMetrics
+	if ctx.Config.Metrics.Enabled {
+		stats = newDavMetrics(ctx.Metrics)
+	}
+	jobs := newJobStore(ctx.Config.Webdav.JobRetention)
+	handlers := newHandlerCache(func(user, prefix string, userFs afero.Fs) *webdav.Handler {
+		fs := NewWebdavFS(userFs)
+		fs.Usage = func(name string) (mergefs.DiskUsage, bool) {
+			return ctx.Usage(user, name)
+		}
+		if etagConfig.Mode != ETagMtime {
+			fs.ETag = func(c context.Context, name string, info os.FileInfo) (string, error) {
+				return etagger.ETag(c, userFs, ctx.CacheKey(user, name), name, info)
+			}
+		}
+		fs.SyncToken = func() string {
+			return syncTokenPrefix + ctx.Journal.Token()
+		}
+		if ctx.Config.Webdav.Checksum.Enabled {
+			fs.Checksums = func(c context.Context, name string, info os.FileInfo) (Checksums, bool) {
+				return checksums.Get(c, userFs, ctx.CacheKey(user, name), name, info, isChecksumRequest(c))
+			}
+		}
+		return &webdav.Handler{
+			Prefix:     prefix,
+			FileSystem: fs,
+			LockSystem: locker,
+		}
+	})
 	return func(r chi.Router) {
 		r.HandleFunc("/*", func(writer http.ResponseWriter, request *http.Request) {
+			start := time.Now()
 			loadFS, err := ctx.LoadWebFS(request, false)
 			if err != nil {
 				username, _, _ := request.BasicAuth()
@@ -51,12 +110,447 @@ func WithWebdav(ctx *common.FsContext) func(r chi.Router) {
 				return
 			}
 			slog.Info("|webdav| Request.", "method", request.Method, "path", request.URL.Path, "remote", request.RemoteAddr, "user", loadFS.User)
-			handler := &webdav.Handler{
-				Prefix:     ctx.Config.Webdav.Prefix,
-				FileSystem: NewWebdavFS(loadFS),
-				LockSystem: locker,
+			if id, ok := strings.CutPrefix(request.URL.Path, ctx.Config.Webdav.Prefix+jobsPath); ok {
+				handleJob(writer, request, jobs, loadFS.User, id)
+				return
+			}
+			prefix, ok := userPrefix(ctx, writer, request, loadFS.User)
+			if !ok {
+				return
+			}
+			space := ""
+			if host, ok := ctx.Config.WebdavHost(request.Host); ok {
+				space = host
+				loadFS = &common.AuthFS{User: loadFS.User, Fs: ctx.ScopedFS(loadFS.User, host)}
+			}
+			var uploaded *uploadBody
+			if stats != nil {
+				recorder := &statusResponseWriter{ResponseWriter: writer}
+				writer = recorder
+				defer func() {
+					size := int64(-1)
+					if uploaded != nil {
+						size = uploaded.read
+					}
+					stats.observe(ctx, prefix, request, loadFS.User, recorder.code, start, size)
+				}()
+			}
+			if err := poolError(ctx, prefix, request); err != nil {
+				slog.Warn("|webdav| Pool unavailable.", "method", request.Method, "path", request.URL.Path, "err", err)
+				writer.Header().Set("Retry-After", strconv.Itoa(ctx.RetryAfter()))
+				http.Error(writer, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+			if err := checkMove(prefix, loadFS, request); err != nil {
+				slog.Warn("|webdav| Cross-pool move refused.", "path", request.URL.Path, "user", loadFS.User, "err", err)
+				http.Error(writer, "cross-pool move is disabled, copy the files instead", http.StatusBadGateway)
+				return
+			}
+			if !writable(ctx, loadFS.User, prefix, request) {
+				slog.Warn("|webdav| Write refused.", "method", request.Method, "path", request.URL.Path, "user", loadFS.User)
+				http.Error(writer, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			handler := handlers.get(loadFS.User, prefix, space, loadFS.Fs)
+			if ctx.Config.Filenames.Enabled() {
+				if err := sanitizeNames(ctx, writer, request, prefix, loadFS.Fs); err != nil {
+					slog.Warn("|webdav| File name refused.", "method", request.Method, "path", request.URL.Path, "user", loadFS.User, "err", err)
+					http.Error(writer, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			if isConditionalWrite(request) {
+				name := strings.TrimPrefix(request.URL.Path, prefix)
+				if code := checkPreconditions(request.Context(), request, handler.FileSystem, name); code != 0 {
+					slog.Debug("|webdav| Precondition failed.", "method", request.Method, "path", request.URL.Path, "user", loadFS.User)
+					http.Error(writer, http.StatusText(code), code)
+					return
+				}
+			}
+			if isInfinitePropfind(request) {
+				if !ctx.Config.Webdav.InfiniteDepth {
+					slog.Debug("|webdav| Infinite depth PROPFIND refused.", "path", request.URL.Path, "user", loadFS.User)
+					writePreconditionError(writer, "propfind-finite-depth")
+					return
+				}
+				if maxDepth := ctx.Config.Webdav.MaxDepth; maxDepth > 0 {
+					root := strings.TrimPrefix(request.URL.Path, prefix)
+					request = request.WithContext(withDepthLimit(request.Context(), root, maxDepth))
+				}
+			}
+			if request.Method == http.MethodPut && !isPartialUpload(request) && request.Header.Get("If-Match") == "" &&
+				ctx.Config.ConflictPolicy(request) == common.ConflictRename {
+				name := strings.TrimPrefix(request.URL.Path, prefix)
+				renamed, err := common.ConflictName(loadFS.Fs, name)
+				if err != nil {
+					slog.Warn("|webdav| Conflict rename failed.", "path", request.URL.Path, "user", loadFS.User, "err", err)
+					http.Error(writer, http.StatusText(http.StatusConflict), http.StatusConflict)
+					return
+				}
+				if renamed != name {
+					slog.Info("|webdav| Upload renamed on conflict.", "path", request.URL.Path, "user", loadFS.User, "name", renamed)
+					target := *request.URL
+					target.Path, target.RawPath = prefix+renamed, ""
+					request.URL = &target
+					writer.Header().Set("Location", target.EscapedPath())
+				}
+			}
+			if request.Method == http.MethodPut {
+				limit := ctx.MaxFileSize(strings.TrimPrefix(request.URL.Path, prefix))
+				if limit > 0 && request.ContentLength > limit {
+					slog.Warn("|webdav| Upload too large.", "path", request.URL.Path, "user", loadFS.User, "size", request.ContentLength, "limit", limit)
+					http.Error(writer, errFileTooLarge.Error(), http.StatusRequestEntityTooLarge)
+					return
+				}
+				// 请求体读取失败（如客户端中断上传）时取消 ctx，使写入的临时文件被丢弃
+				uploadCtx, cancel := context.WithCancelCause(request.Context())
+				defer cancel(nil)
+				request = request.WithContext(uploadCtx)
+				expected, err := expectedChecksums(request)
+				if err != nil {
+					http.Error(writer, "invalid checksum header", http.StatusBadRequest)
+					return
+				}
+				body := &uploadBody{ReadCloser: request.Body, cancel: cancel, limit: limit, expected: expected}
+				if len(expected) > 0 || ctx.Config.Webdav.Checksum.Enabled {
+					body.hasher = newChecksumHasher()
+				}
+				uploaded = body
+				request.Body = body
+				writer = &uploadResponseWriter{ResponseWriter: writer, body: body}
+				if spool := ctx.Config.Webdav.Spool; spool.Dir != "" && !isPartialUpload(request) &&
+					(request.ContentLength < 0 || request.ContentLength >= int64(spool.MinSize)) {
+					file, size, err := spoolUpload(spool.Dir, body)
+					if err != nil {
+						slog.Warn("|webdav| Upload spooling failed.", "path", request.URL.Path, "user", loadFS.User, "err", err)
+						if context.Cause(uploadCtx) != nil {
+							// 读取请求体失败，超过大小上限或校验和不一致时由 uploadResponseWriter 替换状态码
+							http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+						} else {
+							http.Error(writer, http.StatusText(http.StatusInsufficientStorage), http.StatusInsufficientStorage)
+						}
+						return
+					}
+					defer file.Close()
+					request.Body = file
+					request.ContentLength = size
+				}
+			}
+			if request.Method == "COPY" {
+				request = request.WithContext(withCopyRequest(request.Context()))
+			}
+			if request.Method == "LOCK" {
+				request.Header.Set("Timeout", timeouts.clamp(request.Header.Get("Timeout")))
+			}
+			if isAsyncCopy(ctx, prefix, request) {
+				slog.Info("|webdav| Copy started in background.", "method", request.Method, "path", request.URL.Path, "user", loadFS.User)
+				startJob(ctx, writer, request, handler, loadFS.Fs, jobs, loadFS.User, prefix, func(code int) {
+					publishChanges(ctx, loadFS.User, prefix, request, code)
+				})
+				return
+			}
+			if request.Method == "REPORT" {
+				handleSyncCollection(writer, request, handler, ctx.Journal, loadFS.User)
+				return
+			}
+			if request.Method == "SEARCH" {
+				handleSearch(writer, request, handler, loadFS.Fs, ctx.Config.Webdav.SearchLimit)
+				return
+			}
+			if request.Method == http.MethodOptions {
+				handleOptions(writer, request, handler)
+				return
+			}
+			if ctx.Config.Webdav.Checksum.Enabled {
+				request = withChecksumRequest(request)
+				if request.Method == http.MethodGet || request.Method == http.MethodHead {
+					name := strings.TrimPrefix(request.URL.Path, prefix)
+					if info, err := loadFS.Fs.Stat(name); err == nil {
+						if sums, ok := checksums.Get(request.Context(), loadFS.Fs, ctx.CacheKey(loadFS.User, name), name, info, true); ok {
+							writer.Header().Set("OC-Checksum", "SHA1:"+sums.SHA1)
+						}
+					}
+				}
+			}
+			if request.Method == http.MethodGet && ctx.Config.Webdav.Sendfile.Mode != "" &&
+				handleSendfile(writer, request, ctx, handler, loadFS.User) {
+				return
+			}
+			serve := func(w http.ResponseWriter, r *http.Request) {
+				if batch := ctx.Config.Webdav.PropfindBatch; batch > 0 && streamPropfind(w, r, handler, batch) {
+					return
+				}
+				if r.Method == http.MethodGet && serveOsFile(w, r, handler, loadFS.Fs) {
+					return
+				}
+				handler.ServeHTTP(w, r)
+			}
+			status := &statusResponseWriter{ResponseWriter: writer}
+			if isPartialUpload(request) {
+				handlePartialUpload(status, request, handler, strings.TrimPrefix(request.URL.Path, prefix))
+			} else if minimal := newMinimalResponseWriter(status, request); minimal != nil {
+				serve(minimal, request)
+				_ = minimal.finish()
+			} else {
+				serve(status, request)
+			}
+			publishChanges(ctx, loadFS.User, prefix, request, status.code)
+			if uploaded != nil && uploaded.hasher != nil && !isPartialUpload(request) &&
+				(status.code == http.StatusCreated || status.code == http.StatusNoContent) {
+				name := strings.TrimPrefix(request.URL.Path, prefix)
+				if info, err := loadFS.Fs.Stat(name); err == nil {
+					checksums.Put(ctx.CacheKey(loadFS.User, name), info, uploaded.hasher.Sum())
+				}
 			}
-			handler.ServeHTTP(writer, request)
 		})
 	}
 }
+
+// checksumCacheSize 内存中缓存的校验和数量
+const checksumCacheSize = 100000
+
+// errFileTooLarge 上传的文件超过存储池的 max_file_size
+var errFileTooLarge = errors.New("file exceeds the pool's max_file_size")
+
+// uploadBody 在读取请求体出错时取消上传的 ctx，未声明长度的上传在超过大小上限时中止，
+// 内容与客户端声明的校验和不一致时在读取结束时中止
+type uploadBody struct {
+	io.ReadCloser
+	cancel   context.CancelCauseFunc
+	limit    int64
+	read     int64
+	tooLarge bool
+	// hasher 不为 nil 时计算上传内容的校验和，expected 为客户端声明的值
+	hasher   *checksumHasher
+	expected map[string]string
+	mismatch bool
+}
+
+func (b *uploadBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.limit > 0 && b.read > b.limit {
+		b.tooLarge = true
+		n, err = 0, errFileTooLarge
+	}
+	if b.hasher != nil {
+		_, _ = b.hasher.Write(p[:n])
+		if err == io.EOF && !b.hasher.Sum().verify(b.expected) {
+			b.mismatch = true
+			err = errChecksumMismatch
+		}
+	}
+	if err != nil && err != io.EOF {
+		b.cancel(err)
+	}
+	return n, err
+}
+
+// uploadResponseWriter 上传超过大小上限或校验和不一致时，将 webdav.Handler 返回的 405 替换为 413 或 400
+type uploadResponseWriter struct {
+	http.ResponseWriter
+	body     *uploadBody
+	replaced bool
+}
+
+func (w *uploadResponseWriter) WriteHeader(code int) {
+	if code >= http.StatusBadRequest && w.body.tooLarge {
+		w.replaced = true
+		http.Error(w.ResponseWriter, errFileTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if code >= http.StatusBadRequest && w.body.mismatch {
+		w.replaced = true
+		http.Error(w.ResponseWriter, errChecksumMismatch.Error(), http.StatusBadRequest)
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *uploadResponseWriter) Write(p []byte) (int, error) {
+	if w.replaced {
+		// 丢弃 webdav.Handler 随后写入的状态文本
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// statusResponseWriter 记录 webdav.Handler 返回的状态码
+type statusResponseWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusResponseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusResponseWriter) Write(p []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ReadFrom 保留底层 ResponseWriter 的 io.ReaderFrom，使 sendfile 穿过状态记录
+func (w *statusResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return io.Copy(w.ResponseWriter, r)
+}
+
+// publishChanges 发布写入请求成功后产生的变更，供缓存刷新与 sync-collection 使用
+func publishChanges(ctx *common.FsContext, user, prefix string, request *http.Request, code int) {
+	if code < http.StatusOK || code >= http.StatusMultipleChoices {
+		return
+	}
+	name := strings.TrimPrefix(request.URL.Path, prefix)
+	switch request.Method {
+	case http.MethodPut, http.MethodPatch, "PROPPATCH":
+		ctx.PublishChange(user, name, events.OpWrite)
+	case "MKCOL":
+		ctx.PublishChange(user, name, events.OpCreate)
+	case http.MethodDelete:
+		ctx.PublishChange(user, name, events.OpRemove)
+	case "MOVE", "COPY":
+		if request.Method == "MOVE" {
+			ctx.PublishChange(user, name, events.OpRename)
+		}
+		if dst, ok := destination(prefix, request); ok {
+			ctx.PublishChange(user, dst, events.OpCreate)
+		}
+	}
+}
+
+// writePreconditionError 返回 403 与 WebDAV 定义的前置条件，如 RFC 4918 的 propfind-finite-depth
+func writePreconditionError(w http.ResponseWriter, condition string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusForbidden)
+	_, _ = fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<D:error xmlns:D=\"DAV:\"><D:%s/></D:error>", condition)
+}
+
+// poolError 检查请求路径与 COPY/MOVE 的目标路径所在的存储池是否可用，
+// 避免存储池离线时客户端收到 404 而误以为文件已被删除
+func poolError(ctx *common.FsContext, prefix string, request *http.Request) error {
+	if err := ctx.PoolError(strings.TrimPrefix(request.URL.Path, prefix)); err != nil {
+		return err
+	}
+	if dst, ok := destination(prefix, request); ok {
+		return ctx.PoolError(dst)
+	}
+	return nil
+}
+
+// renameChecker 可在重命名前检查是否允许跨文件系统移动
+type renameChecker interface {
+	CheckRename(oldname, newname string) error
+}
+
+// checkMove 预先检查 MOVE 是否跨越禁止隐式复制的存储池。webdav.Handler 会将重命名失败统一返回 403，
+// 此处改为 502，提示客户端目标拒绝了该移动，需自行复制后删除
+func checkMove(prefix string, fs *common.AuthFS, request *http.Request) error {
+	checker, ok := fs.Fs.(renameChecker)
+	if request.Method != "MOVE" || !ok {
+		return nil
+	}
+	dst, ok := destination(prefix, request)
+	if !ok {
+		return nil
+	}
+	return checker.CheckRename(strings.TrimPrefix(request.URL.Path, prefix), dst)
+}
+
+// writable 预先检查写入请求涉及的路径是否可写，只读用户或没有写入权限的存储池直接返回 403，
+// 避免上传完整个请求体后才因文件系统只读而失败
+func writable(ctx *common.FsContext, user, prefix string, request *http.Request) bool {
+	name := strings.TrimPrefix(request.URL.Path, prefix)
+	switch request.Method {
+	case http.MethodPut, http.MethodPatch, http.MethodDelete, "MKCOL", "PROPPATCH", "LOCK":
+		return ctx.Writable(user, name)
+	case "MOVE", "COPY":
+		if request.Method == "MOVE" && !ctx.Writable(user, name) {
+			return false
+		}
+		dst, ok := destination(prefix, request)
+		return !ok || ctx.Writable(user, dst)
+	}
+	return true
+}
+
+// userPrefix 返回请求使用的前缀。开启 user_prefix 时为 <prefix>/<用户名>，访问其他用户的路径返回 403，
+// 访问前缀根目录时重定向到当前用户的根路径
+func userPrefix(ctx *common.FsContext, w http.ResponseWriter, request *http.Request, user string) (string, bool) {
+	prefix := ctx.Config.Webdav.Prefix
+	if !ctx.Config.Webdav.UserPrefix {
+		return prefix, true
+	}
+	owner, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(request.URL.Path, prefix), "/"), "/")
+	switch owner {
+	case user:
+		return prefix + "/" + user, true
+	case "":
+		http.Redirect(w, request, prefix+"/"+url.PathEscape(user)+"/", http.StatusTemporaryRedirect)
+	default:
+		slog.Warn("|webdav| Other user's prefix refused.", "path", request.URL.Path, "user", user)
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	}
+	return "", false
+}
+
+// destination 返回 Destination 头中去除前缀后的路径
+func destination(prefix string, request *http.Request) (string, bool) {
+	dst := request.Header.Get("Destination")
+	if dst == "" {
+		return "", false
+	}
+	u, err := url.Parse(dst)
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimPrefix(u.Path, prefix), true
+}
+
+// sanitizeNames 按 filenames 策略处理 PUT、MKCOL 新建的路径与 COPY/MOVE 的目标路径，
+// 名称被修改时改写请求，并以 Location 返回实际的地址。已存在的目标保持原名
+func sanitizeNames(ctx *common.FsContext, w http.ResponseWriter, request *http.Request, prefix string, fs afero.Fs) error {
+	rewrite := func(name string) (string, error) {
+		if _, err := fs.Stat(name); err == nil {
+			return name, nil
+		}
+		return ctx.Config.Filenames.SanitizePath(name)
+	}
+	switch request.Method {
+	case http.MethodPut, "MKCOL":
+		name := strings.TrimPrefix(request.URL.Path, prefix)
+		sanitized, err := rewrite(name)
+		if err != nil || sanitized == name {
+			return err
+		}
+		target := *request.URL
+		target.Path, target.RawPath = prefix+sanitized, ""
+		request.URL = &target
+		w.Header().Set("Location", target.EscapedPath())
+	case "COPY", "MOVE":
+		name, ok := destination(prefix, request)
+		if !ok {
+			return nil
+		}
+		sanitized, err := rewrite(name)
+		if err != nil || sanitized == name {
+			return err
+		}
+		u, _ := url.Parse(request.Header.Get("Destination"))
+		u.Path, u.RawPath = prefix+sanitized, ""
+		request.Header.Set("Destination", u.String())
+		w.Header().Set("Location", u.EscapedPath())
+	}
+	return nil
+}
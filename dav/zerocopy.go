@@ -0,0 +1,37 @@
+package dav
+
+import (
+	"net/http"
+	"strings"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+	"golang.org/x/net/webdav"
+)
+
+// serveOsFile 处理本地文件的 GET，与 webdav.Handler 相同地设置 ETag 后以 http.ServeContent 发送，
+// 但直接交给它 *os.File，使内容经 sendfile 由内核写入连接，不经过各层包装与用户态缓冲。
+// 文件不是本地文件时返回 false，仍由 webdav.Handler 处理
+func serveOsFile(w http.ResponseWriter, r *http.Request, handler *webdav.Handler, fs afero.Fs) bool {
+	name := strings.TrimPrefix(r.URL.Path, handler.Prefix)
+	file, err := fs.Open(name)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	osFile, ok := mergefs.OsFile(file)
+	if !ok {
+		return false
+	}
+	info, err := handler.FileSystem.Stat(r.Context(), name)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	etag, err := fileETag(r.Context(), info)
+	if err != nil {
+		return false
+	}
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, name, info.ModTime(), osFile)
+	return true
+}
@@ -0,0 +1,37 @@
+package dav
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+func TestServeOsFile(t *testing.T) {
+	fs := mergefs.NewOsPathFs(t.TempDir(), mergefs.SymlinkWithin)
+	assert.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("hello world"), 0o644))
+	handler := &webdav.Handler{FileSystem: NewWebdavFS(fs), LockSystem: webdav.NewMemLS()}
+
+	expected := httptest.NewRecorder()
+	handler.ServeHTTP(expected, httptest.NewRequest(http.MethodGet, "/a.txt", nil))
+	rec := httptest.NewRecorder()
+	assert.True(t, serveOsFile(rec, httptest.NewRequest(http.MethodGet, "/a.txt", nil), handler, fs))
+	assert.Equal(t, expected.Body.String(), rec.Body.String())
+	assert.Equal(t, expected.Header().Get("ETag"), rec.Header().Get("ETag"), "与 webdav.Handler 的 ETag 一致")
+
+	req := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+	req.Header.Set("Range", "bytes=6-")
+	rec = httptest.NewRecorder()
+	assert.True(t, serveOsFile(rec, req, handler, fs))
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "world", rec.Body.String())
+
+	memFs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(memFs, "/a.txt", []byte("hello"), 0o644))
+	memHandler := &webdav.Handler{FileSystem: NewWebdavFS(memFs), LockSystem: webdav.NewMemLS()}
+	assert.False(t, serveOsFile(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a.txt", nil), memHandler, memFs), "非本地文件交由 webdav.Handler")
+}
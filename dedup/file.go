@@ -0,0 +1,99 @@
+package dedup
+
+import (
+	"os"
+	"path"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// fileInfo 由索引文件生成的文件信息
+type fileInfo struct {
+	name string
+	e    *entry
+}
+
+func (e *entry) info(name string) os.FileInfo {
+	return &fileInfo{name: path.Base(name), e: e}
+}
+
+func (f *fileInfo) Name() string       { return f.name }
+func (f *fileInfo) Size() int64        { return f.e.Size }
+func (f *fileInfo) Mode() os.FileMode  { return f.e.Mode }
+func (f *fileInfo) ModTime() time.Time { return f.e.MTime }
+func (f *fileInfo) IsDir() bool        { return false }
+func (f *fileInfo) Sys() any           { return nil }
+
+// readFile 只读打开的内容文件，对外表现为逻辑文件
+type readFile struct {
+	afero.File
+	info os.FileInfo
+}
+
+func (f *readFile) Name() string {
+	return f.info.Name()
+}
+
+func (f *readFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+// writeFile 写入临时文件，Close 时存入 blobs 并更新索引
+type writeFile struct {
+	afero.File
+	fs     *Fs
+	name   string
+	mode   os.FileMode
+	closed bool
+}
+
+func (f *writeFile) Name() string {
+	return path.Base(f.name)
+}
+
+func (f *writeFile) Stat() (os.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{name: path.Base(f.name), e: &entry{Size: info.Size(), Mode: f.mode, MTime: info.ModTime()}}, nil
+}
+
+func (f *writeFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	tmp := f.File.Name()
+	if err := f.File.Close(); err != nil {
+		_ = f.fs.store.Remove(tmp)
+		return err
+	}
+	hash, size, err := f.fs.storeBlob(tmp)
+	if err != nil {
+		_ = f.fs.store.Remove(tmp)
+		return err
+	}
+	return f.fs.writeEntry(f.name, &entry{Hash: hash, Size: size, Mode: f.mode, MTime: time.Now()})
+}
+
+// dirFile 目录文件，列表中的文件信息由索引文件生成
+type dirFile struct {
+	afero.File
+	fs   *Fs
+	name string
+}
+
+func (f *dirFile) Readdir(count int) ([]os.FileInfo, error) {
+	infos, err := f.File.Readdir(count)
+	for i, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		if e, readErr := f.fs.readEntry(path.Join(f.name, info.Name())); readErr == nil {
+			infos[i] = e.info(info.Name())
+		}
+	}
+	return infos, err
+}
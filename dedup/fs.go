@@ -0,0 +1,296 @@
+// Package dedup 实现按内容寻址的去重存储池：文件内容按 SHA-256 存放在 blobs 目录，
+// index 目录保存与文件树一一对应的索引文件，记录每个文件引用的内容及元数据。
+package dedup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	indexDir = "/index"
+	blobsDir = "/blobs"
+	tempDir  = "/tmp"
+)
+
+// entry 索引文件内容
+type entry struct {
+	Hash  string      `json:"hash"`
+	Size  int64       `json:"size"`
+	Mode  os.FileMode `json:"mode"`
+	MTime time.Time   `json:"mtime"`
+}
+
+// Fs 去重文件系统，store 为存储根目录
+type Fs struct {
+	store afero.Fs
+	index afero.Fs
+}
+
+// NewFs 在 store 上创建去重文件系统
+func NewFs(store afero.Fs) (*Fs, error) {
+	for _, dir := range []string{indexDir, blobsDir, tempDir} {
+		if err := store.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &Fs{
+		store: store,
+		index: afero.NewBasePathFs(store, indexDir),
+	}, nil
+}
+
+// blobPath 返回内容的存放路径
+func blobPath(hash string) string {
+	return path.Join(blobsDir, hash[:2], hash)
+}
+
+func (d *Fs) readEntry(name string) (*entry, error) {
+	data, err := afero.ReadFile(d.index, name)
+	if err != nil {
+		return nil, err
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, &os.PathError{Op: "read", Path: name, Err: err}
+	}
+	return &e, nil
+}
+
+// writeEntry 先写入临时文件再重命名，保证索引文件完整
+func (d *Fs) writeEntry(name string, e *entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	tmp, err := afero.TempFile(d.store, tempDir, "entry-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = d.store.Rename(tmp.Name(), path.Join(indexDir, name))
+	}
+	if err != nil {
+		_ = d.store.Remove(tmp.Name())
+	}
+	return err
+}
+
+// storeBlob 计算临时文件的哈希并移动到 blobs 目录，内容已存在时直接删除临时文件
+func (d *Fs) storeBlob(tmp string) (string, int64, error) {
+	file, err := d.store.Open(tmp)
+	if err != nil {
+		return "", 0, err
+	}
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, file)
+	_ = file.Close()
+	if err != nil {
+		return "", 0, err
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	target := blobPath(hash)
+	if _, err := d.store.Stat(target); err == nil {
+		// 更新修改时间，避免与 GC 竞争时被误删
+		now := time.Now()
+		_ = d.store.Chtimes(target, now, now)
+		return hash, size, d.store.Remove(tmp)
+	}
+	if err := d.store.MkdirAll(path.Dir(target), 0o755); err != nil {
+		return "", 0, err
+	}
+	if err := d.store.Rename(tmp, target); err != nil {
+		return "", 0, err
+	}
+	return hash, size, nil
+}
+
+func (d *Fs) Create(name string) (afero.File, error) {
+	return d.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (d *Fs) Mkdir(name string, perm os.FileMode) error {
+	return d.index.Mkdir(name, perm)
+}
+
+func (d *Fs) MkdirAll(path string, perm os.FileMode) error {
+	return d.index.MkdirAll(path, perm)
+}
+
+func (d *Fs) Open(name string) (afero.File, error) {
+	return d.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (d *Fs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	info, err := d.index.Stat(name)
+	if err == nil && info.IsDir() {
+		if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+		}
+		dir, err := d.index.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		return &dirFile{File: dir, fs: d, name: name}, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	exists := err == nil
+	switch {
+	case !exists && flag&os.O_CREATE == 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	case exists && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+	}
+	var current *entry
+	if exists {
+		if current, err = d.readEntry(name); err != nil {
+			return nil, err
+		}
+	}
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		if current == nil {
+			// 以只读方式创建文件时先写入空内容
+			file, err := d.openWrite(name, flag, perm, nil)
+			if err == nil {
+				err = file.Close()
+			}
+			if err != nil {
+				return nil, err
+			}
+			if current, err = d.readEntry(name); err != nil {
+				return nil, err
+			}
+		}
+		blob, err := d.store.Open(blobPath(current.Hash))
+		if err != nil {
+			return nil, err
+		}
+		return &readFile{File: blob, info: current.info(name)}, nil
+	}
+	return d.openWrite(name, flag, perm, current)
+}
+
+// openWrite 写入操作在临时文件中进行，Close 时再存入 blobs 并更新索引
+func (d *Fs) openWrite(name string, flag int, perm os.FileMode, current *entry) (afero.File, error) {
+	if _, err := d.index.Stat(path.Dir(name)); err != nil {
+		return nil, err
+	}
+	tmp, err := afero.TempFile(d.store, tempDir, "blob-")
+	if err != nil {
+		return nil, err
+	}
+	mode := perm.Perm()
+	if current != nil {
+		mode = current.Mode
+		if flag&os.O_TRUNC == 0 {
+			// 保留原有内容以支持追加与随机写入
+			if err := d.copyBlob(current.Hash, tmp); err != nil {
+				_ = tmp.Close()
+				_ = d.store.Remove(tmp.Name())
+				return nil, err
+			}
+		}
+	}
+	if flag&os.O_APPEND == 0 {
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			_ = tmp.Close()
+			_ = d.store.Remove(tmp.Name())
+			return nil, err
+		}
+	}
+	return &writeFile{File: tmp, fs: d, name: name, mode: mode}, nil
+}
+
+func (d *Fs) copyBlob(hash string, dst io.Writer) error {
+	blob, err := d.store.Open(blobPath(hash))
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+	_, err = io.Copy(dst, blob)
+	return err
+}
+
+func (d *Fs) Remove(name string) error {
+	return d.index.Remove(name)
+}
+
+func (d *Fs) RemoveAll(path string) error {
+	return d.index.RemoveAll(path)
+}
+
+func (d *Fs) Rename(oldname, newname string) error {
+	return d.index.Rename(oldname, newname)
+}
+
+func (d *Fs) Stat(name string) (os.FileInfo, error) {
+	info, err := d.index.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return info, nil
+	}
+	e, err := d.readEntry(name)
+	if err != nil {
+		return nil, err
+	}
+	return e.info(info.Name()), nil
+}
+
+func (d *Fs) Name() string {
+	return "DedupFs"
+}
+
+// updateEntry 修改文件元数据，目录直接作用于索引目录
+func (d *Fs) updateEntry(name string, fn func(e *entry)) error {
+	info, err := d.index.Stat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return errors.ErrUnsupported
+	}
+	e, err := d.readEntry(name)
+	if err != nil {
+		return err
+	}
+	fn(e)
+	return d.writeEntry(name, e)
+}
+
+func (d *Fs) Chmod(name string, mode os.FileMode) error {
+	err := d.updateEntry(name, func(e *entry) { e.Mode = mode.Perm() })
+	if errors.Is(err, errors.ErrUnsupported) {
+		return d.index.Chmod(name, mode)
+	}
+	return err
+}
+
+func (d *Fs) Chown(_ string, _, _ int) error {
+	// 内容由多个文件共享，不支持修改属主
+	return nil
+}
+
+func (d *Fs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	err := d.updateEntry(name, func(e *entry) { e.MTime = mtime })
+	if errors.Is(err, errors.ErrUnsupported) {
+		return d.index.Chtimes(name, atime, mtime)
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package dedup
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func countBlobs(t *testing.T, store afero.Fs) int {
+	count := 0
+	err := afero.Walk(store, blobsDir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			count++
+		}
+		return err
+	})
+	assert.NoError(t, err)
+	return count
+}
+
+func TestFs_Dedup(t *testing.T) {
+	store := afero.NewMemMapFs()
+	fs, err := NewFs(store)
+	assert.NoError(t, err)
+
+	_ = fs.MkdirAll("/a/b", 0o755)
+	assert.NoError(t, afero.WriteFile(fs, "/a/one.bin", []byte("same content"), 0o644))
+	assert.NoError(t, afero.WriteFile(fs, "/a/b/two.bin", []byte("same content"), 0o644))
+	assert.NoError(t, afero.WriteFile(fs, "/a/three.bin", []byte("other"), 0o644))
+	assert.Equal(t, 2, countBlobs(t, store), "相同内容应只存储一份")
+
+	data, err := afero.ReadFile(fs, "/a/b/two.bin")
+	assert.NoError(t, err)
+	assert.Equal(t, "same content", string(data))
+	stat, err := fs.Stat("/a/one.bin")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len("same content")), stat.Size())
+
+	infos, err := afero.ReadDir(fs, "/a")
+	assert.NoError(t, err)
+	assert.Len(t, infos, 3)
+	for _, info := range infos {
+		if !info.IsDir() {
+			assert.NotZero(t, info.Size(), "目录列表应返回逻辑文件大小")
+		}
+	}
+
+	// 追加写入保留原有内容
+	file, err := fs.OpenFile("/a/three.bin", os.O_WRONLY|os.O_APPEND, 0)
+	assert.NoError(t, err)
+	_, _ = file.Write([]byte("+more"))
+	assert.NoError(t, file.Close())
+	data, _ = afero.ReadFile(fs, "/a/three.bin")
+	assert.Equal(t, "other+more", string(data))
+}
+
+func TestFs_GC(t *testing.T) {
+	store := afero.NewMemMapFs()
+	fs, _ := NewFs(store)
+	_ = afero.WriteFile(fs, "/keep.txt", []byte("keep"), 0o644)
+	_ = afero.WriteFile(fs, "/drop.txt", []byte("drop"), 0o644)
+	assert.NoError(t, fs.Remove("/drop.txt"))
+
+	result, err := fs.GC(time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Removed, "宽限期内的内容不应被删除")
+
+	result, err = fs.GC(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Removed, "应删除未被引用的内容")
+	assert.Equal(t, int64(4), result.Freed)
+	data, err := afero.ReadFile(fs, "/keep.txt")
+	assert.NoError(t, err, "被引用的内容应保留")
+	assert.Equal(t, "keep", string(data))
+}
@@ -0,0 +1,61 @@
+package dedup
+
+import (
+	"os"
+	"path"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// GCResult 清理结果
+type GCResult struct {
+	// 被删除的内容数量
+	Removed int
+	// 释放的字节数
+	Freed int64
+}
+
+// GC 删除未被任何文件引用的内容以及残留的临时文件，
+// 修改时间在 grace 以内的内容可能正被写入，不会被删除
+func (d *Fs) GC(grace time.Duration) (GCResult, error) {
+	var result GCResult
+	referenced := make(map[string]struct{})
+	err := afero.Walk(d.index, "/", func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		e, err := d.readEntry(p)
+		if err != nil {
+			return err
+		}
+		referenced[e.Hash] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	deadline := time.Now().Add(-grace)
+	for _, dir := range []string{blobsDir, tempDir} {
+		err = afero.Walk(d.store, dir, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || info.ModTime().After(deadline) {
+				return err
+			}
+			if _, ok := referenced[path.Base(p)]; ok && dir == blobsDir {
+				return nil
+			}
+			if err := d.store.Remove(p); err != nil {
+				return err
+			}
+			if dir == blobsDir {
+				result.Removed++
+				result.Freed += info.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Op 变更类型
+type Op string
+
+const (
+	OpCreate Op = "create"
+	OpWrite  Op = "write"
+	OpRemove Op = "remove"
+	OpRename Op = "rename"
+)
+
+// Event 存储池内的文件变更
+type Event struct {
+	Pool string    `json:"pool"`
+	Path string    `json:"path"`
+	Op   Op        `json:"op"`
+	Time time.Time `json:"time"`
+	// User 变更发生在该用户的私有空间（家目录、独立的内存存储池与 upper 层）中，为空时为共享的存储池
+	User string `json:"user,omitempty"`
+}
+
+// Hub 变更事件分发，订阅者回调在发布者的 goroutine 中同步执行，不应阻塞
+type Hub struct {
+	mu   sync.RWMutex
+	next int
+	subs map[int]func(Event)
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[int]func(Event))}
+}
+
+// Subscribe 订阅变更事件，返回取消订阅函数
+func (h *Hub) Subscribe(fn func(Event)) func() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	id := h.next
+	h.next++
+	h.subs[id] = fn
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subs, id)
+	}
+}
+
+// Publish 发布变更事件
+func (h *Hub) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, fn := range h.subs {
+		fn(event)
+	}
+}
@@ -0,0 +1,86 @@
+package events
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JournalEntry 变更日志中的一条记录
+type JournalEntry struct {
+	Seq uint64
+	// Name 用户文件系统中的路径
+	Name string
+	// User 私有空间（如家目录）的所属用户，为空时所有用户可见
+	User string
+}
+
+// Journal 在内存中保存最近的变更，供客户端按同步令牌增量获取。
+// 令牌包含创建时间，重启后旧的令牌失效
+type Journal struct {
+	mu      sync.Mutex
+	epoch   string
+	size    int
+	seq     uint64
+	entries []JournalEntry
+}
+
+// NewJournal 创建最多保存 size 条记录的变更日志
+func NewJournal(size int) *Journal {
+	return &Journal{
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		size:  max(size, 1),
+	}
+}
+
+// Append 记录一次变更
+func (j *Journal) Append(name, user string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.seq++
+	if len(j.entries) >= j.size {
+		j.entries = append(j.entries[:0], j.entries[len(j.entries)-j.size+1:]...)
+	}
+	j.entries = append(j.entries, JournalEntry{Seq: j.seq, Name: name, User: user})
+}
+
+// Token 返回当前的同步令牌
+func (j *Journal) Token() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.token()
+}
+
+func (j *Journal) token() string {
+	return j.epoch + "-" + strconv.FormatUint(j.seq, 10)
+}
+
+// Since 返回令牌之后的变更与当前的令牌。令牌来自之前的进程或早于保存的记录时返回 false，
+// 客户端需重新完整同步
+func (j *Journal) Since(token string) ([]JournalEntry, string, bool) {
+	epoch, seqText, ok := strings.Cut(token, "-")
+	if !ok || epoch != j.epoch {
+		return nil, "", false
+	}
+	seq, err := strconv.ParseUint(seqText, 10, 64)
+	if err != nil {
+		return nil, "", false
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if seq > j.seq {
+		return nil, "", false
+	}
+	// 第一条保存的记录之前的变更已被丢弃
+	if len(j.entries) > 0 && j.entries[0].Seq > seq+1 {
+		return nil, "", false
+	}
+	var result []JournalEntry
+	for _, entry := range j.entries {
+		if entry.Seq > seq {
+			result = append(result, entry)
+		}
+	}
+	return result, j.token(), true
+}
@@ -0,0 +1,78 @@
+package events
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"path"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchPool 递归监听存储池目录，将磁盘上的变更以存储池内路径发布到 hub，
+// 使绕过本服务直接修改磁盘的操作也能及时生效
+func WatchPool(ctx context.Context, hub *Hub, pool, root string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		_ = watcher.Close()
+		return err
+	}
+	addRecursive(watcher, root)
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				rel, err := filepath.Rel(root, event.Name)
+				if err != nil {
+					continue
+				}
+				op := OpWrite
+				switch {
+				case event.Has(fsnotify.Create):
+					op = OpCreate
+					addRecursive(watcher, event.Name)
+				case event.Has(fsnotify.Remove):
+					op = OpRemove
+				case event.Has(fsnotify.Rename):
+					op = OpRename
+				}
+				hub.Publish(Event{
+					Pool: pool,
+					Path: path.Clean("/" + filepath.ToSlash(rel)),
+					Op:   op,
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("|watch| Watcher error.", "pool", pool, "err", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// addRecursive 监听目录及其所有子目录（inotify 不支持递归监听）
+func addRecursive(watcher *fsnotify.Watcher, dir string) {
+	_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if err := watcher.Add(p); err != nil {
+			slog.Warn("|watch| Add watch failed.", "path", p, "err", err)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
@@ -0,0 +1,47 @@
+package events
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchPool(t *testing.T) {
+	root := t.TempDir()
+	hub := NewHub()
+	received := make(chan Event, 16)
+	cancel := hub.Subscribe(func(event Event) {
+		received <- event
+	})
+	defer cancel()
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	assert.NoError(t, WatchPool(ctx, hub, "data", root))
+
+	// 新建子目录后，子目录中的变更也应被监听
+	assert.NoError(t, os.Mkdir(filepath.Join(root, "sub"), 0o755))
+	waitEvent(t, received, "/sub", OpCreate)
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "sub", "a.txt"), []byte("a"), 0o644))
+	waitEvent(t, received, "/sub/a.txt", OpCreate)
+}
+
+func waitEvent(t *testing.T, ch chan Event, p string, op Op) {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-ch:
+			if event.Path == p && event.Op == op {
+				assert.Equal(t, "data", event.Pool)
+				return
+			}
+		case <-timeout:
+			t.Fatalf("未收到事件 %s %s", op, p)
+		}
+	}
+}
@@ -1,16 +1,43 @@
 package index
 
 import (
+	"encoding/json"
 	"log/slog"
 	"net/http"
 	"strings"
 
 	"code.d7z.net/packages/webdav-server/assets"
 	"code.d7z.net/packages/webdav-server/common"
+	"code.d7z.net/packages/webdav-server/supervisor"
 	"github.com/go-chi/chi/v5"
 )
 
-func WithIndex(ctx *common.FsContext, route *chi.Mux) {
+// versionInfo /version 返回的构建信息，各协议服务的运行状态仅对已登录用户或携带指标令牌的请求返回
+type versionInfo struct {
+	common.BuildInfo
+	Services []supervisor.Status `json:"services,omitempty"`
+}
+
+// WithIndex 挂载首页、登录与 /version，services 返回各协议服务的运行状态
+func WithIndex(ctx *common.FsContext, route *chi.Mux, services func() []supervisor.Status) {
+	branding := ctx.Config.Branding
+	brand := assets.Branding{Title: branding.Title, Logo: branding.LogoURL(), Footer: branding.Footer}
+	if branding.CSS != "" {
+		brand.CSS = "/branding/style.css"
+	}
+	assets.SetBranding(brand)
+	if branding.Logo != "" && branding.LogoURL() != branding.Logo {
+		route.Get("/branding/logo", func(w http.ResponseWriter, r *http.Request) {
+			http.ServeFile(w, r, branding.Logo)
+		})
+	}
+	if branding.CSS != "" {
+		route.Get("/branding/style.css", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/css; charset=utf-8")
+			http.ServeFile(w, r, branding.CSS)
+		})
+	}
+
 	route.Get("/logout", func(writer http.ResponseWriter, request *http.Request) {
 		http.SetCookie(writer, &http.Cookie{
 			Name:   "webdav_session",
@@ -21,9 +48,18 @@ func WithIndex(ctx *common.FsContext, route *chi.Mux) {
 		http.Redirect(writer, request, "/", http.StatusFound)
 	})
 
+	route.Get("/version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		info := versionInfo{BuildInfo: common.GetBuildInfo()}
+		if user, err := ctx.GetUserFromCookie(r); (err == nil && user != "guest") || ctx.HasMetricsToken(r) {
+			info.Services = services()
+		}
+		_ = json.NewEncoder(w).Encode(info)
+	})
+
 	route.Get("/login", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Content-Type", "text/html; charset=utf-8")
-		_ = assets.ZLogin.Execute(w, map[string]interface{}{
+		_ = assets.ZLogin.Execute(w, assets.Language(w, r), map[string]interface{}{
 			"Return": r.URL.Query().Get("return"),
 		})
 	})
@@ -41,9 +77,10 @@ func WithIndex(ctx *common.FsContext, route *chi.Mux) {
 		}
 
 		if _, err := ctx.LoadFS(username, password, nil, false); err != nil {
+			lang := assets.Language(w, r)
 			w.Header().Add("Content-Type", "text/html; charset=utf-8")
 			w.WriteHeader(http.StatusUnauthorized)
-			_ = assets.ZLogin.Execute(w, map[string]interface{}{
+			_ = assets.ZLogin.Execute(w, lang, map[string]interface{}{
 				"Error":  "用户名或密码错误",
 				"Return": returnUrl,
 			})
@@ -81,11 +118,18 @@ func WithIndex(ctx *common.FsContext, route *chi.Mux) {
 			return
 		}
 
+		// 空间使用情况只对登录用户显示
+		pools := ctx.Config.UserPools("guest")
+		if currentUser != "" && currentUser != "guest" {
+			pools = ctx.PoolUsage(currentUser)
+		}
 		writer.Header().Add("Content-Type", "text/html; charset=utf-8")
-		_ = assets.ZIndex.Execute(writer, map[string]interface{}{
+		_ = assets.ZIndex.Execute(writer, assets.Language(writer, request), map[string]interface{}{
 			"Config":   ctx.Config,
+			"Pools":    pools,
 			"IsLogged": currentUser != "" && currentUser != "guest",
 			"User":     currentUser,
+			"Build":    common.GetBuildInfo(),
 		})
 	})
 }
@@ -4,11 +4,12 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"fmt"
 	"log/slog"
-	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -18,6 +19,8 @@ import (
 	"code.d7z.net/packages/webdav-server/index"
 	"code.d7z.net/packages/webdav-server/preview"
 	"code.d7z.net/packages/webdav-server/sftp_service"
+	"code.d7z.net/packages/webdav-server/site"
+	"code.d7z.net/packages/webdav-server/supervisor"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
@@ -25,35 +28,87 @@ import (
 var (
 	config = "./config.yml"
 	debug  bool
+	check  bool
+	ver    bool
 )
 
 func init() {
 	flag.StringVar(&config, "config", config, "config file")
 	flag.BoolVar(&debug, "debug", debug, "debug mode")
+	flag.BoolVar(&check, "check", check, "check config and pools, then exit")
+	flag.BoolVar(&ver, "version", ver, "print version and exit")
 	flag.Parse()
 	if debug {
 		slog.SetLogLoggerLevel(slog.LevelDebug)
+	} else if check {
+		slog.SetLogLoggerLevel(slog.LevelInfo)
 	} else {
 		slog.SetLogLoggerLevel(slog.LevelWarn)
 	}
 }
 
 func main() {
-	cfg, err := common.LoadConfig(config)
+	if ver {
+		info := common.GetBuildInfo()
+		fmt.Printf("webdav-server %s (commit %s, built %s, %s)\n", info.Version, info.Commit, info.BuildDate, info.GoVersion)
+		return
+	}
+	if flag.Arg(0) == "service" {
+		if err := serviceCommand(flag.Args()[1:]); err != nil {
+			slog.Error("service command err", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+	var cfg *common.Config
+	var err error
+	if flag.Arg(0) == "serve" {
+		cfg, err = loadServeConfig(flag.Args()[1:])
+	} else {
+		cfg, err = common.LoadConfig(config)
+	}
 	if err != nil {
 		slog.Error("load config err", "err", err)
 		os.Exit(1)
 	}
-	osCtx, cancel := context.WithCancel(context.Background())
+	if flag.Arg(0) == "gc" {
+		if err := gcCommand(cfg, flag.Args()[1:]); err != nil {
+			slog.Error("gc err", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.Arg(0) == "trash" {
+		if err := trashCommand(cfg, flag.Args()[1:]); err != nil {
+			slog.Error("trash err", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.Arg(0) == "snapshot" {
+		if err := snapshotCommand(cfg, flag.Args()[1:]); err != nil {
+			slog.Error("snapshot err", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if cfg.Templates != "" {
+		replaced, err := assets.LoadTemplates(cfg.Templates)
+		if err != nil {
+			slog.Error("load templates err", "err", err)
+			os.Exit(1)
+		}
+		slog.Info("custom templates loaded", "dir", cfg.Templates, "files", replaced)
+	}
+	if err = common.SelfTest(cfg); err != nil && (check || cfg.StrictPools) {
+		slog.Error("pool self-test failed", "err", err)
+		os.Exit(1)
+	}
+	if check {
+		return
+	}
+	osCtx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
-	go func() {
-		defer cancel()
-		sig := make(chan os.Signal, 1)
-		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
-		defer signal.Stop(sig)
-		defer close(sig)
-		<-sig
-	}()
 	ctx, err := common.NewContext(osCtx, cfg)
 	if err != nil {
 		slog.Error("new context err", "err", err)
@@ -62,8 +117,10 @@ func main() {
 
 	route := chi.NewMux()
 	route.Use(middleware.RequestID)
-	route.Use(middleware.RealIP)
+	route.Use(common.RealIP(cfg))
 	route.Use(middleware.Recoverer)
+	route.Use(common.Compress(cfg))
+	route.Use(site.Hosts(ctx))
 	if debug {
 		route.Use(middleware.Logger)
 	}
@@ -71,53 +128,220 @@ func main() {
 	// Static files
 	route.Handle("/static/*", http.StripPrefix("/static/", http.FileServer(http.FS(assets.StaticFS))))
 
+	if cfg.Metrics.Enabled {
+		route.Handle(cfg.Metrics.Path, ctx.MetricsHandler())
+	}
 	if cfg.Webdav.Enabled {
 		slog.Info("webdav enabled")
 		route.Route(cfg.Webdav.Prefix, dav.WithWebdav(ctx))
 	}
 	route.Route("/preview", preview.WithPreview(ctx))
-	index.WithIndex(ctx, route)
-
-	httpListen, err := net.Listen("tcp", cfg.Bind)
-	if err != nil {
-		slog.Error("listen http err", "err", err)
-		os.Exit(1)
+	if cfg.Preview.Thumbnails.Enabled {
+		route.Route("/thumb", preview.WithThumbnails(ctx))
+	}
+	if cfg.Preview.Shares.Enabled {
+		route.Route("/s", preview.WithShares(ctx))
 	}
-	var sftpListen net.Listener
-	var sftpServer *sftp_service.SFTPServer
+	site.WithSites(ctx, route)
+	super := supervisor.New(supervisor.RestartPolicy{
+		MaxRetries: cfg.Restart.MaxRetries,
+		Delay:      cfg.Restart.Delay,
+	}, 10*time.Second)
+	index.WithIndex(ctx, route, super.Statuses)
+
+	super.Add("http", serveHTTP(cfg, route))
 	if cfg.SFTP.Enabled {
-		sftpServer, err = sftp_service.NewSFTPServer(ctx)
+		sftpServer, err := sftp_service.NewSFTPServer(ctx)
 		if err != nil {
 			slog.Error("sftp init err", "err", err)
 			os.Exit(1)
 		}
-		sftpListen, err = net.Listen("tcp", cfg.SFTP.Bind)
+		super.Add("sftp", func(runCtx context.Context) error {
+			listener, err := cfg.Listen(cfg.SFTP.Bind, cfg.SFTP.ProxyProtocol)
+			if err != nil {
+				return err
+			}
+			slog.Info("sftp enabled", "addr", cfg.SFTP.Bind)
+			return sftpServer.Serve(runCtx, listener)
+		})
+	}
+	if err := runService(osCtx, super.Run); err != nil {
+		slog.Error("serve err", "err", err)
+		os.Exit(1)
+	}
+}
+
+func serveHTTP(cfg *common.Config, handler http.Handler) supervisor.RunFunc {
+	return func(ctx context.Context) error {
+		listener, err := cfg.Listen(cfg.Bind, cfg.ProxyProtocol)
 		if err != nil {
-			slog.Error("listen sftp err", "err", err)
-			os.Exit(1)
+			return err
+		}
+		server := &http.Server{
+			Addr:              cfg.Bind,
+			Handler:           handler,
+			ReadHeaderTimeout: cfg.Timeouts.ReadHeader,
+			ReadTimeout:       cfg.Timeouts.Read,
+			WriteTimeout:      cfg.Timeouts.Write,
+			IdleTimeout:       cfg.Timeouts.Idle,
+		}
+		serveErr := make(chan error, 1)
+		go func() {
+			serveErr <- server.Serve(listener)
+		}()
+		select {
+		case err := <-serveErr:
+			return err
+		case <-ctx.Done():
+		}
+		timeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(timeout); err != nil {
+			return err
 		}
+		if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
+
+// gcCommand 解析 gc 子命令: gc [-grace 1h] [pool...]
+func gcCommand(cfg *common.Config, args []string) error {
+	slog.SetLogLoggerLevel(slog.LevelInfo)
+	set := flag.NewFlagSet("gc", flag.ExitOnError)
+	grace := set.Duration("grace", time.Hour, "keep unreferenced blobs newer than this")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	return common.GCPools(cfg, set.Args(), *grace)
+}
 
+// trashCommand 解析 trash 子命令:
+// trash list <pool> | trash restore <pool> <id...> | trash delete <pool> <id...> | trash empty <pool> | trash purge [pool...]
+func trashCommand(cfg *common.Config, args []string) error {
+	slog.SetLogLoggerLevel(slog.LevelInfo)
+	if len(args) == 0 {
+		return errors.New("usage: trash list|restore|delete|empty|purge")
+	}
+	if args[0] == "purge" {
+		return common.PurgeTrash(cfg, args[1:], time.Now())
 	}
-	server := http.Server{
-		Addr:    cfg.Bind,
-		Handler: route,
+	if len(args) < 2 {
+		return fmt.Errorf("usage: trash %s <pool>", args[0])
 	}
-	go func() {
-		if err := server.Serve(httpListen); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			slog.Error("serve err", "err", err)
+	trashFs, err := common.OpenTrash(cfg, args[1])
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "list":
+		entries, err := trashFs.List()
+		if err != nil {
+			return err
 		}
-	}()
-	go func() {
-		if sftpServer != nil && sftpListen != nil {
-			slog.Info("sftp enabled", "addr", cfg.SFTP.Bind)
-			sftpServer.Serve(ctx, sftpListen)
+		for _, entry := range entries {
+			fmt.Printf("%s\t%s\t%s\n", entry.ID, entry.DeletedAt.Local().Format(time.DateTime), entry.Path)
 		}
-	}()
-	<-osCtx.Done()
-	timeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := server.Shutdown(timeout); err != nil {
-		slog.Error("shutdown err", "err", err)
-		os.Exit(1)
+	case "restore":
+		for _, id := range args[2:] {
+			entry, err := trashFs.Restore(id)
+			if err != nil {
+				return err
+			}
+			slog.Info("|trash| Restored.", "pool", args[1], "path", entry.Path)
+		}
+	case "delete":
+		for _, id := range args[2:] {
+			if err := trashFs.Delete(id); err != nil {
+				return err
+			}
+		}
+	case "empty":
+		count, err := trashFs.Purge(time.Now().Add(time.Second))
+		if err != nil {
+			return err
+		}
+		slog.Info("|trash| Pool emptied.", "pool", args[1], "removed", count)
+	default:
+		return fmt.Errorf("unknown trash command: %s", args[0])
+	}
+	return nil
+}
+
+// snapshotCommand 解析 snapshot 子命令:
+// snapshot list <pool> | snapshot create <pool> [name] | snapshot delete <pool> <name...>
+func snapshotCommand(cfg *common.Config, args []string) error {
+	slog.SetLogLoggerLevel(slog.LevelInfo)
+	if len(args) < 2 {
+		return errors.New("usage: snapshot list|create|delete <pool>")
+	}
+	switch args[0] {
+	case "list":
+		names, err := common.ListSnapshots(cfg, args[1])
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Printf("/%s@%s\n", args[1], name)
+		}
+	case "create":
+		name := time.Now().Format(time.DateOnly)
+		if len(args) > 2 {
+			name = args[2]
+		}
+		return common.CreateSnapshot(cfg, args[1], name)
+	case "delete":
+		for _, name := range args[2:] {
+			if err := common.DeleteSnapshot(cfg, args[1], name); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown snapshot command: %s", args[0])
+	}
+	return nil
+}
+
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// loadServeConfig 解析 serve 子命令: serve [flags] <dir> [flags]
+func loadServeConfig(args []string) (*common.Config, error) {
+	var users stringsFlag
+	set := flag.NewFlagSet("serve", flag.ExitOnError)
+	bind := set.String("bind", "127.0.0.1:8080", "http bind address")
+	guest := set.String("guest", "r", "guest permission (r, rw or none)")
+	set.Var(&users, "user", "read-write user as name:password, can be repeated")
+	if err := set.Parse(args); err != nil {
+		return nil, err
+	}
+	dir := "."
+	if set.NArg() > 0 {
+		dir = set.Arg(0)
+		if err := set.Parse(set.Args()[1:]); err != nil {
+			return nil, err
+		}
+		if set.NArg() > 0 {
+			return nil, fmt.Errorf("unexpected arguments: %v", set.Args())
+		}
+	}
+	guestPerm := common.FilePerm(*guest)
+	if *guest == "none" {
+		guestPerm = ""
+	}
+	cfg, err := common.NewServeConfig(dir, *bind, users, guestPerm)
+	if err != nil {
+		return nil, err
 	}
+	fmt.Printf("Serving %s on http://%s/preview/ (webdav: %s)\n", dir, cfg.Bind, cfg.Webdav.Prefix)
+	return cfg, nil
 }
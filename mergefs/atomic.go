@@ -0,0 +1,212 @@
+package mergefs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/spf13/afero"
+)
+
+// atomicTempPrefix 临时文件名前缀，目录列表中会被过滤
+const atomicTempPrefix = ".~atomic-"
+
+// ContextOpener 支持在 ctx 取消时放弃写入的文件系统
+type ContextOpener interface {
+	OpenFileContext(ctx context.Context, name string, flag int, perm os.FileMode) (afero.File, error)
+}
+
+// Aborter 由 AtomicFs 返回的文件实现，放弃本次写入并删除临时文件
+type Aborter interface {
+	Abort() error
+}
+
+// openFileContext 在底层文件系统支持时传递 ctx
+func openFileContext(ctx context.Context, fs afero.Fs, name string, flag int, perm os.FileMode) (afero.File, error) {
+	if opener, ok := fs.(ContextOpener); ok {
+		return opener.OpenFileContext(ctx, name, flag, perm)
+	}
+	return fs.OpenFile(name, flag, perm)
+}
+
+// atomicTempName 返回与 name 位于同一目录的随机临时文件名
+func atomicTempName(name string) string {
+	suffix := make([]byte, 8)
+	_, _ = rand.Read(suffix)
+	dir, base := path.Split(NormalizePath(name))
+	return path.Join(dir, atomicTempPrefix+base+"-"+hex.EncodeToString(suffix))
+}
+
+// WriteFileAtomic 将 data 写入 fs 中的 name，先写入同目录下的临时文件再重命名，
+// 写入失败时原有的文件保持不变
+func WriteFileAtomic(ctx context.Context, fs afero.Fs, name string, data []byte, perm os.FileMode) error {
+	file, err := NewAtomicFs(fs).OpenFileContext(ctx, name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.(Aborter).Abort()
+		return err
+	}
+	return file.Close()
+}
+
+// AtomicFs 将截断写入（上传）的文件先写入同目录下的临时文件，Close 时再重命名到目标位置，
+// 上传中断或 ctx 取消时删除临时文件，其他读取者不会看到不完整的文件
+type AtomicFs struct {
+	afero.Fs
+}
+
+// NewAtomicFs 创建 AtomicFs
+func NewAtomicFs(fs afero.Fs) *AtomicFs {
+	return &AtomicFs{Fs: fs}
+}
+
+func (a *AtomicFs) Create(name string) (afero.File, error) {
+	return a.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (a *AtomicFs) Open(name string) (afero.File, error) {
+	return a.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (a *AtomicFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	return a.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+func (a *AtomicFs) OpenFileContext(ctx context.Context, name string, flag int, perm os.FileMode) (afero.File, error) {
+	// 仅截断写入的文件需要原子替换，追加或随机写入需要保留原有内容
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 || flag&os.O_TRUNC == 0 || flag&os.O_APPEND != 0 {
+		file, err := openFileContext(ctx, a.Fs, name, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		if info, err := file.Stat(); err == nil && info.IsDir() {
+			return &atomicDirFile{File: file}, nil
+		}
+		return file, nil
+	}
+	info, err := a.Fs.Stat(name)
+	switch {
+	case err == nil && info.IsDir():
+		return nil, &os.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	case err == nil && flag&os.O_EXCL != 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+	case err != nil && (!os.IsNotExist(err) || flag&os.O_CREATE == 0):
+		return nil, err
+	}
+	tmp := atomicTempName(name)
+	file, err := openFileContext(ctx, a.Fs, tmp, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return nil, err
+	}
+	if info != nil {
+		// 替换已有文件时保留其权限与自定义属性
+		_ = a.Fs.Chmod(tmp, info.Mode().Perm())
+		copyProps(a.Fs, name, a.Fs, tmp)
+	}
+	return &atomicFile{File: file, fs: a.Fs, ctx: ctx, name: name, tmp: tmp}, nil
+}
+
+func (a *AtomicFs) Name() string {
+	return "AtomicFs"
+}
+
+// atomicFile 写入临时文件，关闭时重命名到目标位置
+type atomicFile struct {
+	afero.File
+	fs   afero.Fs
+	ctx  context.Context
+	name string
+	tmp  string
+
+	mu     sync.Mutex
+	failed error
+	closed bool
+}
+
+func (f *atomicFile) Name() string {
+	return f.name
+}
+
+// TransferError 由 sftp 在连接异常断开时调用，标记本次传输失败
+func (f *atomicFile) TransferError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failed = err
+}
+
+func (f *atomicFile) Abort() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	_ = f.File.Close()
+	return f.fs.Remove(f.tmp)
+}
+
+func (f *atomicFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	err := f.File.Close()
+	if err == nil {
+		err = f.failed
+	}
+	if err == nil {
+		err = context.Cause(f.ctx)
+	}
+	if err == nil {
+		err = f.fs.Rename(f.tmp, f.name)
+	}
+	if err != nil {
+		_ = f.fs.Remove(f.tmp)
+		return err
+	}
+	return nil
+}
+
+// atomicDirFile 在目录列表中过滤正在写入的临时文件
+type atomicDirFile struct {
+	afero.File
+}
+
+func (f *atomicDirFile) Readdir(count int) ([]os.FileInfo, error) {
+	for {
+		infos, err := f.File.Readdir(count)
+		result := make([]os.FileInfo, 0, len(infos))
+		for _, info := range infos {
+			if !strings.HasPrefix(info.Name(), atomicTempPrefix) {
+				result = append(result, info)
+			}
+		}
+		if len(result) > 0 || err != nil || count <= 0 {
+			return result, err
+		}
+	}
+}
+
+func (f *atomicDirFile) Readdirnames(count int) ([]string, error) {
+	for {
+		names, err := f.File.Readdirnames(count)
+		result := make([]string, 0, len(names))
+		for _, name := range names {
+			if !strings.HasPrefix(name, atomicTempPrefix) {
+				result = append(result, name)
+			}
+		}
+		if len(result) > 0 || err != nil || count <= 0 {
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package mergefs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAtomicFs(t *testing.T) {
+	base := afero.NewMemMapFs()
+	_ = afero.WriteFile(base, "/file.txt", []byte("old"), 0o600)
+	fs := NewAtomicFs(base)
+
+	file, err := fs.OpenFile("/file.txt", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+	assert.NoError(t, err)
+	_, _ = file.Write([]byte("new content"))
+	data, _ := afero.ReadFile(fs, "/file.txt")
+	assert.Equal(t, "old", string(data), "关闭前其他读取者应看到旧内容")
+	assert.Equal(t, []string{"file.txt"}, readNames(t, fs, "/"), "目录列表不应包含临时文件")
+	assert.NoError(t, file.Close())
+	data, _ = afero.ReadFile(fs, "/file.txt")
+	assert.Equal(t, "new content", string(data), "关闭后应替换为新内容")
+	stat, _ := fs.Stat("/file.txt")
+	assert.Equal(t, os.FileMode(0o600), stat.Mode().Perm(), "应保留原有权限")
+
+	// ctx 取消时放弃写入
+	ctx, cancel := context.WithCancel(context.Background())
+	file, err = fs.OpenFileContext(ctx, "/file.txt", os.O_WRONLY|os.O_TRUNC, 0o666)
+	assert.NoError(t, err)
+	_, _ = file.Write([]byte("partial"))
+	cancel()
+	assert.ErrorIs(t, file.Close(), context.Canceled)
+	data, _ = afero.ReadFile(fs, "/file.txt")
+	assert.Equal(t, "new content", string(data), "取消的上传不应覆盖原文件")
+
+	// sftp 传输异常
+	file, err = fs.Create("/other.txt")
+	assert.NoError(t, err)
+	file.(interface{ TransferError(error) }).TransferError(errors.New("broken"))
+	assert.Error(t, file.Close())
+	_, err = base.Stat("/other.txt")
+	assert.True(t, os.IsNotExist(err), "传输失败时不应留下文件")
+	names, _ := afero.ReadDir(base, "/")
+	assert.Len(t, names, 1, "临时文件应被删除")
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/conf/app.yaml", []byte("old"), 0o644))
+	assert.NoError(t, WriteFileAtomic(context.Background(), fs, "/conf/app.yaml", []byte("new"), 0o644))
+	content, err := afero.ReadFile(fs, "/conf/app.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+	entries, _ := afero.ReadDir(fs, "/conf")
+	assert.Len(t, entries, 1, "不留下临时文件")
+}
@@ -0,0 +1,365 @@
+package mergefs
+
+import (
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// CacheOptions 缓存配置
+type CacheOptions struct {
+	// 元数据（Stat 与目录列表）缓存时间
+	TTL time.Duration
+	// 内容缓存层，为 nil 时不缓存文件内容
+	Content afero.Fs
+	// 单个文件允许缓存的最大字节数
+	MaxFileSize int64
+}
+
+// CacheFs 读穿透缓存，用于包装较慢的后端：缓存 Stat、目录列表以及（可选）文件内容，
+// 通过本文件系统的写操作会立即使相关缓存失效，其他修改在 TTL 过期后可见。
+type CacheFs struct {
+	afero.Fs
+	opts CacheOptions
+
+	mu      sync.Mutex
+	inserts int
+	stats   map[string]cacheStat
+	dirs    map[string]cacheDir
+	content map[string]cacheContent
+}
+
+type cacheStat struct {
+	info   os.FileInfo
+	expire time.Time
+}
+
+type cacheDir struct {
+	entries []os.FileInfo
+	expire  time.Time
+}
+
+type cacheContent struct {
+	size    int64
+	modTime time.Time
+}
+
+// NewCacheFs 创建 CacheFs
+func NewCacheFs(fs afero.Fs, opts CacheOptions) *CacheFs {
+	return &CacheFs{
+		Fs:      fs,
+		opts:    opts,
+		stats:   make(map[string]cacheStat),
+		dirs:    make(map[string]cacheDir),
+		content: make(map[string]cacheContent),
+	}
+}
+
+func (c *CacheFs) Name() string {
+	return "CacheFs"
+}
+
+// Invalidate 使路径及其父目录的缓存失效
+func (c *CacheFs) Invalidate(name string) {
+	name = NormalizePath(name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invalidateLocked(name)
+}
+
+func (c *CacheFs) invalidateLocked(name string) {
+	delete(c.stats, name)
+	delete(c.dirs, name)
+	delete(c.dirs, path.Dir(name))
+	if _, ok := c.content[name]; ok {
+		delete(c.content, name)
+		if c.opts.Content != nil {
+			_ = c.opts.Content.Remove(name)
+		}
+	}
+}
+
+// InvalidateTree 使路径及其下的所有缓存失效，用于目录删除与重命名
+func (c *CacheFs) InvalidateTree(name string) {
+	name = NormalizePath(name)
+	prefix := name + "/"
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invalidateLocked(name)
+	for key := range c.stats {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.stats, key)
+		}
+	}
+	for key := range c.dirs {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.dirs, key)
+		}
+	}
+	for key := range c.content {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.content, key)
+		}
+	}
+	if c.opts.Content != nil {
+		_ = c.opts.Content.RemoveAll(name)
+	}
+}
+
+// sweepLocked 定期清理过期的元数据，避免缓存无限增长
+func (c *CacheFs) sweepLocked(now time.Time) {
+	c.inserts++
+	if c.inserts < 1024 {
+		return
+	}
+	c.inserts = 0
+	for key, entry := range c.stats {
+		if !now.Before(entry.expire) {
+			delete(c.stats, key)
+		}
+	}
+	for key, entry := range c.dirs {
+		if !now.Before(entry.expire) {
+			delete(c.dirs, key)
+		}
+	}
+}
+
+func (c *CacheFs) Stat(name string) (os.FileInfo, error) {
+	name = NormalizePath(name)
+	now := time.Now()
+	c.mu.Lock()
+	if entry, ok := c.stats[name]; ok && now.Before(entry.expire) {
+		c.mu.Unlock()
+		return entry.info, nil
+	}
+	c.mu.Unlock()
+	info, err := c.Fs.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.stats[name] = cacheStat{info: info, expire: now.Add(c.opts.TTL)}
+	c.sweepLocked(now)
+	c.mu.Unlock()
+	return info, nil
+}
+
+func (c *CacheFs) Open(name string) (afero.File, error) {
+	return c.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (c *CacheFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	name = NormalizePath(name)
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		c.Invalidate(name)
+		file, err := c.Fs.OpenFile(name, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		return &cacheWriteFile{File: file, fs: c, name: name}, nil
+	}
+	info, err := c.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return c.openDir(name, info)
+	}
+	if c.opts.Content != nil && info.Size() <= c.opts.MaxFileSize {
+		if file, err := c.openContent(name, info); err == nil {
+			return file, nil
+		}
+	}
+	return c.Fs.OpenFile(name, flag, perm)
+}
+
+func (c *CacheFs) openDir(name string, info os.FileInfo) (afero.File, error) {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.dirs[name]
+	c.mu.Unlock()
+	if !ok || !now.Before(entry.expire) {
+		file, err := c.Fs.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := file.Readdir(-1)
+		_ = file.Close()
+		if err != nil {
+			return nil, err
+		}
+		entry = cacheDir{entries: entries, expire: now.Add(c.opts.TTL)}
+		c.mu.Lock()
+		c.dirs[name] = entry
+		for _, child := range entries {
+			c.stats[path.Join(name, child.Name())] = cacheStat{info: child, expire: entry.expire}
+		}
+		c.sweepLocked(now)
+		c.mu.Unlock()
+	}
+	return &cacheDirFile{name: name, info: info, entries: entry.entries}, nil
+}
+
+// openContent 从内容缓存层打开文件，缓存缺失或过期时从后端复制
+func (c *CacheFs) openContent(name string, info os.FileInfo) (afero.File, error) {
+	c.mu.Lock()
+	cached, ok := c.content[name]
+	c.mu.Unlock()
+	if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return c.opts.Content.Open(name)
+	}
+	src, err := c.Fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	if err = c.opts.Content.MkdirAll(path.Dir(name), 0o755); err != nil {
+		return nil, err
+	}
+	dst, err := c.opts.Content.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(dst, io.LimitReader(src, c.opts.MaxFileSize+1)); err != nil {
+		_ = dst.Close()
+		_ = c.opts.Content.Remove(name)
+		return nil, err
+	}
+	if err = dst.Close(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.content[name] = cacheContent{size: info.Size(), modTime: info.ModTime()}
+	c.mu.Unlock()
+	return c.opts.Content.Open(name)
+}
+
+func (c *CacheFs) Create(name string) (afero.File, error) {
+	return c.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (c *CacheFs) Mkdir(name string, perm os.FileMode) error {
+	defer c.Invalidate(name)
+	return c.Fs.Mkdir(name, perm)
+}
+
+// MkdirAll 使新建的最上层目录及其父目录的列表失效
+func (c *CacheFs) MkdirAll(p string, perm os.FileMode) error {
+	// 直接查询后端，缓存中的结果可能已经过时
+	top := NormalizePath(p)
+	for top != "/" {
+		if _, err := c.Fs.Stat(path.Dir(top)); err == nil {
+			break
+		}
+		top = path.Dir(top)
+	}
+	defer c.InvalidateTree(top)
+	return c.Fs.MkdirAll(p, perm)
+}
+
+func (c *CacheFs) Remove(name string) error {
+	defer c.InvalidateTree(name)
+	return c.Fs.Remove(name)
+}
+
+func (c *CacheFs) RemoveAll(p string) error {
+	defer c.InvalidateTree(p)
+	return c.Fs.RemoveAll(p)
+}
+
+func (c *CacheFs) Rename(oldname, newname string) error {
+	defer c.InvalidateTree(newname)
+	defer c.InvalidateTree(oldname)
+	return c.Fs.Rename(oldname, newname)
+}
+
+func (c *CacheFs) Chmod(name string, mode os.FileMode) error {
+	defer c.Invalidate(name)
+	return c.Fs.Chmod(name, mode)
+}
+
+func (c *CacheFs) Chown(name string, uid, gid int) error {
+	defer c.Invalidate(name)
+	return c.Fs.Chown(name, uid, gid)
+}
+
+func (c *CacheFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	defer c.Invalidate(name)
+	return c.Fs.Chtimes(name, atime, mtime)
+}
+
+// cacheWriteFile 关闭时再次使缓存失效，避免写入过程中缓存了中间状态
+type cacheWriteFile struct {
+	afero.File
+	fs   *CacheFs
+	name string
+}
+
+func (f *cacheWriteFile) Close() error {
+	defer f.fs.Invalidate(f.name)
+	return f.File.Close()
+}
+
+// cacheDirFile 由缓存的目录列表构造的只读目录句柄
+type cacheDirFile struct {
+	name    string
+	info    os.FileInfo
+	entries []os.FileInfo
+	offset  int
+}
+
+func (d *cacheDirFile) Close() error               { return nil }
+func (d *cacheDirFile) Name() string               { return d.name }
+func (d *cacheDirFile) Stat() (os.FileInfo, error) { return d.info, nil }
+func (d *cacheDirFile) Sync() error                { return nil }
+func (d *cacheDirFile) Read([]byte) (int, error)   { return 0, d.isDir("read") }
+func (d *cacheDirFile) ReadAt([]byte, int64) (int, error) {
+	return 0, d.isDir("read")
+}
+func (d *cacheDirFile) Write([]byte) (int, error)          { return 0, d.isDir("write") }
+func (d *cacheDirFile) WriteAt([]byte, int64) (int, error) { return 0, d.isDir("write") }
+func (d *cacheDirFile) WriteString(string) (int, error)    { return 0, d.isDir("write") }
+func (d *cacheDirFile) Truncate(int64) error               { return d.isDir("truncate") }
+
+func (d *cacheDirFile) isDir(op string) error {
+	return &os.PathError{Op: op, Path: d.name, Err: syscall.EISDIR}
+}
+
+func (d *cacheDirFile) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekStart && offset == 0 {
+		d.offset = 0
+	}
+	return 0, nil
+}
+
+func (d *cacheDirFile) Readdir(count int) ([]os.FileInfo, error) {
+	if d.offset >= len(d.entries) {
+		if count <= 0 {
+			return []os.FileInfo{}, nil
+		}
+		return nil, io.EOF
+	}
+	end := len(d.entries)
+	if count > 0 && d.offset+count < end {
+		end = d.offset + count
+	}
+	result := d.entries[d.offset:end]
+	d.offset = end
+	return result, nil
+}
+
+func (d *cacheDirFile) Readdirnames(count int) ([]string, error) {
+	infos, err := d.Readdir(count)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return names, err
+}
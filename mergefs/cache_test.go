@@ -0,0 +1,67 @@
+package mergefs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheFs_Metadata(t *testing.T) {
+	backend := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(backend, "/a.txt", []byte("a"), 0o644))
+	c := NewCacheFs(backend, CacheOptions{TTL: time.Hour})
+
+	assert.Len(t, readNames(t, c, "/"), 1)
+
+	// 绕过缓存直接修改后端，TTL 内不可见
+	assert.NoError(t, afero.WriteFile(backend, "/b.txt", []byte("b"), 0o644))
+	assert.Len(t, readNames(t, c, "/"), 1, "目录列表应来自缓存")
+
+	// 通过缓存写入会使目录列表失效
+	assert.NoError(t, afero.WriteFile(c, "/c.txt", []byte("c"), 0o644))
+	assert.Equal(t, []string{"a.txt", "b.txt", "c.txt"}, readNames(t, c, "/"))
+
+	// 删除后 Stat 不应返回缓存结果
+	assert.NoError(t, c.Remove("/a.txt"))
+	_, err := c.Stat("/a.txt")
+	assert.Error(t, err)
+
+	// 手动失效
+	assert.NoError(t, backend.Remove("/b.txt"))
+	c.Invalidate("/b.txt")
+	assert.Equal(t, []string{"c.txt"}, readNames(t, c, "/"))
+}
+
+func TestCacheFs_MkdirAll(t *testing.T) {
+	c := NewCacheFs(afero.NewMemMapFs(), CacheOptions{TTL: time.Minute})
+
+	assert.Empty(t, readNames(t, c, "/"))
+	assert.NoError(t, c.MkdirAll("/a/b/c", 0o755))
+	assert.Equal(t, []string{"a"}, readNames(t, c, "/"), "新建的最上层目录应出现在父目录列表中")
+	assert.Equal(t, []string{"b"}, readNames(t, c, "/a"))
+}
+
+func TestCacheFs_Content(t *testing.T) {
+	backend := afero.NewMemMapFs()
+	layer := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(backend, "/small.txt", []byte("small"), 0o644))
+	assert.NoError(t, afero.WriteFile(backend, "/big.txt", []byte("0123456789"), 0o644))
+	c := NewCacheFs(backend, CacheOptions{TTL: time.Hour, Content: layer, MaxFileSize: 8})
+
+	data, err := afero.ReadFile(c, "/small.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "small", string(data))
+	assert.True(t, exists(layer, "/small.txt"), "小文件应被缓存")
+
+	data, err = afero.ReadFile(c, "/big.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+	assert.False(t, exists(layer, "/big.txt"), "超过大小限制的文件不应缓存")
+
+	// 写入后缓存内容失效
+	assert.NoError(t, afero.WriteFile(c, "/small.txt", []byte("changed"), 0o644))
+	data, _ = afero.ReadFile(c, "/small.txt")
+	assert.Equal(t, "changed", string(data))
+}
@@ -0,0 +1,91 @@
+package mergefs
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+// OwnerFs 将新建的文件与目录的属主改为指定的 uid/gid，
+// 使通过本服务上传的文件可以被共享同一目录的 Samba/NFS 使用。
+// 修改属主需要 root 权限或 CAP_CHOWN，失败时忽略
+type OwnerFs struct {
+	afero.Fs
+	uid, gid int
+}
+
+// NewOwnerFs 创建 OwnerFs，uid 或 gid 为 -1 时保持不变
+func NewOwnerFs(fs afero.Fs, uid, gid int) *OwnerFs {
+	return &OwnerFs{Fs: fs, uid: uid, gid: gid}
+}
+
+func (o *OwnerFs) chown(name string) {
+	_ = o.Fs.Chown(name, o.uid, o.gid)
+}
+
+func (o *OwnerFs) Create(name string) (afero.File, error) {
+	return o.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (o *OwnerFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	return o.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+func (o *OwnerFs) OpenFileContext(ctx context.Context, name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&os.O_CREATE == 0 {
+		return openFileContext(ctx, o.Fs, name, flag, perm)
+	}
+	_, err := o.Fs.Stat(name)
+	created := os.IsNotExist(err)
+	file, err := openFileContext(ctx, o.Fs, name, flag, perm)
+	if err == nil && created {
+		o.chown(name)
+	}
+	return file, err
+}
+
+func (o *OwnerFs) Mkdir(name string, perm os.FileMode) error {
+	if err := o.Fs.Mkdir(name, perm); err != nil {
+		return err
+	}
+	o.chown(name)
+	return nil
+}
+
+// MkdirAll 只修改本次新建的目录的属主
+func (o *OwnerFs) MkdirAll(name string, perm os.FileMode) error {
+	name = path.Clean("/" + filepath.ToSlash(name))
+	var missing []string
+	for dir := name; ; dir = path.Dir(dir) {
+		if _, err := o.Fs.Stat(dir); err == nil || dir == "/" {
+			break
+		}
+		missing = append(missing, dir)
+	}
+	if err := o.Fs.MkdirAll(name, perm); err != nil {
+		return err
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		o.chown(missing[i])
+	}
+	return nil
+}
+
+func (o *OwnerFs) LinkIfPossible(oldname, newname string) error {
+	return linkIfPossible(o.Fs, oldname, newname)
+}
+
+func (o *OwnerFs) CopyFile(ctx context.Context, src, dst string) error {
+	if err := copyFileIfPossible(ctx, o.Fs, src, dst); err != nil {
+		return err
+	}
+	o.chown(dst)
+	return nil
+}
+
+func (o *OwnerFs) Name() string {
+	return "OwnerFs"
+}
@@ -0,0 +1,27 @@
+package mergefs
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOwnerFs(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() != 0 {
+		t.Skip("修改属主需要 root 权限")
+	}
+	fs := NewOwnerFs(NewOsPathFs(t.TempDir(), SymlinkWithin), 65534, 65534)
+	assert.NoError(t, fs.MkdirAll("/a/b", 0o755))
+	assert.NoError(t, afero.WriteFile(fs, "/a/b/file.txt", []byte("data"), 0o644))
+	for _, name := range []string{"/a", "/a/b", "/a/b/file.txt"} {
+		info, err := fs.Stat(name)
+		assert.NoError(t, err)
+		uid, gid, ok := FileOwner(info)
+		assert.True(t, ok)
+		assert.Equal(t, 65534, uid, name)
+		assert.Equal(t, 65534, gid, name)
+	}
+}
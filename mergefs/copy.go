@@ -0,0 +1,95 @@
+package mergefs
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/spf13/afero"
+)
+
+// ErrNoCopy 底层文件系统无法在服务端复制该文件，调用方应回退为读取后写入
+var ErrNoCopy = errors.New("server-side copy not supported")
+
+// Copier 支持在服务端复制文件的文件系统，如本地文件系统的 reflink 与对象存储的服务端复制，
+// 数据无需经过本进程读写
+type Copier interface {
+	// CopyFile 将文件 src 复制为 dst，dst 已存在时被替换；无法在服务端复制时返回 ErrNoCopy
+	CopyFile(ctx context.Context, src, dst string) error
+}
+
+// copyFileIfPossible 在底层文件系统支持时在服务端复制文件
+func copyFileIfPossible(ctx context.Context, fs afero.Fs, src, dst string) error {
+	if copier, ok := fs.(Copier); ok {
+		return copier.CopyFile(ctx, src, dst)
+	}
+	return &os.LinkError{Op: "copy", Old: src, New: dst, Err: ErrNoCopy}
+}
+
+func (a *AtomicFs) CopyFile(ctx context.Context, src, dst string) error {
+	// 服务端复制由底层保证原子替换，无需临时文件
+	return copyFileIfPossible(ctx, a.Fs, src, dst)
+}
+
+func (m *MinFreeFs) CopyFile(ctx context.Context, src, dst string) error {
+	if err := m.check("copy", dst); err != nil {
+		return err
+	}
+	return copyFileIfPossible(ctx, m.Fs, src, dst)
+}
+
+func (m *MaxSizeFs) CopyFile(ctx context.Context, src, dst string) error {
+	info, err := m.Fs.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.Size() > m.maxSize {
+		return m.tooLarge("copy", dst)
+	}
+	return copyFileIfPossible(ctx, m.Fs, src, dst)
+}
+
+func (c *CacheFs) CopyFile(ctx context.Context, src, dst string) error {
+	defer c.Invalidate(dst)
+	return copyFileIfPossible(ctx, c.Fs, src, dst)
+}
+
+// CopyTree 在 fs 中将文件或目录 src 复制为 dst，dst 已存在时返回 os.ErrExist。
+// 文件优先在服务端复制；目录先复制到目标位置同级的暂存目录，完成后再重命名，失败时不留下不完整的目录
+func CopyTree(ctx context.Context, fs afero.Fs, src, dst string) error {
+	info, err := fs.Stat(src)
+	if err != nil {
+		return err
+	}
+	if _, err := fs.Stat(dst); err == nil {
+		return &os.LinkError{Op: "copy", Old: src, New: dst, Err: os.ErrExist}
+	}
+	if !info.IsDir() {
+		err := copyFileIfPossible(ctx, fs, src, dst)
+		if errors.Is(err, ErrNoCopy) {
+			err = copyFile(ctx, fs, src, fs, dst)
+		}
+		return err
+	}
+	stage := atomicTempName(dst)
+	err = stageDir(ctx, fs, src, fs, stage)
+	if err == nil {
+		err = fs.Rename(stage, dst)
+	}
+	if err != nil {
+		_ = fs.RemoveAll(stage)
+	}
+	return err
+}
+
+// CopyFile 在服务端复制文件，两个路径必须位于同一挂载点且底层文件系统支持服务端复制
+func (m *MountFs) CopyFile(ctx context.Context, src, dst string) error {
+	srcFs, srcPath := m.GetMount(src)
+	dstFs, dstPath := m.GetMount(dst)
+
+	if srcFs != dstFs {
+		return &os.LinkError{Op: "copy", Old: src, New: dst, Err: ErrNoCopy}
+	}
+	m.negative.invalidate(dst)
+	return copyFileIfPossible(ctx, srcFs, srcPath, dstPath)
+}
@@ -0,0 +1,92 @@
+package mergefs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+// copierFs 记录服务端复制次数的内存文件系统
+type copierFs struct {
+	afero.Fs
+	copies int
+}
+
+func (c *copierFs) CopyFile(_ context.Context, src, dst string) error {
+	data, err := afero.ReadFile(c.Fs, src)
+	if err != nil {
+		return err
+	}
+	c.copies++
+	return afero.WriteFile(c.Fs, dst, data, 0o644)
+}
+
+func TestMountFs_CopyFile(t *testing.T) {
+	pool := &copierFs{Fs: afero.NewMemMapFs()}
+	assert.NoError(t, afero.WriteFile(pool, "/sub/a.txt", []byte("hello"), 0o644))
+	mountFs := NewMountFs(afero.NewMemMapFs())
+	assert.NoError(t, mountFs.Mount("/pool", NewAtomicFs(NewModeFs(pool, ModeOptions{FileMode: 0o600}))))
+	assert.NoError(t, mountFs.Mount("/bind", NewAtomicFs(NewSubFs(pool, "/sub"))))
+	assert.NoError(t, mountFs.Mount("/other", afero.NewMemMapFs()))
+
+	ctx := context.Background()
+	assert.NoError(t, mountFs.CopyFile(ctx, "/pool/sub/a.txt", "/pool/b.txt"))
+	data, err := afero.ReadFile(mountFs, "/pool/b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+	info, err := mountFs.Stat("/pool/b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "复制的文件同样应用固定权限")
+
+	assert.NoError(t, mountFs.CopyFile(ctx, "/bind/a.txt", "/bind/c.txt"))
+	_, err = pool.Stat("/sub/c.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, pool.copies)
+
+	err = mountFs.CopyFile(ctx, "/pool/b.txt", "/other/b.txt")
+	assert.True(t, errors.Is(err, ErrNoCopy), "跨挂载点无法在服务端复制")
+	err = mountFs.CopyFile(ctx, "/other/x.txt", "/other/y.txt")
+	assert.True(t, errors.Is(err, ErrNoCopy), "底层不支持服务端复制")
+}
+
+func TestOsPathFs_CopyFile(t *testing.T) {
+	root := t.TempDir()
+	fs := NewOsPathFs(root, SymlinkWithin)
+	assert.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("hello"), 0o640))
+	assert.NoError(t, afero.WriteFile(fs, "/b.txt", []byte("old"), 0o644))
+
+	err := fs.CopyFile(context.Background(), "/a.txt", "/b.txt")
+	if errors.Is(err, ErrNoCopy) {
+		t.Skip("文件系统不支持 reflink")
+	}
+	assert.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(root, "b.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+	entries, err := os.ReadDir(root)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2, "不应残留临时文件")
+}
+
+func TestCopyTree(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/src/a.txt", []byte("a"), 0o644))
+	assert.NoError(t, afero.WriteFile(fs, "/src/sub/b.txt", []byte("b"), 0o644))
+
+	assert.NoError(t, CopyTree(context.Background(), fs, "/src", "/dst"))
+	content, err := afero.ReadFile(fs, "/dst/sub/b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(content))
+	assert.NoError(t, CopyTree(context.Background(), fs, "/src/a.txt", "/dst/c.txt"))
+	content, _ = afero.ReadFile(fs, "/dst/c.txt")
+	assert.Equal(t, "a", string(content))
+
+	assert.ErrorIs(t, CopyTree(context.Background(), fs, "/src", "/dst"), os.ErrExist, "目标已存在")
+	entries, _ := afero.ReadDir(fs, "/")
+	assert.Len(t, entries, 2, "不留下暂存目录")
+}
@@ -0,0 +1,16 @@
+package mergefs
+
+// DiskUsage 磁盘空间信息（字节）
+type DiskUsage struct {
+	Total uint64
+	// Free 当前用户可用的空间
+	Free uint64
+}
+
+// Used 已使用的空间
+func (d DiskUsage) Used() uint64 {
+	if d.Total < d.Free {
+		return 0
+	}
+	return d.Total - d.Free
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package mergefs
+
+import "errors"
+
+// GetDiskUsage 当前平台不支持
+func GetDiskUsage(_ string) (DiskUsage, error) {
+	return DiskUsage{}, errors.ErrUnsupported
+}
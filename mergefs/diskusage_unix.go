@@ -0,0 +1,17 @@
+//go:build unix
+
+package mergefs
+
+import "golang.org/x/sys/unix"
+
+// GetDiskUsage 获取路径所在文件系统的空间信息
+func GetDiskUsage(path string) (DiskUsage, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return DiskUsage{}, err
+	}
+	return DiskUsage{
+		Total: uint64(stat.Blocks) * uint64(stat.Bsize),
+		Free:  uint64(stat.Bavail) * uint64(stat.Bsize),
+	}, nil
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package mergefs
+
+import "golang.org/x/sys/windows"
+
+// GetDiskUsage 获取路径所在卷的空间信息
+func GetDiskUsage(path string) (DiskUsage, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return DiskUsage{}, err
+	}
+	var free, total, totalFree uint64
+	if err = windows.GetDiskFreeSpaceEx(p, &free, &total, &totalFree); err != nil {
+		return DiskUsage{}, err
+	}
+	return DiskUsage{Total: total, Free: free}, nil
+}
@@ -4,6 +4,7 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -13,125 +14,131 @@ import (
 
 // mountFsFile 是对 afero.File 的一个包装，专门用于处理 MountFs 中的目录。
 // 它重写了 Readdir 和 Readdirnames 方法，以便在列出目录内容时，能够正确地包含挂载点。
+// 目录条目在首次读取时才分批从底层文件系统获取，仅 Stat 或读取内容时不产生额外开销。
 type mountFsFile struct {
 	afero.File
-	fs      *MountFs // 指向其所属的 MountFs
-	path    string   // 文件或目录在 MountFs 中的完整路径
-	offset  int      // 用于 Readdir/Readdirnames 的读取偏移量
-	entries []fs.DirEntry
+	fs      *MountFs               // 指向其所属的 MountFs
+	path    string                 // 文件或目录在 MountFs 中的完整路径
+	offset  int                    // 用于 Readdir/Readdirnames 的读取偏移量
+	entries []fs.DirEntry          // 已从底层读取的条目，Seek 回到开头时重放
+	mounts  map[string]fs.DirEntry // 尚未输出的挂载点及虚拟目录，首次读取时生成
+	rawEOF  bool                   // 底层目录是否已读完
 }
 
+// readdirBatch 每次从底层目录读取的条目数
+const readdirBatch = 256
+
 // newMountFsFile 创建并返回一个新的 mountFsFile 实例。
 func newMountFsFile(file afero.File, fs *MountFs, path string) (*mountFsFile, error) {
-	f := &mountFsFile{
+	return &mountFsFile{
 		File: file,
 		fs:   fs,
 		path: NormalizePath(path),
-	}
-	entries, err := f.collectEntries() // Collect entries once at creation
-	if err != nil {
-		return nil, err
-	}
-	f.entries = entries
-	return f, nil
+	}, nil
 }
 
 // Readdir 读取并返回目录中的 os.FileInfo 列表。
 // 这个实现会合并来自底层文件系统的条目和在当前目录下的挂载点。
 // count 指定最多返回多少个条目。如果 count <= 0，则返回所有条目。
 func (f *mountFsFile) Readdir(count int) ([]os.FileInfo, error) {
-	// 如果已经读完所有条目
-	if f.offset >= len(f.entries) { // Use f.entries directly
-		if count <= 0 {
-			return []os.FileInfo{}, nil
-		}
-		return nil, io.EOF
-	}
-
-	start := f.offset
-	end := len(f.entries) // Use f.entries directly
-	if count > 0 && start+count < end {
-		end = start + count
+	entries, err := f.readEntries(count)
+	if err != nil {
+		return nil, err
 	}
-
-	remainingEntries := f.entries[start:end] // Use f.entries directly
-
-	infos := make([]os.FileInfo, len(remainingEntries))
-	for i, entry := range remainingEntries {
+	infos := make([]os.FileInfo, len(entries))
+	for i, entry := range entries {
 		info, err := entry.Info()
 		if err != nil {
 			return nil, err
 		}
 		infos[i] = info
 	}
-
-	f.offset = end
-
-	if count > 0 && len(infos) == 0 {
-		return nil, io.EOF
-	}
-
 	return infos, nil
 }
 
 // Readdirnames 读取并返回目录中的文件名列表。
 // 实现逻辑与 Readdir 类似，但只返回名称。
 func (f *mountFsFile) Readdirnames(count int) ([]string, error) {
-	// 如果已经读完所有条目
-	if f.offset >= len(f.entries) { // Use f.entries directly
-		if count <= 0 {
-			return []string{}, nil
-		}
-		return nil, io.EOF
-	}
-
-	start := f.offset
-	end := len(f.entries) // Use f.entries directly
-	if count > 0 && start+count < end {
-		end = start + count
+	entries, err := f.readEntries(count)
+	if err != nil {
+		return nil, err
 	}
-
-	remainingEntries := f.entries[start:end] // Use f.entries directly
-
-	names := make([]string, len(remainingEntries))
-	for i, entry := range remainingEntries {
+	names := make([]string, len(entries))
+	for i, entry := range entries {
 		names[i] = entry.Name()
 	}
-
-	f.offset = end
-
-	if count > 0 && len(names) == 0 {
-		return nil, io.EOF
-	}
-
 	return names, nil
 }
 
-func (f *mountFsFile) getEntries() ([]fs.DirEntry, error) {
-	// Entries are now populated once at creation.
-	// This method is no longer needed for lazy loading, but keeping it for consistency if other parts need it.
-	return f.entries, nil
-}
-
-// collectEntries 负责从底层文件系统收集目录条目，并将其与当前路径下的挂载点合并。
-// 返回的条目列表按名称排序。
-func (f *mountFsFile) collectEntries() ([]fs.DirEntry, error) {
-	// 1. 从底层文件系统读取所有条目
-	rawInfos, err := f.File.Readdir(-1)
-	if err != nil {
+// readEntries 按 Readdir 的语义返回条目：count > 0 时最多返回 count 个，读完时返回 io.EOF；
+// count <= 0 时返回剩余的全部条目并按名称排序。
+func (f *mountFsFile) readEntries(count int) ([]fs.DirEntry, error) {
+	if count > 0 {
+		if err := f.fill(f.offset + count); err != nil {
+			return nil, err
+		}
+		end := min(f.offset+count, len(f.entries))
+		result := f.entries[f.offset:end]
+		f.offset = end
+		if len(result) == 0 {
+			return nil, io.EOF
+		}
+		return result, nil
+	}
+	if err := f.fill(-1); err != nil {
 		return nil, err
 	}
+	result := slices.Clone(f.entries[f.offset:])
+	f.offset = len(f.entries)
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name() < result[j].Name()
+	})
+	return result, nil
+}
 
-	entryMap := make(map[string]fs.DirEntry)
-	for _, info := range rawInfos {
-		entryMap[info.Name()] = &dirEntry{info}
+// fill 分批读取底层条目直到缓存中至少有 want 个条目（want < 0 表示全部读完），
+// 底层目录读完后再追加挂载点与虚拟目录。
+func (f *mountFsFile) fill(want int) error {
+	if f.mounts == nil {
+		f.mounts = f.collectMounts()
 	}
+	for !f.rawEOF && (want < 0 || len(f.entries) < want) {
+		infos, err := f.File.Readdir(readdirBatch)
+		for _, info := range infos {
+			if entry, ok := f.mounts[info.Name()]; ok {
+				if _, isMount := entry.(*mountDirEntry); isMount {
+					// 直接挂载点优先级最高，总是覆盖底层同名条目
+					continue
+				}
+				// 底层真实存在的目录优先于虚拟目录
+				delete(f.mounts, info.Name())
+			}
+			f.entries = append(f.entries, &dirEntry{info})
+		}
+		if err == io.EOF || (err == nil && len(infos) == 0) {
+			f.rawEOF = true
+		} else if err != nil {
+			return err
+		}
+	}
+	if f.rawEOF && len(f.mounts) > 0 && (want < 0 || len(f.entries) < want) {
+		names := make([]string, 0, len(f.mounts))
+		for name := range f.mounts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			f.entries = append(f.entries, f.mounts[name])
+		}
+		clear(f.mounts)
+	}
+	return nil
+}
 
-	// 2. 收集当前目录下的所有相关挂载点（包括深层挂载点，用于构建虚拟目录）
-	mounts := f.fs.getMountsUnder(f.path)
-
-	// 3. 处理挂载点和虚拟目录
-	for _, mount := range mounts {
+// collectMounts 收集当前路径下的挂载点，深层挂载点以虚拟目录的形式出现。
+func (f *mountFsFile) collectMounts() map[string]fs.DirEntry {
+	result := make(map[string]fs.DirEntry)
+	for _, mount := range f.fs.getMountsUnder(f.path) {
 		// 获取挂载点相对于当前目录的名称
 		relPath := strings.TrimPrefix(mount.Prefix, f.path)
 		relPath = strings.TrimPrefix(relPath, "/")
@@ -141,46 +148,37 @@ func (f *mountFsFile) collectEntries() ([]fs.DirEntry, error) {
 		}
 		name := parts[0]
 
-		isDirectMount := len(parts) == 1
-
-		_, exists := entryMap[name]
-
-		if isDirectMount {
+		if len(parts) == 1 {
 			// 直接挂载点优先级最高，总是覆盖
-			entryMap[name] = &mountDirEntry{
+			result[name] = &mountDirEntry{
 				name:  name,
 				mode:  os.ModeDir | 0o755,
 				mount: &mount,
 			}
-		} else if !exists {
+		} else if _, exists := result[name]; !exists {
 			// 虚拟目录，仅当不存在时添加
-			entryMap[name] = &dirEntry{info: &virtualFileInfo{
+			result[name] = &dirEntry{info: &virtualFileInfo{
 				name: name,
 				mode: os.ModeDir | 0o755,
 			}}
 		}
 	}
+	return result
+}
 
-	// 4. 将 map 转换为切片并排序
-	var entries []fs.DirEntry
-	for _, entry := range entryMap {
-		entries = append(entries, entry)
-	}
-
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].Name() < entries[j].Name()
-	})
-
-	return entries, nil
+// Stat 返回与 MountFs.Stat 一致的信息，避免虚拟目录与挂载点暴露底层占位目录的属性。
+func (f *mountFsFile) Stat() (os.FileInfo, error) {
+	return f.fs.Stat(f.path)
 }
 
 // Seek 实现了 io.Seeker 接口。
 // 主要用于在调用 Readdir/Readdirnames 之前重置内部偏移量。
 func (f *mountFsFile) Seek(offset int64, whence int) (int64, error) {
-	// 如果是 seek 到文件开头，则重置 readdir 的偏移量
+	// 如果是 seek 到文件开头，则从已读取的条目重新开始；
+	// 不再传递给底层，否则底层目录的读取位置被重置后会产生重复条目
 	if whence == io.SeekStart && offset == 0 {
 		f.offset = 0
-		// 对于目录，底层的 Seek 可能不支持，但我们自己的偏移量需要重置
+		return 0, nil
 	}
 	// 将 seek 操作传递给底层的文件对象
 	return f.File.Seek(offset, whence)
@@ -1,6 +1,7 @@
 package mergefs
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"testing"
@@ -184,3 +185,34 @@ func TestMountDirEntry(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, entry, info)
 }
+
+func TestMountFsFile_LazyBatches(t *testing.T) {
+	defaultFs := afero.NewMemMapFs()
+	total := readdirBatch + 10
+	for i := range total {
+		_, _ = defaultFs.Create(fmt.Sprintf("/dir/file%04d", i))
+	}
+	mountFs := NewMountFs(defaultFs)
+	_ = mountFs.Mount("/dir/mounted", afero.NewMemMapFs())
+
+	file, err := mountFs.Open("/dir")
+	assert.NoError(t, err)
+	defer file.Close()
+	mountFile := file.(*mountFsFile)
+	assert.Nil(t, mountFile.entries, "打开目录时不应读取条目")
+
+	names, err := file.Readdirnames(10)
+	assert.NoError(t, err)
+	assert.Len(t, names, 10)
+	assert.Len(t, mountFile.entries, readdirBatch, "应只读取一批底层条目")
+
+	rest, err := file.Readdirnames(-1)
+	assert.NoError(t, err)
+	assert.Len(t, rest, total-10+1, "剩余条目应包含挂载点")
+	assert.Contains(t, rest, "mounted")
+
+	_, _ = file.Seek(0, io.SeekStart)
+	all, err := file.Readdirnames(-1)
+	assert.NoError(t, err)
+	assert.Len(t, all, total+1, "Seek 后应重放全部条目")
+}
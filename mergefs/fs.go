@@ -2,6 +2,7 @@ package mergefs
 
 import (
 	"cmp"
+	"context"
 	"fmt"
 	"io"
 	"io/fs"
@@ -11,9 +12,11 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/spf13/afero"
+	"golang.org/x/sync/errgroup"
 )
 
 // Mount 定义挂载点
@@ -25,8 +28,12 @@ type Mount struct {
 // MountFs 实现支持多个挂载点的文件系统
 type MountFs struct {
 	mounts    []Mount
+	tree      *mountTrie
 	defaultFs afero.Fs
 	mu        sync.RWMutex
+	negative  *negativeCache
+	// 拒绝跨文件系统重命名的挂载点
+	exdev map[string]bool
 }
 
 // NewMountFs 创建新的 MountFs
@@ -36,22 +43,71 @@ func NewMountFs(defaultFs afero.Fs) *MountFs {
 	}
 	return &MountFs{
 		mounts:    make([]Mount, 0),
+		tree:      newMountTrie(),
 		defaultFs: defaultFs,
 	}
 }
 
+// SetNegativeCache 开启不存在路径的缓存，ttl <= 0 时关闭
+func (m *MountFs) SetNegativeCache(ttl time.Duration) {
+	if ttl <= 0 {
+		m.negative = nil
+		return
+	}
+	m.negative = newNegativeCache(ttl)
+}
+
+// Invalidate 通知 MountFs 路径已在外部发生变化，清除相关缓存
+func (m *MountFs) Invalidate(name string) {
+	m.negative.invalidate(name)
+}
+
+// SetExdev 设置挂载点是否拒绝跨文件系统重命名，开启后移入或移出该挂载点时返回 EXDEV，
+// 由客户端自行复制，避免一次重命名隐式复制大量数据
+func (m *MountFs) SetExdev(prefix string, exdev bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prefix = "/" + strings.Trim(prefix, "/")
+	if !exdev {
+		delete(m.exdev, prefix)
+		return
+	}
+	if m.exdev == nil {
+		m.exdev = make(map[string]bool)
+	}
+	m.exdev[prefix] = true
+}
+
+// CheckRename 检查重命名是否跨越拒绝复制的挂载点，是则返回 EXDEV
+func (m *MountFs) CheckRename(oldname, newname string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	oldPrefix, newPrefix := m.mountPrefix(oldname), m.mountPrefix(newname)
+	if oldPrefix != newPrefix && (m.exdev[oldPrefix] || m.exdev[newPrefix]) {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.EXDEV}
+	}
+	return nil
+}
+
+// mountPrefix 返回路径所在挂载点的前缀，默认文件系统返回空字符串，调用者需持有 mu
+func (m *MountFs) mountPrefix(name string) string {
+	if mount := m.tree.longest(NormalizePath(name)); mount != nil {
+		return mount.Prefix
+	}
+	return ""
+}
+
 // Mount 添加挂载点
 func (m *MountFs) Mount(prefix string, fs afero.Fs) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	prefix = "/" + strings.Trim(prefix, "/")
+	m.negative.invalidate(prefix)
 	if prefix == "/" {
 		return fmt.Errorf("prefix must not be /")
 	}
-	for _, mount := range m.mounts {
-		if mount.Prefix == prefix {
-			return fmt.Errorf("mount point %q already exists", prefix)
-		}
+	if !m.tree.insert(Mount{Prefix: prefix, Fs: fs}) {
+		return fmt.Errorf("mount point %q already exists", prefix)
 	}
 	m.mounts = append(m.mounts, Mount{Prefix: prefix, Fs: fs})
 	slices.SortFunc(m.mounts, func(a, b Mount) int {
@@ -64,13 +120,14 @@ func (m *MountFs) Unmount(prefix string) bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	prefix = "/" + strings.Trim(prefix, "/")
-	for i, mount := range m.mounts {
-		if mount.Prefix == prefix {
-			m.mounts = append(m.mounts[:i], m.mounts[i+1:]...)
-			return true
-		}
+	if !m.tree.remove(prefix) {
+		return false
 	}
-	return false
+	m.mounts = slices.DeleteFunc(m.mounts, func(mount Mount) bool {
+		return mount.Prefix == prefix
+	})
+	delete(m.exdev, prefix)
+	return true
 }
 
 // GetMount 获取指定路径对应的挂载点和相对路径
@@ -79,19 +136,17 @@ func (m *MountFs) GetMount(path string) (afero.Fs, string) {
 	defer m.mu.RUnlock()
 	path = NormalizePath(path)
 	if path == "/" {
-		// fmt.Println("DEBUG: GetMount returning defaultFs for /")
 		return m.defaultFs, path
 	}
-	for _, mount := range m.mounts {
-		if path == mount.Prefix || strings.HasPrefix(path, mount.Prefix+"/") {
-			return mount.Fs, strings.TrimPrefix(path, mount.Prefix)
-		}
+	if mount := m.tree.longest(path); mount != nil {
+		return mount.Fs, strings.TrimPrefix(path, mount.Prefix)
 	}
 	return m.defaultFs, path
 }
 
-// NormalizePath 清理路径
+// NormalizePath 清理路径，虚拟路径中不允许出现盘符 (如 Windows 下的 C:)
 func NormalizePath(p string) string {
+	p = strings.TrimPrefix(p, filepath.VolumeName(p))
 	p = path.Clean(filepath.ToSlash(p))
 	if p == "." {
 		p = "/"
@@ -100,6 +155,7 @@ func NormalizePath(p string) string {
 }
 
 func (m *MountFs) Create(name string) (afero.File, error) {
+	m.negative.invalidate(name)
 	mount, p := m.GetMount(name)
 	return mount.Create(p)
 }
@@ -112,6 +168,7 @@ func (m *MountFs) Mkdir(name string, perm os.FileMode) error {
 			Err:  os.ErrExist,
 		}
 	}
+	m.negative.invalidate(name)
 	mount, p := m.GetMount(name)
 	return mount.Mkdir(p, perm)
 }
@@ -124,6 +181,7 @@ func (m *MountFs) MkdirAll(path string, perm os.FileMode) error {
 			Err:  os.ErrExist,
 		}
 	}
+	m.negative.invalidate(path)
 	mount, relPath := m.GetMount(path)
 	return mount.MkdirAll(relPath, perm)
 }
@@ -170,6 +228,12 @@ func (m *MountFs) RemoveAll(path string) error {
 }
 
 func (m *MountFs) Rename(oldname, newname string) error {
+	return m.RenameContext(context.Background(), oldname, newname)
+}
+
+// RenameContext 与 Rename 相同，跨文件系统复制时并发进行且响应 ctx 取消，
+// 失败或取消时会清理已复制的目标文件
+func (m *MountFs) RenameContext(ctx context.Context, oldname, newname string) error {
 	if m.hasChildMount(oldname) {
 		return &os.PathError{
 			Op:   "rename",
@@ -178,104 +242,176 @@ func (m *MountFs) Rename(oldname, newname string) error {
 		}
 	}
 
+	m.negative.invalidate(newname)
 	oldFs, oldPath := m.GetMount(oldname)
 	newFs, newPath := m.GetMount(newname)
 
 	// 如果跨文件系统，需要特殊处理
 	if oldFs != newFs {
-		return m.crossRename(oldFs, oldPath, newFs, newPath)
+		if err := m.CheckRename(oldname, newname); err != nil {
+			return err
+		}
+		return m.crossRename(ctx, oldFs, oldPath, newFs, newPath)
 	}
 
 	return oldFs.Rename(oldPath, newPath)
 }
 
-func (m *MountFs) crossRename(srcFs afero.Fs, src string, dstFs afero.Fs, dst string) error {
-	srcFile, err := srcFs.Open(src)
-	if err != nil {
-		return err
-	}
-	defer srcFile.Close()
+// crossCopyWorkers 跨文件系统复制目录时的并发文件数
+const crossCopyWorkers = 4
 
-	srcInfo, err := srcFile.Stat()
+func (m *MountFs) crossRename(ctx context.Context, srcFs afero.Fs, src string, dstFs afero.Fs, dst string) error {
+	srcInfo, err := srcFs.Stat(src)
 	if err != nil {
 		return err
 	}
 	if srcInfo.IsDir() {
-		return m.crossRenameDir(srcFs, src, dstFs, dst)
+		return m.crossRenameDir(ctx, srcFs, src, dstFs, dst)
 	}
 
 	// copy file
-	err = copyFile(srcFs, src, dstFs, dst)
+	err = copyFile(ctx, srcFs, src, dstFs, dst)
 	if err != nil {
 		return err
 	}
 	return srcFs.Remove(src)
 }
 
-func (m *MountFs) crossRenameDir(srcFs afero.Fs, src string, dstFs afero.Fs, dst string) error {
-	// 创建目标目录
-	err := dstFs.MkdirAll(dst, 0o755)
-	if err != nil {
-		return err
+// crossRenameDir 先将目录复制到目标位置同级的暂存目录，全部完成后再重命名到目标位置，
+// 失败或取消时删除暂存目录，目标位置不会出现只复制了一部分的目录
+func (m *MountFs) crossRenameDir(ctx context.Context, srcFs afero.Fs, src string, dstFs afero.Fs, dst string) error {
+	if _, err := dstFs.Stat(dst); err == nil {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: os.ErrExist}
+	}
+	stage := atomicTempName(dst)
+	err := stageDir(ctx, srcFs, src, dstFs, stage)
+	if err == nil {
+		err = dstFs.Rename(stage, dst)
 	}
-	dir, err := srcFs.Open(src)
 	if err != nil {
+		_ = dstFs.RemoveAll(stage)
 		return err
 	}
+	return srcFs.RemoveAll(src)
+}
 
-	infos, err := dir.Readdir(-1)
-	_ = dir.Close()
+// stageDir 将 src 目录完整复制到 stage
+func stageDir(ctx context.Context, srcFs afero.Fs, src string, dstFs afero.Fs, stage string) error {
+	// 先按顺序创建目录结构并收集文件
+	var files, dirs []string
+	infos := make(map[string]os.FileInfo)
+	err := afero.Walk(srcFs, src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel := filepath.ToSlash(strings.TrimPrefix(p, src))
+		if !info.IsDir() {
+			files = append(files, rel)
+			return nil
+		}
+		if err := dstFs.Mkdir(path.Join(stage, rel), 0o755); err != nil {
+			return err
+		}
+		dirs = append(dirs, rel)
+		infos[rel] = info
+		return nil
+	})
 	if err != nil {
 		return err
 	}
-	for _, info := range infos {
-		srcPath := path.Join(src, info.Name())
-		dstPath := path.Join(dst, info.Name())
-
-		if info.IsDir() {
-			err = m.crossRenameDir(srcFs, srcPath, dstFs, dstPath)
-		} else {
-			err = copyFile(srcFs, srcPath, dstFs, dstPath)
-		}
-
-		if err != nil {
+	group, gctx := errgroup.WithContext(ctx)
+	group.SetLimit(crossCopyWorkers)
+	for _, rel := range files {
+		group.Go(func() error {
+			return copyFile(gctx, srcFs, path.Join(src, rel), dstFs, path.Join(stage, rel))
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return err
+	}
+	// 目录的修改时间会因写入子项而改变，需在复制完成后自底向上恢复
+	for i := len(dirs) - 1; i >= 0; i-- {
+		target := path.Join(stage, dirs[i])
+		copyProps(srcFs, path.Join(src, dirs[i]), dstFs, target)
+		if err := copyAttrs(dstFs, target, infos[dirs[i]]); err != nil {
 			return err
 		}
 	}
-	return srcFs.RemoveAll(src)
+	return nil
+}
+
+// ContextReader 在每次读取前检查 ctx 是否已取消，用于中断读取整个文件的长时间操作
+type ContextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func NewContextReader(ctx context.Context, r io.Reader) *ContextReader {
+	return &ContextReader{ctx: ctx, r: r}
 }
 
-func copyFile(srcFs afero.Fs, src string, dstFs afero.Fs, dst string) error {
+func (c *ContextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+func copyFile(ctx context.Context, srcFs afero.Fs, src string, dstFs afero.Fs, dst string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	srcFile, err := srcFs.Open(src)
 	if err != nil {
 		return err
 	}
 	defer srcFile.Close()
-	dstFile, err := dstFs.Create(dst)
+	srcInfo, err := srcFile.Stat()
 	if err != nil {
 		return err
 	}
-	defer dstFile.Close()
-	_, err = io.Copy(dstFile, srcFile)
+	dstFile, err := dstFs.Create(dst)
 	if err != nil {
-		_ = dstFs.Remove(dst)
 		return err
 	}
-	srcInfo, err := srcFs.Stat(src)
+	_, err = io.Copy(dstFile, NewContextReader(ctx, srcFile))
+	if closeErr := dstFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = copyAttrs(dstFs, dst, srcInfo)
+	}
 	if err != nil {
 		_ = dstFs.Remove(dst)
 		return err
 	}
-	err = dstFs.Chmod(dst, srcInfo.Mode())
-	if err != nil {
-		_ = dstFs.Remove(dst)
+	copyProps(srcFs, src, dstFs, dst)
+	return nil
+}
+
+// copyAttrs 将源文件的权限、修改时间与属主（如支持）同步到目标
+func copyAttrs(dstFs afero.Fs, dst string, info os.FileInfo) error {
+	if err := dstFs.Chmod(dst, info.Mode()); err != nil {
 		return err
 	}
+	if err := dstFs.Chtimes(dst, info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	if uid, gid, ok := FileOwner(info); ok {
+		// 非特权进程通常无法修改属主，忽略错误
+		_ = dstFs.Chown(dst, uid, gid)
+	}
 	return nil
 }
 
 func (m *MountFs) Stat(name string) (os.FileInfo, error) {
 	name = NormalizePath(name)
+	if m.negative.has(name) {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
 
 	// 1. Check for direct mount points
 	if mount, ok := m.directDir(name); ok {
@@ -301,21 +437,16 @@ func (m *MountFs) Stat(name string) (os.FileInfo, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	for _, mount := range m.mounts {
-		if strings.HasPrefix(mount.Prefix, name) && mount.Prefix != name {
-			// name is a prefix of a mount point, but not the mount point itself
-
-			// Ensure it is a directory prefix
-			if name == "/" || strings.HasPrefix(mount.Prefix, name+"/") {
-				return &virtualFileInfo{
-					name: filepath.Base(name),
-					mode: os.ModeDir | 0o755, // Virtual directories are always directories
-				}, nil
-			}
-		}
+	// name is a directory prefix of a mount point, but not the mount point itself
+	if node := m.tree.find(name); node != nil && node.count > 0 {
+		return &virtualFileInfo{
+			name: filepath.Base(name),
+			mode: os.ModeDir | 0o755, // Virtual directories are always directories
+		}, nil
 	}
 
 	// If not virtual, return the original error from underlying filesystem
+	m.negative.add(name)
 	return nil, err
 }
 
@@ -338,21 +469,41 @@ func (m *MountFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
 	return mount.Chtimes(p, atime, mtime)
 }
 
-// LstatIfPossible 实现 afero.Lstater 接口（如果底层文件系统支持）
+// LstatIfPossible 实现 afero.Lstater 接口（如果底层文件系统支持），
+// 挂载点与虚拟目录返回与 Stat 相同的信息
 func (m *MountFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	name = NormalizePath(name)
+	if _, ok := m.directDir(name); ok {
+		info, err := m.Stat(name)
+		return info, false, err
+	}
 	mount, p := m.GetMount(name)
-	if lstater, ok := mount.(afero.Lstater); ok {
-		return lstater.LstatIfPossible(p)
+	lstater, ok := mount.(afero.Lstater)
+	if !ok {
+		info, err := m.Stat(name)
+		return info, false, err
 	}
-
-	info, err := mount.Stat(p)
-	return info, false, err
+	info, linked, err := lstater.LstatIfPossible(p)
+	if os.IsNotExist(err) && m.hasChildMount(name) {
+		info, err = m.Stat(name)
+	}
+	return info, linked, err
 }
 
 // OpenFile 修改 OpenFile 方法，返回包装后的文件对象
 func (m *MountFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	return m.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+// OpenFileContext 与 OpenFile 相同，ctx 会传递给支持 ContextOpener 的挂载点
+func (m *MountFs) OpenFileContext(ctx context.Context, name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&os.O_CREATE != 0 {
+		m.negative.invalidate(name)
+	} else if m.negative.has(NormalizePath(name)) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
 	mount, p := m.GetMount(name)
-	file, err := mount.OpenFile(p, flag, perm)
+	file, err := openFileContext(ctx, mount, p, flag, perm)
 	if err != nil {
 		return nil, err
 	}
@@ -418,6 +569,7 @@ func (m *MountFs) SymlinkIfPossible(oldname, newname string) error {
 	}
 
 	if linker, ok := oldFs.(afero.Linker); ok {
+		m.negative.invalidate(newname)
 		return linker.SymlinkIfPossible(oldPath, newPath)
 	}
 
@@ -455,14 +607,12 @@ func (m *MountFs) GetMountInfo(name string) (string, afero.Fs, string) {
 	defer m.mu.RUnlock()
 
 	name = NormalizePath(name)
-	for _, mount := range m.mounts {
-		if name == mount.Prefix || strings.HasPrefix(name, mount.Prefix+"/") {
-			relPath := strings.TrimPrefix(name, mount.Prefix)
-			if relPath == "" {
-				relPath = "/"
-			}
-			return mount.Prefix, mount.Fs, relPath
+	if mount := m.tree.longest(name); mount != nil {
+		relPath := strings.TrimPrefix(name, mount.Prefix)
+		if relPath == "" {
+			relPath = "/"
 		}
+		return mount.Prefix, mount.Fs, relPath
 	}
 	return "/", m.defaultFs, name
 }
@@ -471,49 +621,23 @@ func (m *MountFs) GetMountInfo(name string) (string, afero.Fs, string) {
 func (m *MountFs) directDir(dir string) (Mount, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	dir = NormalizePath(dir)
-	for _, mount := range m.mounts {
-		if mount.Prefix == dir {
-			return mount, true
-		}
+	node := m.tree.find(NormalizePath(dir))
+	if node == nil || node.mount == nil {
+		return Mount{}, false
 	}
-	return Mount{}, false
+	return *node.mount, true
 }
 
 func (m *MountFs) hasChildMount(dir string) bool {
-	dir = NormalizePath(dir) + "/"
-	for _, mount := range m.mounts {
-		if strings.HasPrefix(mount.Prefix, dir) {
-			return true
-		}
-	}
-	return false
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	node := m.tree.find(NormalizePath(dir))
+	return node != nil && node.count > 0
 }
 
 func (m *MountFs) getMountsUnder(dir string) []Mount {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-
-	dir = NormalizePath(dir)
-	var result []Mount
-
-	for _, mount := range m.mounts {
-		// 挂载点自身不能作为其子挂载点
-		if mount.Prefix == dir {
-			continue
-		}
-
-		// 检查挂载点是否以当前目录为前缀
-		// 必须确保是真正的子目录 (e.g. /a vs /ab)
-		if dir == "/" {
-			if strings.HasPrefix(mount.Prefix, "/") {
-				result = append(result, mount)
-			}
-		} else {
-			if strings.HasPrefix(mount.Prefix, dir+"/") {
-				result = append(result, mount)
-			}
-		}
-	}
-	return result
+	// 挂载点自身不能作为其子挂载点
+	return m.tree.under(NormalizePath(dir))
 }
@@ -1,10 +1,15 @@
 package mergefs
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io/fs"
 	"os"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
@@ -197,6 +202,111 @@ func TestMountFs_Rename(t *testing.T) {
 	assert.NoError(t, err, "目录内容应被移动")
 }
 
+func TestMountFs_RenameContext(t *testing.T) {
+	defaultFs := afero.NewMemMapFs()
+	for i := range 16 {
+		_ = afero.WriteFile(defaultFs, fmt.Sprintf("/src/sub%d/file.txt", i%3), []byte("data"), 0o644)
+		_ = afero.WriteFile(defaultFs, fmt.Sprintf("/src/file%d.txt", i), []byte("data"), 0o644)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	_ = defaultFs.Chtimes("/src/file0.txt", mtime, mtime)
+	_ = defaultFs.Chtimes("/src/sub0", mtime, mtime)
+	mountFs := NewMountFs(defaultFs)
+	_ = mountFs.Mount("/mounted", afero.NewMemMapFs())
+
+	// 已取消的 ctx 不应留下任何目标文件
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := mountFs.RenameContext(ctx, "/src", "/mounted/dest")
+	assert.ErrorIs(t, err, context.Canceled, "取消后应返回 context.Canceled")
+	_, err = mountFs.Stat("/mounted/dest")
+	assert.True(t, os.IsNotExist(err), "取消后不应留下目标目录")
+	_, err = mountFs.Stat("/src/file0.txt")
+	assert.NoError(t, err, "取消后源文件应保留")
+
+	// 并发复制所有文件
+	err = mountFs.RenameContext(context.Background(), "/src", "/mounted/dest")
+	assert.NoError(t, err, "跨文件系统重命名目录不应出错")
+	for i := range 16 {
+		data, err := afero.ReadFile(mountFs, fmt.Sprintf("/mounted/dest/file%d.txt", i))
+		assert.NoError(t, err, "目录内容应被移动")
+		assert.Equal(t, "data", string(data), "文件内容应一致")
+	}
+	stat, err := mountFs.Stat("/mounted/dest/file0.txt")
+	assert.NoError(t, err)
+	assert.True(t, stat.ModTime().Equal(mtime), "应保留文件修改时间")
+	stat, err = mountFs.Stat("/mounted/dest/sub0")
+	assert.NoError(t, err)
+	assert.True(t, stat.ModTime().Equal(mtime), "应保留目录修改时间")
+	_, err = mountFs.Stat("/src")
+	assert.Error(t, err, "源目录应被删除")
+}
+
+// failOpenFs 打开指定文件时返回错误
+type failOpenFs struct {
+	afero.Fs
+	name string
+}
+
+func (f *failOpenFs) Open(name string) (afero.File, error) {
+	if name == f.name {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+	return f.Fs.Open(name)
+}
+
+func TestMountFs_RenameStaged(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	for i := range 8 {
+		_ = afero.WriteFile(srcFs, fmt.Sprintf("/src/sub/file%d.txt", i), []byte("data"), 0o644)
+	}
+	dstFs := afero.NewMemMapFs()
+	_ = dstFs.Mkdir("/parent", 0o755)
+	mountFs := NewMountFs(&failOpenFs{Fs: srcFs, name: "/src/sub/file5.txt"})
+	_ = mountFs.Mount("/mounted", NewAtomicFs(dstFs))
+
+	// 中途失败时不应留下目标目录或暂存目录
+	err := mountFs.Rename("/src", "/mounted/parent/dest")
+	assert.ErrorIs(t, err, os.ErrPermission)
+	names, err := afero.ReadDir(dstFs, "/parent")
+	assert.NoError(t, err)
+	assert.Empty(t, names, "失败后应清理暂存目录")
+	_, err = srcFs.Stat("/src/sub/file5.txt")
+	assert.NoError(t, err, "失败后源文件应保留")
+
+	mountFs = NewMountFs(srcFs)
+	_ = mountFs.Mount("/mounted", NewAtomicFs(dstFs))
+	assert.NoError(t, mountFs.Rename("/src", "/mounted/parent/dest"))
+	names, err = afero.ReadDir(dstFs, "/parent")
+	assert.NoError(t, err)
+	assert.Len(t, names, 1, "暂存目录应被重命名到目标位置")
+	data, err := afero.ReadFile(dstFs, "/parent/dest/sub/file5.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+
+	// 目标已存在时不合并目录
+	_ = afero.WriteFile(srcFs, "/src2/file.txt", []byte("data"), 0o644)
+	assert.ErrorIs(t, mountFs.Rename("/src2", "/mounted/parent/dest"), os.ErrExist)
+}
+
+func TestMountFs_Exdev(t *testing.T) {
+	mountFs := NewMountFs(afero.NewMemMapFs())
+	a, b := afero.NewMemMapFs(), afero.NewMemMapFs()
+	_ = mountFs.Mount("/a", a)
+	_ = mountFs.Mount("/b", b)
+	_ = afero.WriteFile(a, "/file.txt", []byte("data"), 0o644)
+	mountFs.SetExdev("/b", true)
+
+	err := mountFs.Rename("/a/file.txt", "/b/file.txt")
+	assert.ErrorIs(t, err, syscall.EXDEV, "移入拒绝复制的挂载点应返回 EXDEV")
+	_, err = a.Stat("/file.txt")
+	assert.NoError(t, err, "源文件应保留")
+	assert.NoError(t, mountFs.Rename("/a/file.txt", "/a/moved.txt"), "挂载点内重命名不受影响")
+
+	mountFs.SetExdev("/b", false)
+	assert.NoError(t, mountFs.Rename("/a/moved.txt", "/b/file.txt"), "关闭后恢复跨文件系统复制")
+}
+
 func TestMountFs_OpenFile(t *testing.T) {
 	// Setup
 	defaultFs := afero.NewMemMapFs()
@@ -335,3 +445,106 @@ func TestEmptyDir(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Empty(t, dir)
 }
+
+func TestMountFs_NegativeCache(t *testing.T) {
+	defaultFs := afero.NewMemMapFs()
+	mountedFs := afero.NewMemMapFs()
+	mountFs := NewMountFs(defaultFs)
+	mountFs.SetNegativeCache(time.Hour)
+	_ = mountFs.Mount("/data", mountedFs)
+
+	_, err := mountFs.Stat("/data/desktop.ini")
+	assert.True(t, os.IsNotExist(err))
+
+	// 绕过 MountFs 创建文件，缓存期内仍返回不存在
+	_ = afero.WriteFile(mountedFs, "/desktop.ini", []byte("x"), 0o644)
+	_, err = mountFs.Stat("/data/desktop.ini")
+	assert.True(t, os.IsNotExist(err), "应命中负缓存")
+	_, err = mountFs.Open("/data/desktop.ini")
+	assert.True(t, os.IsNotExist(err), "打开文件也应命中负缓存")
+
+	// 通过 MountFs 创建文件会使缓存失效
+	_, _ = mountFs.Stat("/data/Thumbs.db")
+	f, err := mountFs.Create("/data/Thumbs.db")
+	assert.NoError(t, err)
+	_ = f.Close()
+	_, err = mountFs.Stat("/data/Thumbs.db")
+	assert.NoError(t, err, "创建后缓存应失效")
+
+	// 重命名会使目标路径及其子路径失效
+	_, _ = mountFs.Stat("/data/dir/a.txt")
+	_ = mountFs.Mkdir("/data/src", 0o755)
+	_ = afero.WriteFile(mountFs, "/data/src/a.txt", []byte("a"), 0o644)
+	assert.NoError(t, mountFs.Rename("/data/src", "/data/dir"))
+	_, err = mountFs.Stat("/data/dir/a.txt")
+	assert.NoError(t, err, "重命名后子路径缓存应失效")
+
+	// 创建多级目录会使上级目录的缓存失效
+	_, _ = mountFs.Stat("/data/x")
+	_, _ = mountFs.Stat("/data/x/y")
+	assert.NoError(t, mountFs.MkdirAll("/data/x/y/z", 0o755))
+	_, err = mountFs.Stat("/data/x")
+	assert.NoError(t, err, "上级目录的缓存应失效")
+	_, err = mountFs.Stat("/data/x/y")
+	assert.NoError(t, err, "上级目录的缓存应失效")
+}
+
+func TestMountFs_WriteAtTruncate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("需要支持稀疏文件的临时目录")
+	}
+	pool := NewMinFreeFs(NewOsPathFs(t.TempDir(), SymlinkWithin), "/", 0)
+	mountFs := NewMountFs(afero.NewMemMapFs())
+	assert.NoError(t, mountFs.Mount("/pool", NewAtomicFs(pool)))
+
+	// 超过 4GiB 的偏移量，乱序并发写入
+	const base = int64(5) << 30
+	const chunk = 64 << 10
+	file, err := mountFs.OpenFile("/pool/big.bin", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	assert.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := 7; i >= 0; i-- {
+		wg.Go(func() {
+			data := bytes.Repeat([]byte{byte('a' + i)}, chunk)
+			n, err := file.WriteAt(data, base+int64(i)*chunk)
+			assert.NoError(t, err)
+			assert.Equal(t, chunk, n)
+		})
+	}
+	wg.Wait()
+	_, err = mountFs.Stat("/pool/big.bin")
+	assert.True(t, os.IsNotExist(err), "写入完成前不可见")
+	assert.NoError(t, file.Close())
+
+	info, err := mountFs.Stat("/pool/big.bin")
+	assert.NoError(t, err)
+	assert.Equal(t, base+8*chunk, info.Size())
+	file, err = mountFs.Open("/pool/big.bin")
+	assert.NoError(t, err)
+	buf := make([]byte, 2)
+	_, err = file.ReadAt(buf, base+3*chunk-1)
+	assert.NoError(t, err)
+	assert.Equal(t, "cd", string(buf))
+	_, err = file.ReadAt(buf, base-1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 'a'}, buf, "未写入的部分读取为 0")
+	assert.NoError(t, file.Close())
+
+	// sftp 的 setstat 以不截断的方式打开后 Truncate
+	file, err = mountFs.OpenFile("/pool/big.bin", os.O_WRONLY, 0o644)
+	assert.NoError(t, err)
+	assert.NoError(t, file.Truncate(base+chunk))
+	n, err := file.WriteAt([]byte("z"), base+chunk-1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, file.Close())
+	info, err = mountFs.Stat("/pool/big.bin")
+	assert.NoError(t, err)
+	assert.Equal(t, base+chunk, info.Size())
+	file, err = mountFs.Open("/pool/big.bin")
+	assert.NoError(t, err)
+	_, err = file.ReadAt(buf, base+chunk-2)
+	assert.NoError(t, err)
+	assert.Equal(t, "az", string(buf))
+	assert.NoError(t, file.Close())
+}
@@ -0,0 +1,79 @@
+package mergefs
+
+import "sync"
+
+// HashCache 按文件的大小与修改时间缓存由文件内容计算的结果（哈希、校验和等），
+// 大小或修改时间变化后视为未缓存
+type HashCache[T any] struct {
+	// maxEntries 缓存的最大数量，超过时丢弃一半，为 0 时不限制
+	maxEntries int
+	// keep 返回 true 的条目不会被丢弃，如仍在计算中的结果
+	keep func(T) bool
+
+	mu      sync.Mutex
+	entries map[string]hashCacheEntry[T]
+}
+
+type hashCacheEntry[T any] struct {
+	size    int64
+	modTime int64
+	value   T
+}
+
+// NewHashCache 创建 HashCache，keep 可以为 nil
+func NewHashCache[T any](maxEntries int, keep func(T) bool) *HashCache[T] {
+	return &HashCache[T]{maxEntries: maxEntries, keep: keep, entries: make(map[string]hashCacheEntry[T])}
+}
+
+// Get 返回缓存的值，modTime 为 UnixNano
+func (c *HashCache[T]) Get(key string, size, modTime int64) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || entry.size != size || entry.modTime != modTime {
+		var zero T
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// Put 保存文件当前大小与修改时间对应的值
+func (c *HashCache[T]) Put(key string, size, modTime int64, value T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && c.maxEntries > 0 && len(c.entries) >= c.maxEntries {
+		c.evictLocked()
+	}
+	c.entries[key] = hashCacheEntry[T]{size: size, modTime: modTime, value: value}
+}
+
+// evictLocked 丢弃任意一半可以丢弃的条目
+func (c *HashCache[T]) evictLocked() {
+	evict := len(c.entries) / 2
+	for key, entry := range c.entries {
+		if evict == 0 {
+			break
+		}
+		if c.keep != nil && c.keep(entry.value) {
+			continue
+		}
+		delete(c.entries, key)
+		evict--
+	}
+}
+
+// Range 遍历全部条目，遍历期间持有锁，fn 中不能再调用 HashCache 的方法
+func (c *HashCache[T]) Range(fn func(key string, size, modTime int64, value T)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, entry := range c.entries {
+		fn(key, entry.size, entry.modTime, entry.value)
+	}
+}
+
+// Len 返回缓存的条目数
+func (c *HashCache[T]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
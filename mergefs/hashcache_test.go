@@ -0,0 +1,28 @@
+package mergefs
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashCache(t *testing.T) {
+	cache := NewHashCache(4, func(value string) bool { return value == "pending" })
+	cache.Put("/a", 1, 100, "sum")
+	value, ok := cache.Get("/a", 1, 100)
+	assert.True(t, ok)
+	assert.Equal(t, "sum", value)
+	_, ok = cache.Get("/a", 2, 100)
+	assert.False(t, ok, "大小变化后失效")
+	_, ok = cache.Get("/a", 1, 200)
+	assert.False(t, ok, "修改时间变化后失效")
+
+	cache.Put("/b", 1, 100, "pending")
+	for i := range 4 {
+		cache.Put("/c"+strconv.Itoa(i), 1, 100, "sum")
+	}
+	assert.LessOrEqual(t, cache.Len(), 4)
+	_, ok = cache.Get("/b", 1, 100)
+	assert.True(t, ok, "keep 返回 true 的条目不会被丢弃")
+}
@@ -0,0 +1,353 @@
+package mergefs
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// HiddenOptions 隐藏文件配置
+type HiddenOptions struct {
+	// 隐藏以 . 开头的文件
+	Dotfiles bool
+	// 额外隐藏的文件名，如 .git、.DS_Store
+	Names []string
+	// 排除的 glob 规则，匹配的条目始终不可见也不可读写；
+	// 以 / 结尾的规则仅匹配目录，包含 / 的规则匹配相对根目录的完整路径，否则匹配文件名
+	Exclude []string
+	// 允许通过完整路径直接读取被隐藏（不含被排除）的文件
+	AllowExplicit bool
+}
+
+// HiddenFs 在目录列表中隐藏点文件、指定名称及排除规则匹配的文件，
+// 这些路径不可写入，被隐藏的文件仅在 AllowExplicit 时可通过完整路径读取
+type HiddenFs struct {
+	afero.Fs
+	dotfiles      bool
+	names         map[string]struct{}
+	exclude       []excludeRule
+	allowExplicit bool
+}
+
+type excludeRule struct {
+	pattern  string
+	dirOnly  bool
+	fullPath bool
+}
+
+// ValidateExclude 检查排除规则是否合法
+func ValidateExclude(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(strings.Trim(pattern, "/"), ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewHiddenFs 创建 HiddenFs
+func NewHiddenFs(fs afero.Fs, opts HiddenOptions) *HiddenFs {
+	names := make(map[string]struct{}, len(opts.Names))
+	for _, name := range opts.Names {
+		names[name] = struct{}{}
+	}
+	exclude := make([]excludeRule, 0, len(opts.Exclude))
+	for _, pattern := range opts.Exclude {
+		rule := excludeRule{dirOnly: strings.HasSuffix(pattern, "/")}
+		rule.pattern = strings.Trim(pattern, "/")
+		rule.fullPath = strings.Contains(rule.pattern, "/")
+		if rule.pattern != "" {
+			exclude = append(exclude, rule)
+		}
+	}
+	return &HiddenFs{
+		Fs:            fs,
+		dotfiles:      opts.Dotfiles,
+		names:         names,
+		exclude:       exclude,
+		allowExplicit: opts.AllowExplicit,
+	}
+}
+
+// hiddenName 判断单个文件名是否需要隐藏
+func (h *HiddenFs) hiddenName(name string) bool {
+	// 临时文件与跨文件系统移动的暂存目录由下层 AtomicFs 在列表中过滤
+	if strings.HasPrefix(name, atomicTempPrefix) {
+		return false
+	}
+	if h.dotfiles && strings.HasPrefix(name, ".") {
+		return true
+	}
+	_, ok := h.names[name]
+	return ok
+}
+
+// excludedEntry 判断条目是否被排除规则匹配，p 为相对根目录的完整路径
+func (h *HiddenFs) excludedEntry(p string, isDir bool) bool {
+	p = strings.TrimPrefix(p, "/")
+	for _, rule := range h.exclude {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		target := p
+		if !rule.fullPath {
+			target = path.Base(p)
+		}
+		if ok, _ := path.Match(rule.pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// excludedPath 与 excludedEntry 相同，仅在有目录规则匹配时才查询条目是否为目录
+func (h *HiddenFs) excludedPath(p string) bool {
+	if h.excludedEntry(p, false) {
+		return true
+	}
+	if !h.excludedEntry(p, true) {
+		return false
+	}
+	info, err := h.Fs.Stat(p)
+	return err == nil && info.IsDir()
+}
+
+// excluded 判断路径或其任意上级目录是否被排除
+func (h *HiddenFs) excluded(name string) bool {
+	name = NormalizePath(name)
+	if len(h.exclude) == 0 || name == "/" {
+		return false
+	}
+	parts := strings.Split(name, "/")
+	for i := 1; i < len(parts); i++ {
+		p := strings.Join(parts[:i+1], "/")
+		if i < len(parts)-1 {
+			if h.excludedEntry(p, true) {
+				return true
+			}
+		} else if h.excludedPath(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// HidesEntry 判断目录 dir 中的条目 info 是否在目录列表中被过滤
+func (h *HiddenFs) HidesEntry(dir string, info os.FileInfo) bool {
+	return h.hiddenName(info.Name()) || h.excludedEntry(path.Join(dir, info.Name()), info.IsDir())
+}
+
+// hidden 判断路径中是否包含被隐藏的部分
+func (h *HiddenFs) hidden(name string) bool {
+	for _, part := range strings.Split(NormalizePath(name), "/") {
+		if part != "" && h.hiddenName(part) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRead 检查读取操作，被排除或未允许直接访问时表现为文件不存在
+func (h *HiddenFs) checkRead(op, name string) error {
+	if (h.hidden(name) && !h.allowExplicit) || h.excluded(name) {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	return nil
+}
+
+// checkWrite 检查写入操作，被隐藏或排除的路径始终不可修改
+func (h *HiddenFs) checkWrite(op, name string) error {
+	if h.hidden(name) || h.excluded(name) {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+	}
+	return nil
+}
+
+// checkMkdir 与 checkWrite 相同，但将目标视为目录以匹配仅针对目录的规则
+func (h *HiddenFs) checkMkdir(name string) error {
+	if err := h.checkWrite("mkdir", name); err != nil {
+		return err
+	}
+	if h.excludedEntry(NormalizePath(name), true) {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrPermission}
+	}
+	return nil
+}
+
+func (h *HiddenFs) Create(name string) (afero.File, error) {
+	if err := h.checkWrite("create", name); err != nil {
+		return nil, err
+	}
+	return h.Fs.Create(name)
+}
+
+func (h *HiddenFs) Mkdir(name string, perm os.FileMode) error {
+	if err := h.checkMkdir(name); err != nil {
+		return err
+	}
+	return h.Fs.Mkdir(name, perm)
+}
+
+func (h *HiddenFs) MkdirAll(name string, perm os.FileMode) error {
+	if err := h.checkMkdir(name); err != nil {
+		return err
+	}
+	return h.Fs.MkdirAll(name, perm)
+}
+
+func (h *HiddenFs) Open(name string) (afero.File, error) {
+	return h.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (h *HiddenFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	return h.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+func (h *HiddenFs) OpenFileContext(ctx context.Context, name string, flag int, perm os.FileMode) (afero.File, error) {
+	var err error
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		err = h.checkWrite("open", name)
+	} else {
+		err = h.checkRead("open", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	file, err := openFileContext(ctx, h.Fs, name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := file.Stat(); err != nil || !info.IsDir() {
+		return file, nil
+	}
+	return &hiddenDirFile{File: file, fs: h, path: NormalizePath(name)}, nil
+}
+
+func (h *HiddenFs) Remove(name string) error {
+	if err := h.checkWrite("remove", name); err != nil {
+		return err
+	}
+	return h.Fs.Remove(name)
+}
+
+func (h *HiddenFs) RemoveAll(name string) error {
+	if err := h.checkWrite("remove", name); err != nil {
+		return err
+	}
+	return h.Fs.RemoveAll(name)
+}
+
+func (h *HiddenFs) Rename(oldname, newname string) error {
+	if err := h.checkWrite("rename", oldname); err != nil {
+		return err
+	}
+	if err := h.checkWrite("rename", newname); err != nil {
+		return err
+	}
+	return h.Fs.Rename(oldname, newname)
+}
+
+func (h *HiddenFs) Stat(name string) (os.FileInfo, error) {
+	if err := h.checkRead("stat", name); err != nil {
+		return nil, err
+	}
+	return h.Fs.Stat(name)
+}
+
+func (h *HiddenFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	if err := h.checkRead("lstat", name); err != nil {
+		return nil, false, err
+	}
+	if lstater, ok := h.Fs.(afero.Lstater); ok {
+		return lstater.LstatIfPossible(name)
+	}
+	info, err := h.Fs.Stat(name)
+	return info, false, err
+}
+
+func (h *HiddenFs) Chmod(name string, mode os.FileMode) error {
+	if err := h.checkWrite("chmod", name); err != nil {
+		return err
+	}
+	return h.Fs.Chmod(name, mode)
+}
+
+func (h *HiddenFs) Chown(name string, uid, gid int) error {
+	if err := h.checkWrite("chown", name); err != nil {
+		return err
+	}
+	return h.Fs.Chown(name, uid, gid)
+}
+
+func (h *HiddenFs) Chtimes(name string, atime, mtime time.Time) error {
+	if err := h.checkWrite("chtimes", name); err != nil {
+		return err
+	}
+	return h.Fs.Chtimes(name, atime, mtime)
+}
+
+func (h *HiddenFs) LinkIfPossible(oldname, newname string) error {
+	if err := h.checkWrite("link", oldname); err != nil {
+		return err
+	}
+	if err := h.checkWrite("link", newname); err != nil {
+		return err
+	}
+	return linkIfPossible(h.Fs, oldname, newname)
+}
+
+func (h *HiddenFs) CopyFile(ctx context.Context, src, dst string) error {
+	if err := h.checkRead("copy", src); err != nil {
+		return err
+	}
+	if err := h.checkWrite("copy", dst); err != nil {
+		return err
+	}
+	return copyFileIfPossible(ctx, h.Fs, src, dst)
+}
+
+func (h *HiddenFs) Name() string {
+	return "HiddenFs"
+}
+
+// hiddenDirFile 在读取目录时过滤被隐藏及被排除的条目
+type hiddenDirFile struct {
+	afero.File
+	fs   *HiddenFs
+	path string
+}
+
+func (f *hiddenDirFile) Readdir(count int) ([]os.FileInfo, error) {
+	for {
+		infos, err := f.File.Readdir(count)
+		result := make([]os.FileInfo, 0, len(infos))
+		for _, info := range infos {
+			if !f.fs.HidesEntry(f.path, info) {
+				result = append(result, info)
+			}
+		}
+		// 本批次全部被过滤时继续读取，避免误报 EOF
+		if len(result) > 0 || err != nil || count <= 0 {
+			return result, err
+		}
+	}
+}
+
+func (f *hiddenDirFile) Readdirnames(count int) ([]string, error) {
+	for {
+		names, err := f.File.Readdirnames(count)
+		result := make([]string, 0, len(names))
+		for _, name := range names {
+			if !f.fs.hiddenName(name) && !f.fs.excludedPath(path.Join(f.path, name)) {
+				result = append(result, name)
+			}
+		}
+		if len(result) > 0 || err != nil || count <= 0 {
+			return result, err
+		}
+	}
+}
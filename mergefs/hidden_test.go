@@ -0,0 +1,52 @@
+package mergefs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHiddenFs(t *testing.T) {
+	base := afero.NewMemMapFs()
+	_ = afero.WriteFile(base, "/.git/config", []byte("git"), 0o644)
+	_ = afero.WriteFile(base, "/Thumbs.db", []byte("db"), 0o644)
+	_ = afero.WriteFile(base, "/file.txt", []byte("data"), 0o644)
+
+	hidden := NewHiddenFs(base, HiddenOptions{Dotfiles: true, Names: []string{"Thumbs.db"}})
+	assert.Equal(t, []string{"file.txt"}, readNames(t, hidden, "/"), "目录列表应隐藏点文件及指定文件")
+	_, err := hidden.Stat("/.git/config")
+	assert.ErrorIs(t, err, os.ErrNotExist, "被隐藏的文件应表现为不存在")
+	err = afero.WriteFile(hidden, "/.env", []byte("x"), 0o644)
+	assert.ErrorIs(t, err, os.ErrPermission, "不应允许写入被隐藏的路径")
+
+	explicit := NewHiddenFs(base, HiddenOptions{Dotfiles: true, AllowExplicit: true})
+	assert.Equal(t, []string{"Thumbs.db", "file.txt"}, readNames(t, explicit, "/"))
+	data, err := afero.ReadFile(explicit, "/.git/config")
+	assert.NoError(t, err, "允许时应能直接读取被隐藏的文件")
+	assert.Equal(t, "git", string(data))
+}
+
+func TestHiddenFs_Exclude(t *testing.T) {
+	base := afero.NewMemMapFs()
+	_ = afero.WriteFile(base, "/a.tmp", []byte("tmp"), 0o644)
+	_ = afero.WriteFile(base, "/app/node_modules/x.js", []byte("x"), 0o644)
+	_ = afero.WriteFile(base, "/app/main.js", []byte("main"), 0o644)
+	_ = afero.WriteFile(base, "/node_modules", []byte("file"), 0o644)
+
+	fs := NewHiddenFs(base, HiddenOptions{
+		Exclude:       []string{"*.tmp", "node_modules/"},
+		AllowExplicit: true,
+	})
+	assert.Equal(t, []string{"app", "node_modules"}, readNames(t, fs, "/"), "仅目录规则不应匹配同名文件")
+	assert.Equal(t, []string{"main.js"}, readNames(t, fs, "/app"))
+	_, err := fs.Stat("/a.tmp")
+	assert.ErrorIs(t, err, os.ErrNotExist, "被排除的文件即使允许直接访问也不可见")
+	_, err = fs.Stat("/app/node_modules/x.js")
+	assert.ErrorIs(t, err, os.ErrNotExist, "被排除目录下的文件应不可见")
+	err = afero.WriteFile(fs, "/b.tmp", []byte("x"), 0o644)
+	assert.ErrorIs(t, err, os.ErrPermission, "不应允许创建被排除的文件")
+	err = fs.Mkdir("/app/lib/node_modules", 0o755)
+	assert.ErrorIs(t, err, os.ErrPermission, "不应允许创建被排除的目录")
+}
@@ -0,0 +1,19 @@
+package mergefs
+
+import (
+	"io/fs"
+
+	"github.com/spf13/afero"
+)
+
+var (
+	_ fs.FS        = afero.IOFS{}
+	_ fs.ReadDirFS = afero.IOFS{}
+	_ fs.StatFS    = afero.IOFS{}
+)
+
+// IOFS 返回合并视图的 io/fs.FS 适配器，
+// 可直接用于 fs.WalkDir、http.FileServerFS、testing/fstest 等标准库工具
+func (m *MountFs) IOFS() afero.IOFS {
+	return afero.NewIOFS(m)
+}
@@ -0,0 +1,34 @@
+package mergefs
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMountFs_IOFS(t *testing.T) {
+	defaultFs := NewOsPathFs(t.TempDir(), SymlinkWithin)
+	_ = afero.WriteFile(defaultFs, "/root.txt", []byte("root"), 0o644)
+	mountedFs := NewOsPathFs(t.TempDir(), SymlinkWithin)
+	_ = mountedFs.Mkdir("/sub", 0o755)
+	_ = afero.WriteFile(mountedFs, "/sub/file.txt", []byte("data"), 0o644)
+
+	mountFs := NewMountFs(defaultFs)
+	_ = mountFs.Mount("/mnt/data", mountedFs)
+
+	iofs := mountFs.IOFS()
+	assert.NoError(t, fstest.TestFS(iofs, "root.txt", "mnt/data/sub/file.txt"), "应满足 io/fs 语义")
+
+	var files []string
+	err := fs.WalkDir(iofs, ".", func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, p)
+		}
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mnt/data/sub/file.txt", "root.txt"}, files, "应能遍历挂载点内的文件")
+}
@@ -0,0 +1,57 @@
+package mergefs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+
+	"github.com/spf13/afero"
+)
+
+// ErrNoHardLink 底层文件系统不支持硬链接
+var ErrNoHardLink = errors.New("hard link not supported")
+
+// HardLinker 支持硬链接的文件系统
+type HardLinker interface {
+	LinkIfPossible(oldname, newname string) error
+}
+
+// linkIfPossible 在底层文件系统支持时创建硬链接
+func linkIfPossible(fs afero.Fs, oldname, newname string) error {
+	if linker, ok := fs.(HardLinker); ok {
+		return linker.LinkIfPossible(oldname, newname)
+	}
+	return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: ErrNoHardLink}
+}
+
+func (m *MinFreeFs) LinkIfPossible(oldname, newname string) error {
+	// 硬链接不占用额外空间，无需检查剩余空间
+	return linkIfPossible(m.Fs, oldname, newname)
+}
+
+func (m *MaxSizeFs) LinkIfPossible(oldname, newname string) error {
+	return linkIfPossible(m.Fs, oldname, newname)
+}
+
+func (c *CacheFs) LinkIfPossible(oldname, newname string) error {
+	defer c.Invalidate(newname)
+	defer c.Invalidate(oldname)
+	return linkIfPossible(c.Fs, oldname, newname)
+}
+
+// LinkIfPossible 创建硬链接，两个路径必须位于同一挂载点且底层文件系统支持硬链接
+func (m *MountFs) LinkIfPossible(oldname, newname string) error {
+	oldFs, oldPath := m.GetMount(oldname)
+	newFs, newPath := m.GetMount(newname)
+
+	if oldFs != newFs {
+		return &os.LinkError{
+			Op:  "link",
+			Old: oldname,
+			New: newname,
+			Err: fs.ErrInvalid,
+		}
+	}
+	m.negative.invalidate(newname)
+	return linkIfPossible(oldFs, oldPath, newPath)
+}
@@ -0,0 +1,30 @@
+package mergefs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMountFs_LinkIfPossible(t *testing.T) {
+	mountFs := NewMountFs(afero.NewMemMapFs())
+	_ = mountFs.Mount("/disk", NewOsPathFs(t.TempDir(), SymlinkWithin))
+	_ = mountFs.Mount("/mem", afero.NewMemMapFs())
+
+	_ = afero.WriteFile(mountFs, "/disk/a.txt", []byte("data"), 0o644)
+	err := mountFs.LinkIfPossible("/disk/a.txt", "/disk/b.txt")
+	assert.NoError(t, err, "同一挂载点内应能创建硬链接")
+	_ = afero.WriteFile(mountFs, "/disk/a.txt", []byte("changed"), 0o644)
+	data, err := afero.ReadFile(mountFs, "/disk/b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "changed", string(data), "硬链接应指向同一文件")
+
+	err = mountFs.LinkIfPossible("/disk/a.txt", "/mem/a.txt")
+	assert.ErrorIs(t, err, os.ErrInvalid, "跨挂载点不应允许硬链接")
+
+	_ = afero.WriteFile(mountFs, "/mem/c.txt", []byte("data"), 0o644)
+	err = mountFs.LinkIfPossible("/mem/c.txt", "/mem/d.txt")
+	assert.ErrorIs(t, err, ErrNoHardLink, "不支持硬链接的后端应返回 ErrNoHardLink")
+}
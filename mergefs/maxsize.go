@@ -0,0 +1,111 @@
+package mergefs
+
+import (
+	"context"
+	"os"
+	"syscall"
+
+	"github.com/spf13/afero"
+)
+
+// MaxSizeFs 限制单个文件的大小，写入超过上限的部分时返回 EFBIG
+type MaxSizeFs struct {
+	afero.Fs
+	maxSize int64
+}
+
+// NewMaxSizeFs 创建 MaxSizeFs
+func NewMaxSizeFs(fs afero.Fs, maxSize int64) *MaxSizeFs {
+	return &MaxSizeFs{Fs: fs, maxSize: maxSize}
+}
+
+func (m *MaxSizeFs) tooLarge(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: syscall.EFBIG}
+}
+
+func (m *MaxSizeFs) Create(name string) (afero.File, error) {
+	return m.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (m *MaxSizeFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	return m.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+func (m *MaxSizeFs) OpenFileContext(ctx context.Context, name string, flag int, perm os.FileMode) (afero.File, error) {
+	file, err := openFileContext(ctx, m.Fs, name, flag, perm)
+	if err != nil || flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return file, err
+	}
+	f := &maxSizeFile{File: file, fs: m}
+	if flag&os.O_APPEND != 0 {
+		if info, err := file.Stat(); err == nil {
+			f.offset = info.Size()
+		}
+	}
+	if _, ok := file.(Aborter); ok {
+		return &maxSizeAbortFile{maxSizeFile: f}, nil
+	}
+	return f, nil
+}
+
+func (m *MaxSizeFs) Name() string {
+	return "MaxSizeFs"
+}
+
+// maxSizeFile 记录顺序写入的位置，拒绝超出上限的写入
+type maxSizeFile struct {
+	afero.File
+	fs     *MaxSizeFs
+	offset int64
+}
+
+func (f *maxSizeFile) check(op string, end int64) error {
+	if end > f.fs.maxSize {
+		return f.fs.tooLarge(op, f.Name())
+	}
+	return nil
+}
+
+func (f *maxSizeFile) Write(p []byte) (int, error) {
+	if err := f.check("write", f.offset+int64(len(p))); err != nil {
+		return 0, err
+	}
+	n, err := f.File.Write(p)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *maxSizeFile) WriteAt(p []byte, off int64) (int, error) {
+	if err := f.check("write", off+int64(len(p))); err != nil {
+		return 0, err
+	}
+	return f.File.WriteAt(p, off)
+}
+
+func (f *maxSizeFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *maxSizeFile) Seek(offset int64, whence int) (int64, error) {
+	n, err := f.File.Seek(offset, whence)
+	if err == nil {
+		f.offset = n
+	}
+	return n, err
+}
+
+func (f *maxSizeFile) Truncate(size int64) error {
+	if err := f.check("truncate", size); err != nil {
+		return err
+	}
+	return f.File.Truncate(size)
+}
+
+// maxSizeAbortFile 保留底层文件放弃写入的能力
+type maxSizeAbortFile struct {
+	*maxSizeFile
+}
+
+func (f *maxSizeAbortFile) Abort() error {
+	return f.File.(Aborter).Abort()
+}
@@ -0,0 +1,39 @@
+package mergefs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxSizeFs(t *testing.T) {
+	fs := NewMaxSizeFs(NewAtomicFs(afero.NewMemMapFs()), 8)
+
+	f, err := fs.Create("/a.txt")
+	assert.NoError(t, err)
+	_, err = f.Write([]byte("hello"))
+	assert.NoError(t, err, "未超出上限时应允许写入")
+	_, err = f.Write([]byte("world"))
+	assert.True(t, errors.Is(err, syscall.EFBIG), "超出上限时应返回 EFBIG")
+	_, err = f.WriteAt([]byte("world"), 4)
+	assert.True(t, errors.Is(err, syscall.EFBIG), "随机写入超出上限时应返回 EFBIG")
+	assert.True(t, errors.Is(f.Truncate(9), syscall.EFBIG), "截断到超出上限的大小应返回 EFBIG")
+	_, ok := f.(Aborter)
+	assert.True(t, ok, "应保留底层文件的 Abort")
+	assert.NoError(t, f.Close())
+
+	f, err = fs.OpenFile("/a.txt", os.O_WRONLY|os.O_APPEND, 0o644)
+	assert.NoError(t, err)
+	_, err = f.Write([]byte("!!!!"))
+	assert.True(t, errors.Is(err, syscall.EFBIG), "追加写入应从文件末尾计算大小")
+	_ = f.Close()
+
+	assert.NoError(t, afero.WriteFile(fs.Fs, "/big.txt", []byte("0123456789"), 0o644))
+	f, err = fs.Open("/big.txt")
+	assert.NoError(t, err, "只读打开不受限制")
+	_ = f.Close()
+}
@@ -0,0 +1,274 @@
+package mergefs
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// MemoryOptions 内存存储池选项
+type MemoryOptions struct {
+	// 总容量上限，为 0 时不限制
+	MaxSize int64
+	// 文件在最后一次修改后保留的时间，为 0 时不过期
+	TTL time.Duration
+}
+
+// MemoryFs 不落盘的内存文件系统，限制总容量并清理过期文件
+type MemoryFs struct {
+	afero.Fs
+	opts MemoryOptions
+
+	mu        sync.Mutex
+	sizes     map[string]int64
+	used      int64
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryFs 创建内存文件系统
+func NewMemoryFs(opts MemoryOptions) *MemoryFs {
+	return &MemoryFs{
+		Fs:    afero.NewMemMapFs(),
+		opts:  opts,
+		sizes: make(map[string]int64),
+		now:   time.Now,
+	}
+}
+
+func memoryKey(name string) string {
+	return path.Clean("/" + filepath.ToSlash(name))
+}
+
+// Used 返回当前已使用的字节数
+func (m *MemoryFs) Used() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.used
+}
+
+// expire 按间隔清理过期的文件及空目录
+func (m *MemoryFs) expire() {
+	if m.opts.TTL <= 0 {
+		return
+	}
+	m.mu.Lock()
+	now := m.now()
+	if now.Sub(m.lastSweep) < min(m.opts.TTL/4, time.Minute) {
+		m.mu.Unlock()
+		return
+	}
+	m.lastSweep = now
+	m.mu.Unlock()
+
+	deadline := now.Add(-m.opts.TTL)
+	var files, dirs []string
+	_ = afero.Walk(m.Fs, "/", func(name string, info os.FileInfo, err error) error {
+		if err != nil || name == "/" || !info.ModTime().Before(deadline) {
+			return nil
+		}
+		if info.IsDir() {
+			dirs = append(dirs, name)
+		} else {
+			files = append(files, name)
+		}
+		return nil
+	})
+	for _, name := range files {
+		_ = m.Remove(name)
+	}
+	// 由深到浅删除，Remove 会跳过非空目录
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+	for _, name := range dirs {
+		_ = m.Remove(name)
+	}
+}
+
+// resize 将文件大小调整为 size，超过容量上限时返回 ENOSPC 且不执行 op
+func (m *MemoryFs) resize(name string, size int64, op func() error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.resizeLocked(name, size, op)
+}
+
+// grow 将文件大小扩展到至少 end，在持有锁时读取当前大小，避免并发的乱序写入使计数偏小
+func (m *MemoryFs) grow(name string, file afero.File, end int64, op func() error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return m.resizeLocked(name, max(info.Size(), end), op)
+}
+
+func (m *MemoryFs) resizeLocked(name string, size int64, op func() error) error {
+	key := memoryKey(name)
+	current := m.sizes[key]
+	if m.opts.MaxSize > 0 && size > current && m.used-current+size > m.opts.MaxSize {
+		return &os.PathError{Op: "write", Path: name, Err: syscall.ENOSPC}
+	}
+	if err := op(); err != nil {
+		return err
+	}
+	m.used += size - current
+	m.sizes[key] = size
+	return nil
+}
+
+// release 释放 name 及其子项占用的容量
+func (m *MemoryFs) release(name string) {
+	key := memoryKey(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, size := range m.sizes {
+		if k == key || strings.HasPrefix(k, key+"/") || key == "/" {
+			m.used -= size
+			delete(m.sizes, k)
+		}
+	}
+}
+
+func (m *MemoryFs) Create(name string) (afero.File, error) {
+	return m.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (m *MemoryFs) Open(name string) (afero.File, error) {
+	m.expire()
+	return m.Fs.Open(name)
+}
+
+func (m *MemoryFs) Stat(name string) (os.FileInfo, error) {
+	m.expire()
+	return m.Fs.Stat(name)
+}
+
+func (m *MemoryFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	m.expire()
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return m.Fs.OpenFile(name, flag, perm)
+	}
+	var file afero.File
+	open := func() (err error) {
+		file, err = m.Fs.OpenFile(name, flag, perm)
+		return err
+	}
+	var err error
+	if flag&os.O_TRUNC != 0 {
+		err = m.resize(name, 0, open)
+	} else {
+		err = open()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info, err := file.Stat(); err == nil && info.IsDir() {
+		return file, nil
+	}
+	return &memoryFile{File: file, fs: m, name: name}, nil
+}
+
+// Remove 不允许删除非空目录（MemMapFs 会直接删除并遗留子项）
+func (m *MemoryFs) Remove(name string) error {
+	info, err := m.Fs.Stat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		names, err := afero.ReadDir(m.Fs, name)
+		if err != nil {
+			return err
+		}
+		if len(names) > 0 {
+			return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+		}
+	}
+	if err := m.Fs.Remove(name); err != nil {
+		return err
+	}
+	m.release(name)
+	return nil
+}
+
+func (m *MemoryFs) RemoveAll(name string) error {
+	if err := m.Fs.RemoveAll(name); err != nil {
+		return err
+	}
+	m.release(name)
+	return nil
+}
+
+func (m *MemoryFs) Rename(oldname, newname string) error {
+	info, err := m.Fs.Stat(newname)
+	overwrite := err == nil && !info.IsDir()
+	if err := m.Fs.Rename(oldname, newname); err != nil {
+		return err
+	}
+	if overwrite {
+		m.release(newname)
+	}
+	oldKey, newKey := memoryKey(oldname), memoryKey(newname)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, size := range m.sizes {
+		if k == oldKey || strings.HasPrefix(k, oldKey+"/") {
+			delete(m.sizes, k)
+			m.sizes[newKey+strings.TrimPrefix(k, oldKey)] = size
+		}
+	}
+	return nil
+}
+
+func (m *MemoryFs) Name() string {
+	return "MemoryFs"
+}
+
+// memoryFile 写入前检查容量上限
+type memoryFile struct {
+	afero.File
+	fs   *MemoryFs
+	name string
+}
+
+// grow 写入到 end 位置前预留容量
+func (f *memoryFile) grow(end int64, op func() error) error {
+	return f.fs.grow(f.name, f.File, end, op)
+}
+
+func (f *memoryFile) Write(p []byte) (n int, err error) {
+	off, err := f.File.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	err = f.grow(off+int64(len(p)), func() error {
+		n, err = f.File.Write(p)
+		return err
+	})
+	return n, err
+}
+
+func (f *memoryFile) WriteAt(p []byte, off int64) (n int, err error) {
+	err = f.grow(off+int64(len(p)), func() error {
+		n, err = f.File.WriteAt(p, off)
+		return err
+	})
+	return n, err
+}
+
+func (f *memoryFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *memoryFile) Truncate(size int64) error {
+	return f.fs.resize(f.name, size, func() error {
+		return f.File.Truncate(size)
+	})
+}
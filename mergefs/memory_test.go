@@ -0,0 +1,64 @@
+package mergefs
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryFs_MaxSize(t *testing.T) {
+	fs := NewMemoryFs(MemoryOptions{MaxSize: 10})
+	assert.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("12345678"), 0o644))
+	err := afero.WriteFile(fs, "/b.txt", []byte("12345"), 0o644)
+	assert.ErrorIs(t, err, syscall.ENOSPC, "超出容量上限时应拒绝写入")
+
+	assert.NoError(t, afero.WriteFile(fs, "/a.txt", []byte("1234"), 0o644), "覆盖写入应释放原有容量")
+	assert.NoError(t, afero.WriteFile(fs, "/b.txt", []byte("12345"), 0o644))
+	assert.Equal(t, int64(9), fs.Used())
+
+	assert.NoError(t, fs.Mkdir("/dir", 0o755))
+	assert.NoError(t, fs.Rename("/b.txt", "/dir/b.txt"))
+	assert.ErrorIs(t, fs.Remove("/dir"), syscall.ENOTEMPTY)
+	assert.NoError(t, fs.RemoveAll("/dir"))
+	assert.Equal(t, int64(4), fs.Used(), "删除后应释放容量")
+}
+
+func TestMemoryFs_ConcurrentWriteAt(t *testing.T) {
+	fs := NewMemoryFs(MemoryOptions{MaxSize: 1 << 20})
+	file, err := fs.Create("/a.bin")
+	assert.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := 15; i >= 0; i-- {
+		wg.Go(func() {
+			_, err := file.WriteAt(make([]byte, 1024), int64(i)*1024)
+			assert.NoError(t, err)
+		})
+	}
+	wg.Wait()
+	assert.NoError(t, file.Close())
+	info, err := fs.Stat("/a.bin")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(16*1024), info.Size())
+	assert.Equal(t, info.Size(), fs.Used(), "乱序并发写入后容量计数应与文件大小一致")
+}
+
+func TestMemoryFs_TTL(t *testing.T) {
+	fs := NewMemoryFs(MemoryOptions{TTL: time.Hour})
+	assert.NoError(t, fs.MkdirAll("/dir/sub", 0o755))
+	assert.NoError(t, afero.WriteFile(fs, "/dir/sub/a.txt", []byte("data"), 0o644))
+
+	_, err := fs.Stat("/dir/sub/a.txt")
+	assert.NoError(t, err)
+
+	fs.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	_, err = fs.Stat("/dir/sub/a.txt")
+	assert.True(t, os.IsNotExist(err), "过期文件应被清理")
+	_, err = fs.Stat("/dir")
+	assert.True(t, os.IsNotExist(err), "过期的空目录应被清理")
+	assert.Zero(t, fs.Used())
+}
@@ -0,0 +1,147 @@
+package mergefs
+
+import (
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/spf13/afero"
+)
+
+// minFreeCheckInterval 写入过程中每写入该字节数重新读取一次剩余空间，以发现其他程序占用的空间
+const minFreeCheckInterval = 64 << 20
+
+// MinFreeFs 在写入前检查磁盘剩余空间，写入后将低于阈值时拒绝写入，避免单个用户写满磁盘
+type MinFreeFs struct {
+	afero.Fs
+	minFree uint64
+	free    func() (uint64, error)
+
+	mu sync.Mutex
+	// known 为 false 时 avail 无效，下次写入前重新读取
+	known bool
+	// avail 上次读取的剩余空间减去此后经由本文件系统写入的字节数，所有打开的文件共用
+	avail uint64
+	// unchecked 上次读取剩余空间后写入的字节数
+	unchecked int64
+}
+
+// NewMinFreeFs 创建 MinFreeFs，path 为底层文件系统在磁盘上的路径
+func NewMinFreeFs(fs afero.Fs, path string, minFree uint64) *MinFreeFs {
+	return &MinFreeFs{
+		Fs:      fs,
+		minFree: minFree,
+		free: func() (uint64, error) {
+			usage, err := GetDiskUsage(path)
+			return usage.Free, err
+		},
+	}
+}
+
+// refresh 重新读取剩余空间，调用时需持有 mu。无法获取磁盘信息时返回 false
+func (m *MinFreeFs) refresh() bool {
+	free, err := m.free()
+	if err != nil {
+		m.known = false
+		return false
+	}
+	m.avail, m.unchecked, m.known = free, 0, true
+	return true
+}
+
+// check 在创建、打开与新建目录前读取剩余空间，已低于阈值时拒绝
+func (m *MinFreeFs) check(op, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// 无法获取磁盘信息时不阻止写入
+	if m.refresh() && m.avail < m.minFree {
+		return &os.PathError{Op: op, Path: name, Err: syscall.ENOSPC}
+	}
+	return nil
+}
+
+// reserve 在写入 n 字节前检查，写入后剩余空间将低于阈值时拒绝。按已写入的字节数预估剩余空间，
+// 预估不足或自上次读取后已写入 minFreeCheckInterval 字节时重新读取磁盘信息
+func (m *MinFreeFs) reserve(op, name string, n int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	need := m.minFree + uint64(n)
+	if !m.known || m.avail < need || m.unchecked >= minFreeCheckInterval {
+		if !m.refresh() {
+			return nil
+		}
+	}
+	if m.avail < need {
+		return &os.PathError{Op: op, Path: name, Err: syscall.ENOSPC}
+	}
+	m.avail -= uint64(n)
+	m.unchecked += int64(n)
+	return nil
+}
+
+func (m *MinFreeFs) Create(name string) (afero.File, error) {
+	if err := m.check("create", name); err != nil {
+		return nil, err
+	}
+	file, err := m.Fs.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &minFreeFile{File: file, fs: m}, nil
+}
+
+func (m *MinFreeFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return m.Fs.OpenFile(name, flag, perm)
+	}
+	if err := m.check("open", name); err != nil {
+		return nil, err
+	}
+	file, err := m.Fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &minFreeFile{File: file, fs: m}, nil
+}
+
+func (m *MinFreeFs) Mkdir(name string, perm os.FileMode) error {
+	if err := m.check("mkdir", name); err != nil {
+		return err
+	}
+	return m.Fs.Mkdir(name, perm)
+}
+
+func (m *MinFreeFs) MkdirAll(path string, perm os.FileMode) error {
+	if err := m.check("mkdir", path); err != nil {
+		return err
+	}
+	return m.Fs.MkdirAll(path, perm)
+}
+
+func (m *MinFreeFs) Name() string {
+	return "MinFreeFs"
+}
+
+// minFreeFile 在每次写入前检查剩余空间
+type minFreeFile struct {
+	afero.File
+	fs *MinFreeFs
+}
+
+func (f *minFreeFile) Write(p []byte) (int, error) {
+	if err := f.fs.reserve("write", f.Name(), len(p)); err != nil {
+		return 0, err
+	}
+	return f.File.Write(p)
+}
+
+func (f *minFreeFile) WriteAt(p []byte, off int64) (int, error) {
+	if err := f.fs.reserve("write", f.Name(), len(p)); err != nil {
+		return 0, err
+	}
+	return f.File.WriteAt(p, off)
+}
+
+func (f *minFreeFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
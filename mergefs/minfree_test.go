@@ -0,0 +1,80 @@
+package mergefs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMinFreeFs(t *testing.T) {
+	var free uint64 = 100
+	fs := NewMinFreeFs(afero.NewMemMapFs(), "/", 50)
+	fs.free = func() (uint64, error) { return free, nil }
+
+	// 空间充足时允许写入
+	f, err := fs.Create("/a.txt")
+	assert.NoError(t, err, "空间充足时应允许创建")
+	_, err = f.Write([]byte("hello"))
+	assert.NoError(t, err)
+	_ = f.Close()
+
+	// 空间不足时拒绝写入
+	free = 10
+	_, err = fs.Create("/b.txt")
+	assert.True(t, errors.Is(err, syscall.ENOSPC), "空间不足时应返回 ENOSPC")
+	_, err = fs.OpenFile("/a.txt", os.O_WRONLY|os.O_APPEND, 0o644)
+	assert.True(t, errors.Is(err, syscall.ENOSPC), "空间不足时应拒绝以写模式打开")
+
+	// 只读打开不受影响
+	f, err = fs.OpenFile("/a.txt", os.O_RDONLY, 0)
+	assert.NoError(t, err, "只读打开不应受限")
+	_ = f.Close()
+
+	// 获取磁盘信息失败时不阻止写入
+	fs.free = func() (uint64, error) { return 0, errors.New("statfs failed") }
+	_, err = fs.Create("/c.txt")
+	assert.NoError(t, err)
+
+	// 新建目录同样受限
+	fs.free = func() (uint64, error) { return 10, nil }
+	assert.True(t, errors.Is(fs.Mkdir("/d", 0o755), syscall.ENOSPC), "空间不足时应拒绝新建目录")
+	assert.True(t, errors.Is(fs.MkdirAll("/d/e", 0o755), syscall.ENOSPC))
+}
+
+func TestMinFreeFsWrite(t *testing.T) {
+	var free uint64 = 60
+	var reads int
+	fs := NewMinFreeFs(afero.NewMemMapFs(), "/", 50)
+	fs.free = func() (uint64, error) {
+		reads++
+		return free, nil
+	}
+
+	f, err := fs.Create("/a.txt")
+	assert.NoError(t, err, "剩余空间略高于阈值时允许创建")
+	_, err = f.Write(make([]byte, 11))
+	assert.True(t, errors.Is(err, syscall.ENOSPC), "单次写入会低于阈值时应拒绝")
+	_, err = f.Write(make([]byte, 6))
+	assert.NoError(t, err)
+	free -= 6
+
+	g, err := fs.Create("/b.txt")
+	assert.NoError(t, err)
+	_, err = g.WriteAt(make([]byte, 4), 0)
+	assert.NoError(t, err)
+	free -= 4
+	_, err = f.Write(make([]byte, 1))
+	assert.True(t, errors.Is(err, syscall.ENOSPC), "多个文件的写入共同计入剩余空间")
+
+	free = 1000
+	reads = 0
+	_, err = f.Write(make([]byte, 100))
+	assert.NoError(t, err, "预估不足时重新读取剩余空间")
+	_, err = f.Write(make([]byte, 100))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reads, "预估充足时不重复读取磁盘信息")
+}
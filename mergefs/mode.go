@@ -0,0 +1,134 @@
+package mergefs
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+// ModeOptions 新建文件与目录的权限设置
+type ModeOptions struct {
+	// 新建文件的固定权限，为 0 时使用客户端请求的权限
+	FileMode os.FileMode
+	// 新建目录的固定权限，为 0 时使用客户端请求的权限
+	DirMode os.FileMode
+	// 从客户端请求的权限中去除的位
+	Umask os.FileMode
+}
+
+// ModeFs 统一调整新建文件、目录及 chmod 的权限，避免客户端传入的 0777 等权限直接落盘
+type ModeFs struct {
+	afero.Fs
+	opts ModeOptions
+}
+
+// NewModeFs 创建 ModeFs
+func NewModeFs(fs afero.Fs, opts ModeOptions) *ModeFs {
+	return &ModeFs{Fs: fs, opts: opts}
+}
+
+func (m *ModeFs) mode(perm os.FileMode, dir bool) os.FileMode {
+	switch {
+	case dir && m.opts.DirMode != 0:
+		return m.opts.DirMode.Perm()
+	case !dir && m.opts.FileMode != 0:
+		return m.opts.FileMode.Perm()
+	}
+	return perm &^ m.opts.Umask
+}
+
+// fixed 是否设置了固定权限，此时需要在创建后显式 chmod 以绕过进程的 umask
+func (m *ModeFs) fixed(dir bool) bool {
+	if dir {
+		return m.opts.DirMode != 0
+	}
+	return m.opts.FileMode != 0
+}
+
+func (m *ModeFs) Create(name string) (afero.File, error) {
+	return m.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (m *ModeFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	return m.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+func (m *ModeFs) OpenFileContext(ctx context.Context, name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&os.O_CREATE == 0 {
+		return openFileContext(ctx, m.Fs, name, flag, perm)
+	}
+	created := false
+	if m.fixed(false) {
+		_, err := m.Fs.Stat(name)
+		created = os.IsNotExist(err)
+	}
+	mode := m.mode(perm, false)
+	file, err := openFileContext(ctx, m.Fs, name, flag, mode)
+	if err == nil && created {
+		_ = m.Fs.Chmod(name, mode)
+	}
+	return file, err
+}
+
+func (m *ModeFs) Mkdir(name string, perm os.FileMode) error {
+	mode := m.mode(perm, true)
+	if err := m.Fs.Mkdir(name, mode); err != nil {
+		return err
+	}
+	if m.fixed(true) {
+		_ = m.Fs.Chmod(name, mode)
+	}
+	return nil
+}
+
+func (m *ModeFs) MkdirAll(name string, perm os.FileMode) error {
+	if !m.fixed(true) {
+		return m.Fs.MkdirAll(name, m.mode(perm, true))
+	}
+	// 逐级创建以便为每个新建的目录设置权限
+	if _, err := m.Fs.Stat(name); err == nil {
+		return m.Fs.MkdirAll(name, perm)
+	}
+	name = path.Clean("/" + filepath.ToSlash(name))
+	if parent := path.Dir(name); parent != name {
+		if err := m.MkdirAll(parent, perm); err != nil {
+			return err
+		}
+	}
+	if err := m.Mkdir(name, perm); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Chmod 同样应用固定权限与 umask
+func (m *ModeFs) Chmod(name string, mode os.FileMode) error {
+	info, err := m.Fs.Stat(name)
+	if err != nil {
+		return err
+	}
+	return m.Fs.Chmod(name, mode&^os.ModePerm|m.mode(mode.Perm(), info.IsDir()))
+}
+
+func (m *ModeFs) LinkIfPossible(oldname, newname string) error {
+	return linkIfPossible(m.Fs, oldname, newname)
+}
+
+// CopyFile 复制后的文件保留源文件权限，同样应用固定权限与 umask
+func (m *ModeFs) CopyFile(ctx context.Context, src, dst string) error {
+	if err := copyFileIfPossible(ctx, m.Fs, src, dst); err != nil {
+		return err
+	}
+	info, err := m.Fs.Stat(dst)
+	if err != nil {
+		return err
+	}
+	return m.Fs.Chmod(dst, m.mode(info.Mode().Perm(), false))
+}
+
+func (m *ModeFs) Name() string {
+	return "ModeFs"
+}
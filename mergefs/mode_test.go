@@ -0,0 +1,30 @@
+package mergefs
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModeFs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows 不支持 unix 权限位")
+	}
+	fs := NewModeFs(NewOsPathFs(t.TempDir(), SymlinkWithin), ModeOptions{FileMode: 0o640, DirMode: 0o750})
+	assert.NoError(t, fs.MkdirAll("/a/b", os.ModePerm))
+	assert.NoError(t, afero.WriteFile(fs, "/a/b/file.txt", []byte("data"), os.ModePerm))
+	for name, mode := range map[string]os.FileMode{"/a": 0o750, "/a/b": 0o750, "/a/b/file.txt": 0o640} {
+		info, err := fs.Stat(name)
+		assert.NoError(t, err)
+		assert.Equal(t, mode, info.Mode().Perm(), name)
+	}
+
+	umask := NewModeFs(fs.Fs, ModeOptions{Umask: 0o027})
+	assert.NoError(t, umask.Chmod("/a/b/file.txt", 0o777))
+	info, err := umask.Stat("/a/b/file.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o750), info.Mode().Perm(), "chmod 也应去除 umask 中的位")
+}
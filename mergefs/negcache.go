@@ -0,0 +1,86 @@
+package mergefs
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// negativeCacheMaxEntries 负缓存最大条目数，超过时清理
+const negativeCacheMaxEntries = 10000
+
+// negativeCache 缓存短时间内不存在的路径，减少客户端反复探测
+// (desktop.ini、Thumbs.db 等) 对后端的访问。nil 表示未开启。
+type negativeCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+func newNegativeCache(ttl time.Duration) *negativeCache {
+	return &negativeCache{
+		ttl:     ttl,
+		entries: make(map[string]time.Time),
+	}
+}
+
+func (c *negativeCache) has(name string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expire, ok := c.entries[name]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expire) {
+		delete(c.entries, name)
+		return false
+	}
+	return true
+}
+
+func (c *negativeCache) add(name string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= negativeCacheMaxEntries {
+		for key, expire := range c.entries {
+			if now.After(expire) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= negativeCacheMaxEntries {
+			c.entries = make(map[string]time.Time)
+		}
+	}
+	c.entries[name] = now.Add(c.ttl)
+}
+
+// invalidate 移除路径、其子路径及所有上级目录的缓存
+func (c *negativeCache) invalidate(name string) {
+	if c == nil {
+		return
+	}
+	name = NormalizePath(name)
+	prefix := name + "/"
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// MkdirAll 等操作会同时创建上级目录
+	for dir := name; ; dir = path.Dir(dir) {
+		delete(c.entries, dir)
+		if dir == "/" {
+			break
+		}
+	}
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+}
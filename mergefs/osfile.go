@@ -0,0 +1,24 @@
+package mergefs
+
+import (
+	"os"
+
+	"github.com/spf13/afero"
+)
+
+// OsFile 返回文件底层的 *os.File，发送文件内容时 net/http 可借此使用 sendfile 零拷贝。
+// 只解开不改变读取内容的包装（afero.BasePathFile 与实现 Unwrap 的文件），其他文件返回 false
+func OsFile(f afero.File) (*os.File, bool) {
+	for {
+		switch file := f.(type) {
+		case *os.File:
+			return file, true
+		case *afero.BasePathFile:
+			f = file.File
+		case interface{ Unwrap() afero.File }:
+			f = file.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+}
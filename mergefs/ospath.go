@@ -0,0 +1,336 @@
+package mergefs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// SymlinkPolicy 本地目录中符号链接的解析策略
+type SymlinkPolicy string
+
+const (
+	// SymlinkWithin 仅允许解析到根目录内的符号链接（默认）
+	SymlinkWithin SymlinkPolicy = "within"
+	// SymlinkDeny 拒绝跟随任何符号链接
+	SymlinkDeny SymlinkPolicy = "deny"
+	// SymlinkAnywhere 不做限制，符号链接可指向任意位置
+	SymlinkAnywhere SymlinkPolicy = "anywhere"
+)
+
+// OsPathFs 以本地目录为根的文件系统，在 afero.BasePathFs 基础上支持硬链接，
+// 并按 SymlinkPolicy 检查路径，防止符号链接逃逸出根目录
+type OsPathFs struct {
+	*afero.BasePathFs
+	root   string
+	policy SymlinkPolicy
+}
+
+// NewOsPathFs 创建以 root 为根目录的本地文件系统，policy 为空时使用 SymlinkWithin
+func NewOsPathFs(root string, policy SymlinkPolicy) *OsPathFs {
+	if policy == "" {
+		policy = SymlinkWithin
+	}
+	return &OsPathFs{
+		BasePathFs: afero.NewBasePathFs(afero.NewOsFs(), root).(*afero.BasePathFs),
+		root:       filepath.Clean(root),
+		policy:     policy,
+	}
+}
+
+// checkPath 按符号链接策略检查路径，follow 表示该操作是否会跟随最后一级符号链接
+func (o *OsPathFs) checkPath(op, name string, follow bool) error {
+	if o.policy == SymlinkAnywhere {
+		return nil
+	}
+	realPath, err := o.RealPath(name)
+	if err != nil {
+		return &os.PathError{Op: op, Path: name, Err: err}
+	}
+	var allowed bool
+	if o.policy == SymlinkDeny {
+		allowed = !o.hasSymlink(realPath, follow)
+	} else {
+		allowed = o.resolvesWithin(realPath, follow)
+	}
+	if !allowed {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+	}
+	return nil
+}
+
+// hasSymlink 判断根目录下的路径中是否存在符号链接
+func (o *OsPathFs) hasSymlink(realPath string, follow bool) bool {
+	rel, err := filepath.Rel(o.root, realPath)
+	if err != nil || rel == "." {
+		return false
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	if !follow {
+		parts = parts[:len(parts)-1]
+	}
+	current := o.root
+	for _, part := range parts {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if err != nil {
+			// 路径不存在时后续部分也不可能是符号链接
+			return false
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvesWithin 判断路径解析符号链接后是否仍位于根目录内
+func (o *OsPathFs) resolvesWithin(realPath string, follow bool) bool {
+	target := realPath
+	if !follow {
+		if realPath == o.root {
+			return true
+		}
+		target = filepath.Dir(realPath)
+	}
+	resolved, err := evalExisting(target)
+	if err != nil {
+		return false
+	}
+	root, err := filepath.EvalSymlinks(o.root)
+	if err != nil {
+		root = o.root
+	}
+	rel, err := filepath.Rel(root, resolved)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// evalExisting 解析路径中已存在部分的符号链接，不存在的部分原样拼接
+func evalExisting(p string) (string, error) {
+	rest := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		if info, lErr := os.Lstat(p); lErr == nil && info.Mode()&os.ModeSymlink != 0 {
+			// 悬空的符号链接，写入时会在目标位置创建文件，无法确认其位置
+			return "", os.ErrPermission
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return filepath.Join(p, rest), nil
+		}
+		rest = filepath.Join(filepath.Base(p), rest)
+		p = parent
+	}
+}
+
+func (o *OsPathFs) Chtimes(name string, atime, mtime time.Time) error {
+	if err := o.checkPath("chtimes", name, true); err != nil {
+		return err
+	}
+	return o.BasePathFs.Chtimes(name, atime, mtime)
+}
+
+func (o *OsPathFs) Chmod(name string, mode os.FileMode) error {
+	if err := o.checkPath("chmod", name, true); err != nil {
+		return err
+	}
+	return o.BasePathFs.Chmod(name, mode)
+}
+
+func (o *OsPathFs) Chown(name string, uid, gid int) error {
+	if err := o.checkPath("chown", name, true); err != nil {
+		return err
+	}
+	return o.BasePathFs.Chown(name, uid, gid)
+}
+
+func (o *OsPathFs) Stat(name string) (os.FileInfo, error) {
+	if err := o.checkPath("stat", name, true); err != nil {
+		return nil, err
+	}
+	return o.BasePathFs.Stat(name)
+}
+
+func (o *OsPathFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	if err := o.checkPath("lstat", name, false); err != nil {
+		return nil, false, err
+	}
+	return o.BasePathFs.LstatIfPossible(name)
+}
+
+func (o *OsPathFs) Rename(oldname, newname string) error {
+	if err := o.checkPath("rename", oldname, false); err != nil {
+		return err
+	}
+	if err := o.checkPath("rename", newname, false); err != nil {
+		return err
+	}
+	return o.BasePathFs.Rename(oldname, newname)
+}
+
+func (o *OsPathFs) RemoveAll(name string) error {
+	if err := o.checkPath("remove_all", name, false); err != nil {
+		return err
+	}
+	return o.BasePathFs.RemoveAll(name)
+}
+
+func (o *OsPathFs) Remove(name string) error {
+	if err := o.checkPath("remove", name, false); err != nil {
+		return err
+	}
+	return o.BasePathFs.Remove(name)
+}
+
+func (o *OsPathFs) OpenFile(name string, flag int, mode os.FileMode) (afero.File, error) {
+	if err := o.checkPath("openfile", name, true); err != nil {
+		return nil, err
+	}
+	return o.BasePathFs.OpenFile(name, flag, mode)
+}
+
+func (o *OsPathFs) Open(name string) (afero.File, error) {
+	if err := o.checkPath("open", name, true); err != nil {
+		return nil, err
+	}
+	return o.BasePathFs.Open(name)
+}
+
+func (o *OsPathFs) Mkdir(name string, mode os.FileMode) error {
+	if err := o.checkPath("mkdir", name, true); err != nil {
+		return err
+	}
+	return o.BasePathFs.Mkdir(name, mode)
+}
+
+func (o *OsPathFs) MkdirAll(name string, mode os.FileMode) error {
+	if err := o.checkPath("mkdir", name, true); err != nil {
+		return err
+	}
+	return o.BasePathFs.MkdirAll(name, mode)
+}
+
+func (o *OsPathFs) Create(name string) (afero.File, error) {
+	if err := o.checkPath("create", name, true); err != nil {
+		return nil, err
+	}
+	return o.BasePathFs.Create(name)
+}
+
+func (o *OsPathFs) SymlinkIfPossible(oldname, newname string) error {
+	if o.policy == SymlinkDeny {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: os.ErrPermission}
+	}
+	if err := o.checkPath("symlink", newname, false); err != nil {
+		return err
+	}
+	return o.BasePathFs.SymlinkIfPossible(oldname, newname)
+}
+
+func (o *OsPathFs) ReadlinkIfPossible(name string) (string, error) {
+	if err := o.checkPath("readlink", name, false); err != nil {
+		return "", err
+	}
+	return o.BasePathFs.ReadlinkIfPossible(name)
+}
+
+func (o *OsPathFs) LinkIfPossible(oldname, newname string) error {
+	if err := o.checkPath("link", oldname, false); err != nil {
+		return err
+	}
+	if err := o.checkPath("link", newname, false); err != nil {
+		return err
+	}
+	oldPath, err := o.RealPath(oldname)
+	if err != nil {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: err}
+	}
+	newPath, err := o.RealPath(newname)
+	if err != nil {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: err}
+	}
+	return os.Link(oldPath, newPath)
+}
+
+// CopyFile 通过 reflink 复制文件，先复制到同目录的临时文件再替换 dst，保留权限与修改时间。
+// 文件系统不支持 reflink 时返回 ErrNoCopy
+func (o *OsPathFs) CopyFile(_ context.Context, src, dst string) error {
+	if err := o.checkPath("copy", src, true); err != nil {
+		return err
+	}
+	if err := o.checkPath("copy", dst, false); err != nil {
+		return err
+	}
+	srcPath, err := o.RealPath(src)
+	if err != nil {
+		return &os.LinkError{Op: "copy", Old: src, New: dst, Err: err}
+	}
+	tmpPath, err := o.RealPath(atomicTempName(dst))
+	if err != nil {
+		return &os.LinkError{Op: "copy", Old: src, New: dst, Err: err}
+	}
+	dstPath, err := o.RealPath(dst)
+	if err != nil {
+		return &os.LinkError{Op: "copy", Old: src, New: dst, Err: err}
+	}
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return &os.LinkError{Op: "copy", Old: src, New: dst, Err: err}
+	}
+	if !info.Mode().IsRegular() || reflinkFile(srcPath, tmpPath) != nil {
+		return &os.LinkError{Op: "copy", Old: src, New: dst, Err: ErrNoCopy}
+	}
+	if err = os.Chtimes(tmpPath, info.ModTime(), info.ModTime()); err == nil {
+		err = os.Rename(tmpPath, dstPath)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+func (o *OsPathFs) Name() string {
+	return "OsPathFs"
+}
+
+// Props 读取保存在扩展属性中的自定义属性
+func (o *OsPathFs) Props(name string) (map[string][]byte, error) {
+	if err := o.checkPath("props", name, true); err != nil {
+		return nil, err
+	}
+	realPath, err := o.RealPath(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "props", Path: name, Err: err}
+	}
+	props, err := readXattrs(realPath)
+	if err != nil {
+		return nil, &os.PathError{Op: "props", Path: name, Err: err}
+	}
+	return props, nil
+}
+
+// PatchProps 将自定义属性写入扩展属性，文件系统不支持扩展属性时返回 ErrNoProps
+func (o *OsPathFs) PatchProps(name string, set map[string][]byte, remove []string) error {
+	if err := o.checkPath("proppatch", name, true); err != nil {
+		return err
+	}
+	realPath, err := o.RealPath(name)
+	if err != nil {
+		return &os.PathError{Op: "proppatch", Path: name, Err: err}
+	}
+	if err := writeXattrs(realPath, set, remove); err != nil {
+		return &os.PathError{Op: "proppatch", Path: name, Err: err}
+	}
+	return nil
+}
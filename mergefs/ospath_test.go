@@ -0,0 +1,54 @@
+package mergefs
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOsPathFs_SymlinkPolicy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("创建符号链接需要特权")
+	}
+	base := t.TempDir()
+	root := filepath.Join(base, "pool")
+	outside := filepath.Join(base, "outside")
+	_ = os.MkdirAll(filepath.Join(root, "dir"), 0o755)
+	_ = os.MkdirAll(outside, 0o755)
+	_ = os.WriteFile(filepath.Join(root, "dir", "file.txt"), []byte("inner"), 0o644)
+	_ = os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644)
+	_ = os.Symlink(filepath.Join(root, "dir"), filepath.Join(root, "inner"))
+	_ = os.Symlink(outside, filepath.Join(root, "escape"))
+	_ = os.Symlink(filepath.Join(outside, "new.txt"), filepath.Join(root, "dangling"))
+
+	within := NewOsPathFs(root, SymlinkWithin)
+	_, err := afero.ReadFile(within, "/inner/file.txt")
+	assert.NoError(t, err, "within 策略应允许池内符号链接")
+	_, err = afero.ReadFile(within, "/escape/secret.txt")
+	assert.ErrorIs(t, err, os.ErrPermission, "within 策略应拒绝逃逸出池的符号链接")
+	err = afero.WriteFile(within, "/escape/new.txt", []byte("x"), 0o644)
+	assert.ErrorIs(t, err, os.ErrPermission, "within 策略应拒绝通过符号链接写入池外")
+	err = afero.WriteFile(within, "/dangling", []byte("x"), 0o644)
+	assert.ErrorIs(t, err, os.ErrPermission, "within 策略应拒绝通过悬空符号链接写入")
+	_, err = os.Stat(filepath.Join(outside, "new.txt"))
+	assert.True(t, os.IsNotExist(err), "池外不应创建文件")
+	_, _, err = within.LstatIfPossible("/escape")
+	assert.NoError(t, err, "应能查看符号链接本身")
+
+	deny := NewOsPathFs(root, SymlinkDeny)
+	_, err = afero.ReadFile(deny, "/inner/file.txt")
+	assert.ErrorIs(t, err, os.ErrPermission, "deny 策略应拒绝跟随符号链接")
+	_, err = afero.ReadFile(deny, "/dir/file.txt")
+	assert.NoError(t, err, "deny 策略不影响普通路径")
+	err = deny.SymlinkIfPossible("/dir", "/link")
+	assert.ErrorIs(t, err, os.ErrPermission, "deny 策略应拒绝创建符号链接")
+
+	anywhere := NewOsPathFs(root, SymlinkAnywhere)
+	data, err := afero.ReadFile(anywhere, "/escape/secret.txt")
+	assert.NoError(t, err, "anywhere 策略不限制符号链接")
+	assert.Equal(t, "secret", string(data))
+}
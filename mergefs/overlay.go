@@ -0,0 +1,534 @@
+package mergefs
+
+import (
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	// whiteoutPrefix 上层中以此为前缀的文件表示下层同名条目已被删除
+	whiteoutPrefix = ".wh."
+	// opaqueMarker 上层目录中存在此文件时，下层同名目录的内容全部不可见
+	opaqueMarker = whiteoutPrefix + whiteoutPrefix + ".opq"
+)
+
+// OverlayFs 写时复制的叠加文件系统：只读的下层与可写的上层合并展示，
+// 所有修改只发生在上层，删除下层条目时在上层写入 whiteout 标记。
+type OverlayFs struct {
+	lower afero.Fs
+	upper afero.Fs
+}
+
+// NewOverlayFs 创建 OverlayFs，lower 不会被修改
+func NewOverlayFs(lower, upper afero.Fs) *OverlayFs {
+	return &OverlayFs{lower: lower, upper: upper}
+}
+
+func (o *OverlayFs) Name() string {
+	return "OverlayFs"
+}
+
+func isWhiteoutName(name string) bool {
+	return strings.HasPrefix(path.Base(name), whiteoutPrefix)
+}
+
+func whiteoutPath(name string) string {
+	dir, base := path.Split(name)
+	return path.Join(dir, whiteoutPrefix+base)
+}
+
+func exists(fs afero.Fs, name string) bool {
+	_, err := fs.Stat(name)
+	return err == nil
+}
+
+// lowerHidden 判断下层的 name 是否被上层遮蔽（whiteout、opaque 目录或上层同名文件）
+func (o *OverlayFs) lowerHidden(name string) bool {
+	name = NormalizePath(name)
+	for p := name; p != "/"; p = path.Dir(p) {
+		if exists(o.upper, whiteoutPath(p)) {
+			return true
+		}
+		parent := path.Dir(p)
+		if exists(o.upper, path.Join(parent, opaqueMarker)) {
+			return true
+		}
+		if parent != "/" {
+			if info, err := o.upper.Stat(parent); err == nil && !info.IsDir() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inLower 判断 name 是否以下层条目的身份可见
+func (o *OverlayFs) inLower(name string) bool {
+	return exists(o.lower, name) && !o.lowerHidden(name)
+}
+
+func (o *OverlayFs) Stat(name string) (os.FileInfo, error) {
+	name = NormalizePath(name)
+	if isWhiteoutName(name) {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	if info, err := o.upper.Stat(name); err == nil {
+		return info, nil
+	}
+	if o.lowerHidden(name) {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return o.lower.Stat(name)
+}
+
+func (o *OverlayFs) Open(name string) (afero.File, error) {
+	return o.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (o *OverlayFs) Create(name string) (afero.File, error) {
+	return o.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (o *OverlayFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	name = NormalizePath(name)
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) == 0 {
+		return o.openRead(name)
+	}
+	if isWhiteoutName(name) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+	info, err := o.Stat(name)
+	switch {
+	case err == nil:
+		if flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+		}
+		if info.IsDir() {
+			return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+		}
+		if !exists(o.upper, name) {
+			if flag&os.O_TRUNC != 0 {
+				// 截断写入无需复制内容，只需保留权限
+				if err = o.copyUpDir(path.Dir(name)); err != nil {
+					return nil, err
+				}
+				flag |= os.O_CREATE
+				perm = info.Mode().Perm()
+			} else if err = o.copyUp(name); err != nil {
+				return nil, err
+			}
+		}
+	case os.IsNotExist(err):
+		if flag&os.O_CREATE == 0 {
+			return nil, err
+		}
+		if err = o.prepareCreate(name); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+	return o.upper.OpenFile(name, flag, perm)
+}
+
+// prepareCreate 在上层创建 name 之前确保父目录存在并清除 whiteout
+func (o *OverlayFs) prepareCreate(name string) error {
+	parent := path.Dir(name)
+	info, err := o.Stat(parent)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &os.PathError{Op: "open", Path: name, Err: syscall.ENOTDIR}
+	}
+	if err = o.copyUpDir(parent); err != nil {
+		return err
+	}
+	wh := whiteoutPath(name)
+	if exists(o.upper, wh) {
+		return o.upper.Remove(wh)
+	}
+	return nil
+}
+
+func (o *OverlayFs) openRead(name string) (afero.File, error) {
+	if isWhiteoutName(name) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	upperInfo, err := o.upper.Stat(name)
+	if err == nil {
+		file, err := o.upper.Open(name)
+		if err != nil || !upperInfo.IsDir() {
+			return file, err
+		}
+		merge := false
+		if !o.lowerHidden(name) && !exists(o.upper, path.Join(name, opaqueMarker)) {
+			if lowerInfo, err := o.lower.Stat(name); err == nil && lowerInfo.IsDir() {
+				merge = true
+			}
+		}
+		return &overlayDir{File: file, fs: o, path: name, merge: merge}, nil
+	}
+	if o.lowerHidden(name) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return o.lower.Open(name)
+}
+
+// copyUpDir 在上层中按下层的权限创建目录及其父目录
+func (o *OverlayFs) copyUpDir(dir string) error {
+	dir = NormalizePath(dir)
+	if dir == "/" {
+		return nil
+	}
+	if info, err := o.upper.Stat(dir); err == nil {
+		if !info.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: dir, Err: syscall.ENOTDIR}
+		}
+		return nil
+	}
+	if err := o.copyUpDir(path.Dir(dir)); err != nil {
+		return err
+	}
+	info, err := o.lower.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if err = o.upper.Mkdir(dir, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return o.upper.Chtimes(dir, info.ModTime(), info.ModTime())
+}
+
+// copyUp 将下层文件（或目录本身）复制到上层
+func (o *OverlayFs) copyUp(name string) error {
+	info, err := o.lower.Stat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return o.copyUpDir(name)
+	}
+	if err = o.copyUpDir(path.Dir(name)); err != nil {
+		return err
+	}
+	src, err := o.lower.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := o.upper.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		_ = o.upper.Remove(name)
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		_ = o.upper.Remove(name)
+		return err
+	}
+	return o.upper.Chtimes(name, info.ModTime(), info.ModTime())
+}
+
+// copyUpTree 将合并视图下的整个目录树复制到上层，并标记为 opaque
+func (o *OverlayFs) copyUpTree(name string) error {
+	info, err := o.Stat(name)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		if exists(o.upper, name) {
+			return nil
+		}
+		return o.copyUp(name)
+	}
+	if err = o.copyUpDir(name); err != nil {
+		return err
+	}
+	entries, err := o.readDir(name)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err = o.copyUpTree(path.Join(name, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return o.markOpaque(name)
+}
+
+func (o *OverlayFs) markOpaque(dir string) error {
+	f, err := o.upper.Create(path.Join(dir, opaqueMarker))
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (o *OverlayFs) writeWhiteout(name string) error {
+	if err := o.copyUpDir(path.Dir(name)); err != nil {
+		return err
+	}
+	f, err := o.upper.Create(whiteoutPath(name))
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// readDir 返回合并后的目录条目
+func (o *OverlayFs) readDir(name string) ([]os.FileInfo, error) {
+	f, err := o.openRead(NormalizePath(name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdir(-1)
+}
+
+func (o *OverlayFs) Mkdir(name string, perm os.FileMode) error {
+	name = NormalizePath(name)
+	if isWhiteoutName(name) {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrPermission}
+	}
+	if _, err := o.Stat(name); err == nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	hadWhiteout := exists(o.upper, whiteoutPath(name))
+	if err := o.prepareCreate(name); err != nil {
+		return err
+	}
+	if err := o.upper.Mkdir(name, perm); err != nil {
+		return err
+	}
+	if hadWhiteout {
+		// 下层同名条目已被删除，新目录不能显示其旧内容
+		return o.markOpaque(name)
+	}
+	return nil
+}
+
+func (o *OverlayFs) MkdirAll(p string, perm os.FileMode) error {
+	p = NormalizePath(p)
+	if p == "/" {
+		return nil
+	}
+	if info, err := o.Stat(p); err == nil {
+		if !info.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: p, Err: syscall.ENOTDIR}
+		}
+		return nil
+	}
+	if err := o.MkdirAll(path.Dir(p), perm); err != nil {
+		return err
+	}
+	return o.Mkdir(p, perm)
+}
+
+func (o *OverlayFs) Remove(name string) error {
+	name = NormalizePath(name)
+	info, err := o.Stat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		entries, err := o.readDir(name)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+		}
+	}
+	return o.remove(name)
+}
+
+func (o *OverlayFs) RemoveAll(name string) error {
+	name = NormalizePath(name)
+	if _, err := o.Stat(name); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return o.remove(name)
+}
+
+func (o *OverlayFs) remove(name string) error {
+	lower := o.inLower(name)
+	if exists(o.upper, name) {
+		if err := o.upper.RemoveAll(name); err != nil {
+			return err
+		}
+	}
+	if lower {
+		return o.writeWhiteout(name)
+	}
+	return nil
+}
+
+func (o *OverlayFs) Rename(oldname, newname string) error {
+	oldname = NormalizePath(oldname)
+	newname = NormalizePath(newname)
+	if isWhiteoutName(newname) {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrPermission}
+	}
+	info, err := o.Stat(oldname)
+	if err != nil {
+		return err
+	}
+	if err = o.copyUpTree(oldname); err != nil {
+		return err
+	}
+	newInLower := o.inLower(newname)
+	if err = o.prepareCreate(newname); err != nil {
+		return err
+	}
+	if newInLower && !exists(o.upper, newname) {
+		if newInfo, err := o.lower.Stat(newname); err == nil && newInfo.IsDir() != info.IsDir() {
+			return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrExist}
+		}
+	}
+	oldInLower := o.inLower(oldname)
+	if err = o.upper.Rename(oldname, newname); err != nil {
+		return err
+	}
+	if oldInLower {
+		return o.writeWhiteout(oldname)
+	}
+	return nil
+}
+
+// prepareModify 修改元数据前确保条目已复制到上层
+func (o *OverlayFs) prepareModify(name string) (string, error) {
+	name = NormalizePath(name)
+	if _, err := o.Stat(name); err != nil {
+		return name, err
+	}
+	if exists(o.upper, name) {
+		return name, nil
+	}
+	return name, o.copyUp(name)
+}
+
+func (o *OverlayFs) Chmod(name string, mode os.FileMode) error {
+	name, err := o.prepareModify(name)
+	if err != nil {
+		return err
+	}
+	return o.upper.Chmod(name, mode)
+}
+
+func (o *OverlayFs) Chown(name string, uid, gid int) error {
+	name, err := o.prepareModify(name)
+	if err != nil {
+		return err
+	}
+	return o.upper.Chown(name, uid, gid)
+}
+
+func (o *OverlayFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	name, err := o.prepareModify(name)
+	if err != nil {
+		return err
+	}
+	return o.upper.Chtimes(name, atime, mtime)
+}
+
+// overlayDir 上层目录的包装，Readdir 时合并下层条目并过滤 whiteout
+type overlayDir struct {
+	afero.File
+	fs      *OverlayFs
+	path    string
+	merge   bool
+	entries []os.FileInfo
+	loaded  bool
+	offset  int
+}
+
+func (d *overlayDir) load() error {
+	if d.loaded {
+		return nil
+	}
+	upperInfos, err := d.File.Readdir(-1)
+	if err != nil {
+		return err
+	}
+	entryMap := make(map[string]os.FileInfo)
+	hidden := make(map[string]bool)
+	for _, info := range upperInfos {
+		if strings.HasPrefix(info.Name(), whiteoutPrefix) {
+			hidden[strings.TrimPrefix(info.Name(), whiteoutPrefix)] = true
+			continue
+		}
+		entryMap[info.Name()] = info
+	}
+	if d.merge {
+		lowerInfos, err := afero.ReadDir(d.fs.lower, d.path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, info := range lowerInfos {
+			if hidden[info.Name()] {
+				continue
+			}
+			if _, ok := entryMap[info.Name()]; !ok {
+				entryMap[info.Name()] = info
+			}
+		}
+	}
+	d.entries = make([]os.FileInfo, 0, len(entryMap))
+	for _, info := range entryMap {
+		d.entries = append(d.entries, info)
+	}
+	sort.Slice(d.entries, func(i, j int) bool {
+		return d.entries[i].Name() < d.entries[j].Name()
+	})
+	d.loaded = true
+	return nil
+}
+
+func (d *overlayDir) Readdir(count int) ([]os.FileInfo, error) {
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	if d.offset >= len(d.entries) {
+		if count <= 0 {
+			return []os.FileInfo{}, nil
+		}
+		return nil, io.EOF
+	}
+	end := len(d.entries)
+	if count > 0 && d.offset+count < end {
+		end = d.offset + count
+	}
+	result := d.entries[d.offset:end]
+	d.offset = end
+	return result, nil
+}
+
+func (d *overlayDir) Readdirnames(count int) ([]string, error) {
+	infos, err := d.Readdir(count)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return names, err
+}
+
+func (d *overlayDir) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekStart && offset == 0 {
+		d.offset = 0
+	}
+	return d.File.Seek(offset, whence)
+}
@@ -0,0 +1,97 @@
+package mergefs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestOverlay(t *testing.T) (*OverlayFs, afero.Fs, afero.Fs) {
+	lower := afero.NewMemMapFs()
+	upper := afero.NewMemMapFs()
+	assert.NoError(t, lower.MkdirAll("/tpl/sub", 0o755))
+	assert.NoError(t, afero.WriteFile(lower, "/tpl/a.txt", []byte("lower-a"), 0o644))
+	assert.NoError(t, afero.WriteFile(lower, "/tpl/sub/b.txt", []byte("lower-b"), 0o644))
+	return NewOverlayFs(afero.NewReadOnlyFs(lower), upper), lower, upper
+}
+
+func readNames(t *testing.T, fs afero.Fs, dir string) []string {
+	infos, err := afero.ReadDir(fs, dir)
+	assert.NoError(t, err)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return names
+}
+
+func TestOverlayFs_ReadAndCopyOnWrite(t *testing.T) {
+	o, lower, upper := newTestOverlay(t)
+
+	data, err := afero.ReadFile(o, "/tpl/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "lower-a", string(data), "应读取下层内容")
+
+	// 修改文件只影响上层
+	f, err := o.OpenFile("/tpl/a.txt", os.O_WRONLY|os.O_APPEND, 0)
+	assert.NoError(t, err)
+	_, _ = f.Write([]byte("+upper"))
+	_ = f.Close()
+
+	data, _ = afero.ReadFile(o, "/tpl/a.txt")
+	assert.Equal(t, "lower-a+upper", string(data), "追加写入应基于下层内容")
+	data, _ = afero.ReadFile(lower, "/tpl/a.txt")
+	assert.Equal(t, "lower-a", string(data), "下层不应被修改")
+	assert.True(t, exists(upper, "/tpl/a.txt"), "文件应被复制到上层")
+
+	// 新建文件
+	assert.NoError(t, afero.WriteFile(o, "/tpl/c.txt", []byte("new"), 0o644))
+	assert.Equal(t, []string{"a.txt", "c.txt", "sub"}, readNames(t, o, "/tpl"), "目录应合并上下层条目")
+}
+
+func TestOverlayFs_Whiteout(t *testing.T) {
+	o, lower, _ := newTestOverlay(t)
+
+	assert.NoError(t, o.Remove("/tpl/a.txt"))
+	_, err := o.Stat("/tpl/a.txt")
+	assert.True(t, os.IsNotExist(err), "删除后应不可见")
+	assert.True(t, exists(lower, "/tpl/a.txt"), "下层文件应保留")
+	assert.Equal(t, []string{"sub"}, readNames(t, o, "/tpl"), "列表不应包含 whiteout")
+
+	// 非空目录无法 Remove
+	assert.Error(t, o.Remove("/tpl/sub"))
+	assert.NoError(t, o.RemoveAll("/tpl/sub"))
+	_, err = o.Stat("/tpl/sub/b.txt")
+	assert.True(t, os.IsNotExist(err), "目录删除后子条目应不可见")
+
+	// 重新创建同名目录时不应出现旧内容
+	assert.NoError(t, o.Mkdir("/tpl/sub", 0o755))
+	assert.Empty(t, readNames(t, o, "/tpl/sub"), "重建的目录应为空")
+
+	// 重新创建被删除的文件
+	assert.NoError(t, afero.WriteFile(o, "/tpl/a.txt", []byte("again"), 0o644))
+	data, _ := afero.ReadFile(o, "/tpl/a.txt")
+	assert.Equal(t, "again", string(data))
+
+	// whiteout 名称不可直接访问
+	_, err = o.Create("/tpl/.wh.x")
+	assert.Error(t, err)
+}
+
+func TestOverlayFs_Rename(t *testing.T) {
+	o, lower, _ := newTestOverlay(t)
+
+	assert.NoError(t, o.Rename("/tpl/sub", "/tpl/moved"))
+	_, err := o.Stat("/tpl/sub")
+	assert.True(t, os.IsNotExist(err), "原目录应不可见")
+	data, err := afero.ReadFile(o, "/tpl/moved/b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "lower-b", string(data), "内容应随目录移动")
+	assert.True(t, exists(lower, "/tpl/sub/b.txt"), "下层不应被修改")
+
+	assert.NoError(t, o.Rename("/tpl/a.txt", "/a.txt"))
+	assert.Equal(t, []string{"a.txt", "tpl"}, readNames(t, o, "/"))
+	assert.Equal(t, []string{"moved"}, readNames(t, o, "/tpl"))
+}
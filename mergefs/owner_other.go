@@ -0,0 +1,10 @@
+//go:build !unix
+
+package mergefs
+
+import "os"
+
+// FileOwner 当前平台不支持
+func FileOwner(_ os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
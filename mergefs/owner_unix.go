@@ -0,0 +1,17 @@
+//go:build unix
+
+package mergefs
+
+import (
+	"os"
+	"syscall"
+)
+
+// FileOwner 返回文件的属主，仅当底层为本地文件时可用
+func FileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(stat.Uid), int(stat.Gid), true
+}
@@ -0,0 +1,160 @@
+package mergefs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"github.com/spf13/afero"
+)
+
+// ErrNoProps 底层文件系统无法保存自定义属性
+var ErrNoProps = errors.New("custom properties not supported")
+
+// PropStore 支持为文件保存自定义属性的文件系统，用于 WebDAV 客户端设置的 dead property。
+// 本地存储池保存在扩展属性 (xattr) 中，随文件一起重命名、删除与进入回收站
+type PropStore interface {
+	// Props 返回文件的全部自定义属性，键为 "{命名空间}名称"，值为属性的 XML 内容
+	Props(name string) (map[string][]byte, error)
+	// PatchProps 设置 set 中的属性并删除 remove 中的属性
+	PatchProps(name string, set map[string][]byte, remove []string) error
+}
+
+// propsIfPossible 在底层文件系统支持时读取自定义属性
+func propsIfPossible(fs afero.Fs, name string) (map[string][]byte, error) {
+	if store, ok := fs.(PropStore); ok {
+		return store.Props(name)
+	}
+	return nil, &os.PathError{Op: "props", Path: name, Err: ErrNoProps}
+}
+
+// patchPropsIfPossible 在底层文件系统支持时修改自定义属性
+func patchPropsIfPossible(fs afero.Fs, name string, set map[string][]byte, remove []string) error {
+	if store, ok := fs.(PropStore); ok {
+		return store.PatchProps(name, set, remove)
+	}
+	return &os.PathError{Op: "proppatch", Path: name, Err: ErrNoProps}
+}
+
+// copyProps 尽力将 src 的自定义属性复制到 dst，用于跨文件系统移动与原子替换
+func copyProps(srcFs afero.Fs, src string, dstFs afero.Fs, dst string) {
+	props, err := propsIfPossible(srcFs, src)
+	if err != nil || len(props) == 0 {
+		return
+	}
+	_ = patchPropsIfPossible(dstFs, dst, props, nil)
+}
+
+func (a *AtomicFs) Props(name string) (map[string][]byte, error) {
+	return propsIfPossible(a.Fs, name)
+}
+
+func (a *AtomicFs) PatchProps(name string, set map[string][]byte, remove []string) error {
+	return patchPropsIfPossible(a.Fs, name, set, remove)
+}
+
+func (m *MinFreeFs) Props(name string) (map[string][]byte, error) {
+	return propsIfPossible(m.Fs, name)
+}
+
+func (m *MinFreeFs) PatchProps(name string, set map[string][]byte, remove []string) error {
+	return patchPropsIfPossible(m.Fs, name, set, remove)
+}
+
+func (m *MaxSizeFs) Props(name string) (map[string][]byte, error) {
+	return propsIfPossible(m.Fs, name)
+}
+
+func (m *MaxSizeFs) PatchProps(name string, set map[string][]byte, remove []string) error {
+	return patchPropsIfPossible(m.Fs, name, set, remove)
+}
+
+func (c *CacheFs) Props(name string) (map[string][]byte, error) {
+	return propsIfPossible(c.Fs, name)
+}
+
+func (c *CacheFs) PatchProps(name string, set map[string][]byte, remove []string) error {
+	return patchPropsIfPossible(c.Fs, name, set, remove)
+}
+
+func (o *OwnerFs) Props(name string) (map[string][]byte, error) {
+	return propsIfPossible(o.Fs, name)
+}
+
+func (o *OwnerFs) PatchProps(name string, set map[string][]byte, remove []string) error {
+	return patchPropsIfPossible(o.Fs, name, set, remove)
+}
+
+func (m *ModeFs) Props(name string) (map[string][]byte, error) {
+	return propsIfPossible(m.Fs, name)
+}
+
+func (m *ModeFs) PatchProps(name string, set map[string][]byte, remove []string) error {
+	return patchPropsIfPossible(m.Fs, name, set, remove)
+}
+
+func (s *SubFs) Props(name string) (map[string][]byte, error) {
+	props, err := propsIfPossible(s.fs, s.realPath(name))
+	return props, s.fixErr(err, name)
+}
+
+func (s *SubFs) PatchProps(name string, set map[string][]byte, remove []string) error {
+	return s.fixErr(patchPropsIfPossible(s.fs, s.realPath(name), set, remove), name)
+}
+
+func (h *HiddenFs) Props(name string) (map[string][]byte, error) {
+	if err := h.checkRead("props", name); err != nil {
+		return nil, err
+	}
+	return propsIfPossible(h.Fs, name)
+}
+
+func (h *HiddenFs) PatchProps(name string, set map[string][]byte, remove []string) error {
+	if err := h.checkWrite("proppatch", name); err != nil {
+		return err
+	}
+	return patchPropsIfPossible(h.Fs, name, set, remove)
+}
+
+func (r *reservedFs) Props(name string) (map[string][]byte, error) {
+	if err := r.checkRead("props", name); err != nil {
+		return nil, err
+	}
+	return propsIfPossible(r.Fs, name)
+}
+
+func (r *reservedFs) PatchProps(name string, set map[string][]byte, remove []string) error {
+	if err := r.checkWrite("proppatch", name); err != nil {
+		return err
+	}
+	return patchPropsIfPossible(r.Fs, name, set, remove)
+}
+
+func (m *MountFs) Props(name string) (map[string][]byte, error) {
+	mount, p := m.GetMount(name)
+	return propsIfPossible(mount, p)
+}
+
+func (m *MountFs) PatchProps(name string, set map[string][]byte, remove []string) error {
+	mount, p := m.GetMount(name)
+	return patchPropsIfPossible(mount, p, set, remove)
+}
+
+// ReadOnlyFs 与 afero.ReadOnlyFs 相同，但允许读取底层文件系统的自定义属性
+type ReadOnlyFs struct {
+	afero.Fs
+	source afero.Fs
+}
+
+// NewReadOnlyFs 创建 ReadOnlyFs
+func NewReadOnlyFs(fs afero.Fs) *ReadOnlyFs {
+	return &ReadOnlyFs{Fs: afero.NewReadOnlyFs(fs), source: fs}
+}
+
+func (r *ReadOnlyFs) Props(name string) (map[string][]byte, error) {
+	return propsIfPossible(r.source, name)
+}
+
+func (r *ReadOnlyFs) PatchProps(name string, _ map[string][]byte, _ []string) error {
+	return &os.PathError{Op: "proppatch", Path: name, Err: syscall.EPERM}
+}
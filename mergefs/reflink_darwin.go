@@ -0,0 +1,17 @@
+//go:build darwin
+
+package mergefs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflinkFile 通过 clonefile 创建与 src 共享数据块的 dst，需要 APFS
+func reflinkFile(src, dst string) error {
+	if err := unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW); err != nil {
+		return &os.LinkError{Op: "reflink", Old: src, New: dst, Err: err}
+	}
+	return nil
+}
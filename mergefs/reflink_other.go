@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package mergefs
+
+import "os"
+
+// reflinkFile 当前平台不支持 reflink
+func reflinkFile(src, dst string) error {
+	return &os.LinkError{Op: "reflink", Old: src, New: dst, Err: ErrNoReflink}
+}
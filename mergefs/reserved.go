@@ -0,0 +1,218 @@
+package mergefs
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// reservedFs 将根目录下的 dir 保留给内部使用，该目录对调用方不可见也不可修改，
+// 内部数据通过底层文件系统 Fs 直接访问
+type reservedFs struct {
+	afero.Fs
+	dir string
+}
+
+// reserved 判断路径是否位于保留目录内
+func (r *reservedFs) reserved(name string) bool {
+	name = NormalizePath(name)
+	return name == r.dir || strings.HasPrefix(name, r.dir+"/")
+}
+
+// checkRead 保留目录内的路径表现为不存在
+func (r *reservedFs) checkRead(op, name string) error {
+	if r.reserved(name) {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	return nil
+}
+
+// checkWrite 保留目录内的路径不可修改
+func (r *reservedFs) checkWrite(op, name string) error {
+	if r.reserved(name) {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+	}
+	return nil
+}
+
+func (r *reservedFs) lstat(name string) (os.FileInfo, error) {
+	if lstater, ok := r.Fs.(afero.Lstater); ok {
+		info, _, err := lstater.LstatIfPossible(name)
+		return info, err
+	}
+	return r.Fs.Stat(name)
+}
+
+func (r *reservedFs) Remove(name string) error {
+	if err := r.checkWrite("remove", name); err != nil {
+		return err
+	}
+	return r.Fs.Remove(name)
+}
+
+func (r *reservedFs) RemoveAll(name string) error {
+	if err := r.checkWrite("remove", name); err != nil {
+		return err
+	}
+	return r.Fs.RemoveAll(name)
+}
+
+func (r *reservedFs) Create(name string) (afero.File, error) {
+	if err := r.checkWrite("create", name); err != nil {
+		return nil, err
+	}
+	return r.Fs.Create(name)
+}
+
+func (r *reservedFs) Mkdir(name string, perm os.FileMode) error {
+	if err := r.checkWrite("mkdir", name); err != nil {
+		return err
+	}
+	return r.Fs.Mkdir(name, perm)
+}
+
+func (r *reservedFs) MkdirAll(name string, perm os.FileMode) error {
+	if err := r.checkWrite("mkdir", name); err != nil {
+		return err
+	}
+	return r.Fs.MkdirAll(name, perm)
+}
+
+func (r *reservedFs) Open(name string) (afero.File, error) {
+	return r.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (r *reservedFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	return r.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+func (r *reservedFs) OpenFileContext(ctx context.Context, name string, flag int, perm os.FileMode) (afero.File, error) {
+	var err error
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		err = r.checkWrite("open", name)
+	} else {
+		err = r.checkRead("open", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	file, err := openFileContext(ctx, r.Fs, name, flag, perm)
+	if err != nil || NormalizePath(name) != "/" {
+		return file, err
+	}
+	return &reservedRootFile{File: file, name: path.Base(r.dir)}, nil
+}
+
+func (r *reservedFs) Rename(oldname, newname string) error {
+	if err := r.checkWrite("rename", oldname); err != nil {
+		return err
+	}
+	if err := r.checkWrite("rename", newname); err != nil {
+		return err
+	}
+	return r.Fs.Rename(oldname, newname)
+}
+
+func (r *reservedFs) Stat(name string) (os.FileInfo, error) {
+	if err := r.checkRead("stat", name); err != nil {
+		return nil, err
+	}
+	return r.Fs.Stat(name)
+}
+
+func (r *reservedFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	if err := r.checkRead("lstat", name); err != nil {
+		return nil, false, err
+	}
+	if lstater, ok := r.Fs.(afero.Lstater); ok {
+		return lstater.LstatIfPossible(name)
+	}
+	info, err := r.Fs.Stat(name)
+	return info, false, err
+}
+
+func (r *reservedFs) Chmod(name string, mode os.FileMode) error {
+	if err := r.checkWrite("chmod", name); err != nil {
+		return err
+	}
+	return r.Fs.Chmod(name, mode)
+}
+
+func (r *reservedFs) Chown(name string, uid, gid int) error {
+	if err := r.checkWrite("chown", name); err != nil {
+		return err
+	}
+	return r.Fs.Chown(name, uid, gid)
+}
+
+func (r *reservedFs) Chtimes(name string, atime, mtime time.Time) error {
+	if err := r.checkWrite("chtimes", name); err != nil {
+		return err
+	}
+	return r.Fs.Chtimes(name, atime, mtime)
+}
+
+func (r *reservedFs) LinkIfPossible(oldname, newname string) error {
+	if err := r.checkWrite("link", oldname); err != nil {
+		return err
+	}
+	if err := r.checkWrite("link", newname); err != nil {
+		return err
+	}
+	return linkIfPossible(r.Fs, oldname, newname)
+}
+
+func (r *reservedFs) CopyFile(ctx context.Context, src, dst string) error {
+	if err := r.checkRead("copy", src); err != nil {
+		return err
+	}
+	if err := r.checkWrite("copy", dst); err != nil {
+		return err
+	}
+	return copyFileIfPossible(ctx, r.Fs, src, dst)
+}
+
+func (r *reservedFs) Name() string {
+	return "reservedFs"
+}
+
+// reservedRootFile 在根目录列表中隐藏保留目录
+type reservedRootFile struct {
+	afero.File
+	name string
+}
+
+func (f *reservedRootFile) Readdir(count int) ([]os.FileInfo, error) {
+	for {
+		infos, err := f.File.Readdir(count)
+		result := make([]os.FileInfo, 0, len(infos))
+		for _, info := range infos {
+			if info.Name() != f.name {
+				result = append(result, info)
+			}
+		}
+		// 本批次全部被过滤时继续读取，避免误报 EOF
+		if len(result) > 0 || err != nil || count <= 0 {
+			return result, err
+		}
+	}
+}
+
+func (f *reservedRootFile) Readdirnames(count int) ([]string, error) {
+	for {
+		names, err := f.File.Readdirnames(count)
+		result := make([]string, 0, len(names))
+		for _, name := range names {
+			if name != f.name {
+				result = append(result, name)
+			}
+		}
+		if len(result) > 0 || err != nil || count <= 0 {
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package mergefs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrNoReflink 文件系统或平台不支持 reflink
+var ErrNoReflink = errors.New("reflink not supported")
+
+// SnapshotOptions 快照选项
+type SnapshotOptions struct {
+	// 无法 reflink 时使用硬链接代替复制，原地修改（追加、随机写入）的文件会同时改变快照中的内容
+	Link bool
+	// 不纳入快照的根目录条目名，如回收站与历史版本目录
+	Skip []string
+}
+
+// SnapshotResult 快照中各方式创建的文件数量
+type SnapshotResult struct {
+	Reflinked int
+	Linked    int
+	Copied    int
+}
+
+// Snapshot 将本地目录 src 的当前内容保存到不存在的目录 dst，文件优先使用 reflink，
+// 其次按选项使用硬链接，否则复制；保留权限与修改时间，跳过上传中的临时文件与特殊文件
+func Snapshot(src, dst string, opts SnapshotOptions) (SnapshotResult, error) {
+	var result SnapshotResult
+	src = filepath.Clean(src)
+	type dirTime struct {
+		path  string
+		mtime time.Time
+	}
+	var dirs []dirTime
+	err := filepath.WalkDir(src, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if rel != "." && !strings.Contains(rel, string(filepath.Separator)) && slices.Contains(opts.Skip, rel) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(entry.Name(), atomicTempPrefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			// 保留写权限以便之后删除快照
+			if err := os.Mkdir(target, info.Mode().Perm()|0o700); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirTime{path: target, mtime: info.ModTime()})
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			if reflinkFile(p, target) == nil {
+				result.Reflinked++
+			} else if opts.Link && os.Link(p, target) == nil {
+				result.Linked++
+				return nil
+			} else if err := copyLocalFile(p, target, info.Mode().Perm()); err != nil {
+				return err
+			} else {
+				result.Copied++
+			}
+			return os.Chtimes(target, info.ModTime(), info.ModTime())
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Chtimes(dirs[i].path, dirs[i].mtime, dirs[i].mtime)
+	}
+	return result, nil
+}
+
+func copyLocalFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
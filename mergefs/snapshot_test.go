@@ -0,0 +1,34 @@
+package mergefs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	src := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(src, "docs"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "docs", "a.txt"), []byte("v1"), 0o644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(src, ".trash", "1"), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(src, atomicTempPrefix+"up"), []byte("tmp"), 0o644))
+
+	dst := filepath.Join(t.TempDir(), "snap")
+	result, err := Snapshot(src, dst, SnapshotOptions{Skip: []string{".trash"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Reflinked+result.Copied)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "docs", "a.txt"), []byte("v2"), 0o644))
+	data, err := os.ReadFile(filepath.Join(dst, "docs", "a.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", string(data), "快照不受之后修改的影响")
+	_, err = os.Stat(filepath.Join(dst, ".trash"))
+	assert.True(t, os.IsNotExist(err), "跳过回收站")
+	_, err = os.Stat(filepath.Join(dst, atomicTempPrefix+"up"))
+	assert.True(t, os.IsNotExist(err), "跳过上传中的临时文件")
+
+	_, err = Snapshot(src, dst, SnapshotOptions{})
+	assert.True(t, os.IsExist(err), "目标已存在")
+}
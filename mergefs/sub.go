@@ -0,0 +1,111 @@
+package mergefs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// SubFs 将底层文件系统的子目录作为根目录，用于绑定其他存储池的子目录。
+// 与 afero.BasePathFs 不同，SubFs 会传递 ctx 与硬链接等扩展接口
+type SubFs struct {
+	fs  afero.Fs
+	dir string
+}
+
+// NewSubFs 创建 SubFs，dir 为底层文件系统中的目录
+func NewSubFs(fs afero.Fs, dir string) *SubFs {
+	return &SubFs{fs: fs, dir: path.Clean("/" + filepath.ToSlash(dir))}
+}
+
+func (s *SubFs) realPath(name string) string {
+	return path.Join(s.dir, path.Clean("/"+filepath.ToSlash(name)))
+}
+
+// fixErr 将错误中的路径还原为调用方传入的路径，避免暴露底层目录
+func (s *SubFs) fixErr(err error, name string) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return &os.PathError{Op: pathErr.Op, Path: name, Err: pathErr.Err}
+	}
+	return err
+}
+
+func (s *SubFs) Create(name string) (afero.File, error) {
+	file, err := s.fs.Create(s.realPath(name))
+	return file, s.fixErr(err, name)
+}
+
+func (s *SubFs) Mkdir(name string, perm os.FileMode) error {
+	return s.fixErr(s.fs.Mkdir(s.realPath(name), perm), name)
+}
+
+func (s *SubFs) MkdirAll(name string, perm os.FileMode) error {
+	return s.fixErr(s.fs.MkdirAll(s.realPath(name), perm), name)
+}
+
+func (s *SubFs) Open(name string) (afero.File, error) {
+	file, err := s.fs.Open(s.realPath(name))
+	return file, s.fixErr(err, name)
+}
+
+func (s *SubFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	file, err := s.fs.OpenFile(s.realPath(name), flag, perm)
+	return file, s.fixErr(err, name)
+}
+
+func (s *SubFs) OpenFileContext(ctx context.Context, name string, flag int, perm os.FileMode) (afero.File, error) {
+	file, err := openFileContext(ctx, s.fs, s.realPath(name), flag, perm)
+	return file, s.fixErr(err, name)
+}
+
+func (s *SubFs) Remove(name string) error {
+	return s.fixErr(s.fs.Remove(s.realPath(name)), name)
+}
+
+func (s *SubFs) RemoveAll(name string) error {
+	return s.fixErr(s.fs.RemoveAll(s.realPath(name)), name)
+}
+
+func (s *SubFs) Rename(oldname, newname string) error {
+	err := s.fs.Rename(s.realPath(oldname), s.realPath(newname))
+	var linkErr *os.LinkError
+	if errors.As(err, &linkErr) {
+		return &os.LinkError{Op: linkErr.Op, Old: oldname, New: newname, Err: linkErr.Err}
+	}
+	return s.fixErr(err, oldname)
+}
+
+func (s *SubFs) Stat(name string) (os.FileInfo, error) {
+	info, err := s.fs.Stat(s.realPath(name))
+	return info, s.fixErr(err, name)
+}
+
+func (s *SubFs) Name() string {
+	return "SubFs"
+}
+
+func (s *SubFs) Chmod(name string, mode os.FileMode) error {
+	return s.fixErr(s.fs.Chmod(s.realPath(name), mode), name)
+}
+
+func (s *SubFs) Chown(name string, uid, gid int) error {
+	return s.fixErr(s.fs.Chown(s.realPath(name), uid, gid), name)
+}
+
+func (s *SubFs) Chtimes(name string, atime, mtime time.Time) error {
+	return s.fixErr(s.fs.Chtimes(s.realPath(name), atime, mtime), name)
+}
+
+func (s *SubFs) LinkIfPossible(oldname, newname string) error {
+	return linkIfPossible(s.fs, s.realPath(oldname), s.realPath(newname))
+}
+
+func (s *SubFs) CopyFile(ctx context.Context, src, dst string) error {
+	return copyFileIfPossible(ctx, s.fs, s.realPath(src), s.realPath(dst))
+}
@@ -0,0 +1,31 @@
+package mergefs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubFs(t *testing.T) {
+	base := afero.NewMemMapFs()
+	assert.NoError(t, base.MkdirAll("/media/incoming", 0o755))
+	assert.NoError(t, afero.WriteFile(base, "/media/secret.txt", []byte("secret"), 0o644))
+
+	sub := NewSubFs(base, "/media/incoming")
+	assert.NoError(t, afero.WriteFile(sub, "/new.txt", []byte("data"), 0o644))
+	data, err := afero.ReadFile(base, "/media/incoming/new.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+
+	_, err = sub.Stat("/../secret.txt")
+	assert.True(t, os.IsNotExist(err), "不能访问子目录之外的文件")
+	var pathErr *os.PathError
+	assert.ErrorAs(t, err, &pathErr)
+	assert.Equal(t, "/../secret.txt", pathErr.Path, "错误中不应暴露底层路径")
+
+	assert.NoError(t, sub.Rename("/new.txt", "/renamed.txt"))
+	_, err = base.Stat("/media/incoming/renamed.txt")
+	assert.NoError(t, err)
+}
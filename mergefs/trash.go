@@ -0,0 +1,235 @@
+package mergefs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// TrashDir 回收站在存储池根目录下的位置
+const TrashDir = "/.trash"
+
+const (
+	trashInfoName = "info.json"
+	trashDataName = "data"
+)
+
+// TrashEntry 回收站中的条目
+type TrashEntry struct {
+	ID string `json:"-"`
+	// 删除前的路径
+	Path      string    `json:"path"`
+	DeletedAt time.Time `json:"deleted_at"`
+	IsDir     bool      `json:"is_dir"`
+	// 文件大小，目录为 0
+	Size int64 `json:"size,omitempty"`
+}
+
+// TrashFs 将删除的文件与非空目录移动到根目录下隐藏的 .trash 中，
+// 每个条目记录原路径与删除时间，可恢复或按时间清理。.trash 对调用方不可见也不可修改
+type TrashFs struct {
+	reservedFs
+	seq atomic.Uint64
+	now func() time.Time
+}
+
+// NewTrashFs 创建 TrashFs
+func NewTrashFs(fs afero.Fs) *TrashFs {
+	return &TrashFs{reservedFs: reservedFs{Fs: fs, dir: TrashDir}, now: time.Now}
+}
+
+// inTrash 判断路径是否位于回收站内
+func inTrash(name string) bool {
+	name = NormalizePath(name)
+	return name == TrashDir || strings.HasPrefix(name, TrashDir+"/")
+}
+
+// bypass 判断删除是否无需进入回收站，如上传中途放弃的临时文件
+func (t *TrashFs) bypass(name string) bool {
+	return strings.HasPrefix(path.Base(NormalizePath(name)), atomicTempPrefix)
+}
+
+func (t *TrashFs) Remove(name string) error {
+	info, err := t.lstat(name)
+	// 目录仅在为空时才能删除，无需进入回收站
+	if t.reserved(name) || err != nil || info.IsDir() || t.bypass(name) {
+		return t.reservedFs.Remove(name)
+	}
+	return t.moveToTrash(name, info)
+}
+
+func (t *TrashFs) RemoveAll(name string) error {
+	if NormalizePath(name) == "/" {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrPermission}
+	}
+	info, err := t.lstat(name)
+	if t.reserved(name) || err != nil || t.bypass(name) {
+		return t.reservedFs.RemoveAll(name)
+	}
+	if info.IsDir() {
+		if names, err := readDirNames(t.Fs, name); err == nil && len(names) == 0 {
+			return t.Fs.Remove(name)
+		}
+	}
+	return t.moveToTrash(name, info)
+}
+
+func readDirNames(fs afero.Fs, name string) ([]string, error) {
+	dir, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+	return dir.Readdirnames(-1)
+}
+
+// newID 生成按删除时间排序的条目 ID
+func (t *TrashFs) newID(now time.Time) string {
+	return fmt.Sprintf("%s-%d", now.UTC().Format("20060102-150405.000000000"), t.seq.Add(1))
+}
+
+// moveToTrash 先写入条目信息再移动数据，移动失败时清理条目
+func (t *TrashFs) moveToTrash(name string, info os.FileInfo) error {
+	now := t.now()
+	entry := TrashEntry{
+		Path:      NormalizePath(name),
+		DeletedAt: now,
+		IsDir:     info.IsDir(),
+	}
+	if !info.IsDir() {
+		entry.Size = info.Size()
+	}
+	dir := path.Join(TrashDir, t.newID(now))
+	if err := t.Fs.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err = afero.WriteFile(t.Fs, path.Join(dir, trashInfoName), data, 0o600); err == nil {
+		err = t.Fs.Rename(name, path.Join(dir, trashDataName))
+	}
+	if err != nil {
+		_ = t.Fs.RemoveAll(dir)
+		var linkErr *os.LinkError
+		if errors.As(err, &linkErr) {
+			return &os.PathError{Op: "remove", Path: name, Err: linkErr.Err}
+		}
+		return err
+	}
+	return nil
+}
+
+// validID 校验条目 ID，避免访问回收站以外的路径
+func validID(id string) bool {
+	return id != "" && id != "." && id != ".." && !strings.ContainsAny(id, `/\`)
+}
+
+func (t *TrashFs) readEntry(id string) (TrashEntry, error) {
+	var entry TrashEntry
+	if !validID(id) {
+		return entry, &os.PathError{Op: "trash", Path: id, Err: os.ErrNotExist}
+	}
+	data, err := afero.ReadFile(t.Fs, path.Join(TrashDir, id, trashInfoName))
+	if err != nil {
+		return entry, &os.PathError{Op: "trash", Path: id, Err: os.ErrNotExist}
+	}
+	if err = json.Unmarshal(data, &entry); err != nil {
+		return entry, fmt.Errorf("invalid trash entry %s: %w", id, err)
+	}
+	entry.ID = id
+	return entry, nil
+}
+
+// List 返回回收站中的条目，最近删除的在前
+func (t *TrashFs) List() ([]TrashEntry, error) {
+	ids, err := readDirNames(t.Fs, TrashDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	result := make([]TrashEntry, 0, len(ids))
+	for _, id := range ids {
+		entry, err := t.readEntry(id)
+		if err != nil {
+			continue
+		}
+		if _, err := t.lstat(path.Join(TrashDir, id, trashDataName)); err != nil {
+			continue
+		}
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].DeletedAt.After(result[j].DeletedAt)
+	})
+	return result, nil
+}
+
+// Restore 将条目恢复到原路径，原路径已存在时返回 os.ErrExist
+func (t *TrashFs) Restore(id string) (TrashEntry, error) {
+	entry, err := t.readEntry(id)
+	if err != nil {
+		return entry, err
+	}
+	if _, err := t.lstat(entry.Path); err == nil {
+		return entry, &os.PathError{Op: "restore", Path: entry.Path, Err: os.ErrExist}
+	}
+	if err := t.Fs.MkdirAll(path.Dir(entry.Path), 0o755); err != nil {
+		return entry, err
+	}
+	if err := t.Fs.Rename(path.Join(TrashDir, id, trashDataName), entry.Path); err != nil {
+		return entry, err
+	}
+	return entry, t.Fs.RemoveAll(path.Join(TrashDir, id))
+}
+
+// Delete 永久删除回收站中的条目
+func (t *TrashFs) Delete(id string) error {
+	if _, err := t.readEntry(id); err != nil {
+		return err
+	}
+	return t.Fs.RemoveAll(path.Join(TrashDir, id))
+}
+
+// Purge 永久删除在 before 之前进入回收站的条目，以及信息损坏的条目，返回删除的数量
+func (t *TrashFs) Purge(before time.Time) (int, error) {
+	ids, err := readDirNames(t.Fs, TrashDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	count := 0
+	for _, id := range ids {
+		deletedAt := time.Time{}
+		if entry, err := t.readEntry(id); err == nil {
+			deletedAt = entry.DeletedAt
+		} else if info, err := t.Fs.Stat(path.Join(TrashDir, id)); err == nil {
+			deletedAt = info.ModTime()
+		}
+		if !deletedAt.Before(before) {
+			continue
+		}
+		if err := t.Fs.RemoveAll(path.Join(TrashDir, id)); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (t *TrashFs) Name() string {
+	return "TrashFs"
+}
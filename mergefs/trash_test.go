@@ -0,0 +1,67 @@
+package mergefs
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrashFs(t *testing.T) {
+	base := afero.NewMemMapFs()
+	trash := NewTrashFs(base)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	trash.now = func() time.Time { return now }
+
+	assert.NoError(t, trash.MkdirAll("/docs/empty", 0o755))
+	assert.NoError(t, afero.WriteFile(trash, "/docs/a.txt", []byte("hello"), 0o644))
+	assert.NoError(t, afero.WriteFile(trash, "/docs/b.txt", []byte("world"), 0o644))
+	assert.NoError(t, trash.Remove("/docs/a.txt"))
+	_, err := trash.Stat("/docs/a.txt")
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, trash.RemoveAll("/docs/empty"))
+	now = now.Add(time.Hour)
+	assert.NoError(t, trash.RemoveAll("/docs"))
+
+	entries, err := trash.List()
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2, "空目录不进入回收站") {
+		assert.Equal(t, "/docs", entries[0].Path)
+		assert.True(t, entries[0].IsDir)
+		assert.Equal(t, "/docs/a.txt", entries[1].Path)
+		assert.Equal(t, int64(5), entries[1].Size)
+	}
+
+	names, err := afero.ReadDir(trash, "/")
+	assert.NoError(t, err)
+	assert.Empty(t, names, "回收站在根目录中不可见")
+	_, err = trash.Stat(TrashDir)
+	assert.True(t, os.IsNotExist(err))
+	assert.True(t, os.IsPermission(trash.RemoveAll(TrashDir)), "回收站不可被删除")
+
+	_, err = trash.Restore(entries[1].ID)
+	assert.NoError(t, err)
+	data, err := afero.ReadFile(trash, "/docs/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	_, err = trash.Restore(entries[0].ID)
+	assert.True(t, os.IsExist(err), "原路径已存在时不能恢复")
+	_, err = trash.Restore("../docs")
+	assert.True(t, os.IsNotExist(err))
+
+	count, err := trash.Purge(now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	entries, err = trash.List()
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	assert.NoError(t, afero.WriteFile(trash, "/.~atomic-1", []byte("tmp"), 0o644))
+	assert.NoError(t, trash.Remove("/.~atomic-1"))
+	entries, _ = trash.List()
+	assert.Empty(t, entries, "上传临时文件直接删除")
+}
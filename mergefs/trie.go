@@ -0,0 +1,127 @@
+package mergefs
+
+import "strings"
+
+// mountTrie 按路径分段组织挂载点，查找复杂度与路径深度相关而与挂载点数量无关
+type mountTrie struct {
+	root *mountNode
+}
+
+type mountNode struct {
+	children map[string]*mountNode
+	mount    *Mount // 当前节点为挂载点时非空
+	count    int    // 子树中（不含自身）的挂载点数量
+}
+
+func newMountTrie() *mountTrie {
+	return &mountTrie{root: &mountNode{}}
+}
+
+// splitPath 将规范化路径拆分为路径段，根目录返回空切片
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// insert 添加挂载点，已存在时返回 false
+func (t *mountTrie) insert(mount Mount) bool {
+	if existing := t.find(mount.Prefix); existing != nil && existing.mount != nil {
+		return false
+	}
+	node := t.root
+	for _, part := range splitPath(mount.Prefix) {
+		node.count++
+		child, ok := node.children[part]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[string]*mountNode)
+			}
+			child = &mountNode{}
+			node.children[part] = child
+		}
+		node = child
+	}
+	node.mount = &mount
+	return true
+}
+
+// remove 删除挂载点，并清理不再需要的节点
+func (t *mountTrie) remove(prefix string) bool {
+	parts := splitPath(prefix)
+	nodes := make([]*mountNode, 0, len(parts)+1)
+	node := t.root
+	nodes = append(nodes, node)
+	for _, part := range parts {
+		node = node.children[part]
+		if node == nil {
+			return false
+		}
+		nodes = append(nodes, node)
+	}
+	if node.mount == nil {
+		return false
+	}
+	node.mount = nil
+	for i := len(parts) - 1; i >= 0; i-- {
+		parent := nodes[i]
+		parent.count--
+		child := nodes[i+1]
+		if child.mount == nil && child.count == 0 {
+			delete(parent.children, parts[i])
+		}
+	}
+	return true
+}
+
+// find 返回路径对应的节点，不存在时返回 nil
+func (t *mountTrie) find(p string) *mountNode {
+	node := t.root
+	for _, part := range splitPath(p) {
+		node = node.children[part]
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// longest 返回覆盖该路径的最深挂载点
+func (t *mountTrie) longest(p string) *Mount {
+	var result *Mount
+	node := t.root
+	for _, part := range splitPath(p) {
+		node = node.children[part]
+		if node == nil {
+			break
+		}
+		if node.mount != nil {
+			result = node.mount
+		}
+	}
+	return result
+}
+
+// under 返回路径下（不含自身）的所有挂载点
+func (t *mountTrie) under(p string) []Mount {
+	node := t.find(p)
+	if node == nil || node.count == 0 {
+		return nil
+	}
+	result := make([]Mount, 0, node.count)
+	var walk func(n *mountNode)
+	walk = func(n *mountNode) {
+		for _, child := range n.children {
+			if child.mount != nil {
+				result = append(result, *child.mount)
+			}
+			if child.count > 0 {
+				walk(child)
+			}
+		}
+	}
+	walk(node)
+	return result
+}
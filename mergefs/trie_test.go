@@ -0,0 +1,39 @@
+package mergefs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMountTrie(t *testing.T) {
+	tree := newMountTrie()
+	a, b := afero.NewMemMapFs(), afero.NewMemMapFs()
+	assert.True(t, tree.insert(Mount{Prefix: "/a", Fs: a}))
+	assert.True(t, tree.insert(Mount{Prefix: "/a/b/c", Fs: b}))
+	assert.False(t, tree.insert(Mount{Prefix: "/a", Fs: b}), "重复的挂载点应被拒绝")
+
+	assert.Equal(t, "/a/b/c", tree.longest("/a/b/c/d").Prefix, "应返回最深的挂载点")
+	assert.Equal(t, "/a", tree.longest("/a/b").Prefix)
+	assert.Nil(t, tree.longest("/ab"), "不应匹配部分路径段")
+	assert.Equal(t, 1, tree.find("/a").count)
+	assert.Len(t, tree.under("/"), 2)
+
+	assert.True(t, tree.remove("/a/b/c"))
+	assert.False(t, tree.remove("/a/b/c"))
+	assert.Nil(t, tree.find("/a/b"), "删除后应清理空节点")
+	assert.Equal(t, 0, tree.find("/a").count)
+}
+
+func BenchmarkMountFs_GetMount(b *testing.B) {
+	mountFs := NewMountFs(afero.NewMemMapFs())
+	for i := range 100 {
+		_ = mountFs.Mount(fmt.Sprintf("/pool%03d", i), afero.NewMemMapFs())
+	}
+	b.ResetTimer()
+	for b.Loop() {
+		mountFs.GetMount("/pool050/a/b/c/d/e/f/file.txt")
+	}
+}
@@ -0,0 +1,338 @@
+package mergefs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// VersionDir 历史版本在存储池根目录下的位置，按文件路径分目录存放
+const VersionDir = "/.versions"
+
+// versionTimeLayout 版本 ID 中的时间格式
+const versionTimeLayout = "20060102-150405.000000000"
+
+// VersionOptions 历史版本保留策略
+type VersionOptions struct {
+	// 每个文件保留的历史版本数量，为 0 时不限制
+	Keep int
+	// 历史版本的保留时间，为 0 时不限制
+	MaxAge time.Duration
+}
+
+// FileVersion 文件的历史版本
+type FileVersion struct {
+	ID string `json:"id"`
+	// 被覆盖或删除的时间
+	SavedAt time.Time `json:"saved_at"`
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+}
+
+// VersionFs 在文件被覆盖或删除前将旧内容移动到根目录下隐藏的 .versions 中，
+// 按 VersionOptions 保留，可列出并恢复。回收站中的路径与空文件不保留版本
+type VersionFs struct {
+	reservedFs
+	opts VersionOptions
+	seq  atomic.Uint64
+	now  func() time.Time
+}
+
+// NewVersionFs 创建 VersionFs
+func NewVersionFs(fs afero.Fs, opts VersionOptions) *VersionFs {
+	return &VersionFs{
+		reservedFs: reservedFs{Fs: fs, dir: VersionDir},
+		opts:       opts,
+		now:        time.Now,
+	}
+}
+
+func versionDir(name string) string {
+	return path.Join(VersionDir, NormalizePath(name))
+}
+
+// versioned 返回需要保留版本的现有文件，不需要时返回 nil
+func (v *VersionFs) versioned(name string) os.FileInfo {
+	if v.reserved(name) || inTrash(name) || strings.HasPrefix(path.Base(NormalizePath(name)), atomicTempPrefix) {
+		return nil
+	}
+	info, err := v.lstat(name)
+	if err != nil || !info.Mode().IsRegular() || info.Size() == 0 {
+		return nil
+	}
+	return info
+}
+
+// save 将文件移动为历史版本，返回版本的存放路径
+func (v *VersionFs) save(name string) (string, error) {
+	dir := versionDir(name)
+	if err := v.Fs.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	target := path.Join(dir, fmt.Sprintf("%s-%d", v.now().UTC().Format(versionTimeLayout), v.seq.Add(1)))
+	if err := v.Fs.Rename(name, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// saveAndPrune 保存历史版本并清理超出保留策略的旧版本
+func (v *VersionFs) saveAndPrune(name string) (string, error) {
+	target, err := v.save(name)
+	if err == nil {
+		v.prune(path.Dir(target), v.now())
+	}
+	return target, err
+}
+
+func (v *VersionFs) Create(name string) (afero.File, error) {
+	return v.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (v *VersionFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	return v.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+func (v *VersionFs) OpenFileContext(ctx context.Context, name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&os.O_TRUNC != 0 && flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		if info := v.versioned(name); info != nil {
+			if _, err := v.saveAndPrune(name); err != nil {
+				return nil, err
+			}
+			// 旧文件已移走，以原有权限重新创建
+			flag |= os.O_CREATE
+			perm = info.Mode().Perm()
+		}
+	}
+	return v.reservedFs.OpenFileContext(ctx, name, flag, perm)
+}
+
+func (v *VersionFs) Rename(oldname, newname string) error {
+	if err := v.checkWrite("rename", oldname); err != nil {
+		return err
+	}
+	if err := v.checkWrite("rename", newname); err != nil {
+		return err
+	}
+	var saved string
+	if NormalizePath(oldname) != NormalizePath(newname) && v.versioned(newname) != nil {
+		var err error
+		if saved, err = v.save(newname); err != nil {
+			return err
+		}
+	}
+	if err := v.Fs.Rename(oldname, newname); err != nil {
+		if saved != "" {
+			_ = v.Fs.Rename(saved, newname)
+		}
+		return err
+	}
+	if saved != "" {
+		v.prune(path.Dir(saved), v.now())
+	}
+	return nil
+}
+
+// CopyFile 覆盖已有文件前同样保存历史版本
+func (v *VersionFs) CopyFile(ctx context.Context, src, dst string) error {
+	if err := v.checkRead("copy", src); err != nil {
+		return err
+	}
+	if err := v.checkWrite("copy", dst); err != nil {
+		return err
+	}
+	var saved string
+	if NormalizePath(src) != NormalizePath(dst) && v.versioned(dst) != nil {
+		var err error
+		if saved, err = v.save(dst); err != nil {
+			return err
+		}
+	}
+	if err := copyFileIfPossible(ctx, v.Fs, src, dst); err != nil {
+		if saved != "" {
+			_ = v.Fs.Rename(saved, dst)
+		}
+		return err
+	}
+	if saved != "" {
+		v.prune(path.Dir(saved), v.now())
+	}
+	return nil
+}
+
+func (v *VersionFs) Remove(name string) error {
+	if v.versioned(name) == nil {
+		return v.reservedFs.Remove(name)
+	}
+	_, err := v.saveAndPrune(name)
+	return err
+}
+
+func (v *VersionFs) RemoveAll(name string) error {
+	if err := v.checkWrite("remove", name); err != nil {
+		return err
+	}
+	if NormalizePath(name) == "/" {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrPermission}
+	}
+	info, err := v.lstat(name)
+	if err != nil || inTrash(name) {
+		return v.Fs.RemoveAll(name)
+	}
+	if !info.IsDir() {
+		return v.Remove(name)
+	}
+	var files []string
+	err = afero.Walk(v.Fs, name, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && v.reserved(p) {
+			return filepath.SkipDir
+		}
+		if v.versioned(p) != nil {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if _, err := v.saveAndPrune(file); err != nil {
+			return err
+		}
+	}
+	return v.Fs.RemoveAll(name)
+}
+
+// List 返回文件的历史版本，最新的在前
+func (v *VersionFs) List(name string) ([]FileVersion, error) {
+	infos, err := afero.ReadDir(v.Fs, versionDir(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	result := make([]FileVersion, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		idx := strings.LastIndex(info.Name(), "-")
+		if idx < 0 {
+			continue
+		}
+		savedAt, err := time.Parse(versionTimeLayout, info.Name()[:idx])
+		if err != nil {
+			continue
+		}
+		result = append(result, FileVersion{
+			ID:      info.Name(),
+			SavedAt: savedAt,
+			ModTime: info.ModTime(),
+			Size:    info.Size(),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].SavedAt.Equal(result[j].SavedAt) {
+			return result[i].ID > result[j].ID
+		}
+		return result[i].SavedAt.After(result[j].SavedAt)
+	})
+	return result, nil
+}
+
+// Restore 将历史版本恢复为当前文件，当前文件会先保存为新的历史版本
+func (v *VersionFs) Restore(name, id string) error {
+	if err := v.checkWrite("restore", name); err != nil {
+		return err
+	}
+	if !validID(id) {
+		return &os.PathError{Op: "restore", Path: id, Err: os.ErrNotExist}
+	}
+	source := path.Join(versionDir(name), id)
+	if _, err := v.lstat(source); err != nil {
+		return &os.PathError{Op: "restore", Path: id, Err: os.ErrNotExist}
+	}
+	if info, err := v.lstat(name); err == nil {
+		if info.IsDir() {
+			return &os.PathError{Op: "restore", Path: name, Err: os.ErrExist}
+		}
+		if v.versioned(name) != nil {
+			if _, err := v.save(name); err != nil {
+				return err
+			}
+		}
+	}
+	if err := v.Fs.MkdirAll(path.Dir(NormalizePath(name)), 0o755); err != nil {
+		return err
+	}
+	if err := v.Fs.Rename(source, name); err != nil {
+		return err
+	}
+	v.prune(versionDir(name), v.now())
+	return nil
+}
+
+// prune 按保留策略清理目录中的历史版本，返回删除的数量
+func (v *VersionFs) prune(dir string, now time.Time) int {
+	versions, err := v.List(strings.TrimPrefix(dir, VersionDir))
+	if err != nil {
+		return 0
+	}
+	count := 0
+	for i, version := range versions {
+		if (v.opts.Keep > 0 && i >= v.opts.Keep) || (v.opts.MaxAge > 0 && now.Sub(version.SavedAt) > v.opts.MaxAge) {
+			if v.Fs.Remove(path.Join(dir, version.ID)) == nil {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// Prune 按保留策略清理所有文件的历史版本，并删除不再包含版本的目录，返回删除的版本数量
+func (v *VersionFs) Prune() (int, error) {
+	var dirs []string
+	err := afero.Walk(v.Fs, VersionDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	now := v.now()
+	count := 0
+	for i := len(dirs) - 1; i >= 0; i-- {
+		count += v.prune(dirs[i], now)
+		if dirs[i] != VersionDir {
+			// 仅删除空目录
+			if names, err := readDirNames(v.Fs, dirs[i]); err == nil && len(names) == 0 {
+				_ = v.Fs.Remove(dirs[i])
+			}
+		}
+	}
+	return count, nil
+}
+
+func (v *VersionFs) Name() string {
+	return "VersionFs"
+}
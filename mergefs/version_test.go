@@ -0,0 +1,60 @@
+package mergefs
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionFs(t *testing.T) {
+	base := afero.NewMemMapFs()
+	versions := NewVersionFs(base, VersionOptions{Keep: 2})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	versions.now = func() time.Time { return now }
+
+	for _, content := range []string{"v1", "v2", "v3", "v4"} {
+		assert.NoError(t, afero.WriteFile(versions, "/doc.txt", []byte(content), 0o644))
+		now = now.Add(time.Minute)
+	}
+	list, err := versions.List("/doc.txt")
+	assert.NoError(t, err)
+	assert.Len(t, list, 2, "只保留最近的 2 个版本")
+
+	assert.NoError(t, versions.Restore("/doc.txt", list[1].ID))
+	data, err := afero.ReadFile(versions, "/doc.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", string(data))
+	list, _ = versions.List("/doc.txt")
+	assert.Len(t, list, 2)
+	assert.Equal(t, int64(2), list[0].Size, "恢复前的内容保存为新版本")
+
+	// 原子写入通过重命名覆盖目标文件
+	assert.NoError(t, afero.WriteFile(versions, "/.~atomic-doc", []byte("v5"), 0o644))
+	assert.NoError(t, versions.Rename("/.~atomic-doc", "/doc.txt"))
+	assert.NoError(t, versions.Remove("/doc.txt"))
+	_, err = versions.Stat("/doc.txt")
+	assert.True(t, os.IsNotExist(err))
+	list, _ = versions.List("/doc.txt")
+	if assert.Len(t, list, 2) {
+		assert.NoError(t, versions.Restore("/doc.txt", list[0].ID))
+	}
+	data, _ = afero.ReadFile(versions, "/doc.txt")
+	assert.Equal(t, "v5", string(data), "可恢复被删除的文件")
+
+	names, err := afero.ReadDir(versions, "/")
+	assert.NoError(t, err)
+	assert.Len(t, names, 1, "版本目录在根目录中不可见")
+	_, err = versions.Stat(VersionDir)
+	assert.True(t, os.IsNotExist(err))
+
+	versions.opts = VersionOptions{MaxAge: time.Hour}
+	now = now.Add(2 * time.Hour)
+	count, err := versions.Prune()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	_, err = base.Stat(VersionDir + "/doc.txt")
+	assert.True(t, os.IsNotExist(err), "清理后删除空目录")
+}
@@ -0,0 +1,89 @@
+package mergefs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// WalkDir 与 fs.WalkDir 语义相同地遍历 root 下的目录树，跨越挂载点边界，
+// 并包含只存在于挂载路径中的虚拟目录。条目按名称排序，符号链接不会被跟随
+func (m *MountFs) WalkDir(root string, fn fs.WalkDirFunc) error {
+	root = NormalizePath(root)
+	info, err := m.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = m.walkDir(root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if errors.Is(err, fs.SkipDir) || errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// Walk 与 filepath.Walk 语义相同，基于 WalkDir 实现
+func (m *MountFs) Walk(root string, fn filepath.WalkFunc) error {
+	return m.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		var info os.FileInfo
+		if entry != nil {
+			var infoErr error
+			if info, infoErr = entry.Info(); infoErr != nil && err == nil {
+				return fn(name, nil, infoErr)
+			}
+		}
+		return fn(name, info, err)
+	})
+}
+
+func (m *MountFs) walkDir(name string, entry fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(name, entry, nil); err != nil || !entry.IsDir() {
+		if errors.Is(err, fs.SkipDir) && entry.IsDir() {
+			err = nil
+		}
+		return err
+	}
+	entries, err := m.readDir(name)
+	if err != nil {
+		// 与 fs.WalkDir 相同，读取目录失败时再次调用 fn 报告错误
+		if err = fn(name, entry, err); err != nil {
+			if errors.Is(err, fs.SkipDir) {
+				err = nil
+			}
+			return err
+		}
+	}
+	for _, child := range entries {
+		if err := m.walkDir(path.Join(name, child.Name()), child, fn); err != nil {
+			if errors.Is(err, fs.SkipDir) {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// readDir 读取目录中按名称排序的条目，包含挂载点与虚拟目录
+func (m *MountFs) readDir(name string) ([]fs.DirEntry, error) {
+	dir, err := m.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+	infos, err := dir.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		if entry, ok := info.(fs.DirEntry); ok {
+			entries[i] = entry
+		} else {
+			entries[i] = fs.FileInfoToDirEntry(info)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,50 @@
+package mergefs
+
+import (
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMountFs_WalkDir(t *testing.T) {
+	defaultFs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(defaultFs, "/root.txt", []byte("r"), 0o644))
+	mountFs := NewMountFs(defaultFs)
+	nested := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(nested, "/dir/x.txt", []byte("x"), 0o644))
+	other := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(other, "/y.txt", []byte("y"), 0o644))
+	assert.NoError(t, mountFs.Mount("/a/b", nested))
+	assert.NoError(t, mountFs.Mount("/c", other))
+
+	var paths []string
+	assert.NoError(t, mountFs.WalkDir("/", func(name string, entry fs.DirEntry, err error) error {
+		assert.NoError(t, err)
+		paths = append(paths, name)
+		return nil
+	}))
+	assert.Equal(t, []string{"/", "/a", "/a/b", "/a/b/dir", "/a/b/dir/x.txt", "/c", "/c/y.txt", "/root.txt"}, paths,
+		"应跨越挂载点并包含虚拟目录")
+
+	paths = nil
+	assert.NoError(t, mountFs.Walk("/a", func(name string, info os.FileInfo, err error) error {
+		assert.NoError(t, err)
+		paths = append(paths, name)
+		if name == "/a/b/dir" {
+			return fs.SkipDir
+		}
+		return nil
+	}))
+	assert.Equal(t, []string{"/a", "/a/b", "/a/b/dir"}, paths)
+
+	paths = nil
+	assert.NoError(t, afero.Walk(mountFs, "/a", func(name string, info os.FileInfo, err error) error {
+		assert.NoError(t, err, "afero.Walk 可以读取虚拟目录")
+		paths = append(paths, name)
+		return nil
+	}))
+	assert.Len(t, paths, 4)
+}
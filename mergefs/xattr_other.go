@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package mergefs
+
+// readXattrs 当前平台不支持扩展属性
+func readXattrs(string) (map[string][]byte, error) {
+	return nil, ErrNoProps
+}
+
+// writeXattrs 当前平台不支持扩展属性
+func writeXattrs(string, map[string][]byte, []string) error {
+	return ErrNoProps
+}
@@ -0,0 +1,96 @@
+//go:build linux || darwin
+
+package mergefs
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// xattrPrefix 自定义属性在扩展属性中的名称前缀
+const xattrPrefix = "user.webdav."
+
+// xattrErr 将文件系统不支持扩展属性的错误转换为 ErrNoProps
+func xattrErr(err error) error {
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) {
+		return ErrNoProps
+	}
+	return err
+}
+
+// readXattr 读取完整的值，两次调用之间值可能变长，此时重试
+func readXattr(size func([]byte) (int, error)) ([]byte, error) {
+	for {
+		n, err := size(nil)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, n)
+		n, err = size(buf)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+// xattrNames 返回文件上属于自定义属性的扩展属性名
+func xattrNames(path string) ([]string, error) {
+	list, err := readXattr(func(buf []byte) (int, error) { return unix.Listxattr(path, buf) })
+	if err != nil {
+		return nil, xattrErr(err)
+	}
+	var names []string
+	for name := range strings.SplitSeq(string(list), "\x00") {
+		if strings.HasPrefix(name, xattrPrefix) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func readXattrs(path string) (map[string][]byte, error) {
+	names, err := xattrNames(path)
+	if err != nil {
+		return nil, err
+	}
+	props := make(map[string][]byte, len(names))
+	for _, name := range names {
+		value, err := readXattr(func(buf []byte) (int, error) { return unix.Getxattr(path, name, buf) })
+		if err != nil {
+			// 列出后被并发删除
+			continue
+		}
+		props[strings.TrimPrefix(name, xattrPrefix)] = value
+	}
+	return props, nil
+}
+
+func writeXattrs(path string, set map[string][]byte, remove []string) error {
+	if len(remove) > 0 {
+		names, err := xattrNames(path)
+		if err != nil {
+			return err
+		}
+		for _, key := range remove {
+			// 仅删除已存在的属性，各平台上属性不存在的错误码不同
+			if name := xattrPrefix + key; slices.Contains(names, name) {
+				if err := unix.Removexattr(path, name); err != nil {
+					return xattrErr(err)
+				}
+			}
+		}
+	}
+	for key, value := range set {
+		if err := unix.Setxattr(path, xattrPrefix+key, value, 0); err != nil {
+			return xattrErr(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("requests_total", "Requests.", "method", "user")
+	sizes := r.NewHistogramVec("size_bytes", "Sizes.", []float64{10, 100}, "method")
+	requests.Inc("PUT", "admin")
+	requests.Inc("PUT", "admin")
+	requests.Inc("GET", `a"b`)
+	sizes.Observe(5, "PUT")
+	sizes.Observe(50, "PUT")
+	sizes.Observe(500, "PUT")
+
+	var out strings.Builder
+	_, err := r.WriteTo(&out)
+	assert.NoError(t, err)
+	assert.Equal(t, `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{method="GET",user="a\"b"} 1
+requests_total{method="PUT",user="admin"} 2
+# HELP size_bytes Sizes.
+# TYPE size_bytes histogram
+size_bytes_bucket{method="PUT",le="10"} 1
+size_bytes_bucket{method="PUT",le="100"} 2
+size_bytes_bucket{method="PUT",le="+Inf"} 3
+size_bytes_sum{method="PUT"} 555
+size_bytes_count{method="PUT"} 3
+`, out.String(), "Prometheus 文本格式，标签值被转义")
+}
@@ -0,0 +1,71 @@
+package objstore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAzureStore(t *testing.T) {
+	var blocks []string
+	var blockList string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey devstoreaccount1:"))
+		query := r.URL.Query()
+		switch {
+		case r.Method == http.MethodGet && query.Get("comp") == "list":
+			assert.Equal(t, "pool/docs/", query.Get("prefix"))
+			_, _ = io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?>
+<EnumerationResults><Blobs>
+<Blob><Name>pool/docs/a.txt</Name><Properties><Last-Modified>Mon, 01 Jan 2024 00:00:00 GMT</Last-Modified><Etag>0x1</Etag><Content-Length>5</Content-Length></Properties></Blob>
+<BlobPrefix><Name>pool/docs/sub/</Name></BlobPrefix>
+</Blobs><NextMarker>next</NextMarker></EnumerationResults>`)
+		case r.Method == http.MethodPut && query.Get("comp") == "block":
+			blocks = append(blocks, query.Get("blockid"))
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut && query.Get("comp") == "blocklist":
+			data, _ := io.ReadAll(r.Body)
+			blockList = string(data)
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodHead:
+			w.Header().Set("X-Ms-Error-Code", "BlobNotFound")
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	store, err := NewAzureStore(AzureConfig{
+		Endpoint:  srv.URL + "/devstoreaccount1",
+		Account:   "devstoreaccount1",
+		Key:       "a2V5",
+		Container: "data",
+		Prefix:    "pool",
+	})
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	page, err := store.List(ctx, "docs/", "/", "", 10)
+	assert.NoError(t, err)
+	assert.Len(t, page.Objects, 1)
+	assert.Equal(t, "docs/a.txt", page.Objects[0].Key)
+	assert.Equal(t, int64(5), page.Objects[0].Size)
+	assert.Equal(t, []string{"docs/sub/"}, page.Prefixes)
+	assert.Equal(t, "next", page.NextToken)
+
+	_, err = store.Head(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	upload, err := store.NewUpload(ctx, "big.bin")
+	assert.NoError(t, err)
+	assert.NoError(t, upload.WritePart(ctx, 1, strings.NewReader("abc"), 3))
+	assert.NoError(t, upload.WritePart(ctx, 2, strings.NewReader("def"), 3))
+	assert.NoError(t, upload.Complete(ctx))
+	assert.Len(t, blocks, 2)
+	assert.Len(t, blocks[0], len(blocks[1]), "块 ID 长度必须一致")
+	assert.Equal(t, "<BlockList><Latest>"+blocks[0]+"</Latest><Latest>"+blocks[1]+"</Latest></BlockList>", blockList)
+}
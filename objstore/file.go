@@ -0,0 +1,320 @@
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// fileInfo 对象或目录的文件信息
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func newFileInfo(object ObjectInfo) *fileInfo {
+	return &fileInfo{name: path.Base(object.Key), size: object.Size, modTime: object.ModTime}
+}
+
+func newDirInfo(key string) *fileInfo {
+	name := path.Base(key)
+	if key == "" {
+		name = "/"
+	}
+	return &fileInfo{name: name, dir: true}
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return i.size }
+func (i *fileInfo) ModTime() time.Time { return i.modTime }
+func (i *fileInfo) IsDir() bool        { return i.dir }
+func (i *fileInfo) Sys() any           { return nil }
+func (i *fileInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+// baseFile 提供不支持操作的默认实现
+type baseFile struct {
+	info os.FileInfo
+}
+
+func (b *baseFile) Name() string                       { return b.info.Name() }
+func (b *baseFile) Stat() (os.FileInfo, error)         { return b.info, nil }
+func (b *baseFile) Sync() error                        { return nil }
+func (b *baseFile) Truncate(int64) error               { return b.unsupported("truncate") }
+func (b *baseFile) WriteString(s string) (int, error)  { return 0, b.unsupported("write") }
+func (b *baseFile) Write([]byte) (int, error)          { return 0, b.unsupported("write") }
+func (b *baseFile) WriteAt([]byte, int64) (int, error) { return 0, b.unsupported("write") }
+func (b *baseFile) Readdir(int) ([]os.FileInfo, error) {
+	return nil, b.pathErr("readdir", syscall.ENOTDIR)
+}
+func (b *baseFile) Readdirnames(int) ([]string, error) {
+	return nil, b.pathErr("readdir", syscall.ENOTDIR)
+}
+func (b *baseFile) Read([]byte) (int, error)          { return 0, b.unsupported("read") }
+func (b *baseFile) ReadAt([]byte, int64) (int, error) { return 0, b.unsupported("read") }
+func (b *baseFile) Seek(int64, int) (int64, error)    { return 0, b.unsupported("seek") }
+func (b *baseFile) pathErr(op string, err error) error {
+	return &os.PathError{Op: op, Path: b.info.Name(), Err: err}
+}
+func (b *baseFile) unsupported(op string) error { return b.pathErr(op, errors.ErrUnsupported) }
+func (b *baseFile) Close() error                { return nil }
+
+// readFile 按需发起范围读取的只读文件
+type readFile struct {
+	baseFile
+	fs     *Fs
+	ctx    context.Context
+	key    string
+	offset int64
+	body   io.ReadCloser
+}
+
+func newReadFile(ctx context.Context, f *Fs, key string, info os.FileInfo) *readFile {
+	return &readFile{baseFile: baseFile{info: info}, fs: f, ctx: ctx, key: key}
+}
+
+func (r *readFile) Read(p []byte) (int, error) {
+	if r.offset >= r.info.Size() {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		body, err := r.fs.store.Get(r.ctx, r.key, r.offset, -1)
+		if err != nil {
+			return 0, r.pathErr("read", err)
+		}
+		r.body = body
+	}
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *readFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.info.Size() {
+		return 0, io.EOF
+	}
+	length := min(int64(len(p)), r.info.Size()-off)
+	body, err := r.fs.store.Get(r.ctx, r.key, off, length)
+	if err != nil {
+		return 0, r.pathErr("read", err)
+	}
+	defer body.Close()
+	n, err := io.ReadFull(body, p[:length])
+	if err == nil && int64(n) < int64(len(p)) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r *readFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.info.Size()
+	}
+	if offset < 0 {
+		return 0, r.pathErr("seek", os.ErrInvalid)
+	}
+	if offset != r.offset && r.body != nil {
+		_ = r.body.Close()
+		r.body = nil
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *readFile) Close() error {
+	if r.body != nil {
+		return r.body.Close()
+	}
+	return nil
+}
+
+// writeFile 顺序写入的文件，超过分块大小时转为分块上传
+type writeFile struct {
+	baseFile
+	fs      *Fs
+	ctx     context.Context
+	key     string
+	buf     bytes.Buffer
+	upload  Upload
+	part    int
+	written int64
+	failed  error
+	closed  bool
+}
+
+func newWriteFile(ctx context.Context, f *Fs, key string) *writeFile {
+	return &writeFile{baseFile: baseFile{info: &fileInfo{name: path.Base(key)}}, fs: f, ctx: ctx, key: key}
+}
+
+func (w *writeFile) Stat() (os.FileInfo, error) {
+	return &fileInfo{name: path.Base(w.key), size: w.written, modTime: time.Now()}, nil
+}
+
+func (w *writeFile) Write(p []byte) (int, error) {
+	if w.failed != nil {
+		return 0, w.failed
+	}
+	n, _ := w.buf.Write(p)
+	w.written += int64(n)
+	for int64(w.buf.Len()) >= w.fs.partSize {
+		if err := w.flushPart(w.fs.partSize); err != nil {
+			w.failed = err
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (w *writeFile) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteAt 仅支持顺序写入（如 sftp 上传）
+func (w *writeFile) WriteAt(p []byte, off int64) (int, error) {
+	if off != w.written {
+		return 0, w.unsupported("write")
+	}
+	return w.Write(p)
+}
+
+func (w *writeFile) flushPart(size int64) error {
+	if w.upload == nil {
+		upload, err := w.fs.store.NewUpload(w.ctx, w.key)
+		if err != nil {
+			return err
+		}
+		w.upload = upload
+	}
+	w.part++
+	return w.upload.WritePart(w.ctx, w.part, bytes.NewReader(w.buf.Next(int(size))), size)
+}
+
+// TransferError 由 sftp 在连接异常断开时调用，放弃本次上传
+func (w *writeFile) TransferError(err error) {
+	w.failed = err
+}
+
+func (w *writeFile) Abort() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.upload != nil {
+		return w.upload.Abort(context.Background())
+	}
+	return nil
+}
+
+func (w *writeFile) Close() error {
+	if w.closed {
+		return nil
+	}
+	err := w.failed
+	if err == nil {
+		err = context.Cause(w.ctx)
+	}
+	if err != nil {
+		_ = w.Abort()
+		return err
+	}
+	w.closed = true
+	if w.upload == nil {
+		return w.fs.store.Put(w.ctx, w.key, bytes.NewReader(w.buf.Bytes()), int64(w.buf.Len()))
+	}
+	if w.buf.Len() > 0 {
+		if err := w.flushPart(int64(w.buf.Len())); err != nil {
+			_ = w.upload.Abort(context.Background())
+			return err
+		}
+	}
+	if err := w.upload.Complete(w.ctx); err != nil {
+		_ = w.upload.Abort(context.Background())
+		return err
+	}
+	return nil
+}
+
+// dirFile 目录，分页读取子项
+type dirFile struct {
+	baseFile
+	fs      *Fs
+	ctx     context.Context
+	prefix  string
+	token   string
+	pending []os.FileInfo
+	done    bool
+}
+
+func newDirFile(ctx context.Context, f *Fs, key string, info os.FileInfo) *dirFile {
+	return &dirFile{baseFile: baseFile{info: info}, fs: f, ctx: ctx, prefix: dirKey(key)}
+}
+
+// nextPage 读取下一页条目，跳过目录自身的标记对象
+func (d *dirFile) nextPage() error {
+	page, err := d.fs.store.List(d.ctx, d.prefix, "/", d.token, listPageSize)
+	if err != nil {
+		return d.pathErr("readdir", err)
+	}
+	for _, prefix := range page.Prefixes {
+		d.pending = append(d.pending, newDirInfo(strings.TrimSuffix(prefix, "/")))
+	}
+	for _, object := range page.Objects {
+		if object.Key != d.prefix {
+			d.pending = append(d.pending, newFileInfo(object))
+		}
+	}
+	d.token = page.NextToken
+	d.done = page.NextToken == ""
+	return nil
+}
+
+func (d *dirFile) Readdir(count int) ([]os.FileInfo, error) {
+	for !d.done && (count <= 0 || len(d.pending) < count) {
+		if err := d.nextPage(); err != nil {
+			return nil, err
+		}
+	}
+	if count > 0 && len(d.pending) == 0 {
+		return nil, io.EOF
+	}
+	n := len(d.pending)
+	if count > 0 {
+		n = min(count, n)
+	}
+	result := d.pending[:n:n]
+	d.pending = d.pending[n:]
+	return result, nil
+}
+
+func (d *dirFile) Readdirnames(count int) ([]string, error) {
+	infos, err := d.Readdir(count)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return names, err
+}
+
+// Seek 回到开头时重新读取目录
+func (d *dirFile) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart {
+		return 0, d.unsupported("seek")
+	}
+	d.token, d.pending, d.done = "", nil, false
+	return 0, nil
+}
@@ -0,0 +1,314 @@
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// DefaultPartSize 默认分块大小，小于该大小的文件使用单次上传
+const DefaultPartSize = 8 << 20
+
+// listPageSize 目录列表每页读取的条目数
+const listPageSize = 1000
+
+// Fs 基于对象存储的文件系统
+type Fs struct {
+	store    Store
+	partSize int64
+}
+
+// NewFs 创建对象存储文件系统，partSize <= 0 时使用 DefaultPartSize
+func NewFs(store Store, partSize int64) *Fs {
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+	return &Fs{store: store, partSize: partSize}
+}
+
+// objectKey 将文件路径转换为对象键，根目录为空字符串
+func objectKey(name string) string {
+	return strings.Trim(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+}
+
+func dirKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	return key + "/"
+}
+
+func pathError(op, name string, err error) error {
+	if errors.Is(err, ErrNotFound) {
+		err = os.ErrNotExist
+	}
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// isDir 判断目录是否存在：存在目录标记对象或任意以该前缀开头的对象
+func (f *Fs) isDir(ctx context.Context, key string) (bool, error) {
+	if key == "" {
+		return true, nil
+	}
+	page, err := f.store.List(ctx, dirKey(key), "/", "", 1)
+	if err != nil {
+		return false, err
+	}
+	return len(page.Objects) > 0 || len(page.Prefixes) > 0, nil
+}
+
+func (f *Fs) stat(ctx context.Context, op, name string) (os.FileInfo, error) {
+	key := objectKey(name)
+	if key != "" {
+		object, err := f.store.Head(ctx, key)
+		if err == nil {
+			return newFileInfo(object), nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, pathError(op, name, err)
+		}
+	}
+	ok, err := f.isDir(ctx, key)
+	if err != nil {
+		return nil, pathError(op, name, err)
+	}
+	if !ok {
+		return nil, pathError(op, name, ErrNotFound)
+	}
+	return newDirInfo(key), nil
+}
+
+func (f *Fs) Stat(name string) (os.FileInfo, error) {
+	return f.stat(context.Background(), "stat", name)
+}
+
+func (f *Fs) Create(name string) (afero.File, error) {
+	return f.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (f *Fs) Open(name string) (afero.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (f *Fs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	return f.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+// OpenFileContext ctx 取消时放弃正在进行的上传
+func (f *Fs) OpenFileContext(ctx context.Context, name string, flag int, _ os.FileMode) (afero.File, error) {
+	info, err := f.stat(ctx, "open", name)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	exists := err == nil
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		if !exists {
+			return nil, err
+		}
+		if info.IsDir() {
+			return newDirFile(ctx, f, objectKey(name), info), nil
+		}
+		return newReadFile(ctx, f, objectKey(name), info), nil
+	}
+	switch {
+	case exists && info.IsDir():
+		return nil, pathError("open", name, syscall.EISDIR)
+	case exists && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, pathError("open", name, os.ErrExist)
+	case !exists && flag&os.O_CREATE == 0:
+		return nil, err
+	case exists && info.Size() > 0 && (flag&os.O_TRUNC == 0 || flag&os.O_APPEND != 0):
+		// 对象存储不支持修改已有对象的部分内容
+		return nil, pathError("open", name, errors.ErrUnsupported)
+	}
+	if ok, err := f.isDir(ctx, objectKey(path.Dir(objectKey(name)))); err != nil || !ok {
+		return nil, pathError("open", name, ErrNotFound)
+	}
+	return newWriteFile(ctx, f, objectKey(name)), nil
+}
+
+func (f *Fs) Mkdir(name string, _ os.FileMode) error {
+	ctx := context.Background()
+	key := objectKey(name)
+	if _, err := f.stat(ctx, "mkdir", name); err == nil {
+		return pathError("mkdir", name, os.ErrExist)
+	}
+	if ok, err := f.isDir(ctx, objectKey(path.Dir(key))); err != nil || !ok {
+		return pathError("mkdir", name, ErrNotFound)
+	}
+	return f.store.Put(ctx, dirKey(key), bytes.NewReader(nil), 0)
+}
+
+func (f *Fs) MkdirAll(name string, _ os.FileMode) error {
+	ctx := context.Background()
+	key := objectKey(name)
+	if key == "" {
+		return nil
+	}
+	current := ""
+	for _, part := range strings.Split(key, "/") {
+		current = path.Join(current, part)
+		info, err := f.stat(ctx, "mkdir", current)
+		if err == nil {
+			if !info.IsDir() {
+				return pathError("mkdir", name, syscall.ENOTDIR)
+			}
+			continue
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if err := f.store.Put(ctx, dirKey(current), bytes.NewReader(nil), 0); err != nil {
+			return pathError("mkdir", name, err)
+		}
+	}
+	return nil
+}
+
+func (f *Fs) Remove(name string) error {
+	ctx := context.Background()
+	info, err := f.stat(ctx, "remove", name)
+	if err != nil {
+		return err
+	}
+	key := objectKey(name)
+	if !info.IsDir() {
+		return f.store.Delete(ctx, key)
+	}
+	page, err := f.store.List(ctx, dirKey(key), "/", "", 2)
+	if err != nil {
+		return pathError("remove", name, err)
+	}
+	for _, object := range page.Objects {
+		if object.Key != dirKey(key) {
+			return pathError("remove", name, syscall.ENOTEMPTY)
+		}
+	}
+	if len(page.Prefixes) > 0 {
+		return pathError("remove", name, syscall.ENOTEMPTY)
+	}
+	return f.store.Delete(ctx, dirKey(key))
+}
+
+// walk 递归列出前缀下的所有对象
+func (f *Fs) walk(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	token := ""
+	for {
+		page, err := f.store.List(ctx, prefix, "", token, listPageSize)
+		if err != nil {
+			return err
+		}
+		for _, object := range page.Objects {
+			if err := fn(object); err != nil {
+				return err
+			}
+		}
+		if page.NextToken == "" {
+			return nil
+		}
+		token = page.NextToken
+	}
+}
+
+func (f *Fs) RemoveAll(name string) error {
+	ctx := context.Background()
+	key := objectKey(name)
+	if key != "" {
+		if err := f.store.Delete(ctx, key); err != nil {
+			return pathError("remove", name, err)
+		}
+	}
+	err := f.walk(ctx, dirKey(key), func(object ObjectInfo) error {
+		return f.store.Delete(ctx, object.Key)
+	})
+	if err != nil {
+		return pathError("remove", name, err)
+	}
+	return nil
+}
+
+// Rename 通过复制后删除实现，目录会逐个复制其中的对象
+func (f *Fs) Rename(oldname, newname string) error {
+	ctx := context.Background()
+	info, err := f.stat(ctx, "rename", oldname)
+	if err != nil {
+		return err
+	}
+	oldKey, newKey := objectKey(oldname), objectKey(newname)
+	if !info.IsDir() {
+		if err := f.store.Copy(ctx, oldKey, newKey); err != nil {
+			return pathError("rename", oldname, err)
+		}
+		return f.store.Delete(ctx, oldKey)
+	}
+	if strings.HasPrefix(dirKey(newKey), dirKey(oldKey)) {
+		return pathError("rename", oldname, os.ErrInvalid)
+	}
+	var objects []ObjectInfo
+	if err := f.walk(ctx, dirKey(oldKey), func(object ObjectInfo) error {
+		objects = append(objects, object)
+		return nil
+	}); err != nil {
+		return pathError("rename", oldname, err)
+	}
+	if len(objects) == 0 {
+		return f.store.Put(ctx, dirKey(newKey), bytes.NewReader(nil), 0)
+	}
+	for _, object := range objects {
+		target := dirKey(newKey) + strings.TrimPrefix(object.Key, dirKey(oldKey))
+		if err := f.store.Copy(ctx, object.Key, target); err != nil {
+			return pathError("rename", oldname, err)
+		}
+	}
+	for _, object := range objects {
+		if err := f.store.Delete(ctx, object.Key); err != nil {
+			return pathError("rename", oldname, err)
+		}
+	}
+	return nil
+}
+
+// CopyFile 使用存储的服务端复制，数据不经过本进程
+func (f *Fs) CopyFile(ctx context.Context, src, dst string) error {
+	info, err := f.stat(ctx, "copy", src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return pathError("copy", src, syscall.EISDIR)
+	}
+	dstKey := objectKey(dst)
+	if ok, err := f.isDir(ctx, objectKey(path.Dir(dstKey))); err != nil || !ok {
+		return pathError("copy", dst, ErrNotFound)
+	}
+	if err := f.store.Copy(ctx, objectKey(src), dstKey); err != nil {
+		return pathError("copy", src, err)
+	}
+	return nil
+}
+
+func (f *Fs) Name() string {
+	return "ObjectStoreFs"
+}
+
+// Chmod 对象存储没有权限位，忽略
+func (f *Fs) Chmod(_ string, _ os.FileMode) error {
+	return nil
+}
+
+// Chown 对象存储没有属主，忽略
+func (f *Fs) Chown(_ string, _, _ int) error {
+	return nil
+}
+
+// Chtimes 对象的修改时间由存储决定，忽略
+func (f *Fs) Chtimes(_ string, _, _ time.Time) error {
+	return nil
+}
@@ -0,0 +1,230 @@
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+// memStore 内存对象存储，每页最多返回 pageSize 条以覆盖分页逻辑
+type memStore struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	pageSize int
+	uploads  int
+}
+
+func newMemStore() *memStore {
+	return &memStore{objects: map[string][]byte{}, pageSize: 2}
+}
+
+func (m *memStore) Head(_ context.Context, key string) (ObjectInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return ObjectInfo{}, ErrNotFound
+	}
+	return ObjectInfo{Key: key, Size: int64(len(data)), ModTime: time.Now()}, nil
+}
+
+func (m *memStore) Get(_ context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	data = data[min(offset, int64(len(data))):]
+	if length >= 0 {
+		data = data[:min(length, int64(len(data)))]
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memStore) Put(_ context.Context, key string, r io.Reader, _ int64) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = data
+	return nil
+}
+
+type memUpload struct {
+	store *memStore
+	key   string
+	data  bytes.Buffer
+}
+
+func (u *memUpload) WritePart(_ context.Context, _ int, r io.Reader, _ int64) error {
+	_, err := io.Copy(&u.data, r)
+	return err
+}
+
+func (u *memUpload) Complete(ctx context.Context) error {
+	return u.store.Put(ctx, u.key, &u.data, int64(u.data.Len()))
+}
+
+func (u *memUpload) Abort(context.Context) error {
+	return nil
+}
+
+func (m *memStore) NewUpload(_ context.Context, key string) (Upload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.uploads++
+	return &memUpload{store: m, key: key}, nil
+}
+
+func (m *memStore) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *memStore) Copy(_ context.Context, src, dst string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[src]
+	if !ok {
+		return ErrNotFound
+	}
+	m.objects[dst] = data
+	return nil
+}
+
+func (m *memStore) List(_ context.Context, prefix, delimiter, token string, limit int) (ListPage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	seen := map[string]bool{}
+	for key := range m.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				key = key[:len(prefix)+i+1]
+			}
+		}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	limit = min(limit, m.pageSize)
+	page := ListPage{}
+	for _, key := range keys {
+		if key <= token {
+			continue
+		}
+		if len(page.Objects)+len(page.Prefixes) == limit {
+			page.NextToken = token
+			break
+		}
+		if delimiter != "" && strings.HasSuffix(key, delimiter) && key != prefix {
+			page.Prefixes = append(page.Prefixes, key)
+		} else {
+			page.Objects = append(page.Objects, ObjectInfo{Key: key, Size: int64(len(m.objects[key]))})
+		}
+		token = key
+	}
+	return page, nil
+}
+
+func TestFs_Basic(t *testing.T) {
+	store := newMemStore()
+	fs := NewFs(store, 4)
+
+	assert.NoError(t, fs.MkdirAll("/a/b", 0o755))
+	assert.NoError(t, afero.WriteFile(fs, "/a/small.txt", []byte("hi"), 0o644))
+	assert.NoError(t, afero.WriteFile(fs, "/a/b/large.txt", []byte("0123456789"), 0o644))
+	assert.Equal(t, 1, store.uploads, "超过分块大小的文件应使用分块上传")
+
+	data, err := afero.ReadFile(fs, "/a/b/large.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+
+	f, err := fs.Open("/a/b/large.txt")
+	assert.NoError(t, err)
+	buf := make([]byte, 3)
+	n, err := f.ReadAt(buf, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "789", string(buf[:n]))
+	_, _ = f.Seek(5, io.SeekStart)
+	rest, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "56789", string(rest))
+	assert.NoError(t, f.Close())
+
+	for i := range 5 {
+		assert.NoError(t, afero.WriteFile(fs, "/a/f"+string(rune('0'+i)), nil, 0o644))
+	}
+	infos, err := afero.ReadDir(fs, "/a")
+	assert.NoError(t, err)
+	assert.Len(t, infos, 7, "分页读取应返回全部条目")
+	dir, err := fs.Open("/a")
+	assert.NoError(t, err)
+	total := 0
+	for {
+		batch, err := dir.Readdir(3)
+		total += len(batch)
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 7, total)
+
+	stat, err := fs.Stat("/a/b")
+	assert.NoError(t, err)
+	assert.True(t, stat.IsDir())
+	assert.Error(t, fs.Remove("/a/b"), "非空目录不能删除")
+
+	assert.NoError(t, fs.Rename("/a/b", "/c"))
+	_, err = fs.Stat("/a/b/large.txt")
+	assert.True(t, os.IsNotExist(err))
+	data, err = afero.ReadFile(fs, "/c/large.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+
+	assert.NoError(t, fs.CopyFile(context.Background(), "/c/large.txt", "/c/copy.txt"))
+	data, err = afero.ReadFile(fs, "/c/copy.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+	assert.True(t, os.IsNotExist(fs.CopyFile(context.Background(), "/c/large.txt", "/missing/copy.txt")), "目标目录不存在")
+
+	assert.NoError(t, fs.RemoveAll("/a"))
+	_, err = fs.Stat("/a")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFs_WriteCancel(t *testing.T) {
+	store := newMemStore()
+	fs := NewFs(store, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	f, err := fs.OpenFileContext(ctx, "/file", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	assert.NoError(t, err)
+	_, _ = f.Write([]byte("partial"))
+	cancel()
+	assert.Error(t, f.Close())
+	_, err = fs.Stat("/file")
+	assert.True(t, os.IsNotExist(err), "取消的上传不应产生对象")
+
+	_, err = fs.OpenFile("/missing/file", os.O_WRONLY|os.O_CREATE, 0o644)
+	assert.True(t, os.IsNotExist(err), "父目录不存在时不能创建文件")
+}
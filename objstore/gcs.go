@@ -0,0 +1,32 @@
+package objstore
+
+import "errors"
+
+// GCSConfig Google Cloud Storage 的连接配置，
+// 通过 XML API 的 S3 互操作模式及 HMAC 密钥访问
+type GCSConfig struct {
+	// 服务地址，默认 https://storage.googleapis.com
+	Endpoint  string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+}
+
+// NewGCSStore 创建 Google Cloud Storage 存储
+func NewGCSStore(cfg GCSConfig) (*S3Store, error) {
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("gcs: hmac access key and secret are required")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://storage.googleapis.com"
+	}
+	return NewS3Store(S3Config{
+		Endpoint:  cfg.Endpoint,
+		Region:    "auto",
+		Bucket:    cfg.Bucket,
+		Prefix:    cfg.Prefix,
+		AccessKey: cfg.AccessKey,
+		SecretKey: cfg.SecretKey,
+	})
+}
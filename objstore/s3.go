@@ -0,0 +1,457 @@
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	emptyPayloadHash    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	unsignedPayloadHash = "UNSIGNED-PAYLOAD"
+)
+
+// S3Config S3 兼容存储的连接配置
+type S3Config struct {
+	// 服务地址，为空时使用 AWS 的区域地址
+	Endpoint string
+	// 区域，默认 us-east-1
+	Region string
+	Bucket string
+	// 对象键前缀，池中的所有文件都存放在该前缀下
+	Prefix string
+	// 凭据，为空时读取 AWS_ACCESS_KEY_ID 等环境变量
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	// 使用 bucket.endpoint 形式的虚拟主机地址，默认使用路径形式
+	VirtualHost bool
+}
+
+// S3Store 使用 AWS Signature V4 访问的 S3 兼容存储
+type S3Store struct {
+	cfg      S3Config
+	endpoint *url.URL
+	prefix   string
+	client   *http.Client
+}
+
+// NewS3Store 创建 S3 存储
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3: bucket is required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	if cfg.AccessKey == "" && cfg.SecretKey == "" {
+		cfg.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		cfg.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		cfg.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("s3: invalid endpoint: %w", err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("s3: invalid endpoint scheme %q", endpoint.Scheme)
+	}
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &S3Store{cfg: cfg, endpoint: endpoint, prefix: prefix, client: http.DefaultClient}, nil
+}
+
+// objectURL 生成对象地址，key 为空时指向存储桶
+func (s *S3Store) objectURL(key string, query url.Values) *url.URL {
+	u := *s.endpoint
+	p := "/" + s.cfg.Bucket
+	if s.cfg.VirtualHost {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		p = ""
+	}
+	if key != "" {
+		p += "/" + s.prefix + key
+	} else if p == "" {
+		p = "/"
+	}
+	u.Path = strings.TrimSuffix(s.endpoint.Path, "/") + p
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+func (s *S3Store) do(ctx context.Context, method, key string, query url.Values, header http.Header,
+	body io.Reader, size int64, payloadHash string,
+) (*http.Response, error) {
+	if body == nil {
+		body = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key, query).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+	if s.cfg.AccessKey != "" {
+		signV4(req, payloadHash, "s3", s.cfg.Region, s.cfg.AccessKey, s.cfg.SecretKey, time.Now())
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, parseS3Error(resp)
+	}
+	return resp, nil
+}
+
+// doXML 发送请求并解析 XML 响应，部分接口在 200 响应中返回错误
+func (s *S3Store) doXML(ctx context.Context, method, key string, query url.Values, header http.Header,
+	body []byte, out any,
+) error {
+	hash := emptyPayloadHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		hash = hex.EncodeToString(sum[:])
+	}
+	resp, err := s.do(ctx, method, key, query, header, bytes.NewReader(body), int64(len(body)), hash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var e s3ErrorBody
+	if xml.Unmarshal(data, &e) == nil && e.XMLName.Local == "Error" {
+		return &S3Error{Status: resp.StatusCode, Code: e.Code, Message: e.Message}
+	}
+	if out == nil {
+		return nil
+	}
+	return xml.Unmarshal(data, out)
+}
+
+func (s *S3Store) Head(ctx context.Context, key string) (ObjectInfo, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, nil, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	defer resp.Body.Close()
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return ObjectInfo{
+		Key:     key,
+		Size:    resp.ContentLength,
+		ModTime: modTime,
+		ETag:    strings.Trim(resp.Header.Get("ETag"), `"`),
+	}, nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	header := http.Header{}
+	switch {
+	case length == 0:
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	case length > 0:
+		header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := s.do(ctx, http.MethodGet, key, nil, header, nil, 0, emptyPayloadHash)
+	var s3Err *S3Error
+	if errors.As(err, &s3Err) && s3Err.Status == http.StatusRequestedRangeNotSatisfiable {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	resp, err := s.do(ctx, http.MethodPut, key, nil, nil, r, size, unsignedPayloadHash)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *S3Store) NewUpload(ctx context.Context, key string) (Upload, error) {
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := s.doXML(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &s3Upload{store: s, key: key, id: result.UploadID}, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil, nil, 0, emptyPayloadHash)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Copy 使用服务端复制，单个对象最大 5GiB
+func (s *S3Store) Copy(ctx context.Context, src, dst string) error {
+	header := http.Header{}
+	header.Set("X-Amz-Copy-Source", uriEncode("/"+s.cfg.Bucket+"/"+s.prefix+src, false))
+	return s.doXML(ctx, http.MethodPut, dst, nil, header, nil, nil)
+}
+
+func (s *S3Store) List(ctx context.Context, prefix, delimiter, token string, limit int) (ListPage, error) {
+	query := url.Values{"list-type": {"2"}, "prefix": {s.prefix + prefix}}
+	if delimiter != "" {
+		query.Set("delimiter", delimiter)
+	}
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+	if limit > 0 {
+		query.Set("max-keys", strconv.Itoa(limit))
+	}
+	var result struct {
+		Contents []struct {
+			Key          string
+			LastModified time.Time
+			ETag         string
+			Size         int64
+		}
+		CommonPrefixes []struct {
+			Prefix string
+		}
+		IsTruncated           bool
+		NextContinuationToken string
+	}
+	if err := s.doXML(ctx, http.MethodGet, "", query, nil, nil, &result); err != nil {
+		return ListPage{}, err
+	}
+	page := ListPage{}
+	for _, object := range result.Contents {
+		page.Objects = append(page.Objects, ObjectInfo{
+			Key:     strings.TrimPrefix(object.Key, s.prefix),
+			Size:    object.Size,
+			ModTime: object.LastModified,
+			ETag:    strings.Trim(object.ETag, `"`),
+		})
+	}
+	for _, p := range result.CommonPrefixes {
+		page.Prefixes = append(page.Prefixes, strings.TrimPrefix(p.Prefix, s.prefix))
+	}
+	if result.IsTruncated {
+		page.NextToken = result.NextContinuationToken
+	}
+	return page, nil
+}
+
+// s3Upload S3 分块上传会话
+type s3Upload struct {
+	store *S3Store
+	key   string
+	id    string
+	parts []s3Part
+}
+
+type s3Part struct {
+	PartNumber int
+	ETag       string
+}
+
+func (u *s3Upload) WritePart(ctx context.Context, number int, r io.Reader, size int64) error {
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {u.id}}
+	resp, err := u.store.do(ctx, http.MethodPut, u.key, query, nil, r, size, unsignedPayloadHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	u.parts = append(u.parts, s3Part{PartNumber: number, ETag: resp.Header.Get("ETag")})
+	return nil
+}
+
+func (u *s3Upload) Complete(ctx context.Context) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: u.parts})
+	if err != nil {
+		return err
+	}
+	return u.store.doXML(ctx, http.MethodPost, u.key, url.Values{"uploadId": {u.id}}, nil, body, nil)
+}
+
+func (u *s3Upload) Abort(ctx context.Context) error {
+	resp, err := u.store.do(ctx, http.MethodDelete, u.key, url.Values{"uploadId": {u.id}}, nil, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// S3Error S3 返回的错误
+type S3Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *S3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: status %d", e.Status)
+	}
+	return fmt.Sprintf("s3: %s: %s", e.Code, e.Message)
+}
+
+func (e *S3Error) Unwrap() error {
+	switch {
+	case e.Status == http.StatusNotFound && e.Code != "NoSuchBucket" && e.Code != "NoSuchUpload":
+		return ErrNotFound
+	case e.Status == http.StatusForbidden:
+		return os.ErrPermission
+	}
+	return nil
+}
+
+type s3ErrorBody struct {
+	XMLName xml.Name
+	Code    string
+	Message string
+}
+
+func parseS3Error(resp *http.Response) error {
+	result := &S3Error{Status: resp.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var e s3ErrorBody
+	if xml.Unmarshal(data, &e) == nil {
+		result.Code, result.Message = e.Code, e.Message
+	}
+	return result
+}
+
+// signV4 使用 AWS Signature Version 4 为请求签名，
+// 签名覆盖 host、content-type 与所有 x-amz-* 请求头
+func signV4(req *http.Request, payloadHash, service, region, accessKey, secretKey string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.Join(values, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery 按键排序并严格编码查询参数
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode 按 SigV4 规则编码，仅保留 RFC 3986 非保留字符，
+// encodeSlash 为 false 时保留路径分隔符
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,31 @@
+package objstore
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 使用 AWS 文档中的 Signature V4 示例验证签名
+func TestSignV4(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signV4(req, emptyPayloadHash, "iam", "us-east-1", "AKIDEXAMPLE",
+		"wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, "+
+		"SignedHeaders=content-type;host;x-amz-date, "+
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		req.Header.Get("Authorization"))
+}
+
+func TestS3Store_ObjectURL(t *testing.T) {
+	store, err := NewS3Store(S3Config{Endpoint: "http://localhost:9000", Bucket: "data", Prefix: "/pool/"})
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:9000/data/pool/a%20b/c%2Bd.txt", store.objectURL("a b/c+d.txt", nil).String())
+
+	store, err = NewS3Store(S3Config{Endpoint: "https://s3.example.com", Bucket: "data", VirtualHost: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://data.s3.example.com/?list-type=2", store.objectURL("", map[string][]string{"list-type": {"2"}}).String())
+}
@@ -0,0 +1,56 @@
+// Package objstore 将远程对象存储（S3 等）适配为 afero.Fs，
+// 目录以 / 结尾的空对象表示，也可由包含该前缀的对象隐式存在。
+package objstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("object not found")
+
+// ObjectInfo 对象信息
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+	ETag    string
+}
+
+// ListPage 一页列表结果
+type ListPage struct {
+	// 当前层级的对象
+	Objects []ObjectInfo
+	// 当前层级的子目录前缀，以 / 结尾
+	Prefixes []string
+	// 下一页的分页标记，为空时表示已读完
+	NextToken string
+}
+
+// Upload 分块上传会话，分块按顺序编号（从 1 开始）
+type Upload interface {
+	WritePart(ctx context.Context, number int, r io.Reader, size int64) error
+	Complete(ctx context.Context) error
+	Abort(ctx context.Context) error
+}
+
+// Store 远程对象存储接口，键不以 / 开头
+type Store interface {
+	// Head 获取对象信息，不存在时返回 ErrNotFound
+	Head(ctx context.Context, key string) (ObjectInfo, error)
+	// Get 读取对象从 offset 开始的 length 字节，length < 0 表示读取到末尾
+	Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	// Put 上传完整对象
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// NewUpload 创建分块上传会话，用于大文件的流式上传
+	NewUpload(ctx context.Context, key string) (Upload, error)
+	// Delete 删除对象，对象不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+	// Copy 在存储内复制对象
+	Copy(ctx context.Context, src, dst string) error
+	// List 列出以 prefix 开头的对象，delimiter 为 / 时只列出当前层级，为空时递归列出
+	List(ctx context.Context, prefix, delimiter, token string, limit int) (ListPage, error)
+}
@@ -0,0 +1,183 @@
+package preview
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/spf13/afero"
+)
+
+// archiveWriter 打包下载的归档格式
+type archiveWriter interface {
+	// addDir 添加目录，name 以 / 分隔且不以 / 开头
+	addDir(name string, info os.FileInfo) error
+	// addFile 添加文件，内容从 r 读取
+	addFile(name string, info os.FileInfo, r io.Reader) error
+	Close() error
+}
+
+// archiveFormats 支持的归档格式，键为 ?archive 参数的值
+var archiveFormats = map[string]struct {
+	ext, contentType string
+	create           func(w io.Writer) archiveWriter
+}{
+	"zip": {ext: ".zip", contentType: "application/zip", create: newZipArchive},
+	// tar.gz 保留权限与修改时间，适合很大的目录树
+	"tar.gz": {ext: ".tar.gz", contentType: "application/gzip", create: newTarArchive},
+}
+
+// handleArchive 将目录 p 打包为 format 格式边读取边发送，不在服务端生成临时文件。
+// 遍历经过用户的文件系统，无权读取的存储池与被排除的文件不会出现在归档中
+func handleArchive(w http.ResponseWriter, r *http.Request, fs *common.AuthFS, p, format string) {
+	archive, ok := archiveFormats[format]
+	if !ok {
+		http.Error(w, "不支持的归档格式", http.StatusBadRequest)
+		return
+	}
+	root := "/" + strings.Trim(p, "/")
+	name := path.Base(root)
+	if name == "/" {
+		name = "download"
+	}
+	w.Header().Set("Content-Type", archive.contentType)
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", name+archive.ext))
+	slog.Info("|preview| Archive download.", "path", root, "format", format, "remote", r.RemoteAddr, "user", fs.User)
+	writer := archive.create(w)
+	if err := writeArchive(r, fs, writer, root, name); err != nil {
+		// 不写入归档的结尾，客户端得到不完整的文件而不是缺少内容的归档
+		slog.Warn("|preview| Archive download aborted.", "path", root, "user", fs.User, "err", err)
+		return
+	}
+	if err := writer.Close(); err != nil {
+		slog.Warn("|preview| Archive download aborted.", "path", root, "user", fs.User, "err", err)
+	}
+}
+
+// writeArchive 按名称顺序递归写入 dir，prefix 为归档中对应的路径。符号链接等特殊文件被跳过
+func writeArchive(r *http.Request, fs afero.Fs, writer archiveWriter, dir, prefix string) error {
+	entries, err := afero.ReadDir(fs, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		name := path.Join(dir, entry.Name())
+		archiveName := prefix + "/" + entry.Name()
+		switch {
+		case entry.IsDir():
+			if err := writer.addDir(archiveName, entry); err != nil {
+				return err
+			}
+			if err := writeArchive(r, fs, writer, name, archiveName); err != nil {
+				return err
+			}
+		case entry.Mode().IsRegular():
+			file, err := fs.Open(name)
+			if err != nil {
+				// 文件在遍历期间被删除或不可读时跳过
+				slog.Debug("|preview| Archive entry skipped.", "path", name, "err", err)
+				continue
+			}
+			err = writer.addFile(archiveName, entry, file)
+			_ = file.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// zipArchive 写入 zip，超过 4GB 的文件与条目数自动使用 zip64
+type zipArchive struct {
+	*zip.Writer
+}
+
+func newZipArchive(w io.Writer) archiveWriter {
+	return &zipArchive{Writer: zip.NewWriter(w)}
+}
+
+func (z *zipArchive) addDir(name string, info os.FileInfo) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name + "/"
+	_, err = z.CreateHeader(header)
+	return err
+}
+
+func (z *zipArchive) addFile(name string, info os.FileInfo, r io.Reader) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	w, err := z.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// tarArchive 写入 gzip 压缩的 tar，长路径与大文件使用 PAX 扩展头
+type tarArchive struct {
+	tar  *tar.Writer
+	gzip *gzip.Writer
+}
+
+func newTarArchive(w io.Writer) archiveWriter {
+	gz := gzip.NewWriter(w)
+	return &tarArchive{tar: tar.NewWriter(gz), gzip: gz}
+}
+
+func (t *tarArchive) header(name string, info os.FileInfo) (*tar.Header, error) {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return nil, err
+	}
+	header.Name = name
+	// 所有者来自服务端的文件系统，对下载的用户没有意义
+	header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+	return header, nil
+}
+
+func (t *tarArchive) addDir(name string, info os.FileInfo) error {
+	header, err := t.header(name+"/", info)
+	if err != nil {
+		return err
+	}
+	return t.tar.WriteHeader(header)
+}
+
+func (t *tarArchive) addFile(name string, info os.FileInfo, r io.Reader) error {
+	header, err := t.header(name, info)
+	if err != nil {
+		return err
+	}
+	if err := t.tar.WriteHeader(header); err != nil {
+		return err
+	}
+	// 文件在读取期间变短时 tar 的长度不一致，按错误处理
+	_, err = io.CopyN(t.tar, r, header.Size)
+	return err
+}
+
+func (t *tarArchive) Close() error {
+	if err := t.tar.Close(); err != nil {
+		return err
+	}
+	return t.gzip.Close()
+}
@@ -0,0 +1,67 @@
+package preview
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleArchiveZip(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/data/project/a.txt", []byte("hello"), 0o644))
+	assert.NoError(t, afero.WriteFile(fs, "/data/project/src/main.go", []byte("package main"), 0o644))
+	assert.NoError(t, fs.MkdirAll("/data/project/empty", 0o755))
+
+	rec := httptest.NewRecorder()
+	handleArchive(rec, httptest.NewRequest("GET", "/preview/data/project/?archive=zip", nil), &common.AuthFS{User: "alice", Fs: fs}, "data/project/", "zip")
+	assert.Equal(t, "application/zip", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="project.zip"; filename*=UTF-8''project.zip`, rec.Header().Get("Content-Disposition"))
+
+	reader, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	assert.NoError(t, err)
+	var names []string
+	for _, file := range reader.File {
+		names = append(names, file.Name)
+	}
+	assert.Equal(t, []string{"project/a.txt", "project/empty/", "project/src/", "project/src/main.go"}, names)
+	file, err := reader.File[0].Open()
+	assert.NoError(t, err)
+	content, _ := io.ReadAll(file)
+	assert.Equal(t, "hello", string(content))
+
+	rec = httptest.NewRecorder()
+	handleArchive(rec, httptest.NewRequest("GET", "/preview/data/?archive=rar", nil), &common.AuthFS{User: "alice", Fs: fs}, "data/", "rar")
+	assert.Equal(t, 400, rec.Code, "不支持的格式")
+}
+
+func TestHandleArchiveTarGz(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/data/project/run.sh", []byte("#!/bin/sh"), 0o755))
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, fs.Chtimes("/data/project/run.sh", mtime, mtime))
+
+	rec := httptest.NewRecorder()
+	handleArchive(rec, httptest.NewRequest("GET", "/preview/data/project/?archive=tar.gz", nil), &common.AuthFS{User: "alice", Fs: fs}, "data/project", "tar.gz")
+	assert.Equal(t, `attachment; filename="project.tar.gz"; filename*=UTF-8''project.tar.gz`, rec.Header().Get("Content-Disposition"))
+	gz, err := gzip.NewReader(rec.Body)
+	assert.NoError(t, err)
+	reader := tar.NewReader(gz)
+	header, err := reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "project/run.sh", header.Name)
+	assert.Equal(t, int64(0o755), header.Mode&0o777, "保留文件权限")
+	assert.True(t, mtime.Equal(header.ModTime), "保留修改时间")
+	content, _ := io.ReadAll(reader)
+	assert.Equal(t, "#!/bin/sh", string(content))
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+}
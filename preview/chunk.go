@@ -0,0 +1,253 @@
+package preview
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"code.d7z.net/packages/webdav-server/mergefs"
+)
+
+// chunkExpire 未完成的分块上传的保留时间，超过后被清理
+const chunkExpire = 24 * time.Hour
+
+// chunkPattern 暂存文件的名称
+const chunkPattern = "chunk-*.part"
+
+// chunkIDPattern 客户端生成的上传标识，同一文件重新选择后使用相同的标识以继续上传
+var chunkIDPattern = regexp.MustCompile(`^[0-9A-Za-z_-]{8,64}$`)
+
+var errChunkBusy = errors.New("chunk upload in progress")
+
+// chunkStore 分块上传的暂存目录。每个上传按用户与标识对应一个暂存文件，
+// 分块按顺序追加，中断后客户端查询已接收的长度并从该位置继续
+type chunkStore struct {
+	dir string
+	// maxSize 存储池未限制文件大小时分块上传的大小上限
+	maxSize int64
+
+	mu     sync.Mutex
+	ready  bool
+	active map[string]struct{}
+}
+
+func newChunkStore(dir string, maxSize int64) *chunkStore {
+	return &chunkStore{dir: dir, maxSize: maxSize, active: make(map[string]struct{})}
+}
+
+// prepare 创建暂存目录。未配置 chunk_dir 时使用用户缓存目录下固定的 webdav-server/chunks，
+// 重启后仍可继续上传。目录必须属于当前用户且其他用户无法访问
+func (s *chunkStore) prepare() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ready {
+		return nil
+	}
+	if s.dir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return fmt.Errorf("no default chunk dir, set preview.chunk_dir: %w", err)
+		}
+		s.dir = filepath.Join(cache, "webdav-server", "chunks")
+	}
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	if err := checkPrivateDir(s.dir); err != nil {
+		return err
+	}
+	s.ready = true
+	return nil
+}
+
+// checkPrivateDir 检查目录不是符号链接、属于当前用户且其他用户无法访问，
+// 避免使用其他本地用户预先创建的目录
+func checkPrivateDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("chunk dir %s is not a directory", dir)
+	}
+	if uid, _, ok := mergefs.FileOwner(info); ok && uid != os.Getuid() {
+		return fmt.Errorf("chunk dir %s is owned by uid %d", dir, uid)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("chunk dir %s is accessible by other users (mode %s)", dir, info.Mode().Perm())
+	}
+	return nil
+}
+
+// limit 返回单个分块上传的大小上限：存储池的 max_file_size，未设置时为 chunk_max_size
+func (s *chunkStore) limit(fileLimit int64) int64 {
+	if fileLimit > 0 {
+		return fileLimit
+	}
+	return s.maxSize
+}
+
+// path 返回用户上传的暂存文件，不同用户的相同标识互不影响
+func (s *chunkStore) path(user, id string) string {
+	sum := sha256.Sum256([]byte(user + "\x00" + id))
+	return filepath.Join(s.dir, "chunk-"+hex.EncodeToString(sum[:16])+".part")
+}
+
+// acquire 标记暂存文件正在写入，同一上传的分块不能并发写入
+func (s *chunkStore) acquire(name string) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.active[name]; ok {
+		return nil, errChunkBusy
+	}
+	s.active[name] = struct{}{}
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.active, name)
+	}, nil
+}
+
+// offset 返回已接收的长度
+func (s *chunkStore) offset(name string) int64 {
+	if info, err := os.Stat(name); err == nil {
+		return info.Size()
+	}
+	return 0
+}
+
+// clean 删除超过 chunkExpire 未更新的暂存文件
+func (s *chunkStore) clean() {
+	matches, _ := filepath.Glob(filepath.Join(s.dir, chunkPattern))
+	for _, name := range matches {
+		if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > chunkExpire {
+			if err := os.Remove(name); err != nil {
+				slog.Warn("|preview| Failed to remove stale chunk file.", "path", name, "err", err)
+			}
+		}
+	}
+}
+
+type chunkResponse struct {
+	Offset int64  `json:"offset"`
+	Path   string `json:"path,omitempty"`
+}
+
+func writeChunk(w http.ResponseWriter, code int, response chunkResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// handleChunkStatus 返回上传已接收的长度，客户端据此继续上传
+func handleChunkStatus(w http.ResponseWriter, chunks *chunkStore, fs *common.AuthFS, id string) {
+	if !chunkIDPattern.MatchString(id) {
+		http.Error(w, "参数错误", http.StatusBadRequest)
+		return
+	}
+	if err := chunks.prepare(); err != nil {
+		slog.Warn("|preview| Failed to create chunk dir.", "path", chunks.dir, "err", err)
+		http.Error(w, "上传失败", http.StatusInsufficientStorage)
+		return
+	}
+	writeChunk(w, http.StatusOK, chunkResponse{Offset: chunks.offset(chunks.path(fs.User, id))})
+}
+
+// handleChunk 接收一个分块：POST ?chunk=<id>&offset=N&total=T&name=<相对路径>，请求体为分块内容。
+// offset 必须等于已接收的长度，否则返回 409 与当前长度；接收完 total 字节后将文件保存到 p 下的 name
+func handleChunk(w http.ResponseWriter, r *http.Request, chunks *chunkStore, fs *common.AuthFS, p string, opts uploadOptions) {
+	query := r.URL.Query()
+	id, name := query.Get("chunk"), query.Get("name")
+	offset, offsetErr := strconv.ParseInt(query.Get("offset"), 10, 64)
+	total, totalErr := strconv.ParseInt(query.Get("total"), 10, 64)
+	if !chunkIDPattern.MatchString(id) || name == "" || offsetErr != nil || totalErr != nil || offset < 0 || total < offset {
+		http.Error(w, "参数错误", http.StatusBadRequest)
+		return
+	}
+	if limit := chunks.limit(opts.fileLimit); limit > 0 && total > limit {
+		http.Error(w, "文件超过存储池的大小限制", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := chunks.prepare(); err != nil {
+		slog.Warn("|preview| Failed to create chunk dir.", "path", chunks.dir, "err", err)
+		http.Error(w, "上传失败", http.StatusInsufficientStorage)
+		return
+	}
+	// 暂存目录不属于任何存储池，不受 min_free 限制，剩余空间不足以保存文件的剩余部分时拒绝
+	if usage, err := mergefs.GetDiskUsage(chunks.dir); err == nil && usage.Free < uint64(total-offset) {
+		http.Error(w, "暂存空间不足", http.StatusInsufficientStorage)
+		return
+	}
+	staged := chunks.path(fs.User, id)
+	release, err := chunks.acquire(staged)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	defer release()
+	if offset == 0 {
+		chunks.clean()
+	}
+
+	file, err := os.OpenFile(staged, os.O_WRONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		slog.Warn("|preview| Failed to open chunk file.", "path", staged, "err", err)
+		http.Error(w, "上传失败", http.StatusInsufficientStorage)
+		return
+	}
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil || size != offset {
+		_ = file.Close()
+		writeChunk(w, http.StatusConflict, chunkResponse{Offset: size})
+		return
+	}
+	// 中断时已写入的内容保留，客户端从新的长度继续
+	written, err := io.Copy(file, io.LimitReader(http.MaxBytesReader(w, r.Body, opts.maxSize), total-offset+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	size = offset + written
+	if err != nil {
+		slog.Debug("|preview| Chunk interrupted.", "path", staged, "offset", size, "err", err)
+		writeChunk(w, http.StatusBadRequest, chunkResponse{Offset: size})
+		return
+	}
+	if size > total {
+		_ = os.Remove(staged)
+		http.Error(w, "上传内容超过声明的大小", http.StatusBadRequest)
+		return
+	}
+	if size < total {
+		writeChunk(w, http.StatusOK, chunkResponse{Offset: size})
+		return
+	}
+
+	destPath, status, err := saveUpload(fs, p, name, total, func() (io.ReadCloser, error) { return os.Open(staged) },
+		r.URL.Query().Get("force") == "true", opts)
+	if err != nil {
+		if status >= http.StatusInternalServerError {
+			// 保留暂存文件，客户端以 offset=total 的空分块重试保存
+			writeChunk(w, status, chunkResponse{Offset: size})
+			return
+		}
+		_ = os.Remove(staged)
+		http.Error(w, err.Error(), status)
+		return
+	}
+	_ = os.Remove(staged)
+	slog.Info("|preview| Upload.", "path", destPath, "remote", r.RemoteAddr, "user", fs.User, "chunked", true)
+	writeChunk(w, http.StatusOK, chunkResponse{Offset: size, Path: previewURL(destPath)})
+}
@@ -0,0 +1,93 @@
+package preview
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleChunk(t *testing.T) {
+	fs := &common.AuthFS{User: "alice", Fs: afero.NewMemMapFs()}
+	assert.NoError(t, fs.MkdirAll("/data", 0o755))
+	chunks := newChunkStore(filepath.Join(t.TempDir(), "chunks"), 1<<20)
+	opts := uploadOptions{maxSize: 1 << 20}
+	send := func(offset int, body string) (int, chunkResponse) {
+		query := url.Values{"chunk": {"upload-0001"}, "offset": {strconv.Itoa(offset)}, "total": {"10"}, "name": {"big.bin"}}
+		rec := httptest.NewRecorder()
+		handleChunk(rec, httptest.NewRequest(http.MethodPost, "/preview/data/?"+query.Encode(), strings.NewReader(body)), chunks, fs, "/data/", opts)
+		var response chunkResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &response)
+		return rec.Code, response
+	}
+
+	code, response := send(0, "0123")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, int64(4), response.Offset)
+	code, response = send(2, "2345")
+	assert.Equal(t, http.StatusConflict, code, "offset 与已接收的长度不一致")
+	assert.Equal(t, int64(4), response.Offset)
+
+	rec := httptest.NewRecorder()
+	handleChunkStatus(rec, chunks, fs, "upload-0001")
+	assert.JSONEq(t, `{"offset":4}`, rec.Body.String(), "中断后查询已接收的长度")
+	rec = httptest.NewRecorder()
+	handleChunkStatus(rec, chunks, &common.AuthFS{User: "bob"}, "upload-0001")
+	assert.JSONEq(t, `{"offset":0}`, rec.Body.String(), "其他用户的上传互不影响")
+
+	code, response = send(4, "456789")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "/preview/data/big.bin", response.Path)
+	content, err := afero.ReadFile(fs, "/data/big.bin")
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(content))
+	assert.Equal(t, int64(0), chunks.offset(chunks.path("alice", "upload-0001")), "完成后删除暂存文件")
+}
+
+func TestHandleChunkLimit(t *testing.T) {
+	fs := &common.AuthFS{User: "alice", Fs: afero.NewMemMapFs()}
+	chunks := newChunkStore(filepath.Join(t.TempDir(), "chunks"), 8)
+	send := func(total int, opts uploadOptions) int {
+		query := url.Values{"chunk": {"upload-0002"}, "offset": {"0"}, "total": {strconv.Itoa(total)}, "name": {"big.bin"}}
+		rec := httptest.NewRecorder()
+		handleChunk(rec, httptest.NewRequest(http.MethodPost, "/preview/data/?"+query.Encode(), strings.NewReader("01")), chunks, fs, "/data/", opts)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, send(9, uploadOptions{maxSize: 1 << 20}), "未设置 max_file_size 时使用 chunk_max_size")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, send(5, uploadOptions{maxSize: 1 << 20, fileLimit: 4}), "存储池的 max_file_size 优先")
+	assert.Equal(t, http.StatusOK, send(8, uploadOptions{maxSize: 1 << 20}))
+
+}
+
+func TestChunkStorePrepare(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不检查目录权限")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	cache, err := os.UserCacheDir()
+	assert.NoError(t, err)
+
+	chunks := newChunkStore("", 8)
+	assert.NoError(t, chunks.prepare())
+	assert.Equal(t, filepath.Join(cache, "webdav-server", "chunks"), chunks.dir, "默认使用固定的目录，重启后可以继续上传")
+	info, err := os.Stat(chunks.dir)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+
+	shared := t.TempDir()
+	assert.NoError(t, os.Chmod(shared, 0o755))
+	assert.ErrorContains(t, newChunkStore(shared, 8).prepare(), "accessible by other users", "拒绝其他用户可以访问的目录")
+}
@@ -0,0 +1,180 @@
+package preview
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+)
+
+const (
+	// clipboardTTL 剪贴板在最后一次修改后保留的时间
+	clipboardTTL = 24 * time.Hour
+	// clipboardMaxItems 一次剪切或复制的条目数上限
+	clipboardMaxItems = 1000
+)
+
+// clipboard 剪贴板中的内容，Paths 为用户文件系统中的路径
+type clipboard struct {
+	Op    string   `json:"op"`
+	Paths []string `json:"paths"`
+
+	updated time.Time
+}
+
+// clipboardStore 以登录会话为键保存剪贴板，同一用户在不同浏览器中的剪贴板互不影响。
+// 只保存在内存中，重启后清空
+type clipboardStore struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*clipboard
+}
+
+func newClipboardStore() *clipboardStore {
+	return &clipboardStore{entries: make(map[[sha256.Size]byte]*clipboard)}
+}
+
+// sessionKey 返回请求所属会话的键，未登录时返回 false。会话 Cookie 只以哈希保存
+func sessionKey(r *http.Request, fs *common.AuthFS) ([sha256.Size]byte, bool) {
+	cookie, err := r.Cookie("webdav_session")
+	if err != nil || fs.User == "guest" {
+		return [sha256.Size]byte{}, false
+	}
+	return sha256.Sum256([]byte(fs.User + "\x00" + cookie.Value)), true
+}
+
+func (s *clipboardStore) get(key [sha256.Size]byte) clipboard {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || time.Since(entry.updated) > clipboardTTL {
+		delete(s.entries, key)
+		return clipboard{Paths: []string{}}
+	}
+	return clipboard{Op: entry.Op, Paths: append([]string{}, entry.Paths...)}
+}
+
+// set 保存剪贴板，paths 为空时清空。顺带清理过期的剪贴板
+func (s *clipboardStore) set(key [sha256.Size]byte, op string, paths []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, entry := range s.entries {
+		if now.Sub(entry.updated) > clipboardTTL {
+			delete(s.entries, k)
+		}
+	}
+	if len(paths) == 0 {
+		delete(s.entries, key)
+		return
+	}
+	s.entries[key] = &clipboard{Op: op, Paths: paths, updated: now}
+}
+
+func writeClipboard(w http.ResponseWriter, board clipboard) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(board)
+}
+
+// handleClipboard 以 JSON 返回当前会话的剪贴板 {op, paths}，为空时 op 为空
+func handleClipboard(w http.ResponseWriter, r *http.Request, clips *clipboardStore, fs *common.AuthFS) {
+	key, ok := sessionKey(r, fs)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	writeClipboard(w, clips.get(key))
+}
+
+// handleClip 将目录 p 中的条目放入剪贴板，表单字段 op 为 cut 或 copy，name 可以出现多次；op 为空时清空剪贴板
+func handleClip(w http.ResponseWriter, r *http.Request, clips *clipboardStore, fs *common.AuthFS, p string) {
+	key, ok := sessionKey(r, fs)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "参数错误", http.StatusBadRequest)
+		return
+	}
+	op := r.FormValue("op")
+	if op == "" {
+		clips.set(key, "", nil)
+		writeClipboard(w, clips.get(key))
+		return
+	}
+	names := r.Form["name"]
+	if (op != "cut" && op != "copy") || len(names) == 0 || len(names) > clipboardMaxItems {
+		http.Error(w, "参数错误", http.StatusBadRequest)
+		return
+	}
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+			http.Error(w, "名称非法", http.StatusBadRequest)
+			return
+		}
+		source := path.Join("/", p, name)
+		if _, err := fs.Stat(source); err != nil {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		paths = append(paths, source)
+	}
+	clips.set(key, op, paths)
+	writeClipboard(w, clips.get(key))
+}
+
+// pasteResult 粘贴单个条目的结果，成功时 Path 为目标的预览地址
+type pasteResult struct {
+	Source string `json:"source"`
+	Path   string `json:"path,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// handlePaste 将剪贴板中的条目移动或复制到目录 p 中，逐个执行，单个条目失败不影响其他条目。
+// 剪切的条目粘贴成功后从剪贴板中移除，复制的条目保留，可以多次粘贴
+func handlePaste(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, clips *clipboardStore, fs *common.AuthFS, p string) {
+	key, ok := sessionKey(r, fs)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "参数错误", http.StatusBadRequest)
+		return
+	}
+	board := clips.get(key)
+	if board.Op == "" {
+		http.Error(w, "剪贴板为空", http.StatusConflict)
+		return
+	}
+	dest := "/" + strings.Trim(p, "/")
+	if poolUnavailable(w, ctx, dest) {
+		return
+	}
+	rename := r.FormValue("conflict") == common.ConflictRename
+	results := make([]pasteResult, 0, len(board.Paths))
+	remain := make([]string, 0)
+	for _, source := range board.Paths {
+		result := pasteResult{Source: source}
+		target, terr := transfer(r, ctx, fs, source, dest, board.Op == "copy", rename)
+		if terr != nil {
+			result.Error = terr.msg
+			remain = append(remain, source)
+		} else {
+			result.Path = previewURL(target)
+		}
+		results = append(results, result)
+	}
+	if board.Op == "cut" {
+		clips.set(key, board.Op, remain)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(results)
+}
@@ -0,0 +1,72 @@
+package preview
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClipboardPaste(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0o644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(src, "docs"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "docs", "b.txt"), []byte("b"), 0o644))
+	cfg := &common.Config{
+		Users: map[string]common.ConfigUser{"alice": {Password: "pw"}},
+		Pools: map[string]common.ConfigPool{
+			"src": {Path: src, DefaultPerm: "rw"},
+			"dst": {Path: dst, DefaultPerm: "rw"},
+		},
+	}
+	ctx, err := common.NewContext(t.Context(), cfg)
+	assert.NoError(t, err)
+	authFS, err := ctx.LoadFS("alice", "pw", nil, false)
+	assert.NoError(t, err)
+	clips := newClipboardStore()
+	session := &http.Cookie{Name: "webdav_session", Value: ctx.SignToken("alice")}
+
+	post := func(query, p string, form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/preview"+p+"?"+query, strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		if query == "clip" {
+			handleClip(rec, request, clips, authFS, p)
+		} else {
+			handlePaste(rec, request, ctx, clips, authFS, p)
+		}
+		return rec
+	}
+
+	rec := post("clip", "/src/", url.Values{"op": {"cut"}, "name": {"a.txt", "docs"}}, session)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	other := &http.Cookie{Name: "webdav_session", Value: "other"}
+	assert.Equal(t, http.StatusConflict, post("paste", "/dst/", nil, other).Code, "剪贴板属于各自的会话")
+
+	rec = post("paste", "/dst/", nil, session)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var results []pasteResult
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+	assert.Len(t, results, 2)
+	content, err := os.ReadFile(filepath.Join(dst, "docs", "b.txt"))
+	assert.NoError(t, err, "跨存储池移动目录")
+	assert.Equal(t, "b", string(content))
+	_, err = os.Stat(filepath.Join(src, "a.txt"))
+	assert.True(t, os.IsNotExist(err), "剪切的文件已移走")
+	assert.Equal(t, http.StatusConflict, post("paste", "/src/", nil, session).Code, "剪切粘贴后剪贴板清空")
+
+	post("clip", "/dst/", url.Values{"op": {"copy"}, "name": {"a.txt"}}, session)
+	rec = post("paste", "/dst/", url.Values{"conflict": {common.ConflictRename}}, session)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+	assert.Empty(t, results[0].Error)
+	_, err = os.Stat(filepath.Join(dst, "a (1).txt"))
+	assert.NoError(t, err, "复制到所在目录时生成副本")
+}
@@ -0,0 +1,145 @@
+package preview
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+)
+
+// SHA-256 的计算状态
+const (
+	checksumDone     = "done"
+	checksumPending  = "pending"
+	checksumError    = "error"
+	checksumTooLarge = "too_large"
+)
+
+const (
+	// hashConcurrency 同时计算的文件数，避免大量请求占满磁盘带宽
+	hashConcurrency = 2
+	// hashMaxEntries 缓存的最大数量，超过时丢弃一半已完成的结果
+	hashMaxEntries = 10000
+)
+
+// hashStore 在后台计算文件的 SHA-256 并缓存结果，文件的大小或修改时间变化后重新计算
+type hashStore struct {
+	maxSize int64
+	limit   chan struct{}
+
+	// mu 保护条目的查找与创建，以及条目的 status 与 sum
+	mu    sync.Mutex
+	cache *mergefs.HashCache[*hashEntry]
+}
+
+type hashEntry struct {
+	status string
+	sum    string
+}
+
+func newHashStore(maxSize int64) *hashStore {
+	s := &hashStore{maxSize: maxSize, limit: make(chan struct{}, hashConcurrency)}
+	// 淘汰时保留计算中的条目，Put 只在持有 mu 时调用
+	s.cache = mergefs.NewHashCache(hashMaxEntries, func(entry *hashEntry) bool {
+		return entry.status == checksumPending
+	})
+	return s
+}
+
+// get 返回文件的 SHA-256 与状态。未计算或文件已变化时在后台开始计算并返回 pending，
+// 计算在 base 结束前持续进行，不受发起请求的连接影响
+func (s *hashStore) get(base context.Context, fs afero.Fs, key, name string, info os.FileInfo) (string, string) {
+	if info.Size() > s.maxSize {
+		return "", checksumTooLarge
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	size, modTime := info.Size(), info.ModTime().UnixNano()
+	if entry, ok := s.cache.Get(key, size, modTime); ok && entry.status != checksumError {
+		return entry.sum, entry.status
+	}
+	entry := &hashEntry{status: checksumPending}
+	s.cache.Put(key, size, modTime, entry)
+	go s.compute(base, fs, name, entry)
+	return "", checksumPending
+}
+
+func (s *hashStore) compute(ctx context.Context, fs afero.Fs, name string, entry *hashEntry) {
+	sum, err := func() (string, error) {
+		select {
+		case s.limit <- struct{}{}:
+			defer func() { <-s.limit }()
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		file, err := fs.Open(name)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		hasher := sha256.New()
+		if _, err := io.Copy(hasher, mergefs.NewContextReader(ctx, file)); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(hasher.Sum(nil)), nil
+	}()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		slog.Warn("|preview| Checksum failed.", "path", name, "err", err)
+		entry.status = checksumError
+		return
+	}
+	entry.sum, entry.status = sum, checksumDone
+}
+
+// fileDetails 文件详情，Checksum 为 SHA-256 的计算状态
+type fileDetails struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mtime"`
+	Mode     string    `json:"mode"`
+	IsDir    bool      `json:"isDir"`
+	SHA256   string    `json:"sha256,omitempty"`
+	Checksum string    `json:"checksum,omitempty"`
+}
+
+// handleDetails 以 JSON 返回文件的大小、修改时间、权限与 SHA-256。SHA-256 在后台计算，
+// 状态为 pending 时客户端稍后再次请求
+func handleDetails(w http.ResponseWriter, ctx *common.FsContext, hashes *hashStore, fs *common.AuthFS, p string) {
+	name := "/" + strings.Trim(p, "/")
+	info, err := fs.Stat(name)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	details := fileDetails{
+		Name:    info.Name(),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Mode:    info.Mode().String(),
+		IsDir:   info.IsDir(),
+	}
+	if !info.IsDir() {
+		base := ctx.Context()
+		if base == nil {
+			base = context.Background()
+		}
+		// 不同用户的同一路径可能是不同的文件
+		details.SHA256, details.Checksum = hashes.get(base, fs, fs.User+":"+name, name, info)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(details)
+}
@@ -0,0 +1,46 @@
+package preview
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleDetails(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/docs/a.txt", []byte("hello"), 0o644))
+	assert.NoError(t, afero.WriteFile(fs, "/docs/big.bin", make([]byte, 16), 0o644))
+	ctx := &common.FsContext{Config: &common.Config{}}
+	authFS := &common.AuthFS{User: "alice", Fs: fs}
+	hashes := newHashStore(8)
+
+	details := func(p string) fileDetails {
+		rec := httptest.NewRecorder()
+		handleDetails(rec, ctx, hashes, authFS, p)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var result fileDetails
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		return result
+	}
+
+	assert.Eventually(t, func() bool {
+		return details("docs/a.txt").Checksum == checksumDone
+	}, 5*time.Second, 10*time.Millisecond, "SHA-256 应在后台计算完成")
+	result := details("docs/a.txt")
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", result.SHA256)
+	assert.Equal(t, int64(5), result.Size)
+	assert.Equal(t, "-rw-r--r--", result.Mode)
+
+	assert.Equal(t, checksumTooLarge, details("docs/big.bin").Checksum, "超过上限的文件不计算")
+	assert.Empty(t, details("docs").Checksum, "目录没有 SHA-256")
+
+	rec := httptest.NewRecorder()
+	handleDetails(rec, ctx, hashes, authFS, "docs/missing")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
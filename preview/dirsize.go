@@ -0,0 +1,140 @@
+package preview
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/spf13/afero"
+)
+
+const (
+	// dirSizeConcurrency 同时统计的目录数，避免大量请求占满磁盘
+	dirSizeConcurrency = 2
+	// dirSizeTTL 统计完成后结果保留的时间，之后的请求重新统计
+	dirSizeTTL = time.Minute
+)
+
+// dirSizeStore 在后台递归统计目录的大小与条目数，统计期间可以查询进度。状态与 SHA-256 的计算状态相同
+type dirSizeStore struct {
+	limit chan struct{}
+
+	mu      sync.Mutex
+	entries map[string]*dirSizeEntry
+}
+
+type dirSizeEntry struct {
+	size  atomic.Int64
+	files atomic.Int64
+	dirs  atomic.Int64
+
+	// 以下字段由 dirSizeStore.mu 保护
+	status   string
+	finished time.Time
+}
+
+// dirSize 目录的统计结果，Status 为 pending 时是目前的进度
+type dirSize struct {
+	Status string `json:"status"`
+	Size   int64  `json:"size"`
+	Files  int64  `json:"files"`
+	Dirs   int64  `json:"dirs"`
+}
+
+func newDirSizeStore() *dirSizeStore {
+	return &dirSizeStore{limit: make(chan struct{}, dirSizeConcurrency), entries: make(map[string]*dirSizeEntry)}
+}
+
+// get 返回目录 name 的统计结果。没有结果或结果已过期时在后台开始统计，
+// 统计在 base 结束前持续进行，不受发起请求的连接影响
+func (s *dirSizeStore) get(base context.Context, fs afero.Fs, key, name string) dirSize {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, e := range s.entries {
+		if e.status != checksumPending && now.Sub(e.finished) > dirSizeTTL {
+			delete(s.entries, k)
+		}
+	}
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &dirSizeEntry{status: checksumPending}
+		s.entries[key] = entry
+		go s.compute(base, fs, name, entry)
+	}
+	return dirSize{Status: entry.status, Size: entry.size.Load(), Files: entry.files.Load(), Dirs: entry.dirs.Load()}
+}
+
+func (s *dirSizeStore) compute(ctx context.Context, fs afero.Fs, name string, entry *dirSizeEntry) {
+	err := func() error {
+		select {
+		case s.limit <- struct{}{}:
+			defer func() { <-s.limit }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return walkDirSize(ctx, fs, name, entry)
+	}()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.finished = time.Now()
+	if err != nil {
+		slog.Warn("|preview| Directory size failed.", "path", name, "err", err)
+		entry.status = checksumError
+		return
+	}
+	entry.status = checksumDone
+}
+
+// walkDirSize 递归累加 dir 下文件的大小与条目数，无法读取的子目录被跳过
+func walkDirSize(ctx context.Context, fs afero.Fs, dir string, entry *dirSizeEntry) error {
+	entries, err := afero.ReadDir(fs, dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			entry.files.Add(1)
+			entry.size.Add(info.Size())
+			continue
+		}
+		entry.dirs.Add(1)
+		name := path.Join(dir, info.Name())
+		if err := walkDirSize(ctx, fs, name, entry); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			slog.Debug("|preview| Directory size entry skipped.", "path", name, "err", err)
+		}
+	}
+	return nil
+}
+
+// handleDirSize 以 JSON 返回目录 p 的递归大小与文件、子目录数。统计在后台进行，
+// 状态为 pending 时返回目前的进度，客户端稍后再次请求
+func handleDirSize(w http.ResponseWriter, ctx *common.FsContext, sizes *dirSizeStore, fs *common.AuthFS, p string) {
+	name := "/" + strings.Trim(p, "/")
+	info, err := fs.Stat(name)
+	if err != nil || !info.IsDir() {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	base := ctx.Context()
+	if base == nil {
+		base = context.Background()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	// 不同用户的同一路径可能是不同的目录
+	_ = json.NewEncoder(w).Encode(sizes.get(base, fs, fs.User+":"+name, name))
+}
@@ -0,0 +1,44 @@
+package preview
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleDirSize(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/docs/a.txt", []byte("hello"), 0o644))
+	assert.NoError(t, afero.WriteFile(fs, "/docs/sub/b.bin", make([]byte, 16), 0o644))
+	assert.NoError(t, afero.WriteFile(fs, "/docs/sub/deep/c.bin", make([]byte, 100), 0o644))
+	ctx := &common.FsContext{Config: &common.Config{}}
+	authFS := &common.AuthFS{User: "alice", Fs: fs}
+	sizes := newDirSizeStore()
+
+	size := func(p string) dirSize {
+		rec := httptest.NewRecorder()
+		handleDirSize(rec, ctx, sizes, authFS, p)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var result dirSize
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		return result
+	}
+
+	assert.Eventually(t, func() bool {
+		return size("docs").Status == checksumDone
+	}, 5*time.Second, 10*time.Millisecond, "目录大小应在后台统计完成")
+	assert.Equal(t, dirSize{Status: checksumDone, Size: 121, Files: 3, Dirs: 2}, size("docs"))
+
+	assert.NoError(t, afero.WriteFile(fs, "/docs/new.txt", []byte("x"), 0o644))
+	assert.Equal(t, int64(121), size("docs").Size, "结果过期前沿用缓存")
+
+	rec := httptest.NewRecorder()
+	handleDirSize(rec, ctx, sizes, authFS, "docs/a.txt")
+	assert.Equal(t, http.StatusNotFound, rec.Code, "文件不能统计目录大小")
+}
@@ -0,0 +1,45 @@
+package preview
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// wantsDownload 请求带有 ?dl=1 时浏览器下载文件而不是打开文件
+func wantsDownload(r *http.Request) bool {
+	dl, _ := strconv.ParseBool(r.URL.Query().Get("dl"))
+	return dl
+}
+
+// contentDisposition 返回 Content-Disposition 头，disposition 为 inline 或 attachment。
+// filename 为只含 ASCII 的替代名称，供不支持 RFC 5987 的客户端使用；filename* 为 UTF-8 编码的原名
+func contentDisposition(disposition, name string) string {
+	var fallback, encoded strings.Builder
+	for _, c := range []byte(name) {
+		if c < 0x20 || c >= 0x7f || c == '"' || c == '\\' || c == '%' {
+			// 多字节字符只替换首字节，跳过后续字节，每个字符对应一个 _
+			if c < 0x80 || c >= 0xc0 {
+				fallback.WriteByte('_')
+			}
+		} else {
+			fallback.WriteByte(c)
+		}
+		if isAttrChar(c) {
+			encoded.WriteByte(c)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+	return disposition + `; filename="` + fallback.String() + `"; filename*=UTF-8''` + encoded.String()
+}
+
+// isAttrChar 判断 c 是否为 RFC 5987 中无需编码的 attr-char
+func isAttrChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) != -1
+}
@@ -0,0 +1,35 @@
+package preview
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentDisposition(t *testing.T) {
+	assert.Equal(t, `attachment; filename="a b.txt"; filename*=UTF-8''a%20b.txt`, contentDisposition("attachment", "a b.txt"))
+	assert.Equal(t, `inline; filename="__.pdf"; filename*=UTF-8''%E6%8A%A5%E5%91%8A.pdf`, contentDisposition("inline", "报告.pdf"),
+		"非 ASCII 字符在 filename 中替换，在 filename* 中编码")
+	assert.Equal(t, `attachment; filename="_x___.txt"; filename*=UTF-8''%22x%5C%25%22.txt`, contentDisposition("attachment", `"x\%".txt`),
+		"引号与反斜杠不能出现在 filename 中")
+}
+
+func TestServeFileDownload(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/data/报告.txt", []byte("hello"), 0o644))
+	info, err := fs.Stat("/data/报告.txt")
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	serveFile(rec, httptest.NewRequest(http.MethodGet, "/preview/data/x", nil), fs, "/data/报告.txt", info, false)
+	assert.Equal(t, `inline; filename="__.txt"; filename*=UTF-8''%E6%8A%A5%E5%91%8A.txt`, rec.Header().Get("Content-Disposition"))
+
+	rec = httptest.NewRecorder()
+	serveFile(rec, httptest.NewRequest(http.MethodGet, "/preview/data/x?dl=1", nil), fs, "/data/报告.txt", info, false)
+	assert.Equal(t, `attachment; filename="__.txt"; filename*=UTF-8''%E6%8A%A5%E5%91%8A.txt`, rec.Header().Get("Content-Disposition"),
+		"?dl=1 强制下载")
+	assert.Equal(t, "hello", rec.Body.String())
+}
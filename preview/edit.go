@@ -0,0 +1,134 @@
+package preview
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"code.d7z.net/packages/webdav-server/assets"
+	"code.d7z.net/packages/webdav-server/common"
+	"code.d7z.net/packages/webdav-server/events"
+	"code.d7z.net/packages/webdav-server/mergefs"
+)
+
+var errNotText = errors.New("not a text file")
+
+// EditorData 编辑页的数据
+type EditorData struct {
+	Path    string
+	Name    string
+	Content string
+	// ETag 打开编辑页时文件的版本，保存时通过 If-Match 检查文件是否已被修改
+	ETag     string
+	ReadOnly bool
+}
+
+// editETag 以修改时间与大小生成文件的版本标识
+func editETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+// readText 读取不超过 maxSize 的 UTF-8 文本文件，二进制文件返回 errNotText
+func readText(fs *common.AuthFS, name string, maxSize int64) ([]byte, os.FileInfo, error) {
+	info, err := fs.Stat(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() || info.Size() > maxSize {
+		return nil, info, errNotText
+	}
+	file, err := fs.Open(name)
+	if err != nil {
+		return nil, info, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return nil, info, err
+	}
+	if int64(len(data)) > maxSize || !utf8.Valid(data) || bytes.IndexByte(data, 0) != -1 {
+		return nil, info, errNotText
+	}
+	return data, info, nil
+}
+
+// handleEdit 返回文本文件的编辑页，超过 edit_max_size 或不是 UTF-8 文本的文件无法编辑
+func handleEdit(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS, p string) {
+	name := "/" + strings.Trim(p, "/")
+	data, info, err := readText(fs, name, int64(ctx.Config.Preview.EditMaxSize))
+	switch {
+	case errors.Is(err, errNotText):
+		http.Error(w, "文件过大或不是文本文件", http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	etag := editETag(info)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("ETag", etag)
+	_ = assets.ZEditor.Execute(w, assets.Language(w, r), EditorData{
+		Path:     strings.Trim(path.Dir(name), "/"),
+		Name:     path.Base(name),
+		Content:  string(data),
+		ETag:     etag,
+		ReadOnly: fs.User == "guest" || !ctx.Writable(fs.User, name),
+	})
+}
+
+// handleSave 以请求体替换文本文件的内容。请求带有 If-Match 时，文件在打开编辑页后被修改则返回 412，
+// 避免覆盖其他人的修改。内容先写入临时文件再替换，保存失败时原文件不变
+func handleSave(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS, p string) {
+	name := "/" + strings.Trim(p, "/")
+	maxSize := int64(ctx.Config.Preview.EditMaxSize)
+	info, err := fs.Stat(name)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if info.IsDir() {
+		http.Error(w, "目录无法编辑", http.StatusBadRequest)
+		return
+	}
+	if value := r.Header.Get("If-Match"); value != "" && value != "*" && value != editETag(info) {
+		w.Header().Set("ETag", editETag(info))
+		http.Error(w, "文件已被修改，请重新打开后编辑", http.StatusPreconditionFailed)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSize))
+	if err != nil {
+		http.Error(w, "内容超过可编辑的大小", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !utf8.Valid(data) {
+		http.Error(w, "内容不是 UTF-8 文本", http.StatusBadRequest)
+		return
+	}
+	if err := mergefs.WriteFileAtomic(r.Context(), fs, name, data, info.Mode().Perm()); err != nil {
+		slog.Warn("|preview| Save failed.", "path", name, "user", fs.User, "err", err)
+		if errors.Is(err, os.ErrPermission) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		http.Error(w, "保存失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ctx.PublishChange(fs.User, name, events.OpWrite)
+	slog.Info("|preview| Save.", "path", name, "size", len(data), "remote", r.RemoteAddr, "user", fs.User)
+	etag := ""
+	if info, err := fs.Stat(name); err == nil {
+		etag = editETag(info)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag)
+	_ = json.NewEncoder(w).Encode(map[string]string{"etag": etag})
+}
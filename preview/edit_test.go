@@ -0,0 +1,48 @@
+package preview
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"code.d7z.net/packages/webdav-server/events"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditAndSave(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/conf/app.yaml", []byte("title: <b>\n"), 0o644))
+	assert.NoError(t, afero.WriteFile(fs, "/conf/blob.bin", []byte{0x00, 0x01}, 0o644))
+	ctx := &common.FsContext{Events: events.NewHub(), Config: &common.Config{Preview: common.ConfigPreview{EditMaxSize: 1024}}}
+	authFS := &common.AuthFS{User: "alice", Fs: fs}
+
+	rec := httptest.NewRecorder()
+	handleEdit(rec, httptest.NewRequest(http.MethodGet, "/preview/conf/app.yaml?edit", nil), ctx, authFS, "conf/app.yaml")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "title: &lt;b&gt;", "内容被转义")
+	etag := rec.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	rec = httptest.NewRecorder()
+	handleEdit(rec, httptest.NewRequest(http.MethodGet, "/preview/conf/blob.bin?edit", nil), ctx, authFS, "conf/blob.bin")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "二进制文件无法编辑")
+
+	save := func(etag, content string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/preview/conf/app.yaml?save", strings.NewReader(content))
+		request.Header.Set("If-Match", etag)
+		rec := httptest.NewRecorder()
+		handleSave(rec, request, ctx, authFS, "/conf/app.yaml")
+		return rec
+	}
+	rec = save(etag, "title: new\n")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	content, _ := afero.ReadFile(fs, "/conf/app.yaml")
+	assert.Equal(t, "title: new\n", string(content))
+
+	assert.Equal(t, http.StatusPreconditionFailed, save(etag, "title: stale\n").Code, "文件已被修改")
+	assert.Equal(t, http.StatusOK, save(rec.Header().Get("ETag"), "title: newer\n").Code, "使用保存后返回的版本继续编辑")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, save("", strings.Repeat("x", 2048)).Code)
+}
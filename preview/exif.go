@@ -0,0 +1,262 @@
+package preview
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var errNoExif = errors.New("no exif data")
+
+// 读取的 EXIF 标签，键为 IFD 中的标签号
+var (
+	exifIFD0Tags = map[uint16]string{
+		0x010F: "Make",
+		0x0110: "Model",
+		0x0112: "Orientation",
+		0x0131: "Software",
+		0x0132: "DateTime",
+	}
+	exifSubTags = map[uint16]string{
+		0x829A: "ExposureTime",
+		0x829D: "FNumber",
+		0x8827: "ISO",
+		0x9003: "DateTimeOriginal",
+		0x920A: "FocalLength",
+		0xA434: "LensModel",
+	}
+)
+
+const (
+	exifSubIFD  = 0x8769
+	exifGPSIFD  = 0x8825
+	exifMaxIFD  = 512
+	exifMaxText = 256
+)
+
+// readJPEGExif 从 JPEG 的 APP1 段中读取 EXIF，只读取图像数据之前的段
+func readJPEGExif(r io.Reader) (map[string]string, error) {
+	br := bufio.NewReader(r)
+	var soi [2]byte
+	if _, err := io.ReadFull(br, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return nil, errNoExif
+	}
+	for {
+		var marker [4]byte
+		if _, err := io.ReadFull(br, marker[:]); err != nil || marker[0] != 0xFF {
+			return nil, errNoExif
+		}
+		// SOS 之后是图像数据，EXIF 不会出现在其后
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			return nil, errNoExif
+		}
+		size := int(binary.BigEndian.Uint16(marker[2:])) - 2
+		if size < 0 {
+			return nil, errNoExif
+		}
+		if marker[1] != 0xE1 {
+			if _, err := br.Discard(size); err != nil {
+				return nil, errNoExif
+			}
+			continue
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, errNoExif
+		}
+		if tiff, ok := bytes.CutPrefix(data, []byte("Exif\x00\x00")); ok {
+			return parseTIFF(tiff)
+		}
+	}
+}
+
+// parseTIFF 解析 EXIF 的 TIFF 结构，读取 IFD0、Exif 子 IFD 与 GPS IFD 中的常用标签
+func parseTIFF(data []byte) (map[string]string, error) {
+	if len(data) < 8 {
+		return nil, errNoExif
+	}
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, errNoExif
+	}
+	t := tiffReader{data: data, order: order}
+	result := make(map[string]string)
+	entries := t.ifd(order.Uint32(data[4:]))
+	for _, e := range entries {
+		if name, ok := exifIFD0Tags[e.tag]; ok {
+			if value := t.format(e); value != "" {
+				result[name] = value
+			}
+		}
+	}
+	if sub, ok := t.find(entries, exifSubIFD); ok {
+		for _, e := range t.ifd(t.uint(sub)) {
+			if name, ok := exifSubTags[e.tag]; ok {
+				if value := t.format(e); value != "" {
+					result[name] = value
+				}
+			}
+		}
+	}
+	if gps, ok := t.find(entries, exifGPSIFD); ok {
+		if value := t.gps(t.ifd(t.uint(gps))); value != "" {
+			result["GPS"] = value
+		}
+	}
+	if len(result) == 0 {
+		return nil, errNoExif
+	}
+	return result, nil
+}
+
+type tiffEntry struct {
+	tag, typ uint16
+	count    uint32
+	value    []byte
+}
+
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// tiffTypeSize TIFF 数据类型的字节数：BYTE、ASCII、SHORT、LONG、RATIONAL
+var tiffTypeSize = map[uint16]uint32{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8}
+
+// ifd 读取 offset 处的 IFD，越界的条目被忽略
+func (t tiffReader) ifd(offset uint32) []tiffEntry {
+	if uint64(offset)+2 > uint64(len(t.data)) {
+		return nil
+	}
+	count := min(int(t.order.Uint16(t.data[offset:])), exifMaxIFD)
+	var entries []tiffEntry
+	for i := range count {
+		start := uint64(offset) + 2 + uint64(i)*12
+		if start+12 > uint64(len(t.data)) {
+			break
+		}
+		raw := t.data[start : start+12]
+		e := tiffEntry{tag: t.order.Uint16(raw), typ: t.order.Uint16(raw[2:]), count: t.order.Uint32(raw[4:])}
+		size, ok := tiffTypeSize[e.typ]
+		if !ok {
+			continue
+		}
+		total := uint64(size) * uint64(e.count)
+		if total <= 4 {
+			e.value = raw[8 : 8+total]
+		} else {
+			valueOffset := uint64(t.order.Uint32(raw[8:]))
+			if valueOffset+total > uint64(len(t.data)) {
+				continue
+			}
+			e.value = t.data[valueOffset : valueOffset+total]
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func (t tiffReader) find(entries []tiffEntry, tag uint16) (tiffEntry, bool) {
+	for _, e := range entries {
+		if e.tag == tag {
+			return e, true
+		}
+	}
+	return tiffEntry{}, false
+}
+
+// uint 读取 SHORT 或 LONG 类型的第一个值
+func (t tiffReader) uint(e tiffEntry) uint32 {
+	switch {
+	case e.typ == 3 && len(e.value) >= 2:
+		return uint32(t.order.Uint16(e.value))
+	case (e.typ == 4 || e.typ == 9) && len(e.value) >= 4:
+		return t.order.Uint32(e.value)
+	}
+	return 0
+}
+
+// rational 读取 RATIONAL 类型的第 i 个值
+func (t tiffReader) rational(e tiffEntry, i int) (uint32, uint32, bool) {
+	if (e.typ != 5 && e.typ != 10) || len(e.value) < (i+1)*8 {
+		return 0, 0, false
+	}
+	num, den := t.order.Uint32(e.value[i*8:]), t.order.Uint32(e.value[i*8+4:])
+	return num, den, den != 0
+}
+
+// format 将条目格式化为便于阅读的字符串
+func (t tiffReader) format(e tiffEntry) string {
+	switch e.tag {
+	case 0x829A:
+		if num, den, ok := t.rational(e, 0); ok && num > 0 {
+			if num >= den {
+				return strconv.FormatFloat(float64(num)/float64(den), 'f', -1, 64) + "s"
+			}
+			return fmt.Sprintf("1/%ds", (den+num/2)/num)
+		}
+		return ""
+	case 0x829D:
+		if num, den, ok := t.rational(e, 0); ok {
+			return "f/" + strconv.FormatFloat(float64(num)/float64(den), 'f', 1, 64)
+		}
+		return ""
+	case 0x920A:
+		if num, den, ok := t.rational(e, 0); ok {
+			return strconv.FormatFloat(float64(num)/float64(den), 'f', -1, 64) + "mm"
+		}
+		return ""
+	}
+	switch e.typ {
+	case 2:
+		text := strings.TrimSpace(string(bytes.TrimRight(e.value, "\x00")))
+		if len(text) > exifMaxText {
+			text = text[:exifMaxText]
+		}
+		return strings.ToValidUTF8(text, "")
+	case 3, 4:
+		return strconv.FormatUint(uint64(t.uint(e)), 10)
+	}
+	return ""
+}
+
+// gps 将 GPS IFD 中的经纬度格式化为十进制度数
+func (t tiffReader) gps(entries []tiffEntry) string {
+	coordinate := func(refTag, valueTag uint16, negative byte) (float64, bool) {
+		value, ok := t.find(entries, valueTag)
+		if !ok {
+			return 0, false
+		}
+		var result float64
+		for i, unit := range []float64{1, 60, 3600} {
+			num, den, ok := t.rational(value, i)
+			if !ok {
+				return 0, false
+			}
+			result += float64(num) / float64(den) / unit
+		}
+		if ref, ok := t.find(entries, refTag); ok && len(ref.value) > 0 && ref.value[0] == negative {
+			result = -result
+		}
+		return result, true
+	}
+	lat, ok := coordinate(1, 2, 'S')
+	if !ok {
+		return ""
+	}
+	lon, ok := coordinate(3, 4, 'W')
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%.6f,%.6f", lat, lon)
+}
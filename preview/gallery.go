@@ -0,0 +1,30 @@
+package preview
+
+import (
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"code.d7z.net/packages/webdav-server/common"
+)
+
+// isImage 判断文件是否为浏览器可直接显示的图片，网格显示时这些文件在灯箱中打开
+func isImage(info os.FileInfo) bool {
+	return !info.IsDir() && slices.Contains(thumbImageExts, strings.ToLower(path.Ext(info.Name())))
+}
+
+// mostlyImages 判断目录中超过一半的文件是可生成缩略图的图片，未指定显示方式时这样的目录使用网格显示
+func mostlyImages(cfg common.ConfigPreviewThumbnails, entries []os.FileInfo) bool {
+	files, images := 0, 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		files++
+		if isImage(entry) && thumbSupported(cfg, entry) {
+			images++
+		}
+	}
+	return images > 0 && images*2 > files
+}
@@ -0,0 +1,25 @@
+package preview
+
+import (
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMostlyImages(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, name := range []string{"/a.jpg", "/b.PNG", "/notes.txt"} {
+		assert.NoError(t, afero.WriteFile(fs, name, []byte("x"), 0o644))
+	}
+	assert.NoError(t, fs.Mkdir("/sub", 0o755))
+	entries, _ := afero.ReadDir(fs, "/")
+	cfg := common.ConfigPreviewThumbnails{Enabled: true, MaxSourceSize: 1 << 20}
+
+	assert.True(t, mostlyImages(cfg, entries), "超过一半的文件是图片")
+	assert.False(t, mostlyImages(common.ConfigPreviewThumbnails{}, entries), "未启用缩略图时默认使用列表")
+	assert.NoError(t, afero.WriteFile(fs, "/more.txt", []byte("x"), 0o644))
+	entries, _ = afero.ReadDir(fs, "/")
+	assert.False(t, mostlyImages(cfg, entries), "图片不超过一半")
+}
@@ -0,0 +1,51 @@
+package preview
+
+import (
+	"net/http"
+	"os"
+	"slices"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+)
+
+// hiddenCookie 保存是否显示隐藏文件的 Cookie 名称
+const hiddenCookie = "webdav_hidden"
+
+// showHidden 判断目录列表与搜索是否显示点文件及 preview.hide 匹配的条目。
+// ?hidden=show|hide 切换并写入 Cookie，之后的页面沿用；没有选择时访客隐藏，登录用户显示
+func showHidden(w http.ResponseWriter, r *http.Request, fs *common.AuthFS) bool {
+	value := r.URL.Query().Get("hidden")
+	if value == "show" || value == "hide" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     hiddenCookie,
+			Value:    value,
+			Path:     "/preview",
+			MaxAge:   86400 * 365,
+			SameSite: http.SameSiteLaxMode,
+		})
+		return value == "show"
+	}
+	if cookie, err := r.Cookie(hiddenCookie); err == nil && (cookie.Value == "show" || cookie.Value == "hide") {
+		return cookie.Value == "show"
+	}
+	return fs.User != "guest"
+}
+
+// hidingFs 返回在目录列表中过滤点文件及 preview.hide 匹配条目的文件系统，规则与存储池的 hide、exclude 相同。
+// 被过滤的条目仍可通过完整路径访问
+func hidingFs(ctx *common.FsContext, fs afero.Fs) *mergefs.HiddenFs {
+	return mergefs.NewHiddenFs(fs, mergefs.HiddenOptions{
+		Dotfiles:      true,
+		Exclude:       ctx.Config.Preview.Hide,
+		AllowExplicit: true,
+	})
+}
+
+// filterHidden 移除目录 dir 的条目中被过滤的条目
+func filterHidden(hider *mergefs.HiddenFs, dir string, entries []os.FileInfo) []os.FileInfo {
+	return slices.DeleteFunc(entries, func(info os.FileInfo) bool {
+		return hider.HidesEntry(dir, info)
+	})
+}
@@ -0,0 +1,51 @@
+package preview
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShowHidden(t *testing.T) {
+	guest := &common.AuthFS{User: "guest"}
+	alice := &common.AuthFS{User: "alice"}
+	request := httptest.NewRequest(http.MethodGet, "/preview/data/", nil)
+	assert.False(t, showHidden(httptest.NewRecorder(), request, guest), "访客默认隐藏")
+	assert.True(t, showHidden(httptest.NewRecorder(), request, alice))
+
+	rec := httptest.NewRecorder()
+	assert.False(t, showHidden(rec, httptest.NewRequest(http.MethodGet, "/preview/data/?hidden=hide", nil), alice))
+	cookies := rec.Result().Cookies()
+	assert.Len(t, cookies, 1, "选择写入 Cookie")
+	request.AddCookie(cookies[0])
+	assert.False(t, showHidden(httptest.NewRecorder(), request, alice), "之后的页面沿用选择")
+}
+
+func TestFilterHidden(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, name := range []string{"/data/.env", "/data/a.txt", "/data/Thumbs.db", "/data/node_modules/x.js"} {
+		assert.NoError(t, afero.WriteFile(fs, name, nil, 0o644))
+	}
+	ctx := &common.FsContext{Config: &common.Config{Preview: common.ConfigPreview{Hide: []string{"Thumbs.db", "node_modules/"}}}}
+	entries, err := afero.ReadDir(fs, "/data")
+	assert.NoError(t, err)
+	var names []string
+	for _, info := range filterHidden(hidingFs(ctx, fs), "data", entries) {
+		names = append(names, info.Name())
+	}
+	assert.Equal(t, []string{"a.txt"}, names, "过滤点文件与 preview.hide 匹配的条目")
+
+	var found []string
+	assert.NoError(t, afero.Walk(hidingFs(ctx, fs), "/data", func(p string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			found = append(found, p)
+		}
+		return nil
+	}))
+	assert.Equal(t, []string{"/data/a.txt"}, found, "搜索同样跳过被过滤的目录")
+}
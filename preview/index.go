@@ -0,0 +1,40 @@
+package preview
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"code.d7z.net/packages/webdav-server/common"
+)
+
+// indexPolicy index.html 与预览页面同源，在沙箱中打开，脚本不能以登录用户的身份调用预览接口
+const indexPolicy = "sandbox allow-scripts allow-forms allow-popups allow-downloads"
+
+// serveIndex 在 preview.index_html 开启时以目录中的 index.html 代替目录列表，返回是否已处理请求。
+// ?listing=1、JSON 请求与被隐藏的 index.html 仍显示列表
+func serveIndex(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS, p string, listing Listing, show bool) bool {
+	if !ctx.Config.Preview.IndexHTML || listing.Force || wantsJSON(r) {
+		return false
+	}
+	name := path.Join("/", p, "index.html")
+	info, err := fs.Stat(name)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	if !show && hidingFs(ctx, fs).HidesEntry(p, info) {
+		return false
+	}
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		// 补全结尾的 /，页面中的相对链接才能指向目录内
+		target := r.URL.Path + "/"
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+		return true
+	}
+	w.Header().Set("Content-Security-Policy", indexPolicy)
+	serveFile(w, r, fs, name, info, false)
+	return true
+}
@@ -0,0 +1,51 @@
+package preview
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeIndex(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/docs/index.html", []byte("<h1>docs</h1>"), 0o644))
+	assert.NoError(t, afero.WriteFile(fs, "/plain/a.txt", []byte("a"), 0o644))
+	ctx := &common.FsContext{Config: &common.Config{Preview: common.ConfigPreview{IndexHTML: true}}}
+	authFS := &common.AuthFS{User: "alice", Fs: fs}
+
+	serve := func(target, p string) (*httptest.ResponseRecorder, bool) {
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		listing := parseListing(request.URL.Query(), 10, 100)
+		return rec, serveIndex(rec, request, ctx, authFS, p, listing, true)
+	}
+
+	rec, ok := serve("/preview/docs/", "docs/")
+	assert.True(t, ok)
+	assert.Equal(t, "<h1>docs</h1>", rec.Body.String())
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "sandbox", "index.html 在沙箱中打开")
+
+	rec, ok = serve("/preview/docs?view=grid", "docs")
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/preview/docs/?view=grid", rec.Header().Get("Location"))
+
+	_, ok = serve("/preview/docs/?listing=1", "docs/")
+	assert.False(t, ok, "?listing=1 显示目录列表")
+	_, ok = serve("/preview/docs/?format=json", "docs/")
+	assert.False(t, ok, "JSON 请求返回目录列表")
+	_, ok = serve("/preview/plain/", "plain/")
+	assert.False(t, ok, "没有 index.html 的目录显示列表")
+
+	ctx.Config.Preview.IndexHTML = false
+	_, ok = serve("/preview/docs/", "docs/")
+	assert.False(t, ok, "未开启时始终显示列表")
+
+	listing := parseListing(map[string][]string{"listing": {"1"}}, 10, 100)
+	assert.Contains(t, listing.PageLink(2), "listing=1", "翻页与排序保留 ?listing=1")
+}
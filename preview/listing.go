@@ -0,0 +1,222 @@
+package preview
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// Listing 目录列表的排序、分页与显示方式，由 ?sort=name|size|mtime&order=asc|desc&page&limit&view=list|grid 指定
+type Listing struct {
+	Sort  string
+	Order string
+	// View 显示方式，为空时按目录内容自动选择
+	View string
+	// Force 由 ?listing=1 指定，目录中有 index.html 时仍显示列表
+	Force bool
+	// Page 当前页，从 1 开始
+	Page  int
+	Pages int
+	Limit int
+	// Total 目录中的条目总数
+	Total int
+}
+
+// parseListing 解析列表参数，无效的值使用默认值：按名称升序，每页 pageSize 条，?limit 不超过 maxPageSize
+func parseListing(query url.Values, pageSize, maxPageSize int) Listing {
+	l := Listing{Sort: "name", Order: "asc", Page: 1, Limit: pageSize}
+	switch sort := query.Get("sort"); sort {
+	case "name", "size", "mtime":
+		l.Sort = sort
+	}
+	if query.Get("order") == "desc" {
+		l.Order = "desc"
+	}
+	if page, err := strconv.Atoi(query.Get("page")); err == nil && page > 0 {
+		l.Page = page
+	}
+	if limit, err := strconv.Atoi(query.Get("limit")); err == nil && limit > 0 {
+		l.Limit = min(limit, maxPageSize)
+	}
+	switch view := query.Get("view"); view {
+	case "list", "grid":
+		l.View = view
+	}
+	l.Force = query.Get("listing") == "1"
+	return l
+}
+
+// apply 排序 entries 并返回当前页的条目，目录始终排在文件之前
+func (l *Listing) apply(entries []os.FileInfo) []os.FileInfo {
+	slices.SortStableFunc(entries, func(a, b os.FileInfo) int {
+		if a.IsDir() != b.IsDir() {
+			if a.IsDir() {
+				return -1
+			}
+			return 1
+		}
+		var c int
+		switch l.Sort {
+		case "size":
+			c = cmp.Compare(a.Size(), b.Size())
+		case "mtime":
+			c = a.ModTime().Compare(b.ModTime())
+		}
+		if c == 0 {
+			c = strings.Compare(a.Name(), b.Name())
+		}
+		if l.Order == "desc" {
+			return -c
+		}
+		return c
+	})
+	l.Total = len(entries)
+	l.Pages = max((l.Total+l.Limit-1)/l.Limit, 1)
+	l.Page = min(l.Page, l.Pages)
+	start := (l.Page - 1) * l.Limit
+	return entries[start:min(start+l.Limit, l.Total)]
+}
+
+// readDirLimit 读取目录中最多 limit 个条目，条目更多时 truncated 为 true 并返回 nil，
+// 避免一次读取并排序几十万个条目。limit 为负数时不限制
+func readDirLimit(fs afero.Fs, p string, limit int) (entries []os.FileInfo, truncated bool, err error) {
+	if limit < 0 {
+		entries, err = afero.ReadDir(fs, p)
+		return entries, false, err
+	}
+	dir, err := fs.Open(p)
+	if err != nil {
+		return nil, false, err
+	}
+	defer dir.Close()
+	entries, err = dir.Readdir(limit + 1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, false, err
+	}
+	if len(entries) > limit {
+		return nil, true, nil
+	}
+	return entries, false, nil
+}
+
+// query 生成保留其他参数的列表地址
+func (l Listing) query(sort, order string, page int) string {
+	values := url.Values{}
+	values.Set("sort", sort)
+	values.Set("order", order)
+	values.Set("page", strconv.Itoa(page))
+	values.Set("limit", strconv.Itoa(l.Limit))
+	if l.View != "" {
+		values.Set("view", l.View)
+	}
+	if l.Force {
+		values.Set("listing", "1")
+	}
+	return "?" + values.Encode()
+}
+
+// SortLink 表头的排序地址，再次点击当前排序的列时反转顺序
+func (l Listing) SortLink(sort string) string {
+	order := "asc"
+	if sort == l.Sort && l.Order == "asc" {
+		order = "desc"
+	}
+	return l.query(sort, order, 1)
+}
+
+// SortMark 当前排序列的方向标记
+func (l Listing) SortMark(sort string) string {
+	switch {
+	case sort != l.Sort:
+		return ""
+	case l.Order == "desc":
+		return " ↓"
+	}
+	return " ↑"
+}
+
+// Prev 上一页的页码
+func (l Listing) Prev() int {
+	return l.Page - 1
+}
+
+// Next 下一页的页码
+func (l Listing) Next() int {
+	return l.Page + 1
+}
+
+// PageLink 指定页的地址
+func (l Listing) PageLink(page int) string {
+	return l.query(l.Sort, l.Order, page)
+}
+
+// ViewLink 切换显示方式的地址，保留当前的排序与页码
+func (l Listing) ViewLink(view string) string {
+	l.View = view
+	return l.query(l.Sort, l.Order, l.Page)
+}
+
+// wantsJSON 判断请求是否需要 JSON 格式的目录列表：?format=json，
+// 或 Accept 中的第一个类型为 application/json
+func wantsJSON(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "json" {
+		return true
+	}
+	first, _, _ := strings.Cut(r.Header.Get("Accept"), ",")
+	mediaType, _, _ := strings.Cut(first, ";")
+	return strings.EqualFold(strings.TrimSpace(mediaType), "application/json")
+}
+
+// listingEntry JSON 目录列表中的一项
+type listingEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	IsDir   bool      `json:"isDir"`
+	Mime    string    `json:"mime,omitempty"`
+}
+
+type listingResponse struct {
+	Path    string         `json:"path"`
+	Entries []listingEntry `json:"entries"`
+	Sort    string         `json:"sort"`
+	Order   string         `json:"order"`
+	Page    int            `json:"page"`
+	Pages   int            `json:"pages"`
+	Limit   int            `json:"limit"`
+	Total   int            `json:"total"`
+}
+
+// writeListingJSON 以 JSON 返回当前页的目录列表，排序与分页参数与网页相同
+func writeListingJSON(w http.ResponseWriter, p string, l Listing, entries []os.FileInfo) {
+	response := listingResponse{
+		Path:    "/" + strings.Trim(p, "/"),
+		Entries: make([]listingEntry, 0, len(entries)),
+		Sort:    l.Sort,
+		Order:   l.Order,
+		Page:    l.Page,
+		Pages:   l.Pages,
+		Limit:   l.Limit,
+		Total:   l.Total,
+	}
+	for _, entry := range entries {
+		item := listingEntry{Name: entry.Name(), Size: entry.Size(), ModTime: entry.ModTime(), IsDir: entry.IsDir()}
+		if !entry.IsDir() {
+			item.Mime = fileType(entry.Name())
+		}
+		response.Entries = append(response.Entries, item)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Vary", "Accept")
+	_ = json.NewEncoder(w).Encode(response)
+}
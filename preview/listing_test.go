@@ -0,0 +1,102 @@
+package preview
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListing(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	now := time.Now()
+	for i, name := range []string{"b.txt", "a.txt", "c.txt"} {
+		assert.NoError(t, afero.WriteFile(fs, "/"+name, make([]byte, 10*(3-i)), 0o644))
+		assert.NoError(t, fs.Chtimes("/"+name, now, now.Add(time.Duration(i)*time.Minute)))
+	}
+	assert.NoError(t, fs.Mkdir("/z", 0o755))
+	names := func(query string) []string {
+		entries, err := afero.ReadDir(fs, "/")
+		assert.NoError(t, err)
+		values, _ := url.ParseQuery(query)
+		listing := parseListing(values, 2, 10000)
+		var result []string
+		for _, entry := range listing.apply(entries) {
+			result = append(result, entry.Name())
+		}
+		return result
+	}
+	assert.Equal(t, []string{"z", "a.txt"}, names(""), "目录在前，默认按名称排序")
+	assert.Equal(t, []string{"b.txt", "c.txt"}, names("page=2"))
+	assert.Equal(t, []string{"b.txt", "c.txt"}, names("page=9"), "超出范围的页显示最后一页")
+	assert.Equal(t, []string{"z", "c.txt", "a.txt", "b.txt"}, names("sort=mtime&order=desc&limit=10"))
+	assert.Equal(t, []string{"z", "b.txt", "a.txt", "c.txt"}, names("sort=size&order=desc&limit=10"))
+
+	listing := parseListing(url.Values{"sort": {"size"}}, 2, 10000)
+	assert.Equal(t, "?limit=2&order=desc&page=1&sort=size", listing.SortLink("size"), "再次点击反转顺序")
+	assert.Equal(t, "?limit=2&order=asc&page=1&sort=name", listing.SortLink("name"))
+}
+
+func TestListingView(t *testing.T) {
+	listing := parseListing(url.Values{"view": {"grid"}, "page": {"2"}}, 2, 10000)
+	assert.Equal(t, "grid", listing.View)
+	assert.Contains(t, listing.PageLink(3), "view=grid", "翻页保留显示方式")
+	assert.Equal(t, "?limit=2&order=asc&page=2&sort=name&view=list", listing.ViewLink("list"))
+	assert.Empty(t, parseListing(url.Values{"view": {"table"}}, 2, 10000).View, "无效的值按目录内容自动选择")
+	assert.Equal(t, 50, parseListing(url.Values{"limit": {"100"}}, 2, 50).Limit, "limit 不超过 max_page_size")
+}
+
+func TestReadDirLimit(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, name := range []string{"a", "b", "c"} {
+		assert.NoError(t, afero.WriteFile(fs, "/data/"+name, nil, 0o644))
+	}
+	entries, truncated, err := readDirLimit(fs, "/data", 3)
+	assert.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Len(t, entries, 3)
+
+	entries, truncated, err = readDirLimit(fs, "/data", 2)
+	assert.NoError(t, err)
+	assert.True(t, truncated, "条目超过上限时不返回条目")
+	assert.Nil(t, entries)
+
+	entries, truncated, _ = readDirLimit(fs, "/data", -1)
+	assert.False(t, truncated, "负数不限制")
+	assert.Len(t, entries, 3)
+
+	_, _, err = readDirLimit(fs, "/missing", 2)
+	assert.Error(t, err)
+}
+
+func TestListingJSON(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/preview/data/", nil)
+	assert.False(t, wantsJSON(request))
+	request.Header.Set("Accept", "text/html,application/json;q=0.9")
+	assert.False(t, wantsJSON(request), "浏览器优先 HTML")
+	request.Header.Set("Accept", "application/json")
+	assert.True(t, wantsJSON(request))
+	assert.True(t, wantsJSON(httptest.NewRequest(http.MethodGet, "/preview/data/?format=json", nil)))
+
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/data/a.mp4", []byte("video"), 0o644))
+	assert.NoError(t, fs.Mkdir("/data/sub", 0o755))
+	entries, _ := afero.ReadDir(fs, "/data")
+	listing := parseListing(url.Values{}, 10, 10000)
+	rec := httptest.NewRecorder()
+	writeListingJSON(rec, "data/", listing, listing.apply(entries))
+
+	var response listingResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "/data", response.Path)
+	assert.Equal(t, 2, response.Total)
+	assert.Equal(t, "sub", response.Entries[0].Name, "目录在前")
+	assert.True(t, response.Entries[0].IsDir)
+	assert.Equal(t, "video/mp4", response.Entries[1].Mime)
+	assert.Equal(t, int64(5), response.Entries[1].Size)
+}
@@ -0,0 +1,72 @@
+package preview
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"code.d7z.net/packages/webdav-server/assets"
+	"code.d7z.net/packages/webdav-server/common"
+)
+
+// mediaTypes 音视频文件的 Content-Type。系统的 MIME 表通常缺少这些类型，
+// 浏览器收到 application/octet-stream 时只能下载而无法播放
+var mediaTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".webm": "video/webm",
+	".mkv":  "video/x-matroska",
+	".mov":  "video/quicktime",
+	".ogv":  "video/ogg",
+	".avi":  "video/x-msvideo",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".flac": "audio/flac",
+	".wav":  "audio/wav",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/ogg",
+	".weba": "audio/webm",
+}
+
+// mediaType 返回音视频文件的 Content-Type，其他文件为空
+func mediaType(name string) string {
+	return mediaTypes[strings.ToLower(path.Ext(name))]
+}
+
+// mediaKind 返回 video 或 audio，其他文件为空
+func mediaKind(name string) string {
+	kind, _, _ := strings.Cut(mediaType(name), "/")
+	return kind
+}
+
+// PlayerData 播放页的数据
+type PlayerData struct {
+	Path string
+	Name string
+	// Kind 为 video 或 audio
+	Kind string
+	Type string
+	// Src 文件的地址，支持 Range 请求以便拖动进度
+	Src string
+}
+
+// renderMedia 返回内嵌 HTML5 播放器的页面，p 必须是音视频文件
+func renderMedia(w http.ResponseWriter, r *http.Request, _ *common.FsContext, fs *common.AuthFS, p string, _ os.FileInfo) {
+	kind := mediaKind(p)
+	if kind == "" {
+		http.Error(w, "不支持播放的文件", http.StatusBadRequest)
+		return
+	}
+	name := "/" + strings.Trim(p, "/")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = assets.ZPlayer.Execute(w, assets.Language(w, r), PlayerData{
+		Path: strings.Trim(path.Dir(name), "/"),
+		Name: path.Base(name),
+		Kind: kind,
+		Type: mediaType(name),
+		Src:  previewURL(name),
+	})
+}
@@ -0,0 +1,27 @@
+package preview
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMedia(t *testing.T) {
+	assert.Equal(t, "video/x-matroska", mediaType("/movies/a.MKV"))
+	assert.Equal(t, "audio", mediaKind("song.flac"))
+	assert.Empty(t, mediaKind("notes.txt"))
+
+	fs := &common.AuthFS{User: "alice", Fs: afero.NewMemMapFs()}
+	rec := httptest.NewRecorder()
+	renderMedia(rec, httptest.NewRequest(http.MethodGet, "/preview/movies/my%20clip.mp4?play", nil), nil, fs, "movies/my clip.mp4", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `<source src="/preview/movies/my%20clip.mp4" type="video/mp4">`)
+
+	rec = httptest.NewRecorder()
+	renderMedia(rec, httptest.NewRequest(http.MethodGet, "/preview/notes.txt?play", nil), nil, fs, "notes.txt", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "非音视频文件")
+}
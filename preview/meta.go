@@ -0,0 +1,179 @@
+package preview
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+)
+
+const (
+	// metaReadLimit 解析图片尺寸与 EXIF 时最多读取的字节数
+	metaReadLimit = 1024 * 1024
+	// metaProbeTimeout 调用 ffprobe 的时间上限
+	metaProbeTimeout = 10 * time.Second
+	// metaProbeOutput ffprobe 输出的大小上限
+	metaProbeOutput = 256 * 1024
+	// metaMaxTags 返回的音视频标签数上限，超长的值被截断
+	metaMaxTags     = 50
+	metaMaxTagValue = 512
+)
+
+// Metadata 文件的元数据，无法取得的字段被省略
+type Metadata struct {
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mtime"`
+	ContentType string    `json:"type,omitempty"`
+	Width       int       `json:"width,omitempty"`
+	Height      int       `json:"height,omitempty"`
+	// Duration 音视频的时长（秒）
+	Duration float64           `json:"duration,omitempty"`
+	Codecs   []string          `json:"codecs,omitempty"`
+	EXIF     map[string]string `json:"exif,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+}
+
+// handleMeta 以 JSON 返回文件的元数据：图片的尺寸与 EXIF 由服务端直接解析，只读取文件开头的一部分；
+// 配置 preview.ffprobe 后音视频的时长、尺寸、编码与标签由 ffprobe 读取
+func handleMeta(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS, p string) {
+	name := "/" + strings.Trim(p, "/")
+	info, err := fs.Stat(name)
+	if err != nil || info.IsDir() {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	meta := Metadata{Name: info.Name(), Size: info.Size(), ModTime: info.ModTime(), ContentType: mediaType(name)}
+	if meta.ContentType == "" {
+		meta.ContentType = mime.TypeByExtension(path.Ext(name))
+	}
+	switch {
+	case isImage(info):
+		if err := imageMeta(fs, name, &meta); err != nil {
+			slog.Debug("|preview| Image metadata unavailable.", "path", name, "err", err)
+		}
+	case mediaKind(name) != "" && ctx.Config.Preview.FFprobe != "":
+		if err := probeMeta(r.Context(), ctx.Config.Preview.FFprobe, fs, name, &meta); err != nil {
+			slog.Warn("|preview| Media metadata unavailable.", "path", name, "user", fs.User, "err", err)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(meta)
+}
+
+// imageMeta 读取图片的尺寸与 JPEG 的 EXIF
+func imageMeta(fs afero.Fs, name string, meta *Metadata) error {
+	file, err := fs.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	config, _, err := image.DecodeConfig(io.LimitReader(file, metaReadLimit))
+	if err != nil {
+		return err
+	}
+	meta.Width, meta.Height = config.Width, config.Height
+	if ext := strings.ToLower(path.Ext(name)); ext != ".jpg" && ext != ".jpeg" {
+		return nil
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if exif, err := readJPEGExif(io.LimitReader(file, metaReadLimit)); err == nil {
+		meta.EXIF = exif
+	}
+	return nil
+}
+
+// probeResult ffprobe -print_format json -show_format -show_streams 的输出中用到的字段
+type probeResult struct {
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		CodecName string `json:"codec_name"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+	} `json:"streams"`
+	Format struct {
+		Duration string            `json:"duration"`
+		Tags     map[string]string `json:"tags"`
+	} `json:"format"`
+}
+
+// probeMeta 调用 ffprobe 读取音视频的元数据。本地文件直接传入路径，其他存储后端的文件通过标准输入传入
+func probeMeta(ctx context.Context, ffprobe string, fs afero.Fs, name string, meta *Metadata) error {
+	file, err := fs.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	ctx, cancel := context.WithTimeout(ctx, metaProbeTimeout)
+	defer cancel()
+	input := "pipe:0"
+	var stdin io.Reader = file
+	if osFile, ok := mergefs.OsFile(file); ok {
+		input, stdin = "file:"+osFile.Name(), nil
+	}
+	cmd := exec.CommandContext(ctx, ffprobe, "-v", "error", "-print_format", "json", "-show_format", "-show_streams", input)
+	cmd.Stdin = stdin
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &limitedBuffer{buf: &stdout, limit: metaProbeOutput}
+	cmd.Stderr = &limitedBuffer{buf: &stderr, limit: 4096}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffprobe: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var result probeResult
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		return err
+	}
+	if duration, err := strconv.ParseFloat(result.Format.Duration, 64); err == nil {
+		meta.Duration = duration
+	}
+	for _, stream := range result.Streams {
+		if stream.CodecName != "" {
+			meta.Codecs = append(meta.Codecs, stream.CodecType+"/"+stream.CodecName)
+		}
+		if stream.CodecType == "video" && meta.Width == 0 {
+			meta.Width, meta.Height = stream.Width, stream.Height
+		}
+	}
+	for key, value := range result.Format.Tags {
+		if len(meta.Tags) >= metaMaxTags {
+			break
+		}
+		if meta.Tags == nil {
+			meta.Tags = make(map[string]string)
+		}
+		if len(value) > metaMaxTagValue {
+			value = strings.ToValidUTF8(value[:metaMaxTagValue], "")
+		}
+		meta.Tags[strings.ToLower(key)] = value
+	}
+	return nil
+}
+
+// limitedBuffer 超过 limit 的输出被丢弃，避免异常的输出占用内存
+type limitedBuffer struct {
+	buf   *bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remain := b.limit - b.buf.Len(); remain > 0 {
+		b.buf.Write(p[:min(len(p), remain)])
+	}
+	return len(p), nil
+}
@@ -0,0 +1,67 @@
+package preview
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+// testExifJPEG 构造只包含 EXIF 段的 JPEG：IFD0 中的 Make 与 Exif 子 IFD 中的光圈、快门
+func testExifJPEG() []byte {
+	var tiff bytes.Buffer
+	le := binary.LittleEndian
+	write := func(values ...any) {
+		for _, v := range values {
+			_ = binary.Write(&tiff, le, v)
+		}
+	}
+	write([]byte("II"), uint16(42), uint32(8))
+	write(uint16(2), uint16(0x010F), uint16(2), uint32(6), uint32(38), uint16(0x8769), uint16(4), uint32(1), uint32(44), uint32(0))
+	write([]byte("Canon\x00"))
+	write(uint16(2), uint16(0x829D), uint16(5), uint32(1), uint32(74), uint16(0x829A), uint16(5), uint32(1), uint32(82), uint32(0))
+	write(uint32(28), uint32(10), uint32(1), uint32(125))
+
+	var jpeg bytes.Buffer
+	jpeg.Write([]byte{0xFF, 0xD8, 0xFF, 0xE1})
+	_ = binary.Write(&jpeg, binary.BigEndian, uint16(tiff.Len()+8))
+	jpeg.WriteString("Exif\x00\x00")
+	jpeg.Write(tiff.Bytes())
+	jpeg.Write([]byte{0xFF, 0xDA, 0x00, 0x02})
+	return jpeg.Bytes()
+}
+
+func TestReadJPEGExif(t *testing.T) {
+	exif, err := readJPEGExif(bytes.NewReader(testExifJPEG()))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"Make": "Canon", "FNumber": "f/2.8", "ExposureTime": "1/125s"}, exif)
+
+	_, err = readJPEGExif(bytes.NewReader([]byte{0xFF, 0xD8, 0xFF, 0xDA}))
+	assert.ErrorIs(t, err, errNoExif)
+}
+
+func TestHandleMeta(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 30))))
+	assert.NoError(t, afero.WriteFile(fs, "/photos/a.png", buf.Bytes(), 0o644))
+	ctx := &common.FsContext{Config: &common.Config{}}
+
+	rec := httptest.NewRecorder()
+	handleMeta(rec, httptest.NewRequest(http.MethodGet, "/preview/photos/a.png?meta", nil), ctx,
+		&common.AuthFS{User: "alice", Fs: fs}, "photos/a.png")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var meta Metadata
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &meta))
+	assert.Equal(t, "image/png", meta.ContentType)
+	assert.Equal(t, 40, meta.Width)
+	assert.Equal(t, 30, meta.Height)
+}
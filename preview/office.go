@@ -0,0 +1,239 @@
+package preview
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/spf13/afero"
+	"golang.org/x/sync/singleflight"
+)
+
+// officeConcurrency 同时进行的转换数，转换器通常占用大量内存
+const officeConcurrency = 2
+
+var officeExts = []string{".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx", ".odt", ".ods", ".odp", ".rtf"}
+
+// officeConverter 调用外部转换器将 Office 文档转换为 PDF 并缓存。缓存以用户、路径、大小与修改时间为键，
+// 文件被修改后重新转换，旧的缓存在长期未访问后被清理
+type officeConverter struct {
+	cfg    common.ConfigPreviewOffice
+	dir    string
+	client *http.Client
+
+	group singleflight.Group
+	limit chan struct{}
+	stale staleCache
+}
+
+func newOfficeConverter(cfg common.ConfigPreviewOffice) *officeConverter {
+	dir := cfg.CacheDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "webdav-server-office")
+	}
+	return &officeConverter{
+		cfg:    cfg,
+		dir:    dir,
+		client: &http.Client{},
+		limit:  make(chan struct{}, officeConcurrency),
+	}
+}
+
+// officeSupported 判断文件是否可以转换预览
+func officeSupported(cfg common.ConfigPreviewOffice, info os.FileInfo) bool {
+	return cfg.Enabled() && !info.IsDir() && info.Size() <= int64(cfg.MaxSourceSize) &&
+		slices.Contains(officeExts, strings.ToLower(path.Ext(info.Name())))
+}
+
+// cachePath 返回转换结果的缓存文件，用户同样是键的一部分
+func (o *officeConverter) cachePath(user, name string, info os.FileInfo) string {
+	key := fmt.Sprintf("%s\x00%s\x00%d\x00%d", user, name, info.Size(), info.ModTime().UnixNano())
+	sum := sha256.Sum256([]byte(key))
+	id := hex.EncodeToString(sum[:16])
+	return filepath.Join(o.dir, id[:2], id+".pdf")
+}
+
+// get 返回 name 转换后的 PDF 缓存文件，不存在时转换。同一文件的并发请求只转换一次
+func (o *officeConverter) get(ctx context.Context, fs afero.Fs, user, name string, info os.FileInfo) (string, error) {
+	cached := o.cachePath(user, name, info)
+	if _, err := os.Stat(cached); err == nil {
+		now := time.Now()
+		_ = os.Chtimes(cached, now, now)
+		return cached, nil
+	}
+	_, err, _ := o.group.Do(cached, func() (any, error) {
+		select {
+		case o.limit <- struct{}{}:
+			defer func() { <-o.limit }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		ctx, cancel := context.WithTimeout(ctx, o.cfg.Timeout)
+		defer cancel()
+		if err := os.MkdirAll(filepath.Dir(cached), 0o700); err != nil {
+			return nil, err
+		}
+		// 工作目录与缓存位于同一文件系统，转换结果可以直接重命名到缓存位置
+		work, err := os.MkdirTemp(o.dir, ".work-*")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(work)
+		output, err := o.convert(ctx, fs, name, work)
+		if err != nil {
+			return nil, err
+		}
+		go o.stale.clean(filepath.Join(o.dir, "*", "*.pdf"))
+		return nil, os.Rename(output, cached)
+	})
+	return cached, err
+}
+
+// convert 在工作目录 work 中转换 name，返回生成的 PDF 文件
+func (o *officeConverter) convert(ctx context.Context, fs afero.Fs, name, work string) (string, error) {
+	file, err := fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if o.cfg.URL != "" {
+		return o.convertURL(ctx, file, path.Base(name), work)
+	}
+	return o.convertCommand(ctx, file, path.Base(name), work)
+}
+
+// convertCommand 将源文件复制到工作目录后执行转换命令。源文件使用固定的名称，
+// 文件名不会出现在命令行中
+func (o *officeConverter) convertCommand(ctx context.Context, file io.Reader, base, work string) (string, error) {
+	input := filepath.Join(work, "source"+strings.ToLower(path.Ext(base)))
+	outdir := filepath.Join(work, "out")
+	if err := os.Mkdir(outdir, 0o700); err != nil {
+		return "", err
+	}
+	source, err := os.Create(input)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(source, file)
+	if closeErr := source.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	replacer := strings.NewReplacer("{input}", input, "{outdir}", outdir)
+	args := make([]string, len(o.cfg.Command))
+	for i, arg := range o.cfg.Command {
+		args[i] = replacer.Replace(arg)
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = work
+	var output bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &output, &output
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w: %s", args[0], err, strings.TrimSpace(output.String()))
+	}
+	matches, _ := filepath.Glob(filepath.Join(outdir, "*.pdf"))
+	if len(matches) != 1 {
+		return "", fmt.Errorf("%s: expected one PDF in output directory, found %d", args[0], len(matches))
+	}
+	return matches[0], nil
+}
+
+// convertURL 以 multipart 表单将源文件上传到 Collabora Online 的 convert-to 接口，响应为 PDF
+func (o *officeConverter) convertURL(ctx context.Context, file io.Reader, base, work string) (string, error) {
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		part, err := form.CreateFormFile("data", base)
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		_ = writer.CloseWithError(err)
+	}()
+	// 返回前等待表单写完或被中断，之后源文件才会被关闭
+	defer func() {
+		_ = body.Close()
+		<-done
+	}()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.cfg.URL, body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("converter: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	output := filepath.Join(work, "out.pdf")
+	target, err := os.Create(output)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(target, resp.Body)
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	return output, err
+}
+
+// handleOffice 返回 Office 文档转换后的 PDF，由预览页嵌入显示
+func handleOffice(w http.ResponseWriter, r *http.Request, office *officeConverter, fs *common.AuthFS, p string) {
+	name := "/" + strings.Trim(p, "/")
+	info, err := fs.Stat(name)
+	if err != nil || !officeSupported(office.cfg, info) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	cached, err := office.get(r.Context(), fs, fs.User, name, info)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			slog.Warn("|preview| Office conversion failed.", "path", name, "user", fs.User, "err", err)
+		}
+		http.Error(w, "文档转换失败", http.StatusBadGateway)
+		return
+	}
+	file, err := os.Open(cached)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", contentDisposition("inline", strings.TrimSuffix(info.Name(), path.Ext(info.Name()))+".pdf"))
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
+
+// renderOffice 在预览页中嵌入转换后的 PDF，未配置转换器或文件过大时下载文件
+func renderOffice(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS, p string, info os.FileInfo) {
+	if !officeSupported(ctx.Config.Preview.Office, info) {
+		renderDownload(w, r, ctx, fs, p, info)
+		return
+	}
+	renderView(w, r, newViewData(p, "office"))
+}
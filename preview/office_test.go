@@ -0,0 +1,73 @@
+package preview
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOfficeConverterURL(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		file, header, err := r.FormFile("data")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		_, _ = io.WriteString(w, "%PDF "+header.Filename+" "+string(data))
+	}))
+	defer server.Close()
+
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/docs/报告.docx", []byte("doc"), 0o644))
+	assert.NoError(t, afero.WriteFile(fs, "/docs/a.txt", []byte("text"), 0o644))
+	office := newOfficeConverter(common.ConfigPreviewOffice{
+		URL: server.URL, CacheDir: t.TempDir(), MaxSourceSize: 1024, Timeout: 5 * time.Second,
+	})
+	authFS := &common.AuthFS{User: "alice", Fs: fs}
+
+	for range 2 {
+		rec := httptest.NewRecorder()
+		handleOffice(rec, httptest.NewRequest(http.MethodGet, "/preview/docs/x?office", nil), office, authFS, "docs/报告.docx")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "%PDF 报告.docx doc", rec.Body.String())
+	}
+	assert.Equal(t, int32(1), calls.Load(), "转换结果被缓存")
+
+	rec := httptest.NewRecorder()
+	handleOffice(rec, httptest.NewRequest(http.MethodGet, "/preview/docs/a.txt?office", nil), office, authFS, "docs/a.txt")
+	assert.Equal(t, http.StatusNotFound, rec.Code, "不是 Office 文档")
+}
+
+func TestOfficeConverterCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/docs/a.xlsx", []byte("sheet"), 0o644))
+	info, err := fs.Stat("/docs/a.xlsx")
+	assert.NoError(t, err)
+	office := newOfficeConverter(common.ConfigPreviewOffice{
+		Command:  []string{"sh", "-c", `cp "$0" "$1/a.pdf"`, "{input}", "{outdir}"},
+		CacheDir: t.TempDir(), MaxSourceSize: 1024, Timeout: 5 * time.Second,
+	})
+	cached, err := office.get(t.Context(), fs, "alice", "/docs/a.xlsx", info)
+	assert.NoError(t, err)
+	data, _ := afero.ReadFile(afero.NewOsFs(), cached)
+	assert.Equal(t, "sheet", string(data))
+
+	office.cfg.Command = []string{"sh", "-c", "exit 3"}
+	_, err = office.get(t.Context(), fs, "bob", "/docs/a.xlsx", info)
+	assert.Error(t, err, "命令失败时返回错误")
+}
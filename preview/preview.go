@@ -2,18 +2,24 @@ package preview
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"html/template"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 
 	"code.d7z.net/packages/webdav-server/assets"
 	"code.d7z.net/packages/webdav-server/common"
+	"code.d7z.net/packages/webdav-server/events"
+	"code.d7z.net/packages/webdav-server/mergefs"
 	"github.com/go-chi/chi/v5"
 	"github.com/spf13/afero"
 	"github.com/yuin/goldmark"
@@ -25,13 +31,68 @@ type TemplateData struct {
 	Dirs    []os.FileInfo
 	IsGuest bool
 	Readme  template.HTML
+	Listing Listing
+	// Gallery 以网格显示缩略图，图片在灯箱中浏览
+	Gallery bool
+	// Trash 当前存储池启用了回收站，显示回收站按钮
+	Trash bool
+	// Shares 启用了分享链接，显示分享按钮
+	Shares bool
+	// TooLarge 目录条目超过该数量时不列出条目，提示使用搜索
+	TooLarge int
+	// Hidden 列表中不显示点文件及 preview.hide 匹配的条目
+	Hidden bool
+
+	thumbs  common.ConfigPreviewThumbnails
+	editMax int64
+	config  *common.Config
+}
+
+// Editable 判断列表中的文件是否显示编辑按钮，是否为文本文件在打开编辑页时检查
+func (d TemplateData) Editable(info os.FileInfo) bool {
+	return !info.IsDir() && info.Size() <= d.editMax && !isImage(info) && mediaKind(info.Name()) == ""
+}
+
+// Rendered 判断列表中的文件是否有匹配的预览方式，这些文件链接到 ?render 预览页
+func (d TemplateData) Rendered(info os.FileInfo) bool {
+	_, ok := findRenderer(info.Name())
+	return !info.IsDir() && ok
+}
+
+// IsImage 判断列表中的文件是否在灯箱中打开
+func (d TemplateData) IsImage(info os.FileInfo) bool {
+	return isImage(info)
+}
+
+// Pool 返回预览根目录中存储池的显示名称、说明与图标，其他目录的条目为 nil
+func (d TemplateData) Pool(info os.FileInfo) *common.PoolInfo {
+	if d.config == nil || strings.Trim(d.Path, "/") != "" || !info.IsDir() {
+		return nil
+	}
+	if pool, ok := d.config.PoolInfo(info.Name()); ok {
+		return &pool
+	}
+	return nil
+}
+
+// Thumb 返回列表中文件的缩略图地址，不支持缩略图时为空
+func (d TemplateData) Thumb(info os.FileInfo) string {
+	if !thumbSupported(d.thumbs, info) {
+		return ""
+	}
+	return thumbURL(path.Join("/", d.Path, info.Name()), info)
 }
 
 func WithPreview(ctx *common.FsContext) func(r chi.Router) {
+	chunks := newChunkStore(ctx.Config.Preview.ChunkDir, int64(ctx.Config.Preview.ChunkMaxSize))
+	hashes := newHashStore(int64(ctx.Config.Preview.ChecksumMaxSize))
+	clips := newClipboardStore()
+	sizes := newDirSizeStore()
+	office := newOfficeConverter(ctx.Config.Preview.Office)
 	return func(r chi.Router) {
 		r.Route("/", func(r chi.Router) {
-			r.Get("/*", handleGet(ctx))
-			r.Post("/*", handlePost(ctx))
+			r.Get("/*", handleGet(ctx, chunks, hashes, clips, sizes, office))
+			r.Post("/*", handlePost(ctx, chunks, clips))
 		})
 	}
 }
@@ -48,7 +109,9 @@ func loadPreviewFS(ctx *common.FsContext, r *http.Request) (*common.AuthFS, erro
 	return ctx.LoadFS("guest", "", nil, true)
 }
 
-func handleGet(ctx *common.FsContext) http.HandlerFunc {
+func handleGet(ctx *common.FsContext, chunks *chunkStore, hashes *hashStore, clips *clipboardStore, sizes *dirSizeStore,
+	office *officeConverter,
+) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		fs, err := loadPreviewFS(ctx, r)
 		if err != nil {
@@ -58,26 +121,109 @@ func handleGet(ctx *common.FsContext) http.HandlerFunc {
 		}
 		slog.Info("|preview| Access.", "path", r.URL.Path, "remote", r.RemoteAddr, "user", fs.User)
 		p := strings.TrimPrefix(r.URL.Path, "/preview/")
+		if poolUnavailable(w, ctx, p) {
+			return
+		}
+		if r.URL.Query().Has("trash") {
+			handleTrash(w, ctx, fs, p)
+			return
+		}
+		if r.URL.Query().Has("shares") {
+			handleShares(w, ctx, fs, p)
+			return
+		}
+		if r.URL.Query().Has("clipboard") {
+			handleClipboard(w, r, clips, fs)
+			return
+		}
+		if r.URL.Query().Has("versions") {
+			handleVersions(w, ctx, fs, p)
+			return
+		}
+		if r.URL.Query().Has("chunk") {
+			handleChunkStatus(w, chunks, fs, r.URL.Query().Get("chunk"))
+			return
+		}
+		if r.URL.Query().Has("events") {
+			handleWatch(w, r, ctx, fs, p)
+			return
+		}
+		if r.URL.Query().Has("details") {
+			handleDetails(w, ctx, hashes, fs, p)
+			return
+		}
+		if r.URL.Query().Has("dirsize") {
+			handleDirSize(w, ctx, sizes, fs, p)
+			return
+		}
+		if r.URL.Query().Has("office") {
+			handleOffice(w, r, office, fs, p)
+			return
+		}
+		if r.URL.Query().Has("meta") {
+			handleMeta(w, r, ctx, fs, p)
+			return
+		}
+		if r.URL.Query().Has("dirs") {
+			handleDirs(w, fs, p)
+			return
+		}
+		if r.URL.Query().Has("search") {
+			var searchFs afero.Fs = fs
+			if !showHidden(w, r, fs) {
+				searchFs = hidingFs(ctx, fs)
+			}
+			handleSearch(w, r, ctx, searchFs, p, r.URL.Query().Get("search"))
+			return
+		}
 		stat, err := fs.Stat(p)
 		if err != nil {
 			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 			return
 		}
+		if stat.IsDir() && r.URL.Query().Has("archive") {
+			handleArchive(w, r, fs, p, r.URL.Query().Get("archive"))
+			return
+		}
 		if stat.IsDir() {
-			dir, err := afero.ReadDir(fs, p)
+			show := showHidden(w, r, fs)
+			listing := parseListing(r.URL.Query(), ctx.Config.Preview.PageSize, ctx.Config.Preview.MaxPageSize)
+			if serveIndex(w, r, ctx, fs, p, listing, show) {
+				return
+			}
+			dir, truncated, err := readDirLimit(fs, p, ctx.Config.Preview.MaxEntries)
 			if err != nil {
 				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 				return
 			}
-			slices.SortFunc(dir, func(a, b os.FileInfo) int {
-				if a.IsDir() == b.IsDir() {
-					return strings.Compare(a.Name(), b.Name())
-				} else if a.IsDir() {
-					return -1
+			if !show {
+				dir = filterHidden(hidingFs(ctx, fs), p, dir)
+			}
+			if truncated {
+				slog.Warn("|preview| Directory too large.", "path", p, "limit", ctx.Config.Preview.MaxEntries, "user", fs.User)
+				if wantsJSON(r) {
+					http.Error(w, "目录条目超过 "+strconv.Itoa(ctx.Config.Preview.MaxEntries)+"，请使用搜索", http.StatusUnprocessableEntity)
+					return
 				}
-				return 1
-			})
-
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				_ = assets.ZPreview.Execute(w, assets.Language(w, r), TemplateData{
+					Path:     p,
+					User:     fs.User,
+					IsGuest:  fs.User == "guest",
+					Listing:  listing,
+					TooLarge: ctx.Config.Preview.MaxEntries,
+					Hidden:   !show,
+					Trash:    ctx.TrashEnabled(fs.User, p),
+					Shares:   ctx.Shares != nil && fs.User != "guest",
+					thumbs:   ctx.Config.Preview.Thumbnails,
+					editMax:  int64(ctx.Config.Preview.EditMaxSize),
+				})
+				return
+			}
+			if wantsJSON(r) {
+				writeListingJSON(w, p, listing, listing.apply(dir))
+				return
+			}
 			var readmeHtml template.HTML
 			var readmeName string
 			readmeFiles := []string{"README.md", "README.txt"}
@@ -104,28 +250,76 @@ func handleGet(ctx *common.FsContext) http.HandlerFunc {
 					f.Close()
 				}
 			}
+			gallery := listing.View == "grid" || (listing.View == "" && mostlyImages(ctx.Config.Preview.Thumbnails, dir))
+			page := listing.apply(dir)
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			_ = assets.ZPreview.Execute(w, TemplateData{
+			_ = assets.ZPreview.Execute(w, assets.Language(w, r), TemplateData{
 				Path:    p,
 				User:    fs.User,
-				Dirs:    dir,
+				Dirs:    page,
 				IsGuest: fs.User == "guest",
 				Readme:  readmeHtml,
+				Listing: listing,
+				Gallery: gallery,
+				Hidden:  !show,
+				Trash:   ctx.TrashEnabled(fs.User, p),
+				Shares:  ctx.Shares != nil && fs.User != "guest",
+				thumbs:  ctx.Config.Preview.Thumbnails,
+				editMax: int64(ctx.Config.Preview.EditMaxSize),
+				config:  ctx.Config,
 			})
 		} else {
-			file, err := fs.OpenFile(p, os.O_RDONLY, os.ModePerm)
-			if err != nil {
-				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-				slog.Warn("open file err", "err", err)
+			if r.URL.Query().Has("render") || r.URL.Query().Has("play") {
+				handleRender(w, r, ctx, fs, p, stat)
+				return
+			}
+			if r.URL.Query().Has("edit") {
+				handleEdit(w, r, ctx, fs, p)
 				return
 			}
-			defer file.Close()
-			http.ServeContent(w, r, file.Name(), stat.ModTime(), file)
+			serveFile(w, r, fs, p, stat, false)
 		}
 	}
 }
 
-func handlePost(ctx *common.FsContext) http.HandlerFunc {
+// serveFile 发送文件内容，attachment 为 true 或请求带有 ?dl=1 时浏览器下载而不是打开文件
+func serveFile(w http.ResponseWriter, r *http.Request, fs afero.Fs, p string, stat os.FileInfo, attachment bool) {
+	file, err := fs.OpenFile(p, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		slog.Warn("open file err", "err", err)
+		return
+	}
+	defer file.Close()
+	var content io.ReadSeeker = file
+	if osFile, ok := mergefs.OsFile(file); ok {
+		// 本地文件直接交给 ServeContent 以使用 sendfile
+		content = osFile
+	}
+	if contentType := mediaType(p); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	disposition := "inline"
+	if attachment || wantsDownload(r) {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Disposition", contentDisposition(disposition, stat.Name()))
+	// ServeContent 支持 Range 请求，播放器可以拖动进度而无需下载完整文件
+	http.ServeContent(w, r, stat.Name(), stat.ModTime(), content)
+}
+
+// poolUnavailable 路径所在存储池未通过健康检查时返回 503
+func poolUnavailable(w http.ResponseWriter, ctx *common.FsContext, p string) bool {
+	if err := ctx.PoolError(p); err != nil {
+		slog.Warn("|preview| Pool unavailable.", "path", p, "err", err)
+		w.Header().Set("Retry-After", strconv.Itoa(ctx.RetryAfter()))
+		http.Error(w, "存储池暂时不可用", http.StatusServiceUnavailable)
+		return true
+	}
+	return false
+}
+
+func handlePost(ctx *common.FsContext, chunks *chunkStore, clips *clipboardStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		p := strings.TrimPrefix(r.URL.Path, "/preview")
 		fs, err := loadPreviewFS(ctx, r)
@@ -134,25 +328,78 @@ func handlePost(ctx *common.FsContext) http.HandlerFunc {
 			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 			return
 		}
+		if poolUnavailable(w, ctx, p) {
+			return
+		}
 
 		if r.URL.Query().Has("mkdir") {
-			handleMkdir(w, r, fs, p)
+			handleMkdir(w, r, ctx, fs, p)
 			return
 		}
 		if r.URL.Query().Has("rename") {
-			handleRename(w, r, fs, p)
+			handleRename(w, r, ctx, fs, p)
 			return
 		}
 		if r.URL.Query().Has("delete") {
-			handleDelete(w, r, fs, p)
+			handleDelete(w, r, ctx, fs, p)
+			return
+		}
+		if r.URL.Query().Has("save") {
+			handleSave(w, r, ctx, fs, p)
+			return
+		}
+		if r.URL.Query().Has("move") || r.URL.Query().Has("copy") {
+			handleTransfer(w, r, ctx, fs, p, r.URL.Query().Has("copy"))
+			return
+		}
+		if r.URL.Query().Has("share") {
+			handleShare(w, r, ctx, fs, p)
+			return
+		}
+		if r.URL.Query().Has("clip") {
+			handleClip(w, r, clips, fs, p)
+			return
+		}
+		if r.URL.Query().Has("paste") {
+			handlePaste(w, r, ctx, clips, fs, p)
+			return
+		}
+		if r.URL.Query().Has("unshare") {
+			handleUnshare(w, r, ctx, fs)
+			return
+		}
+		if r.URL.Query().Has("trash_restore") || r.URL.Query().Has("trash_delete") {
+			handleTrashAction(w, r, ctx, fs, p, r.URL.Query().Has("trash_restore"))
+			return
+		}
+		if r.URL.Query().Has("restore_version") {
+			handleRestoreVersion(w, r, ctx, fs, p)
 			return
 		}
 
-		handleUpload(w, r, fs, p, int64(ctx.Config.Preview.MaxUploadSize))
+		opts := uploadOptions{
+			maxSize:   int64(ctx.Config.Preview.MaxUploadSize),
+			fileLimit: ctx.MaxFileSize(p),
+			conflict:  ctx.Config.ConflictPolicy(r),
+			names:     ctx.Config.Filenames,
+			changed: func(name string, op events.Op) {
+				ctx.PublishChange(fs.User, name, op)
+			},
+		}
+		if r.URL.Query().Has("chunk") {
+			// 分块先写入暂存目录，预先检查权限，避免无权写入的用户占用暂存空间
+			if !ctx.Writable(fs.User, p) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			handleChunk(w, r, chunks, fs, p, opts)
+			return
+		}
+		handleUpload(w, r, fs, p, opts)
 	}
 }
 
-func handleMkdir(w http.ResponseWriter, r *http.Request, fs *common.AuthFS, p string) {
+func handleMkdir(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS, p string) {
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "参数错误", http.StatusBadRequest)
 		return
@@ -162,6 +409,11 @@ func handleMkdir(w http.ResponseWriter, r *http.Request, fs *common.AuthFS, p st
 		http.Error(w, "名称非法", http.StatusBadRequest)
 		return
 	}
+	name, err := ctx.Config.Filenames.SanitizeName(name)
+	if err != nil {
+		http.Error(w, "名称非法", http.StatusBadRequest)
+		return
+	}
 	target := filepath.Join(p, name)
 	if _, err := fs.Stat(target); err == nil {
 		http.Error(w, "目录已存在", http.StatusConflict)
@@ -172,11 +424,12 @@ func handleMkdir(w http.ResponseWriter, r *http.Request, fs *common.AuthFS, p st
 		http.Error(w, "创建失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	ctx.PublishChange(fs.User, target, events.OpCreate)
 	slog.Info("|preview| Mkdir.", "path", target, "remote", r.RemoteAddr, "user", fs.User)
 	w.WriteHeader(http.StatusCreated)
 }
 
-func handleRename(w http.ResponseWriter, r *http.Request, fs *common.AuthFS, p string) {
+func handleRename(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS, p string) {
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "参数错误", http.StatusBadRequest)
 		return
@@ -191,6 +444,11 @@ func handleRename(w http.ResponseWriter, r *http.Request, fs *common.AuthFS, p s
 		http.Error(w, "名称非法", http.StatusBadRequest)
 		return
 	}
+	newName, err := ctx.Config.Filenames.SanitizeName(newName)
+	if err != nil {
+		http.Error(w, "名称非法", http.StatusBadRequest)
+		return
+	}
 
 	oldPath := filepath.Join(p, oldName)
 	newPath := filepath.Join(p, newName)
@@ -200,11 +458,13 @@ func handleRename(w http.ResponseWriter, r *http.Request, fs *common.AuthFS, p s
 		http.Error(w, "重命名失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	ctx.PublishChange(fs.User, oldPath, events.OpRename)
+	ctx.PublishChange(fs.User, newPath, events.OpCreate)
 	slog.Info("|preview| Rename.", "old", oldPath, "new", newPath, "remote", r.RemoteAddr, "user", fs.User)
 	w.WriteHeader(http.StatusOK)
 }
 
-func handleDelete(w http.ResponseWriter, r *http.Request, fs *common.AuthFS, p string) {
+func handleDelete(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS, p string) {
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "参数错误", http.StatusBadRequest)
 		return
@@ -220,47 +480,264 @@ func handleDelete(w http.ResponseWriter, r *http.Request, fs *common.AuthFS, p s
 		http.Error(w, "删除失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	ctx.PublishChange(fs.User, target, events.OpRemove)
 	slog.Info("|preview| Delete.", "path", target, "remote", r.RemoteAddr, "user", fs.User)
 	w.WriteHeader(http.StatusOK)
 }
 
-func handleUpload(w http.ResponseWriter, r *http.Request, fs *common.AuthFS, p string, maxSize int64) {
-	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		http.Error(w, "文件过大或解析错误", http.StatusRequestEntityTooLarge)
+// handleVersions 以 JSON 返回文件的历史版本，最新的在前
+func handleVersions(w http.ResponseWriter, ctx *common.FsContext, fs *common.AuthFS, p string) {
+	versionFs, _, rel, err := ctx.LoadVersions(fs.User, p, false)
+	if err != nil {
+		http.Error(w, "未启用历史版本", http.StatusNotFound)
 		return
 	}
+	versions, err := versionFs.List(rel)
+	if err != nil {
+		slog.Warn("list versions failed", "err", err)
+		http.Error(w, "读取历史版本失败", http.StatusInternalServerError)
+		return
+	}
+	if versions == nil {
+		versions = []mergefs.FileVersion{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(versions)
+}
 
-	override := r.FormValue("force") == "true"
-	file, handler, err := r.FormFile("file")
+func handleRestoreVersion(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS, p string) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "参数错误", http.StatusBadRequest)
+		return
+	}
+	id := r.FormValue("id")
+	if id == "" {
+		http.Error(w, "参数缺失", http.StatusBadRequest)
+		return
+	}
+	versionFs, poolName, rel, err := ctx.LoadVersions(fs.User, p, true)
 	if err != nil {
-		http.Error(w, "获取文件失败", http.StatusInternalServerError)
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 		return
 	}
-	defer file.Close()
-	destPath := filepath.Join(p, handler.Filename)
-	stat, err := fs.Stat(destPath)
-	if err == nil {
+	if err := versionFs.Restore(rel, id); err != nil {
+		slog.Warn("restore version failed", "err", err)
+		switch {
+		case os.IsNotExist(err):
+			http.Error(w, "版本不存在", http.StatusNotFound)
+		case os.IsExist(err):
+			http.Error(w, "目标是目录", http.StatusConflict)
+		default:
+			http.Error(w, "恢复失败: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	// 直接修改了存储池，通知缓存刷新
+	ctx.Events.Publish(events.Event{Pool: poolName, Path: rel, Op: events.OpWrite})
+	slog.Info("|preview| Restore version.", "path", p, "version", id, "remote", r.RemoteAddr, "user", fs.User)
+	w.WriteHeader(http.StatusOK)
+}
+
+// uploadOptions 网页上传的限制与冲突处理
+type uploadOptions struct {
+	// maxSize 请求体的大小上限
+	maxSize int64
+	// fileLimit 存储池的单个文件大小上限，为 0 时不限制
+	fileLimit int64
+	// conflict 为 rename 时已存在的文件不被覆盖，另存为 name (1).ext
+	conflict string
+	// names 新文件与目录的名称策略
+	names common.ConfigFilenames
+	// changed 在创建目录与保存文件后调用，为空时不通知
+	changed func(name string, op events.Op)
+}
+
+// uploadResult 单个文件的上传结果，Error 为空时上传成功
+type uploadResult struct {
+	Name  string `json:"name"`
+	Path  string `json:"path,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// handleUpload 处理网页上传，一个请求可包含多个 file 字段。选择文件夹上传时，
+// 每个 file 字段之前的 path 字段给出它的相对路径（webkitRelativePath），缺少的中间目录被创建。
+// 请求体以流的方式读取，文件直接写入目标位置，不在本地暂存；force 需在查询参数或所有 file 字段之前给出。
+// 以 JSON 返回每个文件的结果，全部成功时为 200，否则为第一个失败的状态码
+func handleUpload(w http.ResponseWriter, r *http.Request, fs *common.AuthFS, p string, opts uploadOptions) {
+	r.Body = http.MaxBytesReader(w, r.Body, opts.maxSize)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "文件过大或解析错误", http.StatusBadRequest)
+		return
+	}
+	override := r.URL.Query().Get("force") == "true"
+	results := make([]uploadResult, 0)
+	code := http.StatusOK
+	rel := ""
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// 请求体被截断或超过 max_upload_size，之前保存的文件保留
+			status := http.StatusBadRequest
+			if isMaxBytesError(err) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			if len(results) == 0 {
+				http.Error(w, "文件过大或解析错误", status)
+				return
+			}
+			if code == http.StatusOK {
+				code = status
+				results = append(results, uploadResult{Name: rel, Error: "文件过大或解析错误"})
+			}
+			break
+		}
+		switch part.FormName() {
+		case "path":
+			rel = readFormField(part)
+		case "force":
+			override = readFormField(part) == "true"
+		case "file":
+			name := rel
+			if name == "" {
+				name = part.FileName()
+			}
+			rel = ""
+			result := uploadResult{Name: name}
+			destPath, status, err := saveUpload(fs, p, name, -1, func() (io.ReadCloser, error) { return part, nil }, override, opts)
+			if err != nil {
+				result.Error = err.Error()
+				if code == http.StatusOK {
+					code = status
+				}
+			} else {
+				result.Path = previewURL(destPath)
+				slog.Info("|preview| Upload.", "path", destPath, "remote", r.RemoteAddr, "user", fs.User)
+			}
+			results = append(results, result)
+		}
+		_ = part.Close()
+	}
+	if len(results) == 0 {
+		http.Error(w, "获取文件失败", http.StatusBadRequest)
+		return
+	}
+	if len(results) == 1 && results[0].Path != "" {
+		w.Header().Set("Location", results[0].Path)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string][]uploadResult{"results": results})
+}
+
+// readFormField 读取上传表单中的文本字段，读取失败时由之后的 NextPart 报告错误
+func readFormField(part io.Reader) string {
+	value, _ := io.ReadAll(io.LimitReader(part, 4096))
+	return string(value)
+}
+
+// isMaxBytesError 判断 err 是否因请求体超过 MaxBytesReader 的上限
+func isMaxBytesError(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+// previewURL 返回文件在网页预览中的地址
+func previewURL(name string) string {
+	return (&url.URL{Path: "/preview" + filepath.ToSlash(name)}).EscapedPath()
+}
+
+// errFileLimit 上传的文件超过存储池的单个文件大小上限
+var errFileLimit = errors.New("文件超过存储池的大小限制")
+
+// saveUpload 将 open 返回的大小为 size 的内容保存到 p 下的相对路径 rel，返回保存的路径，失败时返回状态码与原因。
+// size 为 -1 表示大小未知，写入时检查存储池的大小上限
+func saveUpload(fs *common.AuthFS, p, rel string, size int64, open func() (io.ReadCloser, error), override bool,
+	opts uploadOptions,
+) (string, int, error) {
+	if opts.fileLimit > 0 && size > opts.fileLimit {
+		return "", http.StatusRequestEntityTooLarge, errFileLimit
+	}
+	parts := strings.Split(strings.ReplaceAll(rel, "\\", "/"), "/")
+	dir := p
+	for i, part := range parts {
+		if part == "" || part == "." || part == ".." {
+			return "", http.StatusBadRequest, errors.New("名称非法")
+		}
+		target := filepath.Join(dir, part)
+		if _, err := fs.Stat(target); os.IsNotExist(err) && opts.names.Enabled() {
+			name, err := opts.names.SanitizeName(part)
+			if err != nil {
+				return "", http.StatusBadRequest, errors.New("名称非法")
+			}
+			target = filepath.Join(dir, name)
+		}
+		if i == len(parts)-1 {
+			dir = target
+			break
+		}
+		_, statErr := fs.Stat(target)
+		if err := fs.MkdirAll(target, os.ModePerm); err != nil {
+			return "", http.StatusForbidden, errors.New("创建目录失败")
+		}
+		if os.IsNotExist(statErr) && opts.changed != nil {
+			opts.changed(target, events.OpCreate)
+		}
+		dir = target
+	}
+	destPath := dir
+	if stat, err := fs.Stat(destPath); err == nil {
 		if stat.IsDir() {
-			http.Error(w, "目录无法上传内容", http.StatusBadRequest)
-			return
+			return "", http.StatusBadRequest, errors.New("目录无法上传内容")
 		}
-		if !override {
-			http.Error(w, "文件已存在", http.StatusBadRequest)
-			return
+		if !override && opts.conflict == common.ConflictRename {
+			if destPath, err = common.ConflictName(fs, filepath.ToSlash(destPath)); err != nil {
+				return "", http.StatusConflict, errors.New("无法生成新的文件名")
+			}
+		} else if !override {
+			return "", http.StatusBadRequest, errors.New("文件已存在")
 		}
 	}
-	destFile, err := fs.OpenFile(filepath.Join(destPath), os.O_WRONLY|os.O_CREATE, os.ModePerm)
+	file, err := open()
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
-		return
+		return "", http.StatusInternalServerError, errors.New("获取文件失败")
+	}
+	defer file.Close()
+	destFile, err := fs.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return "", http.StatusForbidden, errors.New(http.StatusText(http.StatusForbidden))
+	}
+	var src io.Reader = file
+	if opts.fileLimit > 0 {
+		src = io.LimitReader(file, opts.fileLimit+1)
 	}
-	defer destFile.Close()
-	if _, err = io.Copy(destFile, file); err != nil {
+	written, err := io.Copy(destFile, src)
+	if err == nil && opts.fileLimit > 0 && written > opts.fileLimit {
+		err = errFileLimit
+	}
+	if err != nil {
+		if aborter, ok := destFile.(mergefs.Aborter); ok {
+			_ = aborter.Abort()
+		}
+		_ = destFile.Close()
+		switch {
+		case errors.Is(err, errFileLimit):
+			return "", http.StatusRequestEntityTooLarge, errFileLimit
+		case isMaxBytesError(err):
+			return "", http.StatusRequestEntityTooLarge, errors.New("文件过大或解析错误")
+		}
 		slog.Warn("upload copy failed", "err", err)
-		http.Error(w, "上传失败", http.StatusInternalServerError)
-		return
+		return "", http.StatusInternalServerError, errors.New("上传失败")
 	}
-	slog.Info("|preview| Upload.", "path", destPath, "remote", r.RemoteAddr, "user", fs.User)
-	w.WriteHeader(http.StatusOK)
+	if err = destFile.Close(); err != nil {
+		slog.Warn("upload close failed", "err", err)
+		return "", http.StatusInternalServerError, errors.New("上传失败")
+	}
+	if opts.changed != nil {
+		opts.changed(destPath, events.OpWrite)
+	}
+	return destPath, http.StatusOK, nil
 }
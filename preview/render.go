@@ -0,0 +1,189 @@
+package preview
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"code.d7z.net/packages/webdav-server/assets"
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/yuin/goldmark"
+)
+
+// renderFunc 返回文件 p 的预览页，info 为文件的信息
+type renderFunc func(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS, p string, info os.FileInfo)
+
+// renderer 在网页中打开文件的方式，按扩展名或 MIME 类型匹配文件
+type renderer struct {
+	// name 预览方式的名称，?render=<name> 可以指定预览方式
+	name string
+	// exts 匹配的扩展名（小写），用于 MIME 表中没有类型或类型不准确的文件
+	exts []string
+	// types 匹配的 MIME 类型，以 / 结尾时匹配主类型，如 video/
+	types  []string
+	render renderFunc
+}
+
+// renderers 预览方式，按顺序匹配，第一个匹配的生效。新的预览方式添加到这里即可，
+// 列表中匹配的文件链接到 ?render，不匹配的文件直接由浏览器打开
+var renderers = []renderer{
+	{name: "media", types: []string{"video/", "audio/"}, render: renderMedia},
+	{name: "image", exts: thumbImageExts, render: renderImage},
+	{name: "markdown", exts: []string{".md", ".markdown"}, types: []string{"text/markdown"}, render: renderMarkdown},
+	// 未配置转换器时 renderOffice 直接下载文件，与浏览器打开这些文件的结果相同
+	{name: "office", exts: officeExts, render: renderOffice},
+	{
+		name: "text",
+		exts: []string{".go", ".rs", ".py", ".sh", ".yaml", ".yml", ".toml", ".ini", ".conf", ".log", ".env"},
+		types: []string{
+			"text/", "application/json", "application/xml", "application/javascript",
+			"application/x-sh", "application/yaml", "application/toml",
+		},
+		render: renderText,
+	},
+}
+
+// fallbackRenderer 没有匹配的预览方式或文件无法预览时下载文件
+var fallbackRenderer = renderer{name: "download", render: renderDownload}
+
+// fileType 返回文件的 MIME 类型，音视频使用内置的类型，其他文件按扩展名从 MIME 表查询
+func fileType(name string) string {
+	if ctype := mediaType(name); ctype != "" {
+		return ctype
+	}
+	return mime.TypeByExtension(path.Ext(name))
+}
+
+func (rd renderer) matches(name string) bool {
+	if slices.Contains(rd.exts, strings.ToLower(path.Ext(name))) {
+		return true
+	}
+	ctype, _, _ := strings.Cut(fileType(name), ";")
+	ctype = strings.TrimSpace(ctype)
+	if ctype == "" {
+		return false
+	}
+	for _, t := range rd.types {
+		if ctype == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(ctype, t)) {
+			return true
+		}
+	}
+	return false
+}
+
+// findRenderer 返回打开文件 name 的预览方式，没有匹配时返回 false
+func findRenderer(name string) (renderer, bool) {
+	for _, rd := range renderers {
+		if rd.matches(name) {
+			return rd, true
+		}
+	}
+	return fallbackRenderer, false
+}
+
+// handleRender 以 ?render 指定的预览方式打开文件，未指定时按文件类型选择。?play 与 ?render=media 相同
+func handleRender(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS, p string, info os.FileInfo) {
+	name := r.URL.Query().Get("render")
+	if r.URL.Query().Has("play") {
+		name = "media"
+	}
+	rd, _ := findRenderer(info.Name())
+	if name != "" {
+		idx := slices.IndexFunc(renderers, func(rd renderer) bool { return rd.name == name })
+		switch {
+		case idx != -1:
+			rd = renderers[idx]
+		case name == fallbackRenderer.name:
+			rd = fallbackRenderer
+		default:
+			http.Error(w, "不支持的预览方式", http.StatusBadRequest)
+			return
+		}
+	}
+	slog.Debug("|preview| Render.", "path", p, "renderer", rd.name, "remote", r.RemoteAddr, "user", fs.User)
+	rd.render(w, r, ctx, fs, p, info)
+}
+
+// ViewData 文本、Markdown 与图片预览页的数据
+type ViewData struct {
+	Path string
+	Name string
+	// Kind 为 text、markdown、image 或 office
+	Kind string
+	// Src 文件的地址
+	Src  string
+	Text string
+	HTML template.HTML
+	// Editable 当前用户可以编辑该文本文件
+	Editable bool
+}
+
+func renderView(w http.ResponseWriter, r *http.Request, data ViewData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = assets.ZView.Execute(w, assets.Language(w, r), data)
+}
+
+func newViewData(p, kind string) ViewData {
+	name := "/" + strings.Trim(p, "/")
+	return ViewData{
+		Path: strings.Trim(path.Dir(name), "/"),
+		Name: path.Base(name),
+		Kind: kind,
+		Src:  previewURL(name),
+	}
+}
+
+func renderImage(w http.ResponseWriter, r *http.Request, _ *common.FsContext, _ *common.AuthFS, p string, _ os.FileInfo) {
+	renderView(w, r, newViewData(p, "image"))
+}
+
+// renderText 以只读方式显示文本文件，超过 edit_max_size 或不是 UTF-8 文本的文件改为下载
+func renderText(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS, p string, info os.FileInfo) {
+	data, err := readPreviewText(w, r, ctx, fs, p, info)
+	if err != nil {
+		return
+	}
+	view := newViewData(p, "text")
+	view.Text = string(data)
+	view.Editable = fs.User != "guest" && ctx.Writable(fs.User, "/"+strings.Trim(p, "/"))
+	renderView(w, r, view)
+}
+
+// renderMarkdown 将 Markdown 文件转换为 HTML 显示，其中的原始 HTML 与危险的链接被移除
+func renderMarkdown(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS, p string, info os.FileInfo) {
+	data, err := readPreviewText(w, r, ctx, fs, p, info)
+	if err != nil {
+		return
+	}
+	var buf bytes.Buffer
+	if err := goldmark.Convert(data, &buf); err != nil {
+		renderText(w, r, ctx, fs, p, info)
+		return
+	}
+	view := newViewData(p, "markdown")
+	view.HTML = template.HTML(buf.String())
+	renderView(w, r, view)
+}
+
+// readPreviewText 读取预览的文本，文件无法作为文本显示时下载文件，读取失败时返回 404，两者都返回错误
+func readPreviewText(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS, p string, info os.FileInfo) ([]byte, error) {
+	data, _, err := readText(fs, "/"+strings.Trim(p, "/"), int64(ctx.Config.Preview.EditMaxSize))
+	switch {
+	case errors.Is(err, errNotText):
+		renderDownload(w, r, ctx, fs, p, info)
+	case err != nil:
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	}
+	return data, err
+}
+
+func renderDownload(w http.ResponseWriter, r *http.Request, _ *common.FsContext, fs *common.AuthFS, p string, info os.FileInfo) {
+	serveFile(w, r, fs, p, info, true)
+}
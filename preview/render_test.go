@@ -0,0 +1,68 @@
+package preview
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindRenderer(t *testing.T) {
+	for name, expected := range map[string]string{
+		"movie.MP4": "media",
+		"a.png":     "image",
+		"README.md": "markdown",
+		"main.go":   "text",
+		"notes.txt": "text",
+		"data.json": "text",
+		"plan.docx": "office",
+		"blob.bin":  "download",
+	} {
+		rd, _ := findRenderer(name)
+		assert.Equal(t, expected, rd.name, name)
+	}
+	_, ok := findRenderer("blob.bin")
+	assert.False(t, ok, "没有匹配时列表直接链接到文件")
+}
+
+func TestHandleRender(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("<b>bold</b>"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "doc.md"), []byte("# Title\n\n<script>alert(1)</script>\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "blob.txt"), []byte{0x00, 0x01}, 0o644))
+	cfg := &common.Config{
+		Users:   map[string]common.ConfigUser{"alice": {Password: "pw"}},
+		Pools:   map[string]common.ConfigPool{"data": {Path: dir, DefaultPerm: "rw"}},
+		Preview: common.ConfigPreview{EditMaxSize: 1024},
+	}
+	ctx, err := common.NewContext(t.Context(), cfg)
+	assert.NoError(t, err)
+	fs, err := ctx.LoadFS("alice", "pw", nil, false)
+	assert.NoError(t, err)
+
+	render := func(p, query string) *httptest.ResponseRecorder {
+		info, err := fs.Stat(p)
+		assert.NoError(t, err)
+		rec := httptest.NewRecorder()
+		handleRender(rec, httptest.NewRequest(http.MethodGet, "/preview/"+p+"?"+query, nil), ctx, fs, p, info)
+		return rec
+	}
+
+	rec := render("data/a.txt", "render")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "&lt;b&gt;bold&lt;/b&gt;", "文本被转义")
+	assert.Contains(t, rec.Body.String(), "?edit", "可写的文本文件显示编辑按钮")
+
+	rec = render("data/doc.md", "render")
+	assert.Contains(t, rec.Body.String(), "<h1>Title</h1>")
+	assert.NotContains(t, rec.Body.String(), "<script>alert", "Markdown 中的原始 HTML 被移除")
+	assert.Contains(t, render("data/doc.md", "render=text").Body.String(), "# Title", "指定预览方式")
+
+	rec = render("data/blob.txt", "render")
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), "attachment", "无法显示的文件改为下载")
+	assert.Equal(t, http.StatusBadRequest, render("data/a.txt", "render=unknown").Code)
+}
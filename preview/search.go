@@ -0,0 +1,85 @@
+package preview
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/spf13/afero"
+)
+
+// searchResult 文件名搜索的一项结果，Path 为相对当前目录的路径
+type searchResult struct {
+	Path    string    `json:"path"`
+	Name    string    `json:"name"`
+	IsDir   bool      `json:"dir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+type searchResponse struct {
+	Query   string         `json:"query"`
+	Results []searchResult `json:"results"`
+	// Truncated 达到结果数、深度或时间上限，结果可能不完整
+	Truncated bool `json:"truncated"`
+}
+
+// handleSearch 在 p 下递归搜索名称包含 query 的文件（忽略大小写），以 JSON 返回。
+// 搜索受 preview.search 的深度、时间与结果数限制，避免遍历巨大的目录树
+func handleSearch(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs afero.Fs, p, query string) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		http.Error(w, "参数缺失", http.StatusBadRequest)
+		return
+	}
+	cfg := ctx.Config.Preview.Search
+	searchCtx, cancel := context.WithTimeout(r.Context(), cfg.Timeout)
+	defer cancel()
+	response := searchResponse{Query: query, Results: []searchResult{}}
+	root := "/" + strings.Trim(p, "/")
+	needle := strings.ToLower(query)
+	var walk func(dir string, level int) bool
+	walk = func(dir string, level int) bool {
+		entries, err := afero.ReadDir(fs, dir)
+		if err != nil {
+			return false
+		}
+		for _, entry := range entries {
+			if searchCtx.Err() != nil {
+				return true
+			}
+			name := path.Join(dir, entry.Name())
+			if strings.Contains(strings.ToLower(entry.Name()), needle) {
+				if len(response.Results) >= cfg.MaxResults {
+					return true
+				}
+				response.Results = append(response.Results, searchResult{
+					Path:    strings.TrimPrefix(strings.TrimPrefix(name, root), "/"),
+					Name:    entry.Name(),
+					IsDir:   entry.IsDir(),
+					Size:    entry.Size(),
+					ModTime: entry.ModTime(),
+				})
+			}
+			if entry.IsDir() {
+				if level+1 >= cfg.MaxDepth {
+					response.Truncated = true
+					continue
+				}
+				if walk(name, level+1) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if walk(root, 0) {
+		response.Truncated = true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
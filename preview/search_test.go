@@ -0,0 +1,41 @@
+package preview
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleSearch(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, name := range []string{"/data/Report.pdf", "/data/a/b/report-2.txt", "/data/a/b/c/d/report-deep.txt", "/data/other.txt"} {
+		assert.NoError(t, afero.WriteFile(fs, name, []byte("x"), 0o644))
+	}
+	ctx := &common.FsContext{Config: &common.Config{}}
+	ctx.Config.Preview.Search = common.ConfigPreviewSearch{MaxDepth: 3, Timeout: time.Second, MaxResults: 10}
+	search := func(query string) searchResponse {
+		rec := httptest.NewRecorder()
+		handleSearch(rec, httptest.NewRequest("GET", "/preview/data/", nil).WithContext(context.Background()), ctx, fs, "data/", query)
+		var response searchResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return response
+	}
+	response := search("REPORT")
+	var paths []string
+	for _, result := range response.Results {
+		paths = append(paths, result.Path)
+	}
+	assert.Equal(t, []string{"Report.pdf", "a/b/report-2.txt"}, paths, "忽略大小写，超过深度的目录不再搜索")
+	assert.True(t, response.Truncated)
+
+	ctx.Config.Preview.Search.MaxResults = 1
+	response = search("report")
+	assert.Len(t, response.Results, 1)
+	assert.True(t, response.Truncated, "达到结果上限")
+}
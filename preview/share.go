@@ -0,0 +1,292 @@
+package preview
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/assets"
+	"code.d7z.net/packages/webdav-server/common"
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/go-chi/chi/v5"
+	"github.com/spf13/afero"
+)
+
+// shareCookie 输入密码后保存访问凭据的 Cookie 名称
+const shareCookie = "webdav_share"
+
+// shareItem 分享链接的管理信息，不包含密码
+type shareItem struct {
+	Token        string     `json:"token"`
+	URL          string     `json:"url"`
+	Name         string     `json:"name"`
+	IsDir        bool       `json:"isDir"`
+	Created      time.Time  `json:"created"`
+	Expires      *time.Time `json:"expires,omitempty"`
+	HasPassword  bool       `json:"hasPassword"`
+	DownloadOnly bool       `json:"downloadOnly"`
+}
+
+func newShareItem(share common.Share) shareItem {
+	item := shareItem{
+		Token:        share.Token,
+		URL:          shareLink(share.Token, "", share.IsDir),
+		Name:         path.Base(share.Path),
+		IsDir:        share.IsDir,
+		Created:      share.Created,
+		HasPassword:  share.Password != "",
+		DownloadOnly: share.DownloadOnly,
+	}
+	if !share.Expires.IsZero() {
+		item.Expires = &share.Expires
+	}
+	return item
+}
+
+// shareLink 返回分享链接中子路径的地址，目录以 / 结尾
+func shareLink(token, sub string, isDir bool) string {
+	u := (&url.URL{Path: path.Join("/s", token, sub)}).EscapedPath()
+	if isDir {
+		u += "/"
+	}
+	return u
+}
+
+// sharesEnabled 未启用分享链接或用户为访客时返回 false 并写入错误
+func sharesEnabled(w http.ResponseWriter, ctx *common.FsContext, fs *common.AuthFS) bool {
+	if ctx.Shares == nil {
+		http.Error(w, "未启用分享链接", http.StatusNotFound)
+		return false
+	}
+	if fs.User == "guest" {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// handleShares 以 JSON 返回用户在目录 p 中创建的分享链接
+func handleShares(w http.ResponseWriter, ctx *common.FsContext, fs *common.AuthFS, p string) {
+	if !sharesEnabled(w, ctx, fs) {
+		return
+	}
+	shares := ctx.Shares.List(fs.User, "/"+strings.Trim(p, "/"))
+	items := make([]shareItem, 0, len(shares))
+	for _, share := range shares {
+		items = append(items, newShareItem(share))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+// handleShare 为目录 p 中的 name 创建分享链接。表单字段 expires 为有效期（如 24h），为空时永不过期；
+// password 为访问密码；download_only 为 true 时文件只能下载
+func handleShare(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS, p string) {
+	if !sharesEnabled(w, ctx, fs) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "参数错误", http.StatusBadRequest)
+		return
+	}
+	name := r.FormValue("name")
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		http.Error(w, "参数缺失", http.StatusBadRequest)
+		return
+	}
+	var ttl time.Duration
+	if expires := r.FormValue("expires"); expires != "" {
+		var err error
+		if ttl, err = time.ParseDuration(expires); err != nil || ttl <= 0 {
+			http.Error(w, "有效期错误", http.StatusBadRequest)
+			return
+		}
+	}
+	target := path.Join("/", p, name)
+	info, err := fs.Stat(target)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	share, err := ctx.Shares.Create(fs.User, target, info.IsDir(), ttl, r.FormValue("password"), r.FormValue("download_only") == "true")
+	if err != nil {
+		slog.Warn("|preview| Create share failed.", "path", target, "user", fs.User, "err", err)
+		http.Error(w, "创建失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("|preview| Share created.", "path", target, "expires", share.Expires, "remote", r.RemoteAddr, "user", fs.User)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(newShareItem(share))
+}
+
+// handleUnshare 删除用户创建的分享链接，表单字段 token 为链接的令牌
+func handleUnshare(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS) {
+	if !sharesEnabled(w, ctx, fs) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "参数错误", http.StatusBadRequest)
+		return
+	}
+	if err := ctx.Shares.Remove(fs.User, r.FormValue("token")); err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "链接不存在", http.StatusNotFound)
+			return
+		}
+		slog.Warn("|preview| Remove share failed.", "user", fs.User, "err", err)
+		http.Error(w, "删除失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("|preview| Share removed.", "remote", r.RemoteAddr, "user", fs.User)
+	w.WriteHeader(http.StatusOK)
+}
+
+// ShareData 分享页面的数据，Sub 为分享目录中的子路径
+type ShareData struct {
+	Token        string
+	Name         string
+	Sub          string
+	Dirs         []os.FileInfo
+	DownloadOnly bool
+	NeedPassword bool
+	Error        string
+	Expires      time.Time
+}
+
+// Link 返回分享目录中条目的地址
+func (d ShareData) Link(info os.FileInfo) string {
+	return shareLink(d.Token, path.Join(d.Sub, info.Name()), info.IsDir())
+}
+
+// Parent 返回上一级目录的地址，位于分享的根目录时为空
+func (d ShareData) Parent() string {
+	if d.Sub == "/" {
+		return ""
+	}
+	return shareLink(d.Token, path.Dir(d.Sub), true)
+}
+
+// WithShares 提供 /s/<令牌>/<路径> 分享链接的公开访问，无需登录。
+// 内容经过创建者的文件系统只读访问，创建者失去权限或被删除后链接随之失效
+func WithShares(ctx *common.FsContext) func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Get("/{token}", handleShareAccess(ctx))
+		r.Get("/{token}/*", handleShareAccess(ctx))
+		r.Post("/{token}", handleShareLogin(ctx))
+		r.Post("/{token}/*", handleShareLogin(ctx))
+	}
+}
+
+// shareAuthorized 判断请求能否访问分享链接，设置密码的链接需要输入密码后的凭据
+func shareAuthorized(ctx *common.FsContext, r *http.Request, share common.Share) bool {
+	if share.Password == "" {
+		return true
+	}
+	cookie, err := r.Cookie(shareCookie)
+	return err == nil && ctx.VerifyShare(share, cookie.Value)
+}
+
+func renderShare(w http.ResponseWriter, r *http.Request, status int, data ShareData) {
+	lang := assets.Language(w, r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = assets.ZShare.Execute(w, lang, data)
+}
+
+func handleShareLogin(ctx *common.FsContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		share, ok := ctx.Shares.Get(chi.URLParam(r, "token"))
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		if !share.CheckPassword(r.PostFormValue("password")) {
+			slog.Warn("|security| Login failed.", "source", "share", "remote", r.RemoteAddr, "share", share.Token)
+			renderShare(w, r, http.StatusUnauthorized, ShareData{
+				Token: share.Token, Name: path.Base(share.Path), NeedPassword: true, Error: "密码错误",
+			})
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     shareCookie,
+			Value:    ctx.SignShare(share),
+			Path:     "/s/" + share.Token,
+			HttpOnly: true,
+			Secure:   r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https"),
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+	}
+}
+
+func handleShareAccess(ctx *common.FsContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		share, ok := ctx.Shares.Get(chi.URLParam(r, "token"))
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		if !shareAuthorized(ctx, r, share) {
+			renderShare(w, r, http.StatusUnauthorized, ShareData{Token: share.Token, Name: path.Base(share.Path), NeedPassword: true})
+			return
+		}
+		fs, err := ctx.ShareFS(share)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		sub := mergefs.NormalizePath(chi.URLParam(r, "*"))
+		if !share.IsDir && sub != "/" {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		name := share.Resolve(sub)
+		if poolUnavailable(w, ctx, name) {
+			return
+		}
+		info, err := fs.Stat(name)
+		// 分享的文件被替换为目录（或相反）后链接失效
+		if err != nil || (sub == "/" && info.IsDir() != share.IsDir) {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		slog.Info("|share| Access.", "path", name, "remote", r.RemoteAddr, "owner", share.Owner)
+		if !info.IsDir() {
+			// 分享的文件可能是任意 HTML，在沙箱中打开，不能以本站的身份执行脚本
+			w.Header().Set("Content-Security-Policy", "sandbox")
+			serveFile(w, r, fs, name, info, share.DownloadOnly)
+			return
+		}
+		if format := r.URL.Query().Get("archive"); format != "" {
+			handleArchive(w, r, fs, name, format)
+			return
+		}
+		entries, err := afero.ReadDir(fs, name)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		sort.SliceStable(entries, func(i, j int) bool {
+			if entries[i].IsDir() != entries[j].IsDir() {
+				return entries[i].IsDir()
+			}
+			return entries[i].Name() < entries[j].Name()
+		})
+		renderShare(w, r, http.StatusOK, ShareData{
+			Token:        share.Token,
+			Name:         path.Base(share.Path),
+			Sub:          sub,
+			Dirs:         entries,
+			DownloadOnly: share.DownloadOnly,
+			Expires:      share.Expires,
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package preview
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShareLinks(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "docs", "sub"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "docs", "sub", "a.txt"), []byte("hello"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0o644))
+	cfg := &common.Config{
+		Users:   map[string]common.ConfigUser{"alice": {Password: "pw"}},
+		Pools:   map[string]common.ConfigPool{"data": {Path: dir, DefaultPerm: "rw"}},
+		Preview: common.ConfigPreview{Shares: common.ConfigPreviewShares{Enabled: true}},
+	}
+	ctx, err := common.NewContext(t.Context(), cfg)
+	assert.NoError(t, err)
+	authFS, err := ctx.LoadFS("alice", "pw", nil, false)
+	assert.NoError(t, err)
+
+	create := func(name string, form url.Values) shareItem {
+		form.Set("name", name)
+		request := httptest.NewRequest(http.MethodPost, "/preview/data/?share", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handleShare(rec, request, ctx, authFS, "/data/")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var item shareItem
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &item))
+		return item
+	}
+	route := chi.NewRouter()
+	route.Route("/s", WithShares(ctx))
+	get := func(target string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		route.ServeHTTP(rec, request)
+		return rec
+	}
+
+	docs := create("docs", url.Values{"expires": {"24h"}})
+	assert.NotNil(t, docs.Expires)
+	rec := get(docs.URL)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), docs.URL+"sub/")
+	rec = get(docs.URL + "sub/a.txt")
+	assert.Equal(t, "hello", rec.Body.String())
+	assert.Equal(t, "sandbox", rec.Header().Get("Content-Security-Policy"))
+	assert.Equal(t, http.StatusNotFound, get(docs.URL+"../secret.txt").Code, "不能访问分享目录以外的文件")
+
+	file := create("secret.txt", url.Values{"password": {"123"}, "download_only": {"true"}})
+	assert.Equal(t, http.StatusUnauthorized, get(file.URL).Code, "需要输入密码")
+	login := func(password string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, file.URL, strings.NewReader("password="+password))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		route.ServeHTTP(rec, request)
+		return rec
+	}
+	assert.Equal(t, http.StatusUnauthorized, login("wrong").Code)
+	rec = login("123")
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	rec = get(file.URL, rec.Result().Cookies()...)
+	assert.Equal(t, "secret", rec.Body.String())
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), "attachment", "只允许下载时以附件发送")
+
+	rec = httptest.NewRecorder()
+	handleShares(rec, ctx, authFS, "data/")
+	var items []shareItem
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &items))
+	assert.Len(t, items, 2)
+
+	request := httptest.NewRequest(http.MethodPost, "/preview/data/?unshare", strings.NewReader("token="+docs.Token))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	handleUnshare(rec, request, ctx, authFS)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, http.StatusNotFound, get(docs.URL).Code, "取消分享后链接失效")
+}
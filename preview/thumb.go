@@ -0,0 +1,310 @@
+package preview
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/go-chi/chi/v5"
+	"github.com/spf13/afero"
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// thumbExpire 超过该时间未被访问的缓存被清理
+	thumbExpire = 30 * 24 * time.Hour
+	// thumbCleanInterval 两次清理缓存的最小间隔
+	thumbCleanInterval = time.Hour
+	// thumbMaxPixels 解码图片的像素上限，避免很小的文件声明巨大的尺寸耗尽内存
+	thumbMaxPixels = 64 * 1024 * 1024
+	// thumbVideoTimeout 调用 ffmpeg 截取视频帧的时间上限
+	thumbVideoTimeout = 15 * time.Second
+	// thumbConcurrency 同时生成的缩略图数量
+	thumbConcurrency = 4
+)
+
+var (
+	thumbImageExts = []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".bmp"}
+	thumbVideoExts = []string{".mp4", ".m4v", ".mov", ".mkv", ".webm", ".avi"}
+
+	errThumbUnsupported = errors.New("thumbnail not supported")
+)
+
+// thumbnailer 生成并缓存缩略图。缓存以用户、路径、大小与修改时间为键，
+// 文件被修改后生成新的缩略图，旧的缓存在长期未访问后被清理
+type thumbnailer struct {
+	cfg    common.ConfigPreviewThumbnails
+	dir    string
+	size   int
+	ffmpeg string
+
+	group singleflight.Group
+	limit chan struct{}
+	stale staleCache
+}
+
+func newThumbnailer(cfg common.ConfigPreviewThumbnails) *thumbnailer {
+	dir := cfg.CacheDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "webdav-server-thumbs")
+	}
+	return &thumbnailer{
+		cfg:    cfg,
+		dir:    dir,
+		size:   cfg.Size,
+		ffmpeg: cfg.FFmpeg,
+		limit:  make(chan struct{}, thumbConcurrency),
+	}
+}
+
+// thumbSupported 判断文件是否可以生成缩略图
+func thumbSupported(cfg common.ConfigPreviewThumbnails, info os.FileInfo) bool {
+	if !cfg.Enabled || info.IsDir() || info.Size() > int64(cfg.MaxSourceSize) {
+		return false
+	}
+	ext := strings.ToLower(path.Ext(info.Name()))
+	return slices.Contains(thumbImageExts, ext) || (cfg.FFmpeg != "" && slices.Contains(thumbVideoExts, ext))
+}
+
+// thumbURL 返回缩略图地址，地址带有修改时间，文件修改后浏览器不会使用旧的缓存
+func thumbURL(name string, info os.FileInfo) string {
+	return (&url.URL{Path: "/thumb/" + strings.TrimPrefix(name, "/")}).EscapedPath() +
+		"?v=" + strconv.FormatInt(info.ModTime().Unix(), 10)
+}
+
+// cachePath 返回缩略图的缓存文件，不同用户看到的同一路径可能是不同的文件，因此用户也是键的一部分
+func (t *thumbnailer) cachePath(user, name string, info os.FileInfo) string {
+	key := fmt.Sprintf("%s\x00%s\x00%d\x00%d\x00%d", user, name, info.Size(), info.ModTime().UnixNano(), t.size)
+	sum := sha256.Sum256([]byte(key))
+	id := hex.EncodeToString(sum[:16])
+	return filepath.Join(t.dir, id[:2], id+".jpg")
+}
+
+// get 返回 name 的缩略图缓存文件，不存在时生成。同一文件的并发请求只生成一次
+func (t *thumbnailer) get(ctx context.Context, fs afero.Fs, user, name string, info os.FileInfo) (string, error) {
+	cached := t.cachePath(user, name, info)
+	if _, err := os.Stat(cached); err == nil {
+		// 更新访问时间，避免常用的缩略图被清理
+		now := time.Now()
+		_ = os.Chtimes(cached, now, now)
+		return cached, nil
+	}
+	_, err, _ := t.group.Do(cached, func() (any, error) {
+		select {
+		case t.limit <- struct{}{}:
+			defer func() { <-t.limit }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		data, err := t.render(ctx, fs, name)
+		if err != nil {
+			return nil, err
+		}
+		go t.stale.clean(filepath.Join(t.dir, "*", "*.jpg"))
+		return nil, writeCacheFile(cached, data)
+	})
+	return cached, err
+}
+
+// render 生成 JPEG 格式的缩略图
+func (t *thumbnailer) render(ctx context.Context, fs afero.Fs, name string) ([]byte, error) {
+	file, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var src image.Image
+	if slices.Contains(thumbVideoExts, strings.ToLower(path.Ext(name))) {
+		src, err = t.videoFrame(ctx, file)
+	} else {
+		src, err = decodeImage(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleImage(src, t.size), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeImage 先读取图片尺寸，超过 thumbMaxPixels 的图片不解码
+func decodeImage(file afero.File) (image.Image, error) {
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, errThumbUnsupported
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > thumbMaxPixels {
+		return nil, errThumbUnsupported
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return nil, errThumbUnsupported
+	}
+	return src, nil
+}
+
+// videoFrame 调用 ffmpeg 截取视频开头附近的一帧。本地文件直接传入路径以便 ffmpeg 定位，
+// 其他存储后端的文件通过标准输入传入
+func (t *thumbnailer) videoFrame(ctx context.Context, file afero.File) (image.Image, error) {
+	ctx, cancel := context.WithTimeout(ctx, thumbVideoTimeout)
+	defer cancel()
+	input := "pipe:0"
+	var stdin io.Reader = file
+	if osFile, ok := mergefs.OsFile(file); ok {
+		input, stdin = "file:"+osFile.Name(), nil
+	}
+	scale := fmt.Sprintf("thumbnail,scale='min(%d,iw)':-2", t.size)
+	cmd := exec.CommandContext(ctx, t.ffmpeg, "-hide_banner", "-loglevel", "error",
+		"-i", input, "-vf", scale, "-frames:v", "1", "-f", "image2pipe", "-c:v", "mjpeg", "pipe:1")
+	cmd.Stdin = stdin
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	src, err := jpeg.Decode(&stdout)
+	if err != nil {
+		return nil, errThumbUnsupported
+	}
+	return src, nil
+}
+
+// scaleImage 等比缩小到最大边长 size，透明区域填充为白色
+func scaleImage(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, max(height*size/width, 1)
+		} else {
+			width, height = max(width*size/height, 1), size
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.BiLinear.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+	return dst
+}
+
+// writeCacheFile 先写入临时文件再重命名，并发读取时不会得到不完整的缓存
+func writeCacheFile(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o700); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(name), ".cache-*")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), name)
+	}
+	if err != nil {
+		_ = os.Remove(temp.Name())
+	}
+	return err
+}
+
+// staleCache 清理缩略图等缓存目录中长期未访问的文件
+type staleCache struct {
+	mu      sync.Mutex
+	cleaned time.Time
+}
+
+// clean 删除 pattern 匹配的超过 thumbExpire 未访问的缓存，每 thumbCleanInterval 最多执行一次
+func (c *staleCache) clean(pattern string) {
+	c.mu.Lock()
+	if time.Since(c.cleaned) < thumbCleanInterval {
+		c.mu.Unlock()
+		return
+	}
+	c.cleaned = time.Now()
+	c.mu.Unlock()
+	matches, _ := filepath.Glob(pattern)
+	for _, name := range matches {
+		if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > thumbExpire {
+			if err := os.Remove(name); err != nil {
+				slog.Warn("|preview| Failed to remove stale cache.", "path", name, "err", err)
+			}
+		}
+	}
+}
+
+// WithThumbnails 提供 /thumb/<路径> 缩略图，权限与预览页相同
+func WithThumbnails(ctx *common.FsContext) func(r chi.Router) {
+	thumbs := newThumbnailer(ctx.Config.Preview.Thumbnails)
+	return func(r chi.Router) {
+		r.Get("/*", handleThumb(ctx, thumbs))
+	}
+}
+
+func handleThumb(ctx *common.FsContext, thumbs *thumbnailer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fs, err := loadPreviewFS(ctx, r)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		p := "/" + strings.Trim(chi.URLParam(r, "*"), "/")
+		if poolUnavailable(w, ctx, p) {
+			return
+		}
+		info, err := fs.Stat(p)
+		if err != nil || !thumbSupported(thumbs.cfg, info) {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		cached, err := thumbs.get(r.Context(), fs, fs.User, p, info)
+		if err != nil {
+			if !errors.Is(err, errThumbUnsupported) && !errors.Is(err, context.Canceled) {
+				slog.Warn("|preview| Thumbnail failed.", "path", p, "user", fs.User, "err", err)
+			}
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		file, err := os.Open(cached)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		defer file.Close()
+		w.Header().Set("Content-Type", "image/jpeg")
+		// 列表中的地址带有文件的修改时间，文件修改后地址随之变化
+		w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(thumbExpire.Seconds())))
+		http.ServeContent(w, r, "", info.ModTime(), file)
+	}
+}
@@ -0,0 +1,46 @@
+package preview
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThumbnailer(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 800, 400))))
+	assert.NoError(t, afero.WriteFile(fs, "/photos/a.png", buf.Bytes(), 0o644))
+	assert.NoError(t, afero.WriteFile(fs, "/photos/b.png", []byte("not an image"), 0o644))
+	cfg := common.ConfigPreviewThumbnails{Enabled: true, CacheDir: t.TempDir(), Size: 100, MaxSourceSize: 1 << 20}
+	thumbs := newThumbnailer(cfg)
+
+	info, _ := fs.Stat("/photos/a.png")
+	assert.True(t, thumbSupported(cfg, info))
+	assert.Contains(t, thumbURL("/photos/a.png", info), "/thumb/photos/a.png?v=", "地址带有修改时间")
+	cached, err := thumbs.get(context.Background(), fs, "alice", "/photos/a.png", info)
+	assert.NoError(t, err)
+	file, err := os.Open(cached)
+	assert.NoError(t, err)
+	thumb, err := jpeg.DecodeConfig(file)
+	_ = file.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, 100, thumb.Width, "等比缩小到最大边长")
+	assert.Equal(t, 50, thumb.Height)
+	assert.NotEqual(t, cached, thumbs.cachePath("bob", "/photos/a.png", info), "不同用户的缓存互不影响")
+
+	info, _ = fs.Stat("/photos/b.png")
+	_, err = thumbs.get(context.Background(), fs, "alice", "/photos/b.png", info)
+	assert.ErrorIs(t, err, errThumbUnsupported)
+
+	cfg.MaxSourceSize = 10
+	assert.False(t, thumbSupported(cfg, info), "超过大小上限的文件不生成缩略图")
+}
@@ -0,0 +1,126 @@
+package preview
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"code.d7z.net/packages/webdav-server/events"
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+)
+
+// handleDirs 以 JSON 返回 p 下的子目录名称，供移动与复制时选择目标目录
+func handleDirs(w http.ResponseWriter, fs afero.Fs, p string) {
+	entries, err := afero.ReadDir(fs, "/"+strings.Trim(p, "/"))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	dirs := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(dirs)
+}
+
+// transferError 移动或复制失败的原因，status 为对应的 HTTP 状态码
+type transferError struct {
+	status int
+	msg    string
+}
+
+func (e *transferError) Error() string {
+	return e.msg
+}
+
+// handleTransfer 将 p 下的 name 移动或复制到目录 dest 中，dest 为用户文件系统中的绝对路径。
+// 目标已存在时返回 409，表单中 conflict=rename 时另存为 name (1).ext
+func handleTransfer(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS, p string, isCopy bool) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "参数错误", http.StatusBadRequest)
+		return
+	}
+	name, dest := r.FormValue("name"), r.FormValue("dest")
+	if name == "" || dest == "" {
+		http.Error(w, "参数缺失", http.StatusBadRequest)
+		return
+	}
+	if strings.Contains(name, "/") || strings.Contains(name, "\\") || name == "." || name == ".." {
+		http.Error(w, "名称非法", http.StatusBadRequest)
+		return
+	}
+	dest = path.Clean("/" + dest)
+	if poolUnavailable(w, ctx, dest) {
+		return
+	}
+	target, terr := transfer(r, ctx, fs, path.Join("/", p, name), dest, isCopy, r.FormValue("conflict") == common.ConflictRename)
+	if terr != nil {
+		http.Error(w, terr.msg, terr.status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"path": previewURL(target)})
+}
+
+// transfer 将 source 移动或复制到目录 dest 中，返回目标路径。rename 为 true 时目标已存在则另存为 name (1).ext。
+// 跨存储池的移动与复制由挂载层完成，目录复制完成前目标位置不会出现不完整的目录
+func transfer(r *http.Request, ctx *common.FsContext, fs *common.AuthFS, source, dest string, isCopy, rename bool) (string, *transferError) {
+	target := path.Join(dest, path.Base(source))
+	// 复制到所在目录且允许重命名时生成副本
+	if target == source && !(isCopy && rename) {
+		return "", &transferError{http.StatusConflict, "目标与源相同"}
+	}
+	if strings.HasPrefix(target, source+"/") {
+		return "", &transferError{http.StatusConflict, "不能移动或复制到自身的子目录"}
+	}
+	if info, err := fs.Stat(dest); err != nil || !info.IsDir() {
+		return "", &transferError{http.StatusConflict, "目标目录不存在"}
+	}
+	if _, err := fs.Stat(target); err == nil {
+		if !rename {
+			return "", &transferError{http.StatusConflict, "目标已存在"}
+		}
+		if target, err = common.ConflictName(fs, target); err != nil {
+			return "", &transferError{http.StatusConflict, "目标已存在"}
+		}
+	}
+
+	var err error
+	if isCopy {
+		err = mergefs.CopyTree(r.Context(), fs, source, target)
+	} else {
+		err = fs.Rename(source, target)
+	}
+	if err != nil {
+		slog.Warn("|preview| Transfer failed.", "source", source, "target", target, "copy", isCopy, "err", err)
+		switch {
+		case os.IsNotExist(err):
+			return "", &transferError{http.StatusNotFound, http.StatusText(http.StatusNotFound)}
+		case os.IsExist(err):
+			return "", &transferError{http.StatusConflict, "目标已存在"}
+		case errors.Is(err, os.ErrPermission):
+			return "", &transferError{http.StatusForbidden, http.StatusText(http.StatusForbidden)}
+		default:
+			return "", &transferError{http.StatusInternalServerError, "操作失败: " + err.Error()}
+		}
+	}
+	if !isCopy {
+		ctx.PublishChange(fs.User, source, events.OpRename)
+	}
+	ctx.PublishChange(fs.User, target, events.OpCreate)
+	if isCopy {
+		slog.Info("|preview| Copy.", "source", source, "target", target, "remote", r.RemoteAddr, "user", fs.User)
+	} else {
+		slog.Info("|preview| Move.", "source", source, "target", target, "remote", r.RemoteAddr, "user", fs.User)
+	}
+	return target, nil
+}
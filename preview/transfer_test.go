@@ -0,0 +1,51 @@
+package preview
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"code.d7z.net/packages/webdav-server/events"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleTransfer(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/data/a.txt", []byte("a"), 0o644))
+	assert.NoError(t, afero.WriteFile(fs, "/data/docs/b.txt", []byte("b"), 0o644))
+	assert.NoError(t, fs.MkdirAll("/backup", 0o755))
+	ctx := &common.FsContext{Events: events.NewHub(), Config: &common.Config{}}
+	authFS := &common.AuthFS{User: "alice", Fs: fs}
+
+	transfer := func(name, dest, conflict string, isCopy bool) *httptest.ResponseRecorder {
+		form := url.Values{"name": {name}, "dest": {dest}, "conflict": {conflict}}
+		request := httptest.NewRequest(http.MethodPost, "/preview/data/", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handleTransfer(rec, request, ctx, authFS, "/data/", isCopy)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, transfer("docs", "/backup", "", true).Code)
+	content, err := afero.ReadFile(fs, "/backup/docs/b.txt")
+	assert.NoError(t, err, "复制目录")
+	assert.Equal(t, "b", string(content))
+
+	assert.Equal(t, http.StatusConflict, transfer("docs", "/backup", "", true).Code, "目标已存在")
+	rec := transfer("docs", "/backup", common.ConflictRename, true)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "/preview/backup/docs%20%281%29")
+
+	assert.Equal(t, http.StatusConflict, transfer("docs", "/data/docs", "", false).Code, "不能移动到自身的子目录")
+	assert.Equal(t, http.StatusConflict, transfer("a.txt", "/missing", "", false).Code, "目标目录不存在")
+
+	assert.Equal(t, http.StatusOK, transfer("a.txt", "/backup", "", false).Code)
+	exists, _ := afero.Exists(fs, "/data/a.txt")
+	assert.False(t, exists, "移动后源文件被删除")
+	content, _ = afero.ReadFile(fs, "/backup/a.txt")
+	assert.Equal(t, "a", string(content))
+}
@@ -0,0 +1,144 @@
+package preview
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"code.d7z.net/packages/webdav-server/events"
+	"code.d7z.net/packages/webdav-server/mergefs"
+)
+
+// trashItem 回收站中用户可见的条目，Path 为用户文件系统中的原路径
+type trashItem struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	DeletedAt time.Time `json:"deletedAt"`
+	IsDir     bool      `json:"isDir"`
+	Size      int64     `json:"size"`
+}
+
+// userTrashPath 将源存储池中的路径映射为用户文件系统中的路径，不在 prefix 之下的条目返回 false
+func userTrashPath(p, prefix, name string) (string, bool) {
+	pool, _, _ := strings.Cut(strings.TrimPrefix(mergefs.NormalizePath(p), "/"), "/")
+	if prefix != "/" {
+		rel, ok := strings.CutPrefix(name, prefix)
+		if !ok || (rel != "" && !strings.HasPrefix(rel, "/")) {
+			return "", false
+		}
+		name = rel
+	}
+	return path.Join("/", pool, name), true
+}
+
+// loadTrashItems 返回用户可见的回收站条目，最近删除的在前
+func loadTrashItems(trashFs *mergefs.TrashFs, p, prefix string) ([]trashItem, error) {
+	entries, err := trashFs.List()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]trashItem, 0, len(entries))
+	for _, entry := range entries {
+		name, ok := userTrashPath(p, prefix, entry.Path)
+		if !ok {
+			continue
+		}
+		items = append(items, trashItem{
+			ID:        entry.ID,
+			Name:      path.Base(name),
+			Path:      name,
+			DeletedAt: entry.DeletedAt,
+			IsDir:     entry.IsDir,
+			Size:      entry.Size,
+		})
+	}
+	return items, nil
+}
+
+// handleTrash 以 JSON 返回路径所在存储池的回收站条目
+func handleTrash(w http.ResponseWriter, ctx *common.FsContext, fs *common.AuthFS, p string) {
+	trashFs, _, prefix, err := ctx.LoadTrash(fs.User, p, false)
+	if err != nil {
+		http.Error(w, "未启用回收站", http.StatusNotFound)
+		return
+	}
+	items, err := loadTrashItems(trashFs, p, prefix)
+	if err != nil {
+		slog.Warn("|preview| List trash failed.", "path", p, "user", fs.User, "err", err)
+		http.Error(w, "读取回收站失败", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+// handleTrashAction 恢复或永久删除回收站中的条目，表单字段 id 为条目 ID。
+// 条目必须位于用户可见的范围内，恢复时原路径已存在返回 409
+func handleTrashAction(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS, p string, restore bool) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "参数错误", http.StatusBadRequest)
+		return
+	}
+	id := r.FormValue("id")
+	if id == "" {
+		http.Error(w, "参数缺失", http.StatusBadRequest)
+		return
+	}
+	trashFs, poolName, prefix, err := ctx.LoadTrash(fs.User, p, true)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	items, err := loadTrashItems(trashFs, p, prefix)
+	if err != nil {
+		slog.Warn("|preview| List trash failed.", "path", p, "user", fs.User, "err", err)
+		http.Error(w, "读取回收站失败", http.StatusInternalServerError)
+		return
+	}
+	var item *trashItem
+	for i := range items {
+		if items[i].ID == id {
+			item = &items[i]
+			break
+		}
+	}
+	if item == nil {
+		http.Error(w, "条目不存在", http.StatusNotFound)
+		return
+	}
+	if !restore {
+		if err := trashFs.Delete(id); err != nil {
+			slog.Warn("|preview| Delete trash entry failed.", "id", id, "user", fs.User, "err", err)
+			http.Error(w, "删除失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		slog.Info("|preview| Trash entry deleted.", "path", item.Path, "remote", r.RemoteAddr, "user", fs.User)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	entry, err := trashFs.Restore(id)
+	if err != nil {
+		slog.Warn("|preview| Restore trash entry failed.", "id", id, "user", fs.User, "err", err)
+		switch {
+		case os.IsNotExist(err):
+			http.Error(w, "条目不存在", http.StatusNotFound)
+		case os.IsExist(err):
+			http.Error(w, "原路径已存在", http.StatusConflict)
+		default:
+			http.Error(w, "恢复失败: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	// 直接修改了存储池，通知缓存刷新
+	ctx.Events.Publish(events.Event{Pool: poolName, Path: entry.Path, Op: events.OpCreate})
+	slog.Info("|preview| Trash entry restored.", "path", item.Path, "remote", r.RemoteAddr, "user", fs.User)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"path": item.Path})
+}
@@ -0,0 +1,79 @@
+package preview
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"code.d7z.net/packages/webdav-server/events"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleTrash(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "docs"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "docs", "a.txt"), []byte("a"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b"), 0o644))
+	cfg := &common.Config{Pools: map[string]common.ConfigPool{
+		"data": {Path: dir, DefaultPerm: "rw", Permissions: map[string]common.FilePerm{"bob": "r"},
+			Trash: common.ConfigPoolTrash{Enabled: true}},
+		"docs": {SourcePool: "data", Subpath: "docs", DefaultPerm: "rw"},
+	}}
+	ctx := &common.FsContext{Events: events.NewHub(), Config: cfg}
+	trashFs, err := common.OpenTrash(cfg, "data")
+	assert.NoError(t, err)
+	assert.NoError(t, trashFs.Remove("/docs/a.txt"))
+	assert.NoError(t, trashFs.Remove("/b.txt"))
+
+	list := func(p string) []trashItem {
+		rec := httptest.NewRecorder()
+		handleTrash(rec, ctx, &common.AuthFS{User: "alice", Fs: afero.NewMemMapFs()}, p)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var items []trashItem
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &items))
+		return items
+	}
+	action := func(user, p, op, id string) int {
+		request := httptest.NewRequest(http.MethodPost, "/preview/"+p+"?"+op, strings.NewReader(url.Values{"id": {id}}.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handleTrashAction(rec, request, ctx, &common.AuthFS{User: user, Fs: afero.NewMemMapFs()}, p, op == "trash_restore")
+		return rec.Code
+	}
+
+	items := list("data/")
+	assert.Len(t, items, 2)
+	ids := map[string]string{}
+	for _, item := range items {
+		ids[item.Path] = item.ID
+	}
+	assert.Contains(t, ids, "/data/docs/a.txt")
+	assert.Contains(t, ids, "/data/b.txt")
+
+	docs := list("docs/")
+	assert.Len(t, docs, 1, "绑定的存储池只能看到子目录中的条目")
+	assert.Equal(t, "/docs/a.txt", docs[0].Path)
+	assert.Equal(t, http.StatusNotFound, action("alice", "docs/", "trash_restore", ids["/data/b.txt"]), "子目录以外的条目不可恢复")
+
+	assert.Equal(t, http.StatusOK, action("alice", "docs/", "trash_restore", docs[0].ID))
+	content, err := os.ReadFile(filepath.Join(dir, "docs", "a.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(content))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("new"), 0o644))
+	assert.Equal(t, http.StatusConflict, action("alice", "data/", "trash_restore", ids["/data/b.txt"]), "原路径已存在")
+	assert.Equal(t, http.StatusForbidden, action("bob", "data/", "trash_delete", ids["/data/b.txt"]), "只读用户不能删除")
+	assert.Equal(t, http.StatusOK, action("alice", "data/", "trash_delete", ids["/data/b.txt"]))
+	assert.Empty(t, list("data/"))
+
+	rec := httptest.NewRecorder()
+	handleTrash(rec, ctx, &common.AuthFS{User: "alice", Fs: afero.NewMemMapFs()}, "missing/")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,80 @@
+package preview
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleUploadMultiple(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, fs.MkdirAll("/data", 0o755))
+	assert.NoError(t, afero.WriteFile(fs, "/data/exists.txt", []byte("old"), 0o644))
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for _, file := range []struct{ name, path, content string }{
+		{"a.txt", "a.txt", "a"},
+		{"b.txt", "photos/2024/b.txt", "b"},
+		{"exists.txt", "exists.txt", "new"},
+		{"c.txt", "../c.txt", "c"},
+	} {
+		_ = form.WriteField("path", file.path)
+		part, _ := form.CreateFormFile("file", file.name)
+		_, _ = part.Write([]byte(file.content))
+	}
+	assert.NoError(t, form.Close())
+	request := httptest.NewRequest(http.MethodPost, "/preview/data/", &body)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	handleUpload(rec, request, &common.AuthFS{User: "alice", Fs: fs}, "/data/", uploadOptions{maxSize: 1 << 20})
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "部分文件失败时返回第一个失败的状态码")
+	var response struct{ Results []uploadResult }
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Len(t, response.Results, 4)
+	assert.Empty(t, response.Results[0].Error)
+	assert.Equal(t, "/preview/data/photos/2024/b.txt", response.Results[1].Path, "创建中间目录")
+	assert.Equal(t, "文件已存在", response.Results[2].Error)
+	assert.Equal(t, "名称非法", response.Results[3].Error, "拒绝跳出当前目录的路径")
+
+	content, err := afero.ReadFile(fs, "/data/photos/2024/b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(content))
+	content, _ = afero.ReadFile(fs, "/data/exists.txt")
+	assert.Equal(t, "old", string(content), "已存在的文件未被覆盖")
+}
+
+func TestHandleUploadFileLimit(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, fs.MkdirAll("/data", 0o755))
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "big.bin")
+	_, _ = part.Write(make([]byte, 64))
+	part, _ = form.CreateFormFile("file", "small.txt")
+	_, _ = part.Write([]byte("ok"))
+	assert.NoError(t, form.Close())
+	request := httptest.NewRequest(http.MethodPost, "/preview/data/", &body)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	handleUpload(rec, request, &common.AuthFS{User: "alice", Fs: fs}, "/data/", uploadOptions{maxSize: 1 << 20, fileLimit: 16})
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	var response struct{ Results []uploadResult }
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Len(t, response.Results, 2)
+	assert.Equal(t, errFileLimit.Error(), response.Results[0].Error, "写入时发现超过存储池的大小上限")
+	assert.Empty(t, response.Results[1].Error, "之后的文件继续上传")
+	content, err := afero.ReadFile(fs, "/data/small.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", string(content))
+}
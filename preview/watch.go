@@ -0,0 +1,109 @@
+package preview
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sync/atomic"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"code.d7z.net/packages/webdav-server/events"
+	"code.d7z.net/packages/webdav-server/mergefs"
+	"github.com/spf13/afero"
+)
+
+const (
+	// watchHeartbeat 发送注释行的间隔，避免代理关闭空闲的连接
+	watchHeartbeat = 30 * time.Second
+	// watchBuffer 未发送的事件数上限，超过后通知客户端重新加载
+	watchBuffer = 64
+)
+
+// watchEvent 推送给客户端的变更，Name 为目录中的条目名称
+type watchEvent struct {
+	Op   events.Op `json:"op"`
+	Name string    `json:"name"`
+}
+
+// handleWatch 以 Server-Sent Events 推送目录 p 中条目的变更，来源包括本服务的写入与磁盘的监听。
+// 新增的条目必须对用户可见才推送，删除与重命名只推送用户已经看到的条目，隐藏的文件不会通过事件暴露。
+// 事件积压时发送 reset，客户端应重新加载整个列表
+func handleWatch(w http.ResponseWriter, r *http.Request, ctx *common.FsContext, fs *common.AuthFS, p string) {
+	dir := mergefs.NormalizePath(p)
+	entries, err := afero.ReadDir(fs, dir)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	visible := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		visible[entry.Name()] = struct{}{}
+	}
+
+	changes := make(chan events.Event, watchBuffer)
+	var overflow atomic.Bool
+	unsubscribe := ctx.Changes.Subscribe(func(event events.Event) {
+		if (event.User != "" && event.User != fs.User) || path.Dir(event.Path) != dir || event.Path == dir {
+			return
+		}
+		select {
+		case changes <- event:
+		default:
+			overflow.Store(true)
+		}
+	})
+	defer unsubscribe()
+
+	controller := http.NewResponseController(w)
+	// 连接长期保持，不受 HTTP 写入超时限制
+	_ = controller.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprint(w, "retry: 5000\n\n")
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(watchHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			_, _ = fmt.Fprint(w, ": ping\n\n")
+		case event := <-changes:
+			if overflow.Swap(false) {
+				_, _ = fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+				break
+			}
+			name := path.Base(event.Path)
+			switch event.Op {
+			case events.OpRemove, events.OpRename:
+				if _, ok := visible[name]; !ok {
+					continue
+				}
+				if _, err := fs.Stat(event.Path); err == nil {
+					// 目标被替换后仍然存在，按修改处理
+					event.Op = events.OpWrite
+				} else {
+					delete(visible, name)
+				}
+			default:
+				if _, err := fs.Stat(event.Path); err != nil {
+					continue
+				}
+				visible[name] = struct{}{}
+			}
+			data, _ := json.Marshal(watchEvent{Op: event.Op, Name: name})
+			_, _ = fmt.Fprintf(w, "event: change\ndata: %s\n\n", data)
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package preview
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"code.d7z.net/packages/webdav-server/events"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleWatch(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/data/old.txt", []byte("old"), 0o644))
+	ctx := &common.FsContext{Changes: events.NewHub(), Config: &common.Config{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleWatch(w, r, ctx, &common.AuthFS{User: "alice", Fs: fs}, "data/")
+	}))
+	defer server.Close()
+
+	// 响应头在订阅之后发送，返回时已开始接收事件
+	response, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(response.Body)
+		for scanner.Scan() {
+			if line := scanner.Text(); strings.HasPrefix(line, "data:") {
+				lines <- line
+			}
+		}
+	}()
+	next := func() string {
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(5 * time.Second):
+			return "timeout"
+		}
+	}
+	ctx.Changes.Publish(events.Event{Path: "/data/secret.txt", Op: events.OpRemove})
+	ctx.Changes.Publish(events.Event{Path: "/data/other/x.txt", Op: events.OpCreate})
+	ctx.Changes.Publish(events.Event{Path: "/data/new.txt", Op: events.OpCreate, User: "bob"})
+	assert.NoError(t, afero.WriteFile(fs, "/data/new.txt", []byte("new"), 0o644))
+	ctx.Changes.Publish(events.Event{Path: "/data/new.txt", Op: events.OpCreate})
+	assert.Equal(t, `data: {"op":"create","name":"new.txt"}`, next(), "未见过的删除、子目录与其他用户的变更不推送")
+
+	assert.NoError(t, fs.Remove("/data/old.txt"))
+	ctx.Changes.Publish(events.Event{Path: "/data/old.txt", Op: events.OpRemove})
+	assert.Equal(t, `data: {"op":"remove","name":"old.txt"}`, next())
+}
@@ -0,0 +1,187 @@
+// Package remotedav 将远程 WebDAV 服务适配为 afero.Fs，
+// 使本服务可以作为多个已有 DAV 共享的合并网关。
+package remotedav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Config 远程 WebDAV 服务的连接配置
+type Config struct {
+	// 远程共享的根地址，例如 https://dav.example.com/remote.php/webdav/
+	URL      string
+	Username string
+	Password string
+}
+
+// client 远程 WebDAV 请求客户端
+type client struct {
+	base     *url.URL
+	username string
+	password string
+	http     *http.Client
+}
+
+func newClient(cfg Config) (*client, error) {
+	base, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("webdav: invalid url: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("webdav: invalid url scheme %q", base.Scheme)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+	base.RawPath = ""
+	return &client{base: base, username: cfg.Username, password: cfg.Password, http: http.DefaultClient}, nil
+}
+
+// cleanPath 将文件路径规范为以 / 开头的绝对路径
+func cleanPath(name string) string {
+	return path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+}
+
+// url 生成文件在远程服务上的地址
+func (c *client) url(name string) string {
+	u := *c.base
+	u.Path += cleanPath(name)
+	return u.String()
+}
+
+func (c *client) do(ctx context.Context, method, name string, header http.Header, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.url(name), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		_ = resp.Body.Close()
+		return nil, statusError(method, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// statusError 将 HTTP 状态码转换为文件系统错误，
+// 直接返回 os 包的错误以便 os.IsNotExist 等判断
+func statusError(method string, status int) error {
+	switch status {
+	case http.StatusNotFound, http.StatusConflict:
+		return os.ErrNotExist
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return os.ErrPermission
+	case http.StatusMethodNotAllowed, http.StatusPreconditionFailed:
+		return os.ErrExist
+	case http.StatusInsufficientStorage:
+		return syscall.ENOSPC
+	}
+	return fmt.Errorf("webdav: %s: %d %s", method, status, http.StatusText(status))
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>` +
+	`<D:propfind xmlns:D="DAV:"><D:prop>` +
+	`<D:resourcetype/><D:getcontentlength/><D:getlastmodified/>` +
+	`</D:prop></D:propfind>`
+
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+				ContentLength int64  `xml:"DAV: getcontentlength"`
+				LastModified  string `xml:"DAV: getlastmodified"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// propfind 读取文件属性，depth 为 1 时同时返回目录下的子项，
+// 结果中的第一项为 name 自身
+func (c *client) propfind(ctx context.Context, name string, depth int) ([]*fileInfo, error) {
+	header := http.Header{}
+	header.Set("Depth", fmt.Sprint(depth))
+	header.Set("Content-Type", "application/xml; charset=utf-8")
+	resp, err := c.do(ctx, "PROPFIND", name, header, strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("webdav: invalid propfind response: %w", err)
+	}
+	self := cleanPath(name)
+	var selfInfo *fileInfo
+	var infos []*fileInfo
+	for _, response := range result.Responses {
+		href, err := url.Parse(response.Href)
+		if err != nil {
+			continue
+		}
+		p := strings.TrimPrefix(path.Clean(href.Path), c.base.Path)
+		if p = cleanPath(p); p != self && path.Dir(p) != self {
+			continue
+		}
+		info := &fileInfo{name: path.Base(p)}
+		for _, propstat := range response.Propstat {
+			if !strings.Contains(propstat.Status, " 200 ") {
+				continue
+			}
+			info.dir = propstat.Prop.ResourceType.Collection != nil
+			info.size = propstat.Prop.ContentLength
+			info.modTime, _ = http.ParseTime(propstat.Prop.LastModified)
+		}
+		if p == self {
+			selfInfo = info
+		} else {
+			infos = append(infos, info)
+		}
+	}
+	if selfInfo == nil {
+		return nil, errors.New("webdav: propfind response does not contain the requested resource")
+	}
+	return append([]*fileInfo{selfInfo}, infos...), nil
+}
+
+// fileInfo 远程文件信息
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return i.size }
+func (i *fileInfo) ModTime() time.Time { return i.modTime }
+func (i *fileInfo) IsDir() bool        { return i.dir }
+func (i *fileInfo) Sys() any           { return nil }
+func (i *fileInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0o755
+	}
+	return 0o644
+}
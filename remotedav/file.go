@@ -0,0 +1,280 @@
+package remotedav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"syscall"
+	"time"
+)
+
+// baseFile 提供不支持操作的默认实现
+type baseFile struct {
+	name string
+	info os.FileInfo
+}
+
+func (b *baseFile) Name() string                       { return b.name }
+func (b *baseFile) Stat() (os.FileInfo, error)         { return b.info, nil }
+func (b *baseFile) Sync() error                        { return nil }
+func (b *baseFile) Truncate(int64) error               { return b.unsupported("truncate") }
+func (b *baseFile) WriteString(string) (int, error)    { return 0, b.unsupported("write") }
+func (b *baseFile) Write([]byte) (int, error)          { return 0, b.unsupported("write") }
+func (b *baseFile) WriteAt([]byte, int64) (int, error) { return 0, b.unsupported("write") }
+func (b *baseFile) Readdir(int) ([]os.FileInfo, error) {
+	return nil, b.pathErr("readdir", syscall.ENOTDIR)
+}
+func (b *baseFile) Readdirnames(int) ([]string, error) {
+	return nil, b.pathErr("readdir", syscall.ENOTDIR)
+}
+func (b *baseFile) Read([]byte) (int, error)           { return 0, b.unsupported("read") }
+func (b *baseFile) ReadAt([]byte, int64) (int, error)  { return 0, b.unsupported("read") }
+func (b *baseFile) Seek(int64, int) (int64, error)     { return 0, b.unsupported("seek") }
+func (b *baseFile) Close() error                       { return nil }
+func (b *baseFile) pathErr(op string, err error) error { return pathError(op, b.name, err) }
+func (b *baseFile) unsupported(op string) error        { return b.pathErr(op, errors.ErrUnsupported) }
+
+// readFile 按需发起范围请求的只读文件
+type readFile struct {
+	baseFile
+	fs     *Fs
+	ctx    context.Context
+	offset int64
+	body   io.ReadCloser
+}
+
+func newReadFile(ctx context.Context, f *Fs, name string, info os.FileInfo) *readFile {
+	return &readFile{baseFile: baseFile{name: name, info: info}, fs: f, ctx: ctx}
+}
+
+// get 读取从 offset 开始的 length 字节，length < 0 表示读取到末尾，
+// 服务端忽略 Range 时跳过多余的内容
+func (r *readFile) get(offset, length int64) (io.ReadCloser, error) {
+	header := http.Header{}
+	if length > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	} else if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := r.fs.client.do(r.ctx, http.MethodGet, r.name, header, nil)
+	if err != nil {
+		return nil, r.pathErr("read", err)
+	}
+	if resp.StatusCode == http.StatusPartialContent || offset == 0 {
+		return resp.Body, nil
+	}
+	if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+		_ = resp.Body.Close()
+		return nil, r.pathErr("read", err)
+	}
+	return resp.Body, nil
+}
+
+func (r *readFile) Read(p []byte) (int, error) {
+	if r.offset >= r.info.Size() {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		body, err := r.get(r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *readFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.info.Size() {
+		return 0, io.EOF
+	}
+	length := min(int64(len(p)), r.info.Size()-off)
+	body, err := r.get(off, length)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	n, err := io.ReadFull(body, p[:length])
+	if err == nil && int64(n) < int64(len(p)) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r *readFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.info.Size()
+	}
+	if offset < 0 {
+		return 0, r.pathErr("seek", os.ErrInvalid)
+	}
+	if offset != r.offset && r.body != nil {
+		_ = r.body.Close()
+		r.body = nil
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *readFile) Close() error {
+	if r.body != nil {
+		return r.body.Close()
+	}
+	return nil
+}
+
+// writeFile 通过管道将写入的内容以流的方式 PUT 到远程服务
+type writeFile struct {
+	baseFile
+	pipe    *io.PipeWriter
+	done    chan error
+	written int64
+	failed  error
+	closed  bool
+}
+
+func newWriteFile(ctx context.Context, f *Fs, name string) *writeFile {
+	pr, pw := io.Pipe()
+	w := &writeFile{baseFile: baseFile{name: name}, pipe: pw, done: make(chan error, 1)}
+	go func() {
+		resp, err := f.client.do(ctx, http.MethodPut, name, nil, pr)
+		if err == nil {
+			err = resp.Body.Close()
+		}
+		_ = pr.CloseWithError(errors.Join(err, io.ErrClosedPipe))
+		w.done <- err
+	}()
+	return w
+}
+
+func (w *writeFile) Stat() (os.FileInfo, error) {
+	return &fileInfo{name: path.Base(w.name), size: w.written, modTime: time.Now()}, nil
+}
+
+func (w *writeFile) Write(p []byte) (int, error) {
+	if w.failed != nil {
+		return 0, w.failed
+	}
+	n, err := w.pipe.Write(p)
+	w.written += int64(n)
+	if err != nil {
+		w.failed = w.wait()
+		if w.failed == nil {
+			w.failed = err
+		}
+		return n, w.pathErr("write", w.failed)
+	}
+	return n, nil
+}
+
+func (w *writeFile) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteAt 仅支持顺序写入（如 sftp 上传）
+func (w *writeFile) WriteAt(p []byte, off int64) (int, error) {
+	if off != w.written {
+		return 0, w.unsupported("write")
+	}
+	return w.Write(p)
+}
+
+// wait 等待上传请求结束，仅返回一次结果
+func (w *writeFile) wait() error {
+	if w.closed {
+		return w.failed
+	}
+	w.closed = true
+	return <-w.done
+}
+
+// TransferError 由 sftp 在连接异常断开时调用，中断本次上传
+func (w *writeFile) TransferError(err error) {
+	w.failed = err
+	_ = w.pipe.CloseWithError(err)
+}
+
+// Abort 中断上传，远程服务上可能残留不完整的文件
+func (w *writeFile) Abort() error {
+	_ = w.pipe.CloseWithError(errors.New("upload aborted"))
+	_ = w.wait()
+	return nil
+}
+
+func (w *writeFile) Close() error {
+	if w.failed != nil {
+		_ = w.Abort()
+		return w.failed
+	}
+	if w.closed {
+		return nil
+	}
+	_ = w.pipe.Close()
+	if err := w.wait(); err != nil {
+		return w.pathErr("close", err)
+	}
+	return nil
+}
+
+// dirFile 目录，首次读取时一次性列出子项
+type dirFile struct {
+	baseFile
+	fs      *Fs
+	ctx     context.Context
+	entries []os.FileInfo
+	offset  int
+	loaded  bool
+}
+
+func newDirFile(ctx context.Context, f *Fs, name string, info os.FileInfo) *dirFile {
+	return &dirFile{baseFile: baseFile{name: name, info: info}, fs: f, ctx: ctx}
+}
+
+func (d *dirFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.loaded {
+		infos, err := d.fs.client.propfind(d.ctx, d.name, 1)
+		if err != nil {
+			return nil, d.pathErr("readdir", err)
+		}
+		for _, info := range infos[1:] {
+			d.entries = append(d.entries, info)
+		}
+		d.loaded = true
+	}
+	rest := d.entries[d.offset:]
+	if count > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > 0 {
+		rest = rest[:min(count, len(rest))]
+	}
+	d.offset += len(rest)
+	return rest, nil
+}
+
+func (d *dirFile) Readdirnames(count int) ([]string, error) {
+	infos, err := d.Readdir(count)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return names, err
+}
+
+// Seek 回到开头时重新读取目录
+func (d *dirFile) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart {
+		return 0, d.unsupported("seek")
+	}
+	d.entries, d.offset, d.loaded = nil, 0, false
+	return 0, nil
+}
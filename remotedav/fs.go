@@ -0,0 +1,185 @@
+package remotedav
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// Fs 远程 WebDAV 文件系统
+type Fs struct {
+	client *client
+}
+
+// NewFs 创建远程 WebDAV 文件系统
+func NewFs(cfg Config) (*Fs, error) {
+	c, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Fs{client: c}, nil
+}
+
+func pathError(op, name string, err error) error {
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+func (f *Fs) stat(ctx context.Context, op, name string) (*fileInfo, error) {
+	infos, err := f.client.propfind(ctx, name, 0)
+	if err != nil {
+		return nil, pathError(op, name, err)
+	}
+	return infos[0], nil
+}
+
+func (f *Fs) Stat(name string) (os.FileInfo, error) {
+	return f.stat(context.Background(), "stat", name)
+}
+
+func (f *Fs) Create(name string) (afero.File, error) {
+	return f.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (f *Fs) Open(name string) (afero.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (f *Fs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	return f.OpenFileContext(context.Background(), name, flag, perm)
+}
+
+// OpenFileContext ctx 取消时中断正在进行的上传
+func (f *Fs) OpenFileContext(ctx context.Context, name string, flag int, _ os.FileMode) (afero.File, error) {
+	info, err := f.stat(ctx, "open", name)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	exists := err == nil
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		if !exists {
+			return nil, err
+		}
+		if info.IsDir() {
+			return newDirFile(ctx, f, name, info), nil
+		}
+		return newReadFile(ctx, f, name, info), nil
+	}
+	switch {
+	case exists && info.IsDir():
+		return nil, pathError("open", name, syscall.EISDIR)
+	case exists && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, pathError("open", name, os.ErrExist)
+	case !exists && flag&os.O_CREATE == 0:
+		return nil, err
+	case exists && info.Size() > 0 && (flag&os.O_TRUNC == 0 || flag&os.O_APPEND != 0):
+		// WebDAV 只能整体上传文件，不支持修改部分内容
+		return nil, pathError("open", name, errors.ErrUnsupported)
+	}
+	if !exists {
+		parent, err := f.stat(ctx, "open", path.Dir(cleanPath(name)))
+		if err != nil {
+			return nil, err
+		}
+		if !parent.IsDir() {
+			return nil, pathError("open", name, syscall.ENOTDIR)
+		}
+	}
+	return newWriteFile(ctx, f, name), nil
+}
+
+func (f *Fs) Mkdir(name string, _ os.FileMode) error {
+	resp, err := f.client.do(context.Background(), "MKCOL", name, nil, nil)
+	if err != nil {
+		return pathError("mkdir", name, err)
+	}
+	return resp.Body.Close()
+}
+
+func (f *Fs) MkdirAll(name string, perm os.FileMode) error {
+	current := "/"
+	for _, part := range strings.Split(strings.Trim(cleanPath(name), "/"), "/") {
+		if part == "" {
+			continue
+		}
+		current = path.Join(current, part)
+		info, err := f.Stat(current)
+		if err == nil {
+			if !info.IsDir() {
+				return pathError("mkdir", name, syscall.ENOTDIR)
+			}
+			continue
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if err := f.Mkdir(current, perm); err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Fs) Remove(name string) error {
+	ctx := context.Background()
+	infos, err := f.client.propfind(ctx, name, 1)
+	if err != nil {
+		return pathError("remove", name, err)
+	}
+	if infos[0].IsDir() && len(infos) > 1 {
+		return pathError("remove", name, syscall.ENOTEMPTY)
+	}
+	resp, err := f.client.do(ctx, http.MethodDelete, name, nil, nil)
+	if err != nil {
+		return pathError("remove", name, err)
+	}
+	return resp.Body.Close()
+}
+
+func (f *Fs) RemoveAll(name string) error {
+	resp, err := f.client.do(context.Background(), http.MethodDelete, name, nil, nil)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return pathError("remove", name, err)
+	}
+	return resp.Body.Close()
+}
+
+// Rename 使用 MOVE 在远程服务上完成，覆盖已存在的目标
+func (f *Fs) Rename(oldname, newname string) error {
+	header := http.Header{}
+	header.Set("Destination", f.client.url(newname))
+	header.Set("Overwrite", "T")
+	resp, err := f.client.do(context.Background(), "MOVE", oldname, header, nil)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	return resp.Body.Close()
+}
+
+func (f *Fs) Name() string {
+	return "RemoteWebdavFs"
+}
+
+// Chmod 远程服务没有权限位，忽略
+func (f *Fs) Chmod(_ string, _ os.FileMode) error {
+	return nil
+}
+
+// Chown 远程服务没有属主，忽略
+func (f *Fs) Chown(_ string, _, _ int) error {
+	return nil
+}
+
+// Chtimes 修改时间由远程服务决定，忽略
+func (f *Fs) Chtimes(_ string, _, _ time.Time) error {
+	return nil
+}
@@ -0,0 +1,82 @@
+package remotedav
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+func newTestFs(t *testing.T) *Fs {
+	handler := &webdav.Handler{
+		Prefix:     "/share",
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	fs, err := NewFs(Config{URL: srv.URL + "/share/", Username: "admin", Password: "secret"})
+	assert.NoError(t, err)
+	return fs
+}
+
+func TestFs_Remote(t *testing.T) {
+	fs := newTestFs(t)
+
+	assert.NoError(t, fs.MkdirAll("/a/b c", 0o755))
+	assert.NoError(t, afero.WriteFile(fs, "/a/b c/文件.txt", []byte("0123456789"), 0o644))
+	data, err := afero.ReadFile(fs, "/a/b c/文件.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+
+	f, err := fs.Open("/a/b c/文件.txt")
+	assert.NoError(t, err)
+	buf := make([]byte, 3)
+	n, err := f.ReadAt(buf, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "789", string(buf[:n]))
+	assert.NoError(t, f.Close())
+
+	infos, err := afero.ReadDir(fs, "/a")
+	assert.NoError(t, err)
+	assert.Len(t, infos, 1)
+	assert.Equal(t, "b c", infos[0].Name())
+	assert.True(t, infos[0].IsDir())
+
+	assert.Error(t, fs.Remove("/a/b c"), "非空目录不能删除")
+	assert.NoError(t, fs.Rename("/a/b c", "/c"))
+	stat, err := fs.Stat("/c/文件.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), stat.Size())
+	_, err = fs.Stat("/a/b c")
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = fs.OpenFile("/missing/file", os.O_WRONLY|os.O_CREATE, 0o644)
+	assert.True(t, os.IsNotExist(err), "父目录不存在时不能创建文件")
+
+	assert.NoError(t, fs.RemoveAll("/c"))
+	_, err = fs.Stat("/c")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFs_RemoteAbort(t *testing.T) {
+	fs := newTestFs(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	f, err := fs.OpenFileContext(ctx, "/file", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	assert.NoError(t, err)
+	_, _ = io.WriteString(f, "partial")
+	cancel()
+	assert.Error(t, f.Close(), "取消的上传应返回错误")
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+func runService(ctx context.Context, run func(ctx context.Context) error) error {
+	return run(ctx)
+}
+
+func serviceCommand(_ []string) error {
+	return errors.New("service management is only supported on windows, use systemd instead")
+}
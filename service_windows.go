@@ -0,0 +1,128 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const serviceName = "webdav-server"
+
+// runService 作为 Windows 服务启动时交由服务控制管理器托管，否则直接运行
+func runService(ctx context.Context, run func(ctx context.Context) error) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return err
+	}
+	if !isService {
+		return run(ctx)
+	}
+	handler := &windowsService{ctx: ctx, run: run}
+	if err := svc.Run(serviceName, handler); err != nil {
+		return err
+	}
+	return handler.err
+}
+
+type windowsService struct {
+	ctx context.Context
+	run func(ctx context.Context) error
+	err error
+}
+
+func (w *windowsService) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	ctx, cancel := context.WithCancel(w.ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- w.run(ctx)
+	}()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case w.err = <-done:
+			status <- svc.Status{State: svc.StopPending}
+			if w.err != nil {
+				return true, 1
+			}
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+			}
+		}
+	}
+}
+
+// serviceCommand 处理 service install/uninstall/start/stop 子命令
+func serviceCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: service install|uninstall|start|stop")
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	switch args[0] {
+	case "install":
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		cfgPath, err := filepath.Abs(config)
+		if err != nil {
+			return err
+		}
+		s, err := m.CreateService(serviceName, exe, mgr.Config{
+			DisplayName: "WebDAV Server",
+			Description: "Simple WebDAV & SFTP file server",
+			StartType:   mgr.StartAutomatic,
+		}, "-config", cfgPath)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+		return nil
+	}
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	switch args[0] {
+	case "uninstall":
+		return s.Delete()
+	case "start":
+		return s.Start()
+	case "stop":
+		st, err := s.Control(svc.Stop)
+		if err != nil {
+			return err
+		}
+		for timeout := time.Now().Add(30 * time.Second); st.State != svc.Stopped; {
+			if time.Now().After(timeout) {
+				return errors.New("timeout waiting for service to stop")
+			}
+			time.Sleep(300 * time.Millisecond)
+			if st, err = s.Query(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown service command: %s", args[0])
+}
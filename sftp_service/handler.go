@@ -1,20 +1,22 @@
 package sftp_service
 
 import (
+	"errors"
 	"io"
 	"os"
 	"time"
 
+	"code.d7z.net/packages/webdav-server/mergefs"
 	"github.com/pkg/sftp"
 	"github.com/spf13/afero"
 )
 
-// FSHandlers 初始化 SFTP Handlers
-func FSHandlers(fs afero.Fs) sftp.Handlers {
+// FSHandlers 初始化 SFTP Handlers，sanitize 不为 nil 时用于处理新建文件与目录的名称
+func FSHandlers(fs afero.Fs, sanitize func(name string) (string, error)) sftp.Handlers {
 	if fs == nil {
 		fs = afero.NewMemMapFs()
 	}
-	h := &fsHandler{fs: fs}
+	h := &fsHandler{fs: fs, sanitize: sanitize}
 	return sftp.Handlers{
 		FileGet:  h,
 		FilePut:  h,
@@ -24,7 +26,40 @@ func FSHandlers(fs afero.Fs) sftp.Handlers {
 }
 
 type fsHandler struct {
-	fs afero.Fs
+	fs       afero.Fs
+	sanitize func(name string) (string, error)
+}
+
+// newName 返回新建条目使用的路径，已存在的路径保持原名
+func (f *fsHandler) newName(name string) (string, error) {
+	if f.sanitize == nil {
+		return name, nil
+	}
+	if _, err := f.fs.Stat(name); err == nil {
+		return name, nil
+	}
+	sanitized, err := f.sanitize(name)
+	if err != nil {
+		return "", &os.PathError{Op: "create", Path: name, Err: err}
+	}
+	return sanitized, nil
+}
+
+// resolve 返回已存在条目的路径。客户端上传后以原名称继续操作（如设置修改时间）时，
+// 原名称不存在则查找处理后的名称
+func (f *fsHandler) resolve(name string) string {
+	if f.sanitize == nil {
+		return name
+	}
+	if _, err := f.fs.Stat(name); !os.IsNotExist(err) {
+		return name
+	}
+	if sanitized, err := f.sanitize(name); err == nil && sanitized != name {
+		if _, err := f.fs.Stat(sanitized); err == nil {
+			return sanitized
+		}
+	}
+	return name
 }
 
 func (f *fsHandler) Filelist(request *sftp.Request) (sftp.ListerAt, error) {
@@ -37,7 +72,7 @@ func (f *fsHandler) Filelist(request *sftp.Request) (sftp.ListerAt, error) {
 		return listerAt(entries), nil
 
 	case "Stat":
-		fi, err := f.fs.Stat(request.Filepath)
+		fi, err := f.fs.Stat(f.resolve(request.Filepath))
 		if err != nil {
 			return nil, err
 		}
@@ -45,14 +80,14 @@ func (f *fsHandler) Filelist(request *sftp.Request) (sftp.ListerAt, error) {
 
 	case "Lstat":
 		if lstater, ok := f.fs.(afero.Lstater); ok {
-			fi, _, err := lstater.LstatIfPossible(request.Filepath)
+			fi, _, err := lstater.LstatIfPossible(f.resolve(request.Filepath))
 			if err != nil {
 				return nil, err
 			}
 			return listerAt([]os.FileInfo{fi}), nil
 		}
 
-		fi, err := f.fs.Stat(request.Filepath)
+		fi, err := f.fs.Stat(f.resolve(request.Filepath))
 		if err != nil {
 			return nil, err
 		}
@@ -80,7 +115,7 @@ func (f *fsHandler) Filecmd(request *sftp.Request) error {
 	case "Setstat":
 		attrs := request.Attributes()
 		flags := request.AttrFlags()
-		path := request.Filepath
+		path := f.resolve(request.Filepath)
 
 		if flags.Size {
 			file, err := f.fs.OpenFile(path, os.O_WRONLY, 0o644)
@@ -119,22 +154,40 @@ func (f *fsHandler) Filecmd(request *sftp.Request) error {
 		return nil
 
 	case "Rename":
-		return f.fs.Rename(request.Filepath, request.Target)
+		target, err := f.newName(request.Target)
+		if err != nil {
+			return err
+		}
+		return f.fs.Rename(f.resolve(request.Filepath), target)
 
 	case "Rmdir":
-		return f.fs.Remove(request.Filepath)
+		return f.fs.Remove(f.resolve(request.Filepath))
 
 	case "Remove":
-		return f.fs.Remove(request.Filepath)
+		return f.fs.Remove(f.resolve(request.Filepath))
 
 	case "Mkdir":
-		return f.fs.MkdirAll(request.Filepath, 0o755)
+		name, err := f.newName(request.Filepath)
+		if err != nil {
+			return err
+		}
+		return f.fs.MkdirAll(name, 0o755)
 
 	case "Symlink":
 		if linker, ok := f.fs.(afero.Symlinker); ok {
 			return linker.SymlinkIfPossible(request.Target, request.Filepath)
 		}
 		return sftp.ErrSshFxOpUnsupported
+
+	case "Link":
+		if linker, ok := f.fs.(mergefs.HardLinker); ok {
+			err := linker.LinkIfPossible(request.Filepath, request.Target)
+			if errors.Is(err, mergefs.ErrNoHardLink) {
+				return sftp.ErrSshFxOpUnsupported
+			}
+			return err
+		}
+		return sftp.ErrSshFxOpUnsupported
 	}
 
 	return sftp.ErrSshFxOpUnsupported
@@ -142,13 +195,28 @@ func (f *fsHandler) Filecmd(request *sftp.Request) error {
 
 func (f *fsHandler) Filewrite(request *sftp.Request) (io.WriterAt, error) {
 	flag := getOpenFlag(request.Pflags())
-	file, err := f.fs.OpenFile(request.Filepath, flag, 0o666)
+	name := request.Filepath
+	if flag&os.O_CREATE != 0 {
+		var err error
+		if name, err = f.newName(name); err != nil {
+			return nil, err
+		}
+	} else {
+		name = f.resolve(name)
+	}
+	file, err := f.fs.OpenFile(name, flag, 0o666)
 	if err != nil {
 		return nil, err
 	}
 
 	if w, ok := file.(io.WriterAt); ok {
-		return w, nil
+		var offset int64
+		if flag&os.O_TRUNC == 0 {
+			if info, err := file.Stat(); err == nil {
+				offset = info.Size()
+			}
+		}
+		return newOrderedWriterAt(w, offset), nil
 	}
 
 	_ = file.Close()
@@ -157,7 +225,7 @@ func (f *fsHandler) Filewrite(request *sftp.Request) (io.WriterAt, error) {
 
 func (f *fsHandler) Fileread(request *sftp.Request) (io.ReaderAt, error) {
 	flag := getOpenFlag(request.Pflags())
-	file, err := f.fs.OpenFile(request.Filepath, flag, 0o666)
+	file, err := f.fs.OpenFile(f.resolve(request.Filepath), flag, 0o666)
 	if err != nil {
 		return nil, err
 	}
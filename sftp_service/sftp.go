@@ -1,11 +1,13 @@
 package sftp_service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
+	"time"
 
 	"code.d7z.net/packages/webdav-server/common"
 	"github.com/pkg/sftp"
@@ -13,11 +15,13 @@ import (
 )
 
 type SFTPServer struct {
+	ctx    *common.FsContext
 	config *ssh.ServerConfig
 }
 
 func NewSFTPServer(ctx *common.FsContext) (*SFTPServer, error) {
 	config := &ssh.ServerConfig{
+		ServerVersion: common.SSHServerVersion(),
 		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
 			_, err := ctx.LoadFS(conn.User(), "", key, false)
 			if err != nil {
@@ -48,36 +52,55 @@ func NewSFTPServer(ctx *common.FsContext) (*SFTPServer, error) {
 		}
 		config.AddHostKey(key)
 	}
-	return &SFTPServer{config: config}, nil
+	return &SFTPServer{ctx: ctx, config: config}, nil
 }
 
-func (s *SFTPServer) Serve(ctx *common.FsContext, listener net.Listener) {
+// Serve 接受连接直到 ctx 取消（返回 nil）或监听出错
+func (s *SFTPServer) Serve(ctx context.Context, listener net.Listener) error {
 	go func() {
-		<-ctx.Context().Done()
+		<-ctx.Done()
 		_ = listener.Close()
 	}()
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
 			select {
-			case <-ctx.Context().Done():
-				return
+			case <-ctx.Done():
+				return nil
 			default:
-				slog.Error("Accept 错误", "err", err)
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				slog.Warn("Accept 错误", "err", err)
 				continue
 			}
+			return err
 		}
 		go s.handler(ctx, conn)
 	}
 }
 
-func (s *SFTPServer) handler(ctx *common.FsContext, conn net.Conn) {
+func (s *SFTPServer) handler(ctx context.Context, conn net.Conn) {
 	defer conn.Close()
 	go func() {
-		<-ctx.Context().Done()
+		<-ctx.Done()
 		_ = conn.Close()
 	}()
+	timeouts := s.ctx.Config.SFTP.Timeouts
+	if timeouts.Idle > 0 {
+		conn = &idleConn{Conn: conn, timeout: timeouts.Idle}
+	}
+	var handshakeTimer *time.Timer
+	if timeouts.Handshake > 0 {
+		// 握手超时直接断开连接
+		handshakeTimer = time.AfterFunc(timeouts.Handshake, func() {
+			_ = conn.Close()
+		})
+	}
 	sConn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if handshakeTimer != nil {
+		handshakeTimer.Stop()
+	}
 	if err != nil {
 		return
 	}
@@ -100,7 +123,7 @@ func (s *SFTPServer) handler(ctx *common.FsContext, conn net.Conn) {
 					_ = req.Reply(true, nil)
 				case "shell":
 					_ = req.Reply(true, nil)
-					_, _ = fmt.Fprintf(channel, ctx.Config.SFTP.WelcomeMessage, sConn.User())
+					_, _ = fmt.Fprintf(channel, s.ctx.Config.SFTP.WelcomeMessage, sConn.User())
 					_, _ = fmt.Fprintf(channel, "\r\nthis server only supports sftp file transfers.\r\n")
 					_, _ = channel.SendRequest("exit-status", false, []byte{0, 0, 0, 0})
 					return
@@ -108,8 +131,12 @@ func (s *SFTPServer) handler(ctx *common.FsContext, conn net.Conn) {
 					if string(req.Payload[4:]) == "sftp" {
 						_ = req.Reply(true, nil)
 						slog.Info("|sftp| Session started.", "remote", sConn.RemoteAddr().String(), "user", sConn.User())
-						userFS := ctx.LoadUserFS(sConn.User())
-						server := sftp.NewRequestServer(channel, FSHandlers(userFS))
+						userFS := s.ctx.LoadUserFS(sConn.User())
+						var sanitize func(string) (string, error)
+						if names := s.ctx.Config.Filenames; names.Enabled() {
+							sanitize = names.SanitizePath
+						}
+						server := sftp.NewRequestServer(channel, FSHandlers(userFS, sanitize))
 						if err := server.Serve(); err != nil && err != io.EOF {
 							slog.Warn("SFTP Server 错误", "err", err)
 						}
@@ -123,3 +150,19 @@ func (s *SFTPServer) handler(ctx *common.FsContext, conn net.Conn) {
 		}(requests)
 	}
 }
+
+// idleConn 在每次读写时刷新超时时间，超过 timeout 未活动则断开连接
+type idleConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	_ = c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Read(b)
+}
+
+func (c *idleConn) Write(b []byte) (int, error) {
+	_ = c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Write(b)
+}
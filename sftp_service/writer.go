@@ -0,0 +1,116 @@
+package sftp_service
+
+import (
+	"io"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// maxPendingWrite 等待前面的分块时最多缓存的字节数
+const maxPendingWrite = 16 << 20
+
+// orderedWriterAt 将 sftp 并发处理导致乱序到达的分块按偏移量顺序写入，
+// 对象存储、远程 WebDAV 等只支持顺序写入的后端依赖此顺序。
+// 缓存超过上限或关闭时将剩余分块按原偏移量写入，随机写入（如稀疏文件）不受影响
+type orderedWriterAt struct {
+	w io.WriterAt
+
+	mu       sync.Mutex
+	next     int64
+	pending  map[int64][]byte
+	buffered int
+	err      error
+}
+
+func newOrderedWriterAt(w io.WriterAt, offset int64) *orderedWriterAt {
+	return &orderedWriterAt{w: w, next: offset, pending: make(map[int64][]byte)}
+}
+
+func (o *orderedWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.err != nil {
+		return 0, o.err
+	}
+	if off > o.next {
+		// p 在返回后会被 sftp 复用，需要复制
+		o.pending[off] = slices.Clone(p)
+		o.buffered += len(p)
+		if o.buffered > maxPendingWrite {
+			if err := o.flushLocked(); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+	n, err := o.writeLocked(p, off)
+	if err != nil {
+		return n, err
+	}
+	for {
+		data, ok := o.pending[o.next]
+		if !ok {
+			return n, nil
+		}
+		delete(o.pending, o.next)
+		o.buffered -= len(data)
+		if _, err := o.writeLocked(data, o.next); err != nil {
+			return n, err
+		}
+	}
+}
+
+func (o *orderedWriterAt) writeLocked(p []byte, off int64) (int, error) {
+	n, err := o.w.WriteAt(p, off)
+	if err != nil {
+		o.err = err
+		return n, err
+	}
+	o.next = max(o.next, off+int64(n))
+	return n, nil
+}
+
+// flushLocked 按偏移量写入所有缓存的分块
+func (o *orderedWriterAt) flushLocked() error {
+	for _, off := range slices.Sorted(maps.Keys(o.pending)) {
+		data := o.pending[off]
+		delete(o.pending, off)
+		o.buffered -= len(data)
+		if _, err := o.writeLocked(data, off); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TransferError 由 sftp 在连接异常断开时调用，传递给底层文件以放弃本次写入
+func (o *orderedWriterAt) TransferError(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.err = err
+	clear(o.pending)
+	o.buffered = 0
+	if t, ok := o.w.(interface{ TransferError(error) }); ok {
+		t.TransferError(err)
+	}
+}
+
+func (o *orderedWriterAt) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	err := o.err
+	if err == nil {
+		err = o.flushLocked()
+	}
+	if t, ok := o.w.(interface{ TransferError(error) }); ok && err != nil {
+		// 有分块写入失败，放弃不完整的上传
+		t.TransferError(err)
+	}
+	if closer, ok := o.w.(io.Closer); ok {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
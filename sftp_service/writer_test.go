@@ -0,0 +1,84 @@
+package sftp_service
+
+import (
+	"bytes"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sequentialWriter 模拟只支持顺序写入的后端
+type sequentialWriter struct {
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (s *sequentialWriter) WriteAt(p []byte, off int64) (int, error) {
+	if off != int64(s.buf.Len()) {
+		return 0, errors.ErrUnsupported
+	}
+	return s.buf.Write(p)
+}
+
+func (s *sequentialWriter) Close() error {
+	s.closed = true
+	return nil
+}
+
+// sparseWriter 模拟支持随机写入的后端
+type sparseWriter struct {
+	data []byte
+}
+
+func (s *sparseWriter) WriteAt(p []byte, off int64) (int, error) {
+	if end := int(off) + len(p); end > len(s.data) {
+		s.data = append(s.data, make([]byte, end-len(s.data))...)
+	}
+	return copy(s.data[off:], p), nil
+}
+
+func TestOrderedWriterAt(t *testing.T) {
+	const chunk = 1024
+	data := make([]byte, 256*chunk)
+	for i := range data {
+		data[i] = byte(rand.IntN(256))
+	}
+	offsets := make([]int, 0, len(data)/chunk)
+	for off := 0; off < len(data); off += chunk {
+		offsets = append(offsets, off)
+	}
+	rand.Shuffle(len(offsets), func(i, j int) { offsets[i], offsets[j] = offsets[j], offsets[i] })
+
+	backend := &sequentialWriter{}
+	w := newOrderedWriterAt(backend, 0)
+	var wg sync.WaitGroup
+	work := make(chan int)
+	for range 8 {
+		wg.Go(func() {
+			for off := range work {
+				n, err := w.WriteAt(data[off:off+chunk], int64(off))
+				assert.NoError(t, err)
+				assert.Equal(t, chunk, n)
+			}
+		})
+	}
+	for _, off := range offsets {
+		work <- off
+	}
+	close(work)
+	wg.Wait()
+	assert.NoError(t, w.Close())
+	assert.True(t, backend.closed)
+	assert.Equal(t, data, backend.buf.Bytes(), "乱序到达的分块应按顺序写入")
+
+	sparse := &sparseWriter{}
+	w = newOrderedWriterAt(sparse, 0)
+	_, _ = w.WriteAt([]byte("tail"), 100)
+	assert.Empty(t, sparse.data, "前面的分块到达前缓存")
+	assert.NoError(t, w.Close())
+	assert.Len(t, sparse.data, 104, "关闭时写入无法衔接的分块")
+	assert.Equal(t, "tail", string(sparse.data[100:]))
+}
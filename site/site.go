@@ -0,0 +1,163 @@
+package site
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/go-chi/chi/v5"
+	"github.com/spf13/afero"
+)
+
+// sandboxPolicy 以路径前缀发布的网站与本站同源，页面在沙箱中打开，脚本不能以登录用户的身份访问本站
+const sandboxPolicy = "sandbox allow-scripts allow-forms allow-popups allow-downloads"
+
+// site 将存储池的内容作为静态网站提供
+type site struct {
+	ctx  *common.FsContext
+	pool string
+	cfg  common.ConfigPoolSite
+	fs   afero.Fs
+	// prefix 网站的路径前缀，以域名访问时为空
+	prefix string
+}
+
+func newSite(ctx *common.FsContext, poolName, prefix string) (*site, bool) {
+	fs, ok := ctx.SiteFS(poolName)
+	if !ok {
+		return nil, false
+	}
+	return &site{ctx: ctx, pool: poolName, cfg: ctx.Config.Pools[poolName].Site, fs: fs, prefix: prefix}, true
+}
+
+// WithSites 挂载以路径前缀访问的存储池网站
+func WithSites(ctx *common.FsContext, route chi.Router) {
+	for poolName, pool := range ctx.Config.Pools {
+		if !pool.Site.Enabled || pool.Site.Prefix == "" {
+			continue
+		}
+		s, ok := newSite(ctx, poolName, pool.Site.Prefix)
+		if !ok {
+			continue
+		}
+		slog.Info("site enabled", "pool", poolName, "prefix", pool.Site.Prefix)
+		route.Handle(pool.Site.Prefix, s)
+		route.Handle(pool.Site.Prefix+"/*", s)
+	}
+}
+
+// Hosts 将配置了 site.host 的域名的请求交给对应的网站，其他请求交给 next
+func Hosts(ctx *common.FsContext) func(next http.Handler) http.Handler {
+	sites := make(map[string]*site)
+	for poolName, pool := range ctx.Config.Pools {
+		if !pool.Site.Enabled || pool.Site.Host == "" {
+			continue
+		}
+		if s, ok := newSite(ctx, poolName, ""); ok {
+			slog.Info("site enabled", "pool", poolName, "host", pool.Site.Host)
+			sites[poolName] = s
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		if len(sites) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if poolName, ok := ctx.Config.SiteHost(r.Host); ok {
+				sites[poolName].ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ServeHTTP 返回请求路径对应的文件。目录返回其中的 index.html，没有扩展名的路径也尝试 <路径>.html，
+// 网站不列出目录内容
+func (s *site) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if err := s.ctx.PoolError("/" + s.pool); err != nil {
+		slog.Warn("|site| Pool unavailable.", "pool", s.pool, "err", err)
+		w.Header().Set("Retry-After", strconv.Itoa(s.ctx.RetryAfter()))
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	if s.prefix != "" {
+		w.Header().Set("Content-Security-Policy", sandboxPolicy)
+	}
+	rel := strings.TrimPrefix(r.URL.Path, s.prefix)
+	name := path.Clean("/" + rel)
+	slog.Debug("|site| Access.", "pool", s.pool, "path", name, "remote", r.RemoteAddr)
+	info, err := s.fs.Stat(name)
+	switch {
+	case err == nil && info.IsDir():
+		if !strings.HasSuffix(rel, "/") {
+			// 补全结尾的 /，页面中的相对链接才能指向目录内
+			target := r.URL.Path + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+		name = path.Join(name, "index.html")
+		info, err = s.fs.Stat(name)
+	case err != nil && name != "/" && path.Ext(name) == "":
+		name += ".html"
+		info, err = s.fs.Stat(name)
+	}
+	if err != nil || info.IsDir() {
+		s.notFound(w, r)
+		return
+	}
+	file, err := s.fs.Open(name)
+	if err != nil {
+		s.notFound(w, r)
+		return
+	}
+	defer file.Close()
+	setContentType(w, name)
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.Size(), info.ModTime().UnixNano()))
+	if s.cfg.MaxAge > 0 {
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(s.cfg.MaxAge.Seconds())))
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	// ServeContent 处理 Range 请求与 If-None-Match、If-Modified-Since 条件请求
+	http.ServeContent(w, r, name, info.ModTime(), file)
+}
+
+// notFound 返回网站的 404 页面，未配置或无法读取时返回纯文本
+func (s *site) notFound(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.NotFound != "" {
+		if file, err := s.fs.Open(s.cfg.NotFound); err == nil {
+			defer file.Close()
+			setContentType(w, s.cfg.NotFound)
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method != http.MethodHead {
+				_, _ = io.Copy(w, file)
+			}
+			return
+		}
+	}
+	http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+}
+
+// setContentType 按扩展名设置 Content-Type，mime_types 中配置的类型同样生效。
+// 未知的扩展名交给 ServeContent 按内容判断
+func setContentType(w http.ResponseWriter, name string) {
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		w.Header().Set("Content-Type", ctype)
+	}
+}
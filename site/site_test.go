@@ -0,0 +1,83 @@
+package site
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"code.d7z.net/packages/webdav-server/common"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSite(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"index.html":       "home",
+		"guide/index.html": "guide",
+		"about.html":       "about",
+		"style.css":        "body{}",
+		"404.html":         "missing",
+		".env":             "secret",
+	} {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	cfg := &common.Config{
+		Pools: map[string]common.ConfigPool{"docs": {Path: dir, Site: common.ConfigPoolSite{
+			Enabled: true, Prefix: "/docs", Host: "docs.example.com", NotFound: "/404.html", MaxAge: time.Hour,
+		}}},
+		Users: map[string]common.ConfigUser{"guest": {}},
+	}
+	ctx, err := common.NewContext(t.Context(), cfg)
+	assert.NoError(t, err)
+	route := chi.NewMux()
+	route.Use(Hosts(ctx))
+	WithSites(ctx, route)
+	route.Get("/", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("app")) })
+
+	get := func(host, target string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		request.Host = host
+		rec := httptest.NewRecorder()
+		route.ServeHTTP(rec, request)
+		return rec
+	}
+
+	rec := get("example.com", "/docs/")
+	assert.Equal(t, "home", rec.Body.String())
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "public, max-age=3600", rec.Header().Get("Cache-Control"))
+	assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "sandbox", "与本站同源的网站在沙箱中打开")
+
+	rec = get("example.com", "/docs/guide")
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/docs/guide/", rec.Header().Get("Location"))
+	assert.Equal(t, "guide", get("example.com", "/docs/guide/").Body.String())
+	assert.Equal(t, "about", get("example.com", "/docs/about").Body.String(), "没有扩展名时尝试 .html")
+	assert.Contains(t, get("example.com", "/docs/style.css").Header().Get("Content-Type"), "text/css")
+
+	rec = get("example.com", "/docs/.env")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "missing", rec.Body.String(), "使用配置的 404 页面")
+
+	etag := get("example.com", "/docs/about.html").Header().Get("ETag")
+	request := httptest.NewRequest(http.MethodGet, "/docs/about.html", nil)
+	request.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	route.ServeHTTP(rec, request)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	rec = get("docs.example.com:8080", "/")
+	assert.Equal(t, "home", rec.Body.String(), "以域名访问时整个域名都是网站")
+	assert.Empty(t, rec.Header().Get("Content-Security-Policy"))
+	assert.Equal(t, "app", get("example.com", "/").Body.String(), "其他域名不受影响")
+
+	request = httptest.NewRequest(http.MethodPost, "/docs/", nil)
+	rec = httptest.NewRecorder()
+	route.ServeHTTP(rec, request)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,187 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// State 服务状态
+type State string
+
+const (
+	StateRunning    State = "running"
+	StateRestarting State = "restarting"
+	StateStopped    State = "stopped"
+	StateFailed     State = "failed"
+)
+
+// RunFunc 运行服务，阻塞直到 ctx 取消或服务出错；ctx 取消后应完成清理再返回
+type RunFunc func(ctx context.Context) error
+
+// RestartPolicy 服务失败后的重启策略
+type RestartPolicy struct {
+	// 最大重启次数，0 表示不重启
+	MaxRetries int
+	// 两次重启之间的等待时间
+	Delay time.Duration
+}
+
+// resetAfter 服务持续运行超过该时间后视为恢复正常，清零重启次数
+const resetAfter = time.Minute
+
+// Status 服务运行状态
+type Status struct {
+	Name  string `json:"name"`
+	State State  `json:"state"`
+	// Restarts 连续失败后的重启次数，服务稳定运行 resetAfter 后清零
+	Restarts  int   `json:"restarts"`
+	LastError error `json:"-"`
+}
+
+type service struct {
+	name   string
+	run    RunFunc
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	status Status
+}
+
+func (s *service) setState(state State, err error) {
+	s.mu.Lock()
+	s.status.State = state
+	if err != nil {
+		s.status.LastError = err
+	}
+	s.mu.Unlock()
+	if err != nil {
+		slog.Warn("|supervisor| Service state changed.", "service", s.name, "state", state, "err", err)
+	} else {
+		slog.Info("|supervisor| Service state changed.", "service", s.name, "state", state)
+	}
+}
+
+// Supervisor 统一管理多个协议服务：共享生命周期，同时启动、按注册的逆序停止，失败时按策略重启
+type Supervisor struct {
+	policy          RestartPolicy
+	shutdownTimeout time.Duration
+	resetAfter      time.Duration
+	services        []*service
+}
+
+// New 创建 Supervisor，shutdownTimeout 为单个服务的最长停止等待时间
+func New(policy RestartPolicy, shutdownTimeout time.Duration) *Supervisor {
+	return &Supervisor{
+		policy:          policy,
+		shutdownTimeout: shutdownTimeout,
+		resetAfter:      resetAfter,
+	}
+}
+
+// Add 注册服务，必须在 Run 之前调用
+func (s *Supervisor) Add(name string, run RunFunc) {
+	s.services = append(s.services, &service{
+		name:   name,
+		run:    run,
+		status: Status{Name: name, State: StateStopped},
+	})
+}
+
+// Statuses 返回所有服务的当前状态
+func (s *Supervisor) Statuses() []Status {
+	result := make([]Status, 0, len(s.services))
+	for _, svc := range s.services {
+		svc.mu.Lock()
+		result = append(result, svc.status)
+		svc.mu.Unlock()
+	}
+	return result
+}
+
+// Run 运行所有服务，直到 ctx 取消或某个服务彻底失败；返回首个失败服务的错误。
+// 停止超时的服务不再等待，Run 照常返回
+func (s *Supervisor) Run(ctx context.Context) error {
+	failed := make(chan error, len(s.services))
+	for _, svc := range s.services {
+		// 服务使用独立的 context，由下方的停止流程按顺序取消
+		svcCtx, cancel := context.WithCancel(context.Background())
+		svc.cancel = cancel
+		svc.done = make(chan struct{})
+		go func() {
+			defer close(svc.done)
+			defer cancel()
+			if err := s.supervise(svcCtx, svc); err != nil {
+				failed <- err
+			}
+		}()
+	}
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-failed:
+	}
+	s.shutdown()
+	return err
+}
+
+// shutdown 逆序停止服务，每个服务最多等待 shutdownTimeout
+func (s *Supervisor) shutdown() {
+	for i := len(s.services) - 1; i >= 0; i-- {
+		svc := s.services[i]
+		svc.cancel()
+		select {
+		case <-svc.done:
+		case <-time.After(s.shutdownTimeout):
+			slog.Error("|supervisor| Service stop timeout.", "service", svc.name)
+		}
+	}
+}
+
+func (s *Supervisor) supervise(ctx context.Context, svc *service) error {
+	for {
+		started := time.Now()
+		err := s.runOnce(ctx, svc)
+		if ctx.Err() != nil {
+			svc.setState(StateStopped, nil)
+			return nil
+		}
+		if err == nil {
+			err = errors.New("service exited unexpectedly")
+		}
+		svc.mu.Lock()
+		if time.Since(started) >= s.resetAfter {
+			svc.status.Restarts = 0
+		}
+		restarts := svc.status.Restarts
+		svc.mu.Unlock()
+		if restarts >= s.policy.MaxRetries {
+			svc.setState(StateFailed, err)
+			return fmt.Errorf("service %s: %w", svc.name, err)
+		}
+		svc.setState(StateRestarting, err)
+		select {
+		case <-ctx.Done():
+			svc.setState(StateStopped, nil)
+			return nil
+		case <-time.After(s.policy.Delay):
+		}
+		svc.mu.Lock()
+		svc.status.Restarts++
+		svc.mu.Unlock()
+	}
+}
+
+func (s *Supervisor) runOnce(ctx context.Context, svc *service) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	svc.setState(StateRunning, nil)
+	return svc.run(ctx)
+}
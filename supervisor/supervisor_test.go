@@ -0,0 +1,88 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupervisor_ShutdownOrder(t *testing.T) {
+	var mu sync.Mutex
+	var stopped []string
+	s := New(RestartPolicy{}, time.Second)
+	for _, name := range []string{"a", "b", "c"} {
+		s.Add(name, func(ctx context.Context) error {
+			<-ctx.Done()
+			mu.Lock()
+			stopped = append(stopped, name)
+			mu.Unlock()
+			return nil
+		})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	assert.NoError(t, s.Run(ctx))
+	assert.Equal(t, []string{"c", "b", "a"}, stopped, "服务应逆序停止")
+	for _, status := range s.Statuses() {
+		assert.Equal(t, StateStopped, status.State)
+	}
+}
+
+func TestSupervisor_Restart(t *testing.T) {
+	runs := 0
+	s := New(RestartPolicy{MaxRetries: 2, Delay: time.Millisecond}, time.Second)
+	s.Add("flaky", func(ctx context.Context) error {
+		runs++
+		return errors.New("boom")
+	})
+	stopped := false
+	s.Add("other", func(ctx context.Context) error {
+		<-ctx.Done()
+		stopped = true
+		return nil
+	})
+	err := s.Run(context.Background())
+	assert.Error(t, err, "超过重启次数后应返回错误")
+	assert.Equal(t, 3, runs, "应重启两次")
+	assert.True(t, stopped, "其他服务应被停止")
+	status := s.Statuses()[0]
+	assert.Equal(t, StateFailed, status.State)
+	assert.Equal(t, 2, status.Restarts)
+}
+
+func TestSupervisor_ShutdownTimeout(t *testing.T) {
+	s := New(RestartPolicy{}, 50*time.Millisecond)
+	s.Add("stuck", func(ctx context.Context) error {
+		select {}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("停止超时的服务不应阻塞 Run")
+	}
+}
+
+func TestSupervisor_RestartReset(t *testing.T) {
+	runs := 0
+	s := New(RestartPolicy{MaxRetries: 1, Delay: time.Millisecond}, time.Second)
+	s.resetAfter = 20 * time.Millisecond
+	s.Add("flaky", func(ctx context.Context) error {
+		runs++
+		if runs <= 3 {
+			// 稳定运行一段时间后才失败，重启次数清零
+			time.Sleep(30 * time.Millisecond)
+		}
+		return errors.New("boom")
+	})
+	assert.Error(t, s.Run(context.Background()))
+	assert.Equal(t, 4, runs, "稳定运行后失败不计入连续重启次数，只有最后一次失败超出重启次数")
+}